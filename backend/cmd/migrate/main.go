@@ -69,22 +69,32 @@ func migrateSessions(db *service.Database, sessionFile string) error {
 
 	count := 0
 	for _, sess := range sessionData.Sessions {
-		// 将 map 转为 slice
-		messages := make([]service.Message, len(sess.Messages))
-		copy(messages, sess.Messages)
-
 		session := &service.Session{
 			ID:        sess.ID,
-			Messages:  messages,
 			CreatedAt: sess.CreatedAt,
 			UpdatedAt: sess.UpdatedAt,
 		}
 
 		if err := db.SaveSession(session); err != nil {
 			fmt.Printf("  警告: 迁移会话 %s 失败: %v\n", sess.ID, err)
-		} else {
-			count++
+			continue
+		}
+
+		// 消息逐条写入 messages 表（旧数据没有逐条时间，统一记为会话更新时间）
+		for _, m := range sess.Messages {
+			content, _ := m.Content.(string)
+			msg := &service.SessionMessage{
+				SessionID: sess.ID,
+				Role:      m.Role,
+				Content:   content,
+				CreatedAt: sess.UpdatedAt,
+			}
+			if err := db.AppendMessage(msg); err != nil {
+				fmt.Printf("  警告: 迁移会话 %s 的消息失败: %v\n", sess.ID, err)
+				break
+			}
 		}
+		count++
 	}
 
 	fmt.Printf("  迁移了 %d 个会话\n", count)
@@ -8,6 +8,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"time"
+	"voice-memory/internal/audio"
 
 	"github.com/gorilla/websocket"
 )
@@ -44,9 +45,10 @@ func main() {
 	}
 	log.Printf("📂 加载音频文件: %s (大小: %d bytes)", finalPath, len(fileData))
 
-	// 跳过 WAV 头 (44 bytes)
-	if len(fileData) > 44 {
-		fileData = fileData[44:]
+	// 解析 WAV 并转换为服务端要求的 16kHz 单声道 PCM
+	fileData, err = audio.Normalize(fileData, audio.PCM16kMono)
+	if err != nil {
+		log.Fatal("❌ 音频格式无效:", err)
 	}
 
 	// 2. 连接 WebSocket
@@ -0,0 +1,250 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+// buildWAV 构造 WAV 文件，extra 中的 chunk 插入在 fmt 与 data 之间
+func buildWAV(tag uint16, channels, rate, bits int, pcm []byte, extra ...[]byte) []byte {
+	fmtChunk := make([]byte, 24)
+	copy(fmtChunk[0:4], "fmt ")
+	binary.LittleEndian.PutUint32(fmtChunk[4:8], 16)
+	binary.LittleEndian.PutUint16(fmtChunk[8:10], tag)
+	binary.LittleEndian.PutUint16(fmtChunk[10:12], uint16(channels))
+	binary.LittleEndian.PutUint32(fmtChunk[12:16], uint32(rate))
+	binary.LittleEndian.PutUint32(fmtChunk[16:20], uint32(rate*channels*bits/8))
+	binary.LittleEndian.PutUint16(fmtChunk[20:22], uint16(channels*bits/8))
+	binary.LittleEndian.PutUint16(fmtChunk[22:24], uint16(bits))
+
+	body := []byte("WAVE")
+	body = append(body, fmtChunk...)
+	for _, e := range extra {
+		body = append(body, e...)
+	}
+	dataHeader := make([]byte, 8)
+	copy(dataHeader[0:4], "data")
+	binary.LittleEndian.PutUint32(dataHeader[4:8], uint32(len(pcm)))
+	body = append(body, dataHeader...)
+	body = append(body, pcm...)
+
+	out := make([]byte, 8)
+	copy(out[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(body)))
+	return append(out, body...)
+}
+
+// chunk 构造任意 chunk（奇数长度自动补齐）
+func chunk(id string, payload []byte) []byte {
+	out := make([]byte, 8)
+	copy(out[0:4], id)
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(payload)))
+	out = append(out, payload...)
+	if len(payload)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+func TestParseWAV_SampleFormats(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  uint16
+		bits int
+		pcm  []byte
+		want float32
+	}{
+		{"8bit 无符号", wavFormatPCM, 8, []byte{192}, 0.5},
+		{"16bit", wavFormatPCM, 16, []byte{0x00, 0x40}, 0.5},
+		{"24bit", wavFormatPCM, 24, []byte{0x00, 0x00, 0xC0}, -0.5},
+		{"32bit 整数", wavFormatPCM, 32, []byte{0x00, 0x00, 0x00, 0x40}, 0.5},
+		{"32bit 浮点", wavFormatIEEEFloat, 32, float32Bytes(0.25), 0.25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clip, err := ParseWAV(buildWAV(tt.tag, 1, 16000, tt.bits, tt.pcm))
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if len(clip.Samples) != 1 || math.Abs(float64(clip.Samples[0]-tt.want)) > 1e-3 {
+				t.Errorf("采样值错误: 期望 %v, 实际 %v", tt.want, clip.Samples)
+			}
+		})
+	}
+}
+
+func TestParseWAV_SkipsExtraChunks(t *testing.T) {
+	pcm := []byte{0x00, 0x40, 0x00, 0xC0}
+	data := buildWAV(wavFormatPCM, 1, 44100, 16, pcm,
+		chunk("LIST", []byte("INFOISFT\x05\x00\x00\x00Lavf\x00")),
+		chunk("fact", []byte{2, 0, 0, 0}),
+	)
+
+	clip, err := ParseWAV(data)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if clip.Format.SampleRate != 44100 || len(clip.Samples) != 2 {
+		t.Errorf("应跳过 LIST/fact 找到 data chunk: %+v", clip)
+	}
+}
+
+func TestParseWAV_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"空数据", nil, ErrEmpty},
+		{"不是 WAV", []byte("ID3\x03 this is an mp3"), ErrNotWAV},
+		{"缺少 data", buildWAV(wavFormatPCM, 1, 16000, 16, nil)[:36], ErrMalformed},
+		{"不支持的编码", buildWAV(0x0055, 1, 16000, 16, []byte{0, 0}), ErrUnsupported},
+		{"不支持的位深", buildWAV(wavFormatPCM, 1, 16000, 12, []byte{0, 0}), ErrUnsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseWAV(tt.data)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("期望错误 %v, 实际 %v", tt.want, err)
+			}
+			if !IsFormatError(err) {
+				t.Errorf("应返回 *FormatError: %T", err)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	t.Run("48kHz 立体声转 16kHz 单声道", func(t *testing.T) {
+		// 1 秒 48kHz 立体声，左声道 0.5，右声道 0
+		frames := 48000
+		pcm := make([]byte, frames*4)
+		for i := 0; i < frames; i++ {
+			binary.LittleEndian.PutUint16(pcm[i*4:], uint16(16384))
+		}
+
+		out, err := Normalize(buildWAV(wavFormatPCM, 2, 48000, 16, pcm), PCM16kMono)
+		if err != nil {
+			t.Fatalf("转换失败: %v", err)
+		}
+		if len(out) != 16000*2 {
+			t.Errorf("期望 1 秒 16kHz 数据 (32000 字节), 实际 %d", len(out))
+		}
+		// 下混后幅度应为 0.25
+		mid := int16(binary.LittleEndian.Uint16(out[16000:]))
+		if math.Abs(float64(mid)/32767-0.25) > 0.01 {
+			t.Errorf("下混幅度错误: %d", mid)
+		}
+	})
+
+	t.Run("44.1kHz 正弦波保持频率", func(t *testing.T) {
+		frames := 44100
+		pcm := make([]byte, frames*2)
+		for i := 0; i < frames; i++ {
+			v := 0.5 * math.Sin(2*math.Pi*440*float64(i)/44100)
+			binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(v*32767)))
+		}
+
+		out, err := Normalize(buildWAV(wavFormatPCM, 1, 44100, 16, pcm), PCM16kMono)
+		if err != nil {
+			t.Fatalf("转换失败: %v", err)
+		}
+		// 440Hz 一秒约 880 次过零
+		crossings := 0
+		prev := int16(0)
+		for i := 0; i < len(out)/2; i++ {
+			s := int16(binary.LittleEndian.Uint16(out[i*2:]))
+			if i > 0 && (s >= 0) != (prev >= 0) {
+				crossings++
+			}
+			prev = s
+		}
+		if crossings < 860 || crossings > 900 {
+			t.Errorf("重采样后频率异常, 过零次数 %d", crossings)
+		}
+	})
+
+	t.Run("裸 PCM 原样返回", func(t *testing.T) {
+		pcm := []byte{1, 2, 3, 4, 5}
+		out, err := Normalize(pcm, PCM16kMono)
+		if err != nil {
+			t.Fatalf("意外错误: %v", err)
+		}
+		if len(out) != 4 {
+			t.Errorf("应丢弃不完整的采样: %v", out)
+		}
+	})
+
+	t.Run("空数据", func(t *testing.T) {
+		if _, err := Normalize(nil, PCM16kMono); !errors.Is(err, ErrEmpty) {
+			t.Errorf("期望 ErrEmpty, 实际 %v", err)
+		}
+	})
+}
+
+func float32Bytes(v float32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, math.Float32bits(v))
+	return b
+}
+
+func TestStreamConverter(t *testing.T) {
+	t.Run("分片转换与整段转换一致", func(t *testing.T) {
+		frames := 44100
+		pcm := make([]byte, frames*2)
+		for i := 0; i < frames; i++ {
+			v := 0.5 * math.Sin(2*math.Pi*440*float64(i)/44100)
+			binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(v*32767)))
+		}
+		raw := Format{SampleRate: 44100, Channels: 1, BitsPerSample: 16}
+		whole, err := Normalize(pcm, raw)
+		if err != nil {
+			t.Fatalf("转换失败: %v", err)
+		}
+
+		c, err := NewStreamConverter(raw)
+		if err != nil {
+			t.Fatalf("创建转换器失败: %v", err)
+		}
+		var streamed []byte
+		// 奇数长度的分片会把采样切成两半
+		for i := 0; i < len(pcm); i += 4095 {
+			end := i + 4095
+			if end > len(pcm) {
+				end = len(pcm)
+			}
+			streamed = append(streamed, c.Write(pcm[i:end])...)
+		}
+
+		if diff := len(whole) - len(streamed); diff < 0 || diff > 4 {
+			t.Fatalf("长度不一致: 整段 %d, 分片 %d", len(whole), len(streamed))
+		}
+		for i := 0; i+1 < len(streamed); i += 2 {
+			a := int16(binary.LittleEndian.Uint16(whole[i:]))
+			b := int16(binary.LittleEndian.Uint16(streamed[i:]))
+			if d := int(a) - int(b); d > 2 || d < -2 {
+				t.Fatalf("第 %d 个采样不一致: %d vs %d", i/2, a, b)
+			}
+		}
+	})
+
+	t.Run("16kHz 单声道原样返回", func(t *testing.T) {
+		c, _ := NewStreamConverter(PCM16kMono)
+		if out := c.Write([]byte{1, 2, 3}); len(out) != 2 {
+			t.Errorf("应保留不完整的采样到下一片: %v", out)
+		}
+		if out := c.Write([]byte{4, 5, 6}); len(out) != 4 || out[0] != 3 {
+			t.Errorf("应拼接上一片剩下的字节: %v", out)
+		}
+	})
+
+	t.Run("无效格式", func(t *testing.T) {
+		if _, err := NewStreamConverter(Format{SampleRate: 0, Channels: 1, BitsPerSample: 16}); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("期望 ErrUnsupported, 实际 %v", err)
+		}
+	})
+}
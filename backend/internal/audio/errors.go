@@ -0,0 +1,38 @@
+package audio
+
+import "errors"
+
+// 输入音频不合法的错误类别，可用 errors.Is 判断
+var (
+	ErrEmpty       = errors.New("音频数据为空")
+	ErrNotWAV      = errors.New("不是 RIFF/WAVE 格式")
+	ErrMalformed   = errors.New("WAV 文件结构损坏")
+	ErrUnsupported = errors.New("不支持的音频编码")
+)
+
+// FormatError 输入音频不合法（客户端错误，HTTP 接口应返回 400）
+type FormatError struct {
+	Err    error  // 错误类别：ErrEmpty / ErrNotWAV / ErrMalformed / ErrUnsupported
+	Detail string // 具体原因
+}
+
+func (e *FormatError) Error() string {
+	if e.Detail == "" {
+		return e.Err.Error()
+	}
+	return e.Err.Error() + ": " + e.Detail
+}
+
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
+
+// IsFormatError 判断 err 是否由不合法的输入音频引起
+func IsFormatError(err error) bool {
+	var fe *FormatError
+	return errors.As(err, &fe)
+}
+
+func formatError(kind error, detail string) error {
+	return &FormatError{Err: kind, Detail: detail}
+}
@@ -0,0 +1,58 @@
+package audio
+
+// TargetSampleRate 语音识别服务统一使用的采样率
+const TargetSampleRate = 16000
+
+// Normalize 把输入音频转换为 16kHz 单声道 16bit PCM（不含文件头）
+// RIFF/WAVE 数据按文件头解析；其他数据视为裸 PCM，由 raw 描述其格式
+// （WebSocket 上传的分片通常就是 PCM16kMono）。
+func Normalize(data []byte, raw Format) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, formatError(ErrEmpty, "")
+	}
+
+	var clip *Clip
+	if IsWAV(data) {
+		parsed, err := ParseWAV(data)
+		if err != nil {
+			return nil, err
+		}
+		clip = parsed
+	} else {
+		// 已是目标格式时直接返回，避免无谓的编解码
+		if raw == PCM16kMono {
+			if len(data)%2 != 0 {
+				data = data[:len(data)-1]
+			}
+			if len(data) == 0 {
+				return nil, formatError(ErrEmpty, "没有完整的采样帧")
+			}
+			return data, nil
+		}
+		samples, err := decodeSamples(data, raw)
+		if err != nil {
+			return nil, err
+		}
+		clip = &Clip{Format: raw, Samples: samples}
+	}
+
+	mono := DownmixToMono(clip.Samples, clip.Format.Channels)
+	return EncodePCM16(Resample(mono, clip.Format.SampleRate, TargetSampleRate)), nil
+}
+
+// DownmixToMono 把交错排列的多声道采样平均为单声道
+func DownmixToMono(samples []float32, channels int) []float32 {
+	if channels <= 1 {
+		return samples
+	}
+
+	mono := make([]float32, len(samples)/channels)
+	for i := range mono {
+		var sum float32
+		for c := 0; c < channels; c++ {
+			sum += samples[i*channels+c]
+		}
+		mono[i] = sum / float32(channels)
+	}
+	return mono
+}
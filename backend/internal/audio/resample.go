@@ -0,0 +1,56 @@
+package audio
+
+// Resample 把单声道采样从 from Hz 转换为 to Hz
+// 使用线性插值；降采样时先做盒式低通滤波，抑制 44.1/48kHz → 16kHz 时的混叠。
+// 对语音识别而言足够，不追求 Hi-Fi 音质。
+func Resample(samples []float32, from, to int) []float32 {
+	if from == to || from <= 0 || to <= 0 || len(samples) == 0 {
+		return samples
+	}
+
+	src := samples
+	if from > to {
+		src = boxFilter(samples, (from+to-1)/to)
+	}
+
+	ratio := float64(from) / float64(to)
+	n := int(float64(len(src)) / ratio)
+	if n == 0 {
+		n = 1
+	}
+
+	out := make([]float32, n)
+	for i := range out {
+		pos := float64(i) * ratio
+		idx := int(pos)
+		if idx >= len(src)-1 {
+			out[i] = src[len(src)-1]
+			continue
+		}
+		frac := float32(pos - float64(idx))
+		out[i] = src[idx]*(1-frac) + src[idx+1]*frac
+	}
+	return out
+}
+
+// boxFilter 宽度为 width 的滑动平均
+func boxFilter(samples []float32, width int) []float32 {
+	if width <= 1 {
+		return samples
+	}
+
+	out := make([]float32, len(samples))
+	var sum float32
+	for i, s := range samples {
+		sum += s
+		if i >= width {
+			sum -= samples[i-width]
+		}
+		count := width
+		if i+1 < width {
+			count = i + 1
+		}
+		out[i] = sum / float32(count)
+	}
+	return out
+}
@@ -0,0 +1,96 @@
+package audio
+
+// StreamConverter 把分片到达的裸 PCM 逐片转换为 16kHz 单声道 16bit PCM
+// 与 Normalize 的算法一致（下混 + 盒式低通 + 线性插值），但在分片之间保留
+// 不完整的采样帧、滤波窗口和插值位置，分片边界不会产生爆音或累积误差。
+type StreamConverter struct {
+	format  Format
+	partial []byte // 上一片末尾不完整的采样帧
+
+	// 盒式滤波窗口（环形缓冲）
+	window []float32
+	next   int
+	filled int
+	sum    float32
+
+	pending []float32 // 已滤波、尚未被插值用完的采样
+	pos     float64   // 下一个输出采样在 pending 中的位置
+	ratio   float64
+}
+
+// NewStreamConverter 创建流式转换器，raw 描述输入分片的格式
+func NewStreamConverter(raw Format) (*StreamConverter, error) {
+	if err := raw.validate(); err != nil {
+		return nil, err
+	}
+	c := &StreamConverter{format: raw, ratio: float64(raw.SampleRate) / TargetSampleRate}
+	if raw.SampleRate > TargetSampleRate {
+		c.window = make([]float32, (raw.SampleRate+TargetSampleRate-1)/TargetSampleRate)
+	}
+	return c, nil
+}
+
+// Write 转换一个分片，返回可以立即送去识别的 16kHz PCM（可能为空）
+func (c *StreamConverter) Write(data []byte) []byte {
+	buf := data
+	if len(c.partial) > 0 {
+		buf = append(c.partial, data...)
+	}
+	whole := len(buf) / c.format.bytesPerFrame() * c.format.bytesPerFrame()
+	c.partial = append([]byte(nil), buf[whole:]...)
+	if whole == 0 {
+		return nil
+	}
+	// 已是目标格式时只需按采样对齐，避免无谓的编解码
+	if c.format == PCM16kMono {
+		return buf[:whole]
+	}
+
+	samples, err := decodeSamples(buf[:whole], c.format)
+	if err != nil {
+		return nil
+	}
+	mono := DownmixToMono(samples, c.format.Channels)
+	if c.format.SampleRate == TargetSampleRate {
+		return EncodePCM16(mono)
+	}
+	return EncodePCM16(c.resample(mono))
+}
+
+// resample 与 Resample 相同的滤波和插值，状态跨分片延续
+func (c *StreamConverter) resample(samples []float32) []float32 {
+	for _, s := range samples {
+		if c.window != nil {
+			if c.filled == len(c.window) {
+				c.sum -= c.window[c.next]
+			} else {
+				c.filled++
+			}
+			c.window[c.next] = s
+			c.next = (c.next + 1) % len(c.window)
+			c.sum += s
+			s = c.sum / float32(c.filled)
+		}
+		c.pending = append(c.pending, s)
+	}
+
+	// 插值需要 idx 和 idx+1 两个采样，最后一个采样留到下一片
+	var out []float32
+	for {
+		idx := int(c.pos)
+		if idx+1 >= len(c.pending) {
+			break
+		}
+		frac := float32(c.pos - float64(idx))
+		out = append(out, c.pending[idx]*(1-frac)+c.pending[idx+1]*frac)
+		c.pos += c.ratio
+	}
+
+	used := int(c.pos)
+	if used > len(c.pending) {
+		used = len(c.pending)
+	}
+	c.pending = append(c.pending[:0], c.pending[used:]...)
+	c.pos -= float64(used)
+	return out
+}
@@ -0,0 +1,235 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// WAV 编码类型（fmt chunk 中的 wFormatTag）
+const (
+	wavFormatPCM        = 0x0001
+	wavFormatIEEEFloat  = 0x0003
+	wavFormatExtensible = 0xFFFE
+)
+
+// Format 描述 PCM 采样格式
+type Format struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	Float         bool // IEEE 浮点采样（32/64 bit）
+}
+
+// PCM16kMono 语音识别服务要求的格式：16kHz、单声道、16bit 有符号整数
+var PCM16kMono = Format{SampleRate: TargetSampleRate, Channels: 1, BitsPerSample: 16}
+
+// validate 检查格式是否受支持
+func (f Format) validate() error {
+	if f.SampleRate <= 0 {
+		return formatError(ErrUnsupported, fmt.Sprintf("采样率 %d", f.SampleRate))
+	}
+	if f.Channels <= 0 {
+		return formatError(ErrUnsupported, fmt.Sprintf("声道数 %d", f.Channels))
+	}
+	if f.Float {
+		if f.BitsPerSample != 32 && f.BitsPerSample != 64 {
+			return formatError(ErrUnsupported, fmt.Sprintf("%d bit 浮点", f.BitsPerSample))
+		}
+		return nil
+	}
+	switch f.BitsPerSample {
+	case 8, 16, 24, 32:
+		return nil
+	}
+	return formatError(ErrUnsupported, fmt.Sprintf("%d bit 整数", f.BitsPerSample))
+}
+
+// bytesPerFrame 一个采样帧（所有声道各一个采样）的字节数
+func (f Format) bytesPerFrame() int {
+	return f.BitsPerSample / 8 * f.Channels
+}
+
+// Clip 解码后的音频：交错排列的多声道浮点采样，取值范围 [-1, 1]
+type Clip struct {
+	Format  Format
+	Samples []float32
+}
+
+// IsWAV 判断数据是否以 RIFF/WAVE 头开始
+func IsWAV(data []byte) bool {
+	return len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE"
+}
+
+// ParseWAV 解析 RIFF/WAVE 文件
+// 按 chunk 遍历而不是假设 44 字节文件头：LIST、fact 等附加 chunk 会被跳过，
+// 支持 8/16/24/32 bit 整数、32/64 bit 浮点以及 WAVE_FORMAT_EXTENSIBLE。
+func ParseWAV(data []byte) (*Clip, error) {
+	if len(data) == 0 {
+		return nil, formatError(ErrEmpty, "")
+	}
+	if !IsWAV(data) {
+		return nil, formatError(ErrNotWAV, "缺少 RIFF/WAVE 标识")
+	}
+
+	var (
+		format   Format
+		haveFmt  bool
+		pcmBytes []byte
+		haveData bool
+	)
+
+	offset := 12
+	for offset+8 <= len(data) {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		body := offset + 8
+
+		// 流式录音工具可能把 data 长度写成 0 或 0xFFFFFFFF，以实际剩余长度为准
+		if size < 0 || body+size > len(data) {
+			if id != "data" {
+				return nil, formatError(ErrMalformed, fmt.Sprintf("chunk %q 长度 %d 超出文件范围", id, size))
+			}
+			size = len(data) - body
+		}
+
+		switch id {
+		case "fmt ":
+			f, err := parseFmtChunk(data[body : body+size])
+			if err != nil {
+				return nil, err
+			}
+			format = f
+			haveFmt = true
+		case "data":
+			if size == 0 && body < len(data) {
+				size = len(data) - body
+			}
+			pcmBytes = data[body : body+size]
+			haveData = true
+		}
+
+		// chunk 按 2 字节对齐
+		offset = body + size + size%2
+		if haveFmt && haveData {
+			break
+		}
+	}
+
+	if !haveFmt {
+		return nil, formatError(ErrMalformed, "缺少 fmt chunk")
+	}
+	if !haveData {
+		return nil, formatError(ErrMalformed, "缺少 data chunk")
+	}
+
+	samples, err := decodeSamples(pcmBytes, format)
+	if err != nil {
+		return nil, err
+	}
+	return &Clip{Format: format, Samples: samples}, nil
+}
+
+// parseFmtChunk 解析 fmt chunk
+func parseFmtChunk(b []byte) (Format, error) {
+	if len(b) < 16 {
+		return Format{}, formatError(ErrMalformed, fmt.Sprintf("fmt chunk 过短 (%d 字节)", len(b)))
+	}
+
+	tag := binary.LittleEndian.Uint16(b[0:2])
+	f := Format{
+		Channels:      int(binary.LittleEndian.Uint16(b[2:4])),
+		SampleRate:    int(binary.LittleEndian.Uint32(b[4:8])),
+		BitsPerSample: int(binary.LittleEndian.Uint16(b[14:16])),
+	}
+
+	// WAVE_FORMAT_EXTENSIBLE：真实编码在 SubFormat GUID 的前两个字节
+	if tag == wavFormatExtensible {
+		if len(b) < 26 {
+			return Format{}, formatError(ErrMalformed, "WAVE_FORMAT_EXTENSIBLE 缺少 SubFormat")
+		}
+		tag = binary.LittleEndian.Uint16(b[24:26])
+	}
+
+	switch tag {
+	case wavFormatPCM:
+	case wavFormatIEEEFloat:
+		f.Float = true
+	default:
+		return Format{}, formatError(ErrUnsupported, fmt.Sprintf("编码类型 0x%04X", tag))
+	}
+
+	if err := f.validate(); err != nil {
+		return Format{}, err
+	}
+	return f, nil
+}
+
+// decodeSamples 把 PCM 字节解码为 [-1, 1] 的浮点采样，末尾不完整的采样帧会被丢弃
+func decodeSamples(b []byte, f Format) ([]float32, error) {
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+
+	width := f.BitsPerSample / 8
+	frames := len(b) / f.bytesPerFrame()
+	if frames == 0 {
+		return nil, formatError(ErrEmpty, "没有完整的采样帧")
+	}
+
+	samples := make([]float32, frames*f.Channels)
+	for i := range samples {
+		s := b[i*width : (i+1)*width]
+		switch {
+		case f.Float && width == 4:
+			samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(s))
+		case f.Float && width == 8:
+			samples[i] = float32(math.Float64frombits(binary.LittleEndian.Uint64(s)))
+		case width == 1:
+			// 8 bit PCM 是无符号的，128 为零点
+			samples[i] = float32(int(s[0])-128) / 128
+		case width == 2:
+			samples[i] = float32(int16(binary.LittleEndian.Uint16(s))) / 32768
+		case width == 3:
+			v := int32(uint32(s[0])<<8|uint32(s[1])<<16|uint32(s[2])<<24) >> 8
+			samples[i] = float32(v) / 8388608
+		case width == 4:
+			samples[i] = float32(float64(int32(binary.LittleEndian.Uint32(s))) / 2147483648)
+		}
+	}
+	return samples, nil
+}
+
+// EncodePCM16 把 [-1, 1] 的浮点采样编码为 16bit little-endian PCM（超出范围的值会被削波）
+func EncodePCM16(samples []float32) []byte {
+	out := make([]byte, len(samples)*2)
+	for i, s := range samples {
+		if s > 1 {
+			s = 1
+		} else if s < -1 {
+			s = -1
+		}
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(int16(math.Round(float64(s)*32767))))
+	}
+	return out
+}
+
+// EncodeWAV 把 16bit 单声道 PCM 封装为 WAV 文件
+func EncodeWAV(pcm []byte, sampleRate int) []byte {
+	out := make([]byte, 44+len(pcm))
+	copy(out[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(out[4:8], uint32(36+len(pcm)))
+	copy(out[8:12], "WAVE")
+	copy(out[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(out[16:20], 16)
+	binary.LittleEndian.PutUint16(out[20:22], wavFormatPCM)
+	binary.LittleEndian.PutUint16(out[22:24], 1)
+	binary.LittleEndian.PutUint32(out[24:28], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(out[28:32], uint32(sampleRate*2))
+	binary.LittleEndian.PutUint16(out[32:34], 2)
+	binary.LittleEndian.PutUint16(out[34:36], 16)
+	copy(out[36:40], "data")
+	binary.LittleEndian.PutUint32(out[40:44], uint32(len(pcm)))
+	copy(out[44:], pcm)
+	return out
+}
@@ -1,6 +1,9 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+)
 
 // Config 应用配置
 type Config struct {
@@ -21,6 +24,28 @@ type Config struct {
 	// Sherpa Onnx 配置
 	SherpaSTTAddr string // e.g. localhost:6006
 	SherpaTTSAddr string // e.g. http://localhost:19000
+
+	// TTSStreaming 对话中是否启用句子级流式 TTS（默认关闭，需客户端支持带序号的音频帧）
+	TTSStreaming bool
+
+	// IntentLLM 关键词意图不确定时是否交给 LLM (glm-4-flash) 复核（默认关闭）
+	IntentLLM bool
+
+	// VAD 服务端语音活动检测配置（连续收音模式下切分语音）
+	VADEnergyThreshold float64 // 语音帧最低 RMS 能量 (0~1)
+	VADZCRThreshold    float64 // 浊音帧最高过零率
+	VADHangoverMs      int     // 静音多久判定一句话结束
+	VADMinSpeechMs     int     // 最短语音时长
+	VADMaxSpeechMs     int     // 单句最长时长
+
+	// 向量存储配置
+	VectorStore        string // simple (vectors.json 暴力搜索), hnsw (HNSW 近似索引), sqlite (与知识同库)
+	HNSWM              int    // HNSW 每层最大邻居数
+	HNSWEfConstruction int    // HNSW 建图候选集大小
+	HNSWEfSearch       int    // HNSW 查询候选集大小
+
+	// ReminderWebhookURL 提醒触发时额外 POST 到的地址（为空时只推送到 WebSocket）
+	ReminderWebhookURL string
 }
 
 // Load 从环境变量加载配置
@@ -35,6 +60,22 @@ func Load() *Config {
 		TTSProvider:   getEnv("TTS_PROVIDER", "baidu"),
 		SherpaSTTAddr: getEnv("SHERPA_STT_ADDR", "localhost:6006"),
 		SherpaTTSAddr: getEnv("SHERPA_TTS_ADDR", "http://localhost:19000"),
+
+		TTSStreaming: getEnv("TTS_STREAMING", "false") == "true",
+		IntentLLM:    getEnv("INTENT_LLM", "false") == "true",
+
+		VADEnergyThreshold: getEnvFloat("VAD_ENERGY_THRESHOLD", 0.02),
+		VADZCRThreshold:    getEnvFloat("VAD_ZCR_THRESHOLD", 0.25),
+		VADHangoverMs:      getEnvInt("VAD_HANGOVER_MS", 600),
+		VADMinSpeechMs:     getEnvInt("VAD_MIN_SPEECH_MS", 150),
+		VADMaxSpeechMs:     getEnvInt("VAD_MAX_SPEECH_MS", 15000),
+
+		VectorStore:        getEnv("VECTOR_STORE", "simple"),
+		HNSWM:              getEnvInt("HNSW_M", 16),
+		HNSWEfConstruction: getEnvInt("HNSW_EF_CONSTRUCTION", 200),
+		HNSWEfSearch:       getEnvInt("HNSW_EF_SEARCH", 64),
+
+		ReminderWebhookURL: getEnv("REMINDER_WEBHOOK_URL", ""),
 	}
 }
 
@@ -44,3 +85,21 @@ func getEnv(key, defaultVal string) string {
 	}
 	return defaultVal
 }
+
+func getEnvInt(key string, defaultVal int) int {
+	if val := os.Getenv(key); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			return n
+		}
+	}
+	return defaultVal
+}
+
+func getEnvFloat(key string, defaultVal float64) float64 {
+	if val := os.Getenv(key); val != "" {
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return f
+		}
+	}
+	return defaultVal
+}
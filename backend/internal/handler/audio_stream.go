@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"voice-memory/internal/audio"
+	"voice-memory/internal/pipeline"
+	"voice-memory/internal/service"
+)
+
+// audioStream 一次流式语音输入（audio_start 与 audio_end 之间的所有二进制帧）
+// STT 支持流式时边收边识别：部分结果以 stt_partial 推送；识别服务可能把一句话切成多个最终分段，
+// 这些分段先拼接起来，在 audio_end 后识别结束时作为一整句交给 onFinal，只启动一轮对话。
+// 否则（或连接流式 STT 失败时）缓存整段 PCM，在 audio_end 时按整句识别处理。
+// 客户端采集的采样率（浏览器通常是 44.1/48kHz）在 audio_start 中声明，每个分片先转换为 16kHz 再识别或缓存。
+type audioStream struct {
+	stream    service.STTStream
+	converter *audio.StreamConverter
+	buffer    []byte
+	cancel    context.CancelFunc
+	aborted   atomic.Bool
+}
+
+// sttFinalTimeout audio_end 之后等待识别服务返回最终结果的最长时间，超时后用已收到的分段开始对话
+const sttFinalTimeout = 5 * time.Second
+
+// openAudioStream 开始一次流式语音输入，format 是客户端上传的 PCM 格式
+func openAudioStream(parent context.Context, stt service.STTService, conn *wsWriter, format audio.Format, onFinal func(text string)) *audioStream {
+	ctx, cancel := context.WithCancel(parent)
+	s := &audioStream{cancel: cancel}
+
+	converter, err := audio.NewStreamConverter(format)
+	if err != nil {
+		log.Printf("[WS] 不支持的音频格式 %+v，按 16kHz 单声道处理: %v", format, err)
+		converter, _ = audio.NewStreamConverter(audio.PCM16kMono)
+	}
+	s.converter = converter
+
+	streamer, ok := stt.(service.StreamingSTTService)
+	if !ok {
+		return s
+	}
+
+	stream, err := streamer.NewStream(ctx)
+	if err != nil {
+		log.Printf("[WS] 打开流式识别失败，改为整句识别: %v", err)
+		return s
+	}
+	s.stream = stream
+
+	go func() {
+		defer cancel()
+		var finals []string
+		for r := range stream.Results() {
+			text := strings.TrimSpace(r.Text)
+			if text == "" {
+				continue
+			}
+			if !r.IsFinal {
+				sendJSON(conn, pipeline.EventSTTPartial, strings.Join(finals, "")+text)
+				continue
+			}
+			finals = append(finals, text)
+			sendJSON(conn, pipeline.EventSTTPartial, strings.Join(finals, ""))
+		}
+
+		// 被放弃（新的 audio_start 或连接断开）时不启动对话；等待超时则用已收到的分段
+		if s.aborted.Load() || parent.Err() != nil || len(finals) == 0 {
+			return
+		}
+		text := strings.Join(finals, "")
+		sendJSON(conn, pipeline.EventSTTFinal, text)
+		onFinal(text)
+	}()
+
+	return s
+}
+
+// Write 把一段 PCM 音频转换为 16kHz 后转发（非流式模式下先缓存）
+func (s *audioStream) Write(pcm []byte) error {
+	pcm = s.converter.Write(pcm)
+	if len(pcm) == 0 {
+		return nil
+	}
+	if s.stream == nil {
+		s.buffer = append(s.buffer, pcm...)
+		return nil
+	}
+	return s.stream.Write(pcm)
+}
+
+// Finish 结束语音输入，返回非流式模式下缓存的整段音频（流式模式返回 nil）
+// 流式模式下识别结束后，拼接好的整句通过 onFinal 异步送达
+func (s *audioStream) Finish() []byte {
+	if s.stream == nil {
+		s.cancel()
+		return s.buffer
+	}
+	if err := s.stream.Close(); err != nil {
+		log.Printf("[WS] 结束流式识别失败: %v", err)
+		s.cancel()
+		return nil
+	}
+	// 识别服务迟迟不返回最终结果时断开识别连接，避免这句话一直悬而未决
+	time.AfterFunc(sttFinalTimeout, s.cancel)
+	return nil
+}
+
+// Abort 放弃本次语音输入（新的 audio_start 或连接断开）
+func (s *audioStream) Abort() {
+	s.aborted.Store(true)
+	s.cancel()
+}
@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"voice-memory/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultEntityLimit 实体列表默认条数
+const defaultEntityLimit = 50
+
+// GraphHandler 知识图谱处理器
+type GraphHandler struct {
+	graph    *service.KnowledgeGraph
+	database *service.Database
+}
+
+// NewGraphHandler 创建知识图谱处理器
+func NewGraphHandler(graph *service.KnowledgeGraph, database *service.Database) *GraphHandler {
+	return &GraphHandler{
+		graph:    graph,
+		database: database,
+	}
+}
+
+// GraphResponse 知识图谱响应
+type GraphResponse struct {
+	Success    bool                `json:"success"`
+	Entity     *service.Entity     `json:"entity,omitempty"`
+	Entities   []service.Entity    `json:"entities,omitempty"`
+	Neighbors  []service.Neighbor  `json:"neighbors,omitempty"`
+	Graph      *service.Subgraph   `json:"graph,omitempty"`
+	Knowledges []service.Knowledge `json:"knowledges,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// AliasRequest 添加别名请求
+type AliasRequest struct {
+	Alias string `json:"alias" binding:"required"`
+}
+
+// MergeRequest 合并实体请求（把 source 合并到路径中的实体）
+type MergeRequest struct {
+	SourceID int64 `json:"source_id" binding:"required"`
+}
+
+// HandleListEntities 列出实体（?q=名称 &type=类型 &limit=条数）
+func (h *GraphHandler) HandleListEntities(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if limit <= 0 {
+		limit = defaultEntityLimit
+	}
+
+	entities, err := h.graph.ListEntities(c.Query("q"), c.Query("type"), limit)
+	if err != nil {
+		c.JSON(500, GraphResponse{
+			Success: false,
+			Error:   "获取实体失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, GraphResponse{
+		Success:  true,
+		Entities: entities,
+	})
+}
+
+// HandleGetEntity 获取实体详情
+func (h *GraphHandler) HandleGetEntity(c *gin.Context) {
+	entity, ok := h.resolveEntity(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(200, GraphResponse{
+		Success: true,
+		Entity:  entity,
+	})
+}
+
+// HandleNeighbors 列出实体的相邻实体
+func (h *GraphHandler) HandleNeighbors(c *gin.Context) {
+	entity, ok := h.resolveEntity(c)
+	if !ok {
+		return
+	}
+
+	neighbors, err := h.graph.Neighbors(entity.ID)
+	if err != nil {
+		h.fail(c, "获取相邻实体失败: ", err)
+		return
+	}
+
+	c.JSON(200, GraphResponse{
+		Success:   true,
+		Entity:    entity,
+		Neighbors: neighbors,
+	})
+}
+
+// HandleTraverse 从实体出发展开 N 跳子图（?hops=1-3，默认 2）
+func (h *GraphHandler) HandleTraverse(c *gin.Context) {
+	entity, ok := h.resolveEntity(c)
+	if !ok {
+		return
+	}
+
+	hops, err := strconv.Atoi(c.DefaultQuery("hops", strconv.Itoa(service.DefaultGraphHops)))
+	if err != nil || hops < 1 || hops > service.MaxGraphHops {
+		c.JSON(400, GraphResponse{
+			Success: false,
+			Error:   "hops 必须在 1-" + strconv.Itoa(service.MaxGraphHops) + " 之间",
+		})
+		return
+	}
+
+	graph, err := h.graph.Traverse(entity.ID, hops, service.MaxGraphNodes)
+	if err != nil {
+		h.fail(c, "展开子图失败: ", err)
+		return
+	}
+
+	c.JSON(200, GraphResponse{
+		Success: true,
+		Entity:  entity,
+		Graph:   graph,
+	})
+}
+
+// HandleMentions 列出提及实体的知识
+func (h *GraphHandler) HandleMentions(c *gin.Context) {
+	entity, ok := h.resolveEntity(c)
+	if !ok {
+		return
+	}
+
+	ids, err := h.graph.MentionedIn(entity.ID)
+	if err != nil {
+		h.fail(c, "获取相关知识失败: ", err)
+		return
+	}
+
+	knowledges := []service.Knowledge{}
+	for _, id := range ids {
+		k, err := h.database.GetKnowledge(id)
+		if err != nil {
+			h.fail(c, "获取相关知识失败: ", err)
+			return
+		}
+		if k != nil {
+			knowledges = append(knowledges, *k)
+		}
+	}
+
+	c.JSON(200, GraphResponse{
+		Success:    true,
+		Entity:     entity,
+		Knowledges: knowledges,
+	})
+}
+
+// HandleAddAlias 为实体添加别名，别名已属于其他实体时返回 409
+func (h *GraphHandler) HandleAddAlias(c *gin.Context) {
+	entity, ok := h.resolveEntity(c)
+	if !ok {
+		return
+	}
+
+	var req AliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, GraphResponse{
+			Success: false,
+			Error:   "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.graph.AddAlias(entity.ID, req.Alias); err != nil {
+		h.fail(c, "添加别名失败: ", err)
+		return
+	}
+
+	entity, _ = h.graph.GetEntity(entity.ID)
+	c.JSON(200, GraphResponse{
+		Success: true,
+		Entity:  entity,
+	})
+}
+
+// HandleMerge 把 source_id 实体合并到路径中的实体
+func (h *GraphHandler) HandleMerge(c *gin.Context) {
+	entity, ok := h.resolveEntity(c)
+	if !ok {
+		return
+	}
+
+	var req MergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, GraphResponse{
+			Success: false,
+			Error:   "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if req.SourceID == entity.ID {
+		c.JSON(400, GraphResponse{
+			Success: false,
+			Error:   "不能与自身合并",
+		})
+		return
+	}
+
+	if err := h.graph.MergeEntities(entity.ID, req.SourceID); err != nil {
+		h.fail(c, "合并实体失败: ", err)
+		return
+	}
+
+	entity, _ = h.graph.GetEntity(entity.ID)
+	c.JSON(200, GraphResponse{
+		Success: true,
+		Entity:  entity,
+	})
+}
+
+// resolveEntity 路径参数 :id 可以是实体 ID，也可以是实体名称或别名
+func (h *GraphHandler) resolveEntity(c *gin.Context) (*service.Entity, bool) {
+	param := c.Param("id")
+
+	var entity *service.Entity
+	var err error
+	if id, parseErr := strconv.ParseInt(param, 10, 64); parseErr == nil {
+		entity, err = h.graph.GetEntity(id)
+	} else {
+		entity, err = h.graph.FindEntity(param)
+	}
+	if err != nil {
+		h.fail(c, "获取实体失败: ", err)
+		return nil, false
+	}
+	return entity, true
+}
+
+// fail 按错误类型返回 404 / 409 / 500
+func (h *GraphHandler) fail(c *gin.Context, prefix string, err error) {
+	status := 500
+	switch {
+	case errors.Is(err, service.ErrEntityNotFound):
+		status = 404
+	case errors.Is(err, service.ErrAliasConflict):
+		status = 409
+	}
+	c.JSON(status, GraphResponse{
+		Success: false,
+		Error:   prefix + err.Error(),
+	})
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"voice-memory/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGraphHandler(t *testing.T) {
+	db, err := service.NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	repo := service.NewKnowledgeRepository(db, nil)
+	for _, k := range []*service.Knowledge{
+		{
+			ID:        "kb_1",
+			Title:     "Helm 部署",
+			Entities:  service.Entities{Products: []string{"Helm", "Kubernetes"}},
+			Relations: []service.Relation{{Source: "Helm", Type: "requires", Target: "Kubernetes"}},
+		},
+		{ID: "kb_2", Title: "Docker", Entities: service.Entities{Products: []string{"Docker"}}},
+	} {
+		if err := repo.Save(context.Background(), k); err != nil {
+			t.Fatalf("保存知识失败: %v", err)
+		}
+	}
+
+	graph := service.NewKnowledgeGraph(db)
+	h := NewGraphHandler(graph, db)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/graph/entities", h.HandleListEntities)
+	r.GET("/api/graph/entities/:id", h.HandleGetEntity)
+	r.GET("/api/graph/entities/:id/neighbors", h.HandleNeighbors)
+	r.GET("/api/graph/entities/:id/graph", h.HandleTraverse)
+	r.GET("/api/graph/entities/:id/knowledge", h.HandleMentions)
+	r.POST("/api/graph/entities/:id/aliases", h.HandleAddAlias)
+	r.POST("/api/graph/entities/:id/merge", h.HandleMerge)
+
+	do := func(method, path string, body interface{}) (int, GraphResponse) {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var resp GraphResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("解析响应失败: %v, body=%s", err, w.Body.String())
+		}
+		return w.Code, resp
+	}
+
+	t.Run("按名称获取实体和相邻实体", func(t *testing.T) {
+		code, resp := do("GET", "/api/graph/entities/kubernetes/neighbors", nil)
+		if code != 200 || resp.Entity == nil || resp.Entity.Name != "Kubernetes" {
+			t.Fatalf("期望 200, 实际 %d: %+v", code, resp)
+		}
+		if len(resp.Neighbors) != 1 || resp.Neighbors[0].Entity.Name != "Helm" || resp.Neighbors[0].Direction != "in" {
+			t.Errorf("相邻实体错误: %+v", resp.Neighbors)
+		}
+	})
+
+	t.Run("子图和提及的知识", func(t *testing.T) {
+		code, resp := do("GET", "/api/graph/entities/Helm/graph?hops=1", nil)
+		if code != 200 || resp.Graph == nil || len(resp.Graph.Nodes) != 2 {
+			t.Errorf("子图错误: %d %+v", code, resp.Graph)
+		}
+		if code, _ := do("GET", "/api/graph/entities/Helm/graph?hops=9", nil); code != 400 {
+			t.Errorf("hops 超限期望 400, 实际 %d", code)
+		}
+
+		code, resp = do("GET", fmt.Sprintf("/api/graph/entities/%d/knowledge", resp.Entity.ID), nil)
+		if code != 200 || len(resp.Knowledges) != 1 || resp.Knowledges[0].ID != "kb_1" {
+			t.Errorf("提及的知识错误: %d %+v", code, resp.Knowledges)
+		}
+	})
+
+	t.Run("实体不存在返回 404", func(t *testing.T) {
+		if code, _ := do("GET", "/api/graph/entities/不存在", nil); code != 404 {
+			t.Errorf("期望 404, 实际 %d", code)
+		}
+		if code, _ := do("GET", "/api/graph/entities/999/neighbors", nil); code != 404 {
+			t.Errorf("期望 404, 实际 %d", code)
+		}
+	})
+
+	t.Run("别名冲突返回 409，合并后别名可用", func(t *testing.T) {
+		code, _ := do("POST", "/api/graph/entities/Kubernetes/aliases", AliasRequest{Alias: "docker"})
+		if code != 409 {
+			t.Errorf("期望 409, 实际 %d", code)
+		}
+
+		docker, _ := graph.FindEntity("Docker")
+		code, resp := do("POST", "/api/graph/entities/Kubernetes/merge", MergeRequest{SourceID: docker.ID})
+		if code != 200 || resp.Entity == nil {
+			t.Fatalf("合并期望 200, 实际 %d: %s", code, resp.Error)
+		}
+		if len(resp.Entity.Aliases) != 1 || resp.Entity.Aliases[0] != "Docker" || resp.Entity.Mentions != 2 {
+			t.Errorf("合并结果错误: %+v", resp.Entity)
+		}
+	})
+
+	t.Run("列出实体", func(t *testing.T) {
+		code, resp := do("GET", "/api/graph/entities?q=kube&type=product", nil)
+		if code != 200 || len(resp.Entities) != 1 || resp.Entities[0].Name != "Kubernetes" {
+			t.Errorf("结果错误: %d %+v", code, resp.Entities)
+		}
+	})
+}
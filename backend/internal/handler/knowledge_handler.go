@@ -1,11 +1,13 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"time"
+	"voice-memory/internal/audio"
 	"voice-memory/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -13,11 +15,12 @@ import (
 
 // KnowledgeHandler 知识库处理器
 type KnowledgeHandler struct {
-	sttService   service.STTService
-	organizer    *service.KnowledgeOrganizer
-	database     *service.Database
-	audioDir     string
-	ragService   *service.RAGService
+	sttService service.STTService
+	organizer  *service.KnowledgeOrganizer
+	database   *service.Database
+	audioDir   string
+	repo       *service.KnowledgeRepository
+	indexer    *service.KnowledgeIndexer
 }
 
 // NewKnowledgeHandler 创建知识库处理器
@@ -32,13 +35,18 @@ func NewKnowledgeHandler(
 		organizer:  organizer,
 		database:   database,
 		audioDir:   audioDir,
-		ragService: nil,
+		repo:       service.NewKnowledgeRepository(database, nil),
 	}
 }
 
-// SetRAGService 设置 RAG 服务
-func (h *KnowledgeHandler) SetRAGService(ragService *service.RAGService) {
-	h.ragService = ragService
+// SetKnowledgeRepository 设置知识仓库（默认只写数据库，不同步向量库）
+func (h *KnowledgeHandler) SetKnowledgeRepository(repo *service.KnowledgeRepository) {
+	h.repo = repo
+}
+
+// SetIndexer 设置后台索引任务
+func (h *KnowledgeHandler) SetIndexer(indexer *service.KnowledgeIndexer) {
+	h.indexer = indexer
 }
 
 // RecordRequest 语音记录请求
@@ -128,11 +136,21 @@ func (h *KnowledgeHandler) HandleRecord(c *gin.Context) {
 			return
 		}
 
+		// 统一转换为 16kHz 单声道 PCM
+		pcm, err := audio.Normalize(audioData, audio.PCM16kMono)
+		if err != nil {
+			c.JSON(400, RecordResponse{
+				Success: false,
+				Error:   "音频格式无效: " + err.Error(),
+			})
+			return
+		}
+
 		// 调用 STT
-		results, err := h.sttService.Recognize(&service.RecognizeRequest{
-			AudioData: audioData,
-			Format:    "wav",
-			Rate:      16000,
+		results, err := h.sttService.Recognize(c.Request.Context(), &service.RecognizeRequest{
+			AudioData: pcm,
+			Format:    "pcm",
+			Rate:      audio.TargetSampleRate,
 		})
 		if err != nil {
 			c.JSON(500, RecordResponse{
@@ -168,7 +186,7 @@ func (h *KnowledgeHandler) HandleRecord(c *gin.Context) {
 		} else if session != nil && len(session.Messages) > 0 {
 			sessionForSummary = session
 			// 使用 AI 生成标题
-			title, err := h.organizer.GenerateTitleFromSession(session)
+			title, err := h.organizer.GenerateTitleFromSession(c.Request.Context(), session)
 			if err != nil {
 				fmt.Printf("AI 生成标题失败: %v\n", err)
 				// 失败时使用默认标题
@@ -197,7 +215,7 @@ func (h *KnowledgeHandler) HandleRecord(c *gin.Context) {
 			organizeText = text
 		}
 
-		organizeResult, err := h.organizer.Organize(organizeText)
+		organizeResult, err := h.organizer.Organize(c.Request.Context(), organizeText)
 		if err != nil {
 			// 整理失败不影响存储，只记录日志
 			fmt.Printf("AI 整理警告: %v\n", err)
@@ -212,8 +230,8 @@ func (h *KnowledgeHandler) HandleRecord(c *gin.Context) {
 		}
 	}
 
-	// 保存到数据库
-	if err := h.database.SaveKnowledge(knowledge); err != nil {
+	// 保存到数据库并同步向量库（向量化失败不影响主流程）
+	if err := h.repo.Save(c.Request.Context(), knowledge); err != nil {
 		c.JSON(500, RecordResponse{
 			Success: false,
 			Error:   "保存知识库失败: " + err.Error(),
@@ -221,24 +239,6 @@ func (h *KnowledgeHandler) HandleRecord(c *gin.Context) {
 		return
 	}
 
-	// 同步到 RAG 向量库
-	if h.ragService != nil && knowledge.Content != "" {
-		metadata := map[string]interface{}{
-			"category":   knowledge.Category,
-			"tags":       knowledge.Tags,
-			"summary":    knowledge.Summary,
-			"key_points": knowledge.KeyPoints,
-			"source":     knowledge.Source,
-			"created_at": knowledge.CreatedAt,
-		}
-		if err := h.ragService.AddKnowledge(knowledge.ID, knowledge.Content, metadata); err != nil {
-			// 向量化失败不影响主流程，只记录日志
-			fmt.Printf("⚠️  RAG 向量化失败: %v\n", err)
-		} else {
-			fmt.Printf("✅ 知识 %s 已添加到向量库\n", knowledge.ID)
-		}
-	}
-
 	c.JSON(200, RecordResponse{
 		Success:   true,
 		Knowledge: knowledge,
@@ -289,17 +289,26 @@ func (h *KnowledgeHandler) HandleList(c *gin.Context) {
 }
 
 // SearchRequest 搜索请求
+// 未提供 filter 时从 query 中识别过滤条件（如 "上周的工作笔记"）
 type SearchRequest struct {
-	Query string `json:"query" binding:"required"`
+	Query  string                `json:"query" binding:"required"`
+	Limit  int                   `json:"limit"`
+	Filter *service.VectorFilter `json:"filter"`
 }
 
 // SearchResponse 搜索响应
+// Hits 与 Knowledges 一一对应，包含各路检索的分数和高亮摘录
 type SearchResponse struct {
-	Success    bool                 `json:"success"`
-	Knowledges []service.Knowledge  `json:"knowledges,omitempty"`
-	Error      string               `json:"error,omitempty"`
+	Success    bool                  `json:"success"`
+	Knowledges []service.Knowledge   `json:"knowledges,omitempty"`
+	Hits       []service.SearchHit   `json:"hits,omitempty"`
+	Filter     *service.VectorFilter `json:"filter,omitempty"` // 实际使用的过滤条件
+	Error      string                `json:"error,omitempty"`
 }
 
+// defaultSearchLimit 搜索默认返回条数
+const defaultSearchLimit = 10
+
 // HandleSearch 处理搜索
 func (h *KnowledgeHandler) HandleSearch(c *gin.Context) {
 	var req SearchRequest
@@ -311,7 +320,15 @@ func (h *KnowledgeHandler) HandleSearch(c *gin.Context) {
 		return
 	}
 
-	results, err := h.database.SearchKnowledge(req.Query)
+	if req.Limit <= 0 {
+		req.Limit = defaultSearchLimit
+	}
+	filter := req.Filter
+	if filter.IsEmpty() {
+		filter = service.ParseSearchFilter(req.Query, time.Now())
+	}
+
+	hits, err := h.repo.Search(c.Request.Context(), req.Query, req.Limit, filter)
 	if err != nil {
 		c.JSON(500, SearchResponse{
 			Success: false,
@@ -320,12 +337,330 @@ func (h *KnowledgeHandler) HandleSearch(c *gin.Context) {
 		return
 	}
 
+	knowledges := make([]service.Knowledge, len(hits))
+	for i, hit := range hits {
+		knowledges[i] = hit.Knowledge
+	}
 	c.JSON(200, SearchResponse{
 		Success:    true,
-		Knowledges: results,
+		Knowledges: knowledges,
+		Hits:       hits,
+		Filter:     filter,
+	})
+}
+
+// KnowledgeResponse 单条知识响应
+type KnowledgeResponse struct {
+	Success   bool               `json:"success"`
+	Knowledge *service.Knowledge `json:"knowledge,omitempty"`
+	Error     string             `json:"error,omitempty"`
+}
+
+// UpdateKnowledgeRequest 编辑知识请求
+// PUT 整体替换可编辑字段（未提供的字段清空），PATCH 只修改提供的字段
+type UpdateKnowledgeRequest struct {
+	Title       *string   `json:"title"`
+	Content     *string   `json:"content"`
+	Summary     *string   `json:"summary"`
+	Category    *string   `json:"category"`
+	Tags        *[]string `json:"tags"`
+	Importance  *string   `json:"importance"`
+	ActionItems *[]string `json:"action_items"`
+	Version     int       `json:"version"` // 读取时的版本号（可选），与当前版本不一致返回 409
+}
+
+// HandleGet 获取单条知识
+func (h *KnowledgeHandler) HandleGet(c *gin.Context) {
+	knowledge, err := h.repo.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(500, KnowledgeResponse{
+			Success: false,
+			Error:   "获取知识失败: " + err.Error(),
+		})
+		return
+	}
+	if knowledge == nil {
+		c.JSON(404, KnowledgeResponse{
+			Success: false,
+			Error:   "知识不存在",
+		})
+		return
+	}
+
+	c.JSON(200, KnowledgeResponse{
+		Success:   true,
+		Knowledge: knowledge,
+	})
+}
+
+// HandleMessages 获取知识来源的会话消息 (GET /api/knowledge/:id/messages)
+func (h *KnowledgeHandler) HandleMessages(c *gin.Context) {
+	knowledge, err := h.repo.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(500, MessagesResponse{
+			Success: false,
+			Error:   "获取知识失败: " + err.Error(),
+		})
+		return
+	}
+	if knowledge == nil {
+		c.JSON(404, MessagesResponse{
+			Success: false,
+			Error:   "知识不存在",
+		})
+		return
+	}
+
+	messages, err := h.database.GetMessagesByIDs(knowledge.MessageIDs)
+	if err != nil {
+		c.JSON(500, MessagesResponse{
+			Success: false,
+			Error:   "获取消息失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, MessagesResponse{
+		Success:  true,
+		Messages: messages,
+		Total:    len(messages),
+	})
+}
+
+// HandleReplace 整体替换知识的可编辑字段 (PUT)
+func (h *KnowledgeHandler) HandleReplace(c *gin.Context) {
+	h.handleUpdate(c, true)
+}
+
+// HandlePatch 部分更新知识 (PATCH)
+func (h *KnowledgeHandler) HandlePatch(c *gin.Context) {
+	h.handleUpdate(c, false)
+}
+
+// handleUpdate 编辑知识并同步向量库
+func (h *KnowledgeHandler) handleUpdate(c *gin.Context, replace bool) {
+	var req UpdateKnowledgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, KnowledgeResponse{
+			Success: false,
+			Error:   "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if replace && req.Content == nil {
+		c.JSON(400, KnowledgeResponse{
+			Success: false,
+			Error:   "请求参数错误: 缺少 content",
+		})
+		return
+	}
+	if req.Content != nil && *req.Content == "" {
+		c.JSON(400, KnowledgeResponse{
+			Success: false,
+			Error:   "请求参数错误: content 不能为空",
+		})
+		return
+	}
+	if req.Importance != nil && !validImportance(*req.Importance) {
+		c.JSON(400, KnowledgeResponse{
+			Success: false,
+			Error:   "请求参数错误: importance 只能是 high/medium/low",
+		})
+		return
+	}
+
+	knowledge, err := h.repo.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(500, KnowledgeResponse{
+			Success: false,
+			Error:   "获取知识失败: " + err.Error(),
+		})
+		return
+	}
+	if knowledge == nil {
+		c.JSON(404, KnowledgeResponse{
+			Success: false,
+			Error:   "知识不存在",
+		})
+		return
+	}
+
+	if replace {
+		req.applyReplace(knowledge)
+	} else {
+		req.applyPatch(knowledge)
+	}
+
+	// 写入数据库并同步向量库（正文变化时重新向量化）
+	if err := h.repo.Update(c.Request.Context(), knowledge, req.Version); err != nil {
+		status := 500
+		switch {
+		case errors.Is(err, service.ErrKnowledgeNotFound):
+			status = 404
+		case errors.Is(err, service.ErrKnowledgeConflict):
+			status = 409
+		}
+		c.JSON(status, KnowledgeResponse{
+			Success: false,
+			Error:   "更新知识失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, KnowledgeResponse{
+		Success:   true,
+		Knowledge: knowledge,
+	})
+}
+
+// HandleDelete 删除知识（同时删除向量和录音文件）
+func (h *KnowledgeHandler) HandleDelete(c *gin.Context) {
+	id := c.Param("id")
+
+	knowledge, err := h.repo.Get(id)
+	if err == nil && knowledge == nil {
+		err = service.ErrKnowledgeNotFound
+	}
+	if err == nil {
+		err = h.repo.Delete(c.Request.Context(), id)
+	}
+	if err != nil {
+		status := 500
+		if errors.Is(err, service.ErrKnowledgeNotFound) {
+			status = 404
+		}
+		c.JSON(status, KnowledgeResponse{
+			Success: false,
+			Error:   "删除知识失败: " + err.Error(),
+		})
+		return
+	}
+
+	if knowledge.AudioURL != "" {
+		os.Remove(knowledge.AudioURL)
+	}
+
+	c.JSON(200, KnowledgeResponse{
+		Success: true,
+	})
+}
+
+// ReconcileResponse 对账响应
+type ReconcileResponse struct {
+	Success bool                     `json:"success"`
+	Report  *service.ReconcileReport `json:"report,omitempty"`
+	Error   string                   `json:"error,omitempty"`
+}
+
+// HandleReconcile 对比数据库与向量库并修复差异（?dry_run=true 只检查不修复）
+func (h *KnowledgeHandler) HandleReconcile(c *gin.Context) {
+	fix := c.Query("dry_run") != "true"
+
+	report, err := h.repo.Reconcile(c.Request.Context(), fix)
+	if err != nil {
+		c.JSON(500, ReconcileResponse{
+			Success: false,
+			Error:   "对账失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, ReconcileResponse{
+		Success: true,
+		Report:  report,
 	})
 }
 
+// IndexStatusResponse 后台索引状态响应
+type IndexStatusResponse struct {
+	Success  bool                   `json:"success"`
+	Progress *service.IndexProgress `json:"progress,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+// HandleIndexStatus 查询后台索引进度
+func (h *KnowledgeHandler) HandleIndexStatus(c *gin.Context) {
+	if h.indexer == nil {
+		c.JSON(503, IndexStatusResponse{
+			Success: false,
+			Error:   "索引服务未启用",
+		})
+		return
+	}
+
+	progress := h.indexer.Progress()
+	c.JSON(200, IndexStatusResponse{
+		Success:  true,
+		Progress: &progress,
+	})
+}
+
+// HandleIndexStart 在后台启动一次索引（已在运行时返回 409）
+func (h *KnowledgeHandler) HandleIndexStart(c *gin.Context) {
+	if h.indexer == nil {
+		c.JSON(503, IndexStatusResponse{
+			Success: false,
+			Error:   "索引服务未启用",
+		})
+		return
+	}
+
+	if !h.indexer.Start() {
+		progress := h.indexer.Progress()
+		c.JSON(409, IndexStatusResponse{
+			Success:  false,
+			Progress: &progress,
+			Error:    "索引任务正在运行",
+		})
+		return
+	}
+
+	progress := h.indexer.Progress()
+	c.JSON(202, IndexStatusResponse{
+		Success:  true,
+		Progress: &progress,
+	})
+}
+
+// applyReplace 整体替换可编辑字段
+func (r *UpdateKnowledgeRequest) applyReplace(k *service.Knowledge) {
+	k.Title = valueOr(r.Title, "")
+	k.Content = valueOr(r.Content, "")
+	k.Summary = valueOr(r.Summary, "")
+	k.Category = valueOr(r.Category, "")
+	k.Tags = valueOr(r.Tags, nil)
+	k.Importance = valueOr(r.Importance, "medium")
+	k.ActionItems = valueOr(r.ActionItems, nil)
+}
+
+// applyPatch 只修改请求中提供的字段
+func (r *UpdateKnowledgeRequest) applyPatch(k *service.Knowledge) {
+	k.Title = valueOr(r.Title, k.Title)
+	k.Content = valueOr(r.Content, k.Content)
+	k.Summary = valueOr(r.Summary, k.Summary)
+	k.Category = valueOr(r.Category, k.Category)
+	k.Tags = valueOr(r.Tags, k.Tags)
+	k.Importance = valueOr(r.Importance, k.Importance)
+	k.ActionItems = valueOr(r.ActionItems, k.ActionItems)
+}
+
+// valueOr 指针非空时取其值，否则返回默认值
+func valueOr[T any](p *T, def T) T {
+	if p == nil {
+		return def
+	}
+	return *p
+}
+
+// validImportance 重要性取值与知识整理输出保持一致
+func validImportance(importance string) bool {
+	switch importance {
+	case "high", "medium", "low":
+		return true
+	}
+	return false
+}
+
 func generateIntID() int64 {
 	return time.Now().UnixNano()
 }
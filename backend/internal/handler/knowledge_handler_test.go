@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"voice-memory/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupKnowledgeRouter 使用临时数据库创建知识库路由
+func setupKnowledgeRouter(t *testing.T) (*gin.Engine, *service.Database) {
+	db, err := service.NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	h := NewKnowledgeHandler(nil, nil, db, t.TempDir())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/knowledge/:id", h.HandleGet)
+	r.GET("/api/knowledge/:id/messages", h.HandleMessages)
+	r.PUT("/api/knowledge/:id", h.HandleReplace)
+	r.PATCH("/api/knowledge/:id", h.HandlePatch)
+	r.DELETE("/api/knowledge/:id", h.HandleDelete)
+	r.POST("/api/knowledge/search", h.HandleSearch)
+	return r, db
+}
+
+func doKnowledgeRequest(t *testing.T, r *gin.Engine, method, path string, body interface{}) (int, KnowledgeResponse) {
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp KnowledgeResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v, body=%s", err, w.Body.String())
+	}
+	return w.Code, resp
+}
+
+func TestKnowledgeHandler_CRUD(t *testing.T) {
+	r, db := setupKnowledgeRouter(t)
+	err := db.SaveKnowledge(&service.Knowledge{
+		ID:          "kb_1",
+		Title:       "原标题",
+		Content:     "明天下午三点开会",
+		Summary:     "开会",
+		Category:    "工作",
+		Tags:        []string{"会议"},
+		Importance:  "medium",
+		ActionItems: []string{"准备材料"},
+		KeyPoints:   []string{"三点"},
+	})
+	if err != nil {
+		t.Fatalf("保存知识失败: %v", err)
+	}
+
+	t.Run("获取知识", func(t *testing.T) {
+		code, resp := doKnowledgeRequest(t, r, "GET", "/api/knowledge/kb_1", nil)
+		if code != 200 || resp.Knowledge == nil {
+			t.Fatalf("期望 200, 实际 %d: %s", code, resp.Error)
+		}
+		if resp.Knowledge.Title != "原标题" || resp.Knowledge.Version != 1 {
+			t.Errorf("知识内容错误: %+v", resp.Knowledge)
+		}
+	})
+
+	t.Run("不存在返回 404", func(t *testing.T) {
+		code, _ := doKnowledgeRequest(t, r, "GET", "/api/knowledge/kb_missing", nil)
+		if code != 404 {
+			t.Errorf("期望 404, 实际 %d", code)
+		}
+		code, _ = doKnowledgeRequest(t, r, "PATCH", "/api/knowledge/kb_missing", map[string]interface{}{"title": "x"})
+		if code != 404 {
+			t.Errorf("PATCH 期望 404, 实际 %d", code)
+		}
+		code, _ = doKnowledgeRequest(t, r, "DELETE", "/api/knowledge/kb_missing", nil)
+		if code != 404 {
+			t.Errorf("DELETE 期望 404, 实际 %d", code)
+		}
+	})
+
+	t.Run("PATCH 只修改提供的字段", func(t *testing.T) {
+		code, resp := doKnowledgeRequest(t, r, "PATCH", "/api/knowledge/kb_1", map[string]interface{}{
+			"title":      "新标题",
+			"tags":       []string{"会议", "周报"},
+			"importance": "high",
+		})
+		if code != 200 {
+			t.Fatalf("期望 200, 实际 %d: %s", code, resp.Error)
+		}
+
+		k, _ := db.GetKnowledge("kb_1")
+		if k.Title != "新标题" || k.Importance != "high" || len(k.Tags) != 2 {
+			t.Errorf("字段未更新: %+v", k)
+		}
+		if k.Content != "明天下午三点开会" || k.Summary != "开会" || len(k.ActionItems) != 1 {
+			t.Errorf("未提供的字段不应改变: %+v", k)
+		}
+		if len(k.KeyPoints) != 1 {
+			t.Errorf("不可编辑字段不应改变: %+v", k.KeyPoints)
+		}
+		if k.Version != 2 {
+			t.Errorf("版本号应递增为 2, 实际 %d", k.Version)
+		}
+	})
+
+	t.Run("PUT 整体替换可编辑字段", func(t *testing.T) {
+		code, resp := doKnowledgeRequest(t, r, "PUT", "/api/knowledge/kb_1", map[string]interface{}{
+			"title":        "替换标题",
+			"content":      "改到周五开会",
+			"action_items": []string{"通知大家"},
+		})
+		if code != 200 {
+			t.Fatalf("期望 200, 实际 %d: %s", code, resp.Error)
+		}
+
+		k, _ := db.GetKnowledge("kb_1")
+		if k.Content != "改到周五开会" || k.Summary != "" || len(k.Tags) != 0 || k.Importance != "medium" {
+			t.Errorf("PUT 应清空未提供的字段: %+v", k)
+		}
+		if len(k.ActionItems) != 1 || k.ActionItems[0] != "通知大家" {
+			t.Errorf("行动项未更新: %v", k.ActionItems)
+		}
+	})
+
+	t.Run("PUT 缺少 content 返回 400", func(t *testing.T) {
+		code, _ := doKnowledgeRequest(t, r, "PUT", "/api/knowledge/kb_1", map[string]interface{}{"title": "x"})
+		if code != 400 {
+			t.Errorf("期望 400, 实际 %d", code)
+		}
+	})
+
+	t.Run("无效重要性返回 400", func(t *testing.T) {
+		code, _ := doKnowledgeRequest(t, r, "PATCH", "/api/knowledge/kb_1", map[string]interface{}{"importance": "urgent"})
+		if code != 400 {
+			t.Errorf("期望 400, 实际 %d", code)
+		}
+	})
+
+	t.Run("版本号过期返回 409", func(t *testing.T) {
+		k, _ := db.GetKnowledge("kb_1")
+		code, _ := doKnowledgeRequest(t, r, "PATCH", "/api/knowledge/kb_1", map[string]interface{}{
+			"title":   "过期修改",
+			"version": k.Version - 1,
+		})
+		if code != 409 {
+			t.Errorf("期望 409, 实际 %d", code)
+		}
+
+		code, resp := doKnowledgeRequest(t, r, "PATCH", "/api/knowledge/kb_1", map[string]interface{}{
+			"title":   "最新修改",
+			"version": k.Version,
+		})
+		if code != 200 || resp.Knowledge.Version != k.Version+1 {
+			t.Errorf("版本号一致时应成功, 实际 %d: %+v", code, resp)
+		}
+	})
+
+	t.Run("删除知识", func(t *testing.T) {
+		code, _ := doKnowledgeRequest(t, r, "DELETE", "/api/knowledge/kb_1", nil)
+		if code != 200 {
+			t.Fatalf("期望 200, 实际 %d", code)
+		}
+		if k, _ := db.GetKnowledge("kb_1"); k != nil {
+			t.Errorf("知识应已删除")
+		}
+		code, _ = doKnowledgeRequest(t, r, "GET", "/api/knowledge/kb_1", nil)
+		if code != http.StatusNotFound {
+			t.Errorf("删除后期望 404, 实际 %d", code)
+		}
+	})
+}
+
+func TestKnowledgeHandler_Search(t *testing.T) {
+	r, db := setupKnowledgeRouter(t)
+	db.SaveKnowledge(&service.Knowledge{ID: "kb_work", Content: "周会纪要：上线时间定在周五", Category: "工作", Tags: []string{"会议"}, CreatedAt: time.Now()})
+	db.SaveKnowledge(&service.Knowledge{ID: "kb_life", Content: "家庭周会：周末去爬山", Category: "生活", CreatedAt: time.Now()})
+
+	search := func(body map[string]interface{}) SearchResponse {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/api/knowledge/search", bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var resp SearchResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != 200 {
+			t.Fatalf("期望 200, 实际 %d: %s", w.Code, resp.Error)
+		}
+		return resp
+	}
+
+	t.Run("不带过滤条件", func(t *testing.T) {
+		if resp := search(map[string]interface{}{"query": "周会"}); len(resp.Knowledges) != 2 {
+			t.Errorf("期望 2 条, 实际 %d", len(resp.Knowledges))
+		}
+	})
+
+	t.Run("显式过滤条件", func(t *testing.T) {
+		resp := search(map[string]interface{}{"query": "周会", "filter": map[string]interface{}{"category": "工作", "tags": []string{"会议"}}})
+		if len(resp.Knowledges) != 1 || resp.Knowledges[0].ID != "kb_work" {
+			t.Errorf("应只返回工作类知识: %+v", resp.Knowledges)
+		}
+	})
+
+	t.Run("从查询中识别过滤条件", func(t *testing.T) {
+		resp := search(map[string]interface{}{"query": "今天的生活记录"})
+		if resp.Filter == nil || resp.Filter.Category != "生活" || resp.Filter.Since == nil {
+			t.Errorf("应返回识别出的过滤条件: %+v", resp.Filter)
+		}
+	})
+}
+
+func TestKnowledgeHandler_Messages(t *testing.T) {
+	r, db := setupKnowledgeRouter(t)
+	sm := service.NewSessionManagerWithDB(db)
+	sm.GetOrCreateSession("s1")
+	question := &service.SessionMessage{SessionID: "s1", Role: "user", Content: "明天下午三点开会"}
+	answer := &service.SessionMessage{SessionID: "s1", Role: "assistant", Content: "好的，已记下"}
+	sm.AppendMessage(question)
+	sm.AppendMessage(answer)
+	db.SaveKnowledge(&service.Knowledge{ID: "kb_1", Content: "开会", SessionID: "s1",
+		MessageIDs: []int64{question.ID, answer.ID}, CreatedAt: time.Now()})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/knowledge/kb_1/messages", nil))
+	var resp MessagesResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != 200 || len(resp.Messages) != 2 || resp.Messages[0].Content != "明天下午三点开会" {
+		t.Errorf("来源消息错误: %d %+v", w.Code, resp)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/knowledge/missing/messages", nil))
+	if w.Code != 404 {
+		t.Errorf("知识不存在应返回 404, 得到 %d", w.Code)
+	}
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"time"
+	"voice-memory/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReminderHandler 定时提醒处理器
+type ReminderHandler struct {
+	scheduler *service.ReminderScheduler
+	now       func() time.Time
+}
+
+// NewReminderHandler 创建定时提醒处理器
+func NewReminderHandler(scheduler *service.ReminderScheduler) *ReminderHandler {
+	return &ReminderHandler{
+		scheduler: scheduler,
+		now:       time.Now,
+	}
+}
+
+// ReminderResponse 定时提醒响应
+type ReminderResponse struct {
+	Success   bool               `json:"success"`
+	Reminder  *service.Reminder  `json:"reminder,omitempty"`
+	Reminders []service.Reminder `json:"reminders,omitempty"`
+	Total     int                `json:"total"`
+	Error     string             `json:"error,omitempty"`
+}
+
+// CreateReminderRequest 创建提醒请求
+// 只传 text 时按语音指令解析（如 "提醒我明天九点开会"）；传了 remind_at 时 text 即提醒内容
+type CreateReminderRequest struct {
+	Text      string `json:"text" binding:"required"`
+	RemindAt  string `json:"remind_at,omitempty"` // RFC3339 时间
+	UserID    string `json:"user_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// HandleCreate 创建提醒
+func (h *ReminderHandler) HandleCreate(c *gin.Context) {
+	var req CreateReminderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ReminderResponse{
+			Success: false,
+			Error:   "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	now := h.now()
+	text := req.Text
+	var at time.Time
+	if req.RemindAt != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, req.RemindAt); err != nil {
+			c.JSON(400, ReminderResponse{
+				Success: false,
+				Error:   "remind_at 必须是 RFC3339 格式的时间",
+			})
+			return
+		}
+	} else {
+		var ok bool
+		if text, at, ok = service.ParseReminder(req.Text, now); !ok {
+			c.JSON(400, ReminderResponse{
+				Success: false,
+				Error:   "无法识别提醒时间: " + req.Text,
+			})
+			return
+		}
+	}
+	if !at.After(now) {
+		c.JSON(400, ReminderResponse{
+			Success: false,
+			Error:   "提醒时间必须晚于当前时间",
+		})
+		return
+	}
+
+	reminder, err := h.scheduler.Create(req.UserID, req.SessionID, text, at)
+	if err != nil {
+		h.fail(c, "创建提醒失败: ", err)
+		return
+	}
+
+	c.JSON(200, ReminderResponse{
+		Success:  true,
+		Reminder: reminder,
+		Total:    1,
+	})
+}
+
+// HandleList 列出提醒（?user_id= &status=pending|delivering|fired|cancelled|all &limit=条数）
+func (h *ReminderHandler) HandleList(c *gin.Context) {
+	filter := service.ReminderFilter{UserID: c.Query("user_id")}
+	switch status := c.DefaultQuery("status", service.ReminderPending); status {
+	case service.ReminderPending, service.ReminderDelivering, service.ReminderFired, service.ReminderCancelled:
+		filter.Status = status
+	case "all":
+	default:
+		c.JSON(400, ReminderResponse{
+			Success: false,
+			Error:   "status 只能是 pending、delivering、fired、cancelled 或 all",
+		})
+		return
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "0"))
+
+	reminders, err := h.scheduler.List(filter)
+	if err != nil {
+		h.fail(c, "获取提醒失败: ", err)
+		return
+	}
+
+	c.JSON(200, ReminderResponse{
+		Success:   true,
+		Reminders: reminders,
+		Total:     len(reminders),
+	})
+}
+
+// HandleGet 获取提醒详情
+func (h *ReminderHandler) HandleGet(c *gin.Context) {
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	reminder, err := h.scheduler.Get(id)
+	if err != nil {
+		h.fail(c, "获取提醒失败: ", err)
+		return
+	}
+
+	c.JSON(200, ReminderResponse{
+		Success:  true,
+		Reminder: reminder,
+		Total:    1,
+	})
+}
+
+// HandleCancel 取消提醒，已触发的提醒返回 409
+func (h *ReminderHandler) HandleCancel(c *gin.Context) {
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	reminder, err := h.scheduler.Cancel(id)
+	if err != nil {
+		h.fail(c, "取消提醒失败: ", err)
+		return
+	}
+
+	c.JSON(200, ReminderResponse{
+		Success:  true,
+		Reminder: reminder,
+		Total:    1,
+	})
+}
+
+func (h *ReminderHandler) parseID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, ReminderResponse{
+			Success: false,
+			Error:   "无效的提醒 ID",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// fail 按错误类型返回 404 / 409 / 500
+func (h *ReminderHandler) fail(c *gin.Context, prefix string, err error) {
+	status := 500
+	switch {
+	case errors.Is(err, service.ErrReminderNotFound):
+		status = 404
+	case errors.Is(err, service.ErrReminderFired):
+		status = 409
+	}
+	c.JSON(status, ReminderResponse{
+		Success: false,
+		Error:   prefix + err.Error(),
+	})
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"voice-memory/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReminderHandler(t *testing.T) {
+	db, err := service.NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	// 2026-03-11 是周三；调度器不启动，只验证接口
+	now := time.Date(2026, 3, 11, 10, 0, 0, 0, time.Local)
+	h := NewReminderHandler(service.NewReminderScheduler(db))
+	h.now = func() time.Time { return now }
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/reminders", h.HandleCreate)
+	r.GET("/api/reminders", h.HandleList)
+	r.GET("/api/reminders/:id", h.HandleGet)
+	r.DELETE("/api/reminders/:id", h.HandleCancel)
+
+	do := func(method, path string, body interface{}) (int, ReminderResponse) {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var resp ReminderResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("解析响应失败: %v, body=%s", err, w.Body.String())
+		}
+		return w.Code, resp
+	}
+
+	var id int64
+	t.Run("按语音指令创建", func(t *testing.T) {
+		code, resp := do("POST", "/api/reminders", CreateReminderRequest{Text: "提醒我明天九点开会", UserID: "alice"})
+		if code != 200 || resp.Reminder == nil {
+			t.Fatalf("创建失败: code=%d %+v", code, resp)
+		}
+		want := time.Date(2026, 3, 12, 9, 0, 0, 0, time.Local)
+		if resp.Reminder.Text != "开会" || !resp.Reminder.RemindAt.Equal(want) || resp.Reminder.UserID != "alice" {
+			t.Errorf("创建结果错误: %+v", resp.Reminder)
+		}
+		id = resp.Reminder.ID
+	})
+
+	t.Run("指定时间创建", func(t *testing.T) {
+		code, resp := do("POST", "/api/reminders", CreateReminderRequest{Text: "喝水", RemindAt: "2026-03-12T18:00:00+08:00"})
+		if code != 200 || resp.Reminder.Text != "喝水" || resp.Reminder.UserID != service.DefaultReminderUser {
+			t.Errorf("创建结果错误: code=%d %+v", code, resp)
+		}
+	})
+
+	t.Run("参数错误", func(t *testing.T) {
+		bad := []CreateReminderRequest{
+			{},
+			{Text: "提醒我买牛奶"},
+			{Text: "喝水", RemindAt: "明天"},
+			{Text: "喝水", RemindAt: "2026-03-10T09:00:00+08:00"},
+		}
+		for _, req := range bad {
+			if code, _ := do("POST", "/api/reminders", req); code != 400 {
+				t.Errorf("%+v 期望 400, 实际 %d", req, code)
+			}
+		}
+		if code, _ := do("GET", "/api/reminders?status=unknown", nil); code != 400 {
+			t.Errorf("期望 400, 实际 %d", code)
+		}
+	})
+
+	t.Run("按用户列出", func(t *testing.T) {
+		_, resp := do("GET", "/api/reminders?user_id=alice", nil)
+		if resp.Total != 1 || resp.Reminders[0].ID != id {
+			t.Errorf("结果错误: %+v", resp)
+		}
+		if _, resp := do("GET", "/api/reminders", nil); resp.Total != 2 {
+			t.Errorf("期望共 2 条, 实际 %d", resp.Total)
+		}
+	})
+
+	t.Run("取消提醒", func(t *testing.T) {
+		code, resp := do("DELETE", fmt.Sprintf("/api/reminders/%d", id), nil)
+		if code != 200 || resp.Reminder.Status != service.ReminderCancelled {
+			t.Fatalf("取消失败: code=%d %+v", code, resp)
+		}
+		if _, resp := do("GET", "/api/reminders?status=cancelled", nil); resp.Total != 1 {
+			t.Errorf("期望 1 条已取消, 实际 %d", resp.Total)
+		}
+		if code, _ := do("GET", "/api/reminders/999", nil); code != 404 {
+			t.Errorf("期望 404, 实际 %d", code)
+		}
+		if code, _ := do("DELETE", "/api/reminders/999", nil); code != 404 {
+			t.Errorf("期望 404, 实际 %d", code)
+		}
+	})
+}
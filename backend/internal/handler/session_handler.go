@@ -1,6 +1,10 @@
 package handler
 
 import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
 	"voice-memory/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -27,10 +31,32 @@ type GetSessionResponse struct {
 
 // ListSessionsResponse 列表响应
 type ListSessionsResponse struct {
-	Success  bool                 `json:"success"`
-	Sessions []service.Session    `json:"sessions,omitempty"`
-	Count    int                  `json:"count,omitempty"`
-	Error    string               `json:"error,omitempty"`
+	Success  bool              `json:"success"`
+	Sessions []service.Session `json:"sessions,omitempty"`
+	Count    int               `json:"count,omitempty"`
+	Total    int               `json:"total"` // 满足条件的会话总数
+	Limit    int               `json:"limit,omitempty"`
+	Offset   int               `json:"offset"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// MessagesResponse 消息列表响应
+type MessagesResponse struct {
+	Success    bool                     `json:"success"`
+	Messages   []service.SessionMessage `json:"messages"`
+	Total      int                      `json:"total"`
+	HasMore    bool                     `json:"has_more"`
+	NextBefore int64                    `json:"next_before,omitempty"`
+	Error      string                   `json:"error,omitempty"`
+}
+
+// SearchMessagesResponse 历史消息搜索响应
+type SearchMessagesResponse struct {
+	Success bool                       `json:"success"`
+	Query   string                     `json:"query,omitempty"`
+	Results []service.MessageSearchHit `json:"results"`
+	Count   int                        `json:"count"`
+	Error   string                     `json:"error,omitempty"`
 }
 
 // HandleGetSession 获取单个会话
@@ -59,14 +85,244 @@ func (h *SessionHandler) HandleGetSession(c *gin.Context) {
 	})
 }
 
-// HandleListSessions 列出所有会话
+// HandleListSessions 分页列出会话（不含消息内容）
+// GET /api/sessions?limit=&offset=&sort=&order=&tag=&pinned=&archived=&since=&until=
 func (h *SessionHandler) HandleListSessions(c *gin.Context) {
-	sessions := h.sessionManager.GetAllSessions()
+	filter, err := parseSessionFilter(c)
+	if err != nil {
+		c.JSON(400, ListSessionsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	page, err := h.sessionManager.ListSessions(filter)
+	if err != nil {
+		c.JSON(500, ListSessionsResponse{
+			Success: false,
+			Error:   "获取会话列表失败: " + err.Error(),
+		})
+		return
+	}
 
 	c.JSON(200, ListSessionsResponse{
 		Success:  true,
-		Sessions: sessions,
-		Count:    len(sessions),
+		Sessions: page.Sessions,
+		Count:    len(page.Sessions),
+		Total:    page.Total,
+		Limit:    page.Limit,
+		Offset:   page.Offset,
+	})
+}
+
+// parseSessionFilter 解析会话列表的查询参数
+// 归档的会话默认不返回（archived=true 只看归档，archived=all 不限）；
+// since / until 按最后活动时间过滤，可以是 RFC3339 时间或 2006-01-02 日期（until 的日期包含当天）
+func parseSessionFilter(c *gin.Context) (service.SessionFilter, error) {
+	filter := service.SessionFilter{Sort: c.DefaultQuery("sort", "updated_at")}
+	if !service.IsValidSessionSort(filter.Sort) {
+		return filter, errors.New("sort 只能是 updated_at、created_at、title 或 message_count")
+	}
+	switch c.DefaultQuery("order", "desc") {
+	case "desc":
+	case "asc":
+		filter.Asc = true
+	default:
+		return filter, errors.New("order 只能是 asc 或 desc")
+	}
+
+	var err error
+	if filter.Limit, err = queryInt(c, "limit"); err != nil {
+		return filter, err
+	}
+	if filter.Offset, err = queryInt(c, "offset"); err != nil {
+		return filter, err
+	}
+
+	for _, tags := range c.QueryArray("tag") {
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				filter.Tags = append(filter.Tags, tag)
+			}
+		}
+	}
+
+	if v := c.Query("pinned"); v != "" {
+		pinned, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, errors.New("pinned 只能是 true 或 false")
+		}
+		filter.Pinned = &pinned
+	}
+	switch v := c.DefaultQuery("archived", "false"); v {
+	case "all":
+	default:
+		archived, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, errors.New("archived 只能是 true、false 或 all")
+		}
+		filter.Archived = &archived
+	}
+
+	if filter.Since, err = querySessionTime(c, "since", false); err != nil {
+		return filter, err
+	}
+	if filter.Until, err = querySessionTime(c, "until", true); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// queryInt 解析非负整数查询参数，未提供时为 0
+func queryInt(c *gin.Context, name string) (int, error) {
+	v := c.Query(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, errors.New(name + " 必须是非负整数")
+	}
+	return n, nil
+}
+
+// querySessionTime 解析 RFC3339 时间或日期查询参数，endOfDay 为 true 时日期取次日零点（包含当天）
+func querySessionTime(c *gin.Context, name string, endOfDay bool) (*time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return nil, errors.New(name + " 必须是 RFC3339 时间或 2006-01-02 格式的日期")
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// HandleUpdateSession 修改会话标题、标签、置顶和归档状态 (PATCH /api/sessions/:id)
+func (h *SessionHandler) HandleUpdateSession(c *gin.Context) {
+	var req service.SessionUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, GetSessionResponse{
+			Success: false,
+			Error:   "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	session, err := h.sessionManager.UpdateSession(c.Param("id"), req)
+	if errors.Is(err, service.ErrSessionNotFound) {
+		c.JSON(404, GetSessionResponse{
+			Success: false,
+			Error:   "会话不存在",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(500, GetSessionResponse{
+			Success: false,
+			Error:   "修改会话失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, GetSessionResponse{
+		Success: true,
+		Session: session,
+	})
+}
+
+// HandleListMessages 分页获取会话消息 (GET /api/sessions/:id/messages?before=&limit=)
+// 按时间正序返回 ID 小于 before 的最近 limit 条，用 next_before 继续向前翻页
+func (h *SessionHandler) HandleListMessages(c *gin.Context) {
+	var before int64
+	if v := c.Query("before"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			c.JSON(400, MessagesResponse{
+				Success: false,
+				Error:   "before 参数无效",
+			})
+			return
+		}
+		before = n
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	page, err := h.sessionManager.ListMessages(c.Param("id"), before, limit)
+	if errors.Is(err, service.ErrSessionNotFound) {
+		c.JSON(404, MessagesResponse{
+			Success: false,
+			Error:   "会话不存在",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(500, MessagesResponse{
+			Success: false,
+			Error:   "获取消息失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, MessagesResponse{
+		Success:    true,
+		Messages:   page.Messages,
+		Total:      page.Total,
+		HasMore:    page.HasMore,
+		NextBefore: page.NextBefore,
+	})
+}
+
+// HandleSearchMessages 全文搜索历史对话 (GET /api/sessions/search?q=&session_id=&limit=&context=)
+// 返回命中的消息、前后各 context 条上下文消息、所在会话标题和跳转到该消息的分页链接
+func (h *SessionHandler) HandleSearchMessages(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(400, SearchMessagesResponse{
+			Success: false,
+			Error:   "缺少 q 参数",
+		})
+		return
+	}
+
+	opts := service.MessageSearchOptions{SessionID: c.Query("session_id")}
+	var err error
+	if opts.Limit, err = queryInt(c, "limit"); err != nil {
+		c.JSON(400, SearchMessagesResponse{Success: false, Error: err.Error()})
+		return
+	}
+	if v := c.Query("context"); v != "" {
+		if opts.Context, err = queryInt(c, "context"); err != nil {
+			c.JSON(400, SearchMessagesResponse{Success: false, Error: err.Error()})
+			return
+		}
+		if opts.Context == 0 {
+			opts.Context = -1 // context=0 表示不带上下文
+		}
+	}
+
+	results, err := h.sessionManager.SearchMessages(query, opts)
+	if err != nil {
+		c.JSON(500, SearchMessagesResponse{
+			Success: false,
+			Error:   "搜索失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, SearchMessagesResponse{
+		Success: true,
+		Query:   query,
+		Results: results,
+		Count:   len(results),
 	})
 }
 
@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"voice-memory/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSessionHandler_ListMessages(t *testing.T) {
+	db, err := service.NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	sm := service.NewSessionManagerWithDB(db)
+	sm.GetOrCreateSession("s1")
+	sm.AddMessage("s1", "user", "你好")
+	sm.AddMessage("s1", "assistant", "你好，有什么可以帮你")
+	sm.AddMessage("s1", "user", "记一下明天开会")
+
+	h := NewSessionHandler(sm)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/sessions/get", h.HandleGetSession)
+	r.GET("/api/sessions/:id/messages", h.HandleListMessages)
+
+	get := func(path string) (int, MessagesResponse) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		var resp MessagesResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("解析响应失败: %v, body=%s", err, w.Body.String())
+		}
+		return w.Code, resp
+	}
+
+	t.Run("分页获取", func(t *testing.T) {
+		code, resp := get("/api/sessions/s1/messages?limit=2")
+		if code != 200 || resp.Total != 3 || !resp.HasMore || len(resp.Messages) != 2 || resp.Messages[1].Content != "记一下明天开会" {
+			t.Fatalf("第一页错误: %d %+v", code, resp)
+		}
+
+		_, older := get("/api/sessions/s1/messages?limit=2&before=" + strconv.FormatInt(resp.NextBefore, 10))
+		if older.HasMore || len(older.Messages) != 1 || older.Messages[0].Content != "你好" {
+			t.Errorf("第二页错误: %+v", older)
+		}
+	})
+
+	t.Run("参数错误与会话不存在", func(t *testing.T) {
+		if code, _ := get("/api/sessions/s1/messages?before=abc"); code != 400 {
+			t.Errorf("before 无效应返回 400, 得到 %d", code)
+		}
+		if code, _ := get("/api/sessions/missing/messages"); code != 404 {
+			t.Errorf("会话不存在应返回 404, 得到 %d", code)
+		}
+	})
+}
+
+func TestSessionHandler_ListAndUpdate(t *testing.T) {
+	db, err := service.NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	sm := service.NewSessionManagerWithDB(db)
+	for _, id := range []string{"s1", "s2", "s3"} {
+		sm.GetOrCreateSession(id)
+		sm.AddMessage(id, "user", "你好")
+	}
+
+	h := NewSessionHandler(sm)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/sessions", h.HandleListSessions)
+	r.PATCH("/api/sessions/:id", h.HandleUpdateSession)
+
+	list := func(query string) (int, ListSessionsResponse) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/sessions"+query, nil))
+		var resp ListSessionsResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+	patch := func(id, body string) (int, GetSessionResponse) {
+		req := httptest.NewRequest("PATCH", "/api/sessions/"+id, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp GetSessionResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	t.Run("修改元数据", func(t *testing.T) {
+		code, resp := patch("s1", `{"title": "周会", "tags": ["工作"], "pinned": true}`)
+		if code != 200 || resp.Session.Title != "周会" || !resp.Session.Pinned || len(resp.Session.Tags) != 1 {
+			t.Fatalf("修改失败: %d %+v", code, resp)
+		}
+		patch("s3", `{"archived": true}`)
+		if code, _ := patch("missing", `{"pinned": true}`); code != 404 {
+			t.Errorf("会话不存在应返回 404, 得到 %d", code)
+		}
+	})
+
+	t.Run("过滤与分页", func(t *testing.T) {
+		code, resp := list("?limit=1")
+		if code != 200 || resp.Total != 2 || len(resp.Sessions) != 1 || resp.Sessions[0].ID != "s1" {
+			t.Errorf("默认列表错误: %d %+v", code, resp)
+		}
+		if _, resp := list("?tag=工作"); resp.Total != 1 || resp.Sessions[0].Title != "周会" {
+			t.Errorf("标签过滤错误: %+v", resp)
+		}
+		if _, resp := list("?archived=all"); resp.Total != 3 {
+			t.Errorf("archived=all 应返回全部会话: %+v", resp)
+		}
+		if _, resp := list("?since=2000-01-01&until=2000-01-02"); resp.Total != 0 {
+			t.Errorf("日期过滤错误: %+v", resp)
+		}
+	})
+
+	t.Run("参数错误", func(t *testing.T) {
+		for _, query := range []string{"?sort=name", "?order=up", "?limit=-1", "?pinned=yes", "?since=昨天"} {
+			if code, _ := list(query); code != 400 {
+				t.Errorf("%s 应返回 400, 得到 %d", query, code)
+			}
+		}
+	})
+}
+
+func TestSessionHandler_SearchMessages(t *testing.T) {
+	db, err := service.NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	sm := service.NewSessionManagerWithDB(db)
+	sm.GetOrCreateSession("s1")
+	sm.AddMessage("s1", "user", "你好")
+	sm.AddMessage("s1", "user", "Redis 集群怎么扩容")
+	sm.AddMessage("s1", "assistant", "可以用 reshard 迁移槽位")
+
+	h := NewSessionHandler(sm)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/sessions/search", h.HandleSearchMessages)
+	r.GET("/api/sessions/:id/messages", h.HandleListMessages)
+
+	get := func(path string) (int, SearchMessagesResponse) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		var resp SearchMessagesResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("解析响应失败: %v, body=%s", err, w.Body.String())
+		}
+		return w.Code, resp
+	}
+
+	t.Run("搜索并带上下文", func(t *testing.T) {
+		code, resp := get("/api/sessions/search?q=redis&context=1")
+		if code != 200 || resp.Count != 1 || resp.Results[0].Message.Content != "Redis 集群怎么扩容" {
+			t.Fatalf("搜索结果错误: %d %+v", code, resp)
+		}
+		hit := resp.Results[0]
+		if len(hit.Before) != 1 || hit.Before[0].Content != "你好" || len(hit.After) != 1 {
+			t.Errorf("上下文错误: %+v", hit)
+		}
+		if !strings.Contains(hit.Snippet, "<mark>Redis</mark>") {
+			t.Errorf("片段未高亮: %s", hit.Snippet)
+		}
+	})
+
+	t.Run("不带上下文", func(t *testing.T) {
+		_, resp := get("/api/sessions/search?q=reshard&context=0")
+		if resp.Count != 1 || len(resp.Results[0].Before) != 0 || len(resp.Results[0].After) != 0 {
+			t.Errorf("context=0 不应带上下文: %+v", resp)
+		}
+	})
+
+	t.Run("参数错误", func(t *testing.T) {
+		for _, query := range []string{"", "?q=", "?q=redis&limit=abc"} {
+			if code, _ := get("/api/sessions/search" + query); code != 400 {
+				t.Errorf("%s 应返回 400, 得到 %d", query, code)
+			}
+		}
+	})
+}
@@ -2,6 +2,8 @@ package handler
 
 import (
 	"io"
+	"strconv"
+	"voice-memory/internal/audio"
 	"voice-memory/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -22,7 +24,7 @@ func NewSTTHandler(sttService service.STTService) *STTHandler {
 // RecognizeRequest 识别请求
 type RecognizeRequest struct {
 	Format string `json:"format" binding:"required"` // wav/pcm
-	Rate   int    `json:"rate" binding:"required"`   // 采样率
+	Rate   int    `json:"rate" binding:"required"`   // 采样率（仅裸 PCM 需要，WAV 以文件头为准）
 }
 
 // RecognizeResponse 识别响应
@@ -55,7 +57,7 @@ func (h *STTHandler) Recognize(c *gin.Context) {
 	}
 	defer file.Close()
 
-	// 读取音频数据 (前端已经是 WAV 格式)
+	// 读取音频数据 (WAV，或 16bit 单声道裸 PCM)
 	audioData, err := io.ReadAll(file)
 	if err != nil {
 		c.JSON(500, RecognizeResponse{
@@ -65,11 +67,24 @@ func (h *STTHandler) Recognize(c *gin.Context) {
 		return
 	}
 
-	// 直接调用百度 STT (无需转换)
-	results, err := h.sttService.Recognize(&service.RecognizeRequest{
-		AudioData: audioData,
-		Format:    "wav",
-		Rate:      16000,
+	// 统一转换为 16kHz 单声道 PCM（浏览器录音常见 44.1/48kHz）
+	rawFormat := audio.PCM16kMono
+	if rate, err := strconv.Atoi(c.PostForm("rate")); err == nil && rate > 0 {
+		rawFormat.SampleRate = rate
+	}
+	pcm, err := audio.Normalize(audioData, rawFormat)
+	if err != nil {
+		c.JSON(400, RecognizeResponse{
+			Success: false,
+			Error:   "音频格式无效: " + err.Error(),
+		})
+		return
+	}
+
+	results, err := h.sttService.Recognize(c.Request.Context(), &service.RecognizeRequest{
+		AudioData: pcm,
+		Format:    "pcm",
+		Rate:      audio.TargetSampleRate,
 	})
 
 	if err != nil {
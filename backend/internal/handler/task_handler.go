@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"time"
+	"voice-memory/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TaskHandler 待办处理器
+type TaskHandler struct {
+	tasks *service.TaskStore
+	now   func() time.Time
+}
+
+// NewTaskHandler 创建待办处理器
+func NewTaskHandler(tasks *service.TaskStore) *TaskHandler {
+	return &TaskHandler{
+		tasks: tasks,
+		now:   time.Now,
+	}
+}
+
+// TaskResponse 待办响应
+type TaskResponse struct {
+	Success bool           `json:"success"`
+	Task    *service.Task  `json:"task,omitempty"`
+	Tasks   []service.Task `json:"tasks,omitempty"`
+	Total   int            `json:"total"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// SnoozeRequest 推迟待办请求，until 与 text 都为空时推迟一天
+type SnoozeRequest struct {
+	Until string `json:"until,omitempty"` // RFC3339 时间
+	Text  string `json:"text,omitempty"`  // 中文描述，如 "下周三"、"明天下午三点"
+}
+
+// HandleList 列出待办（?status=pending|done|all &due=today|overdue &limit=条数）
+func (h *TaskHandler) HandleList(c *gin.Context) {
+	filter := service.TaskFilter{}
+	switch status := c.DefaultQuery("status", service.TaskPending); status {
+	case service.TaskPending, service.TaskDone:
+		filter.Status = status
+	case "all":
+	default:
+		c.JSON(400, TaskResponse{
+			Success: false,
+			Error:   "status 只能是 pending、done 或 all",
+		})
+		return
+	}
+
+	now := h.now()
+	switch c.Query("due") {
+	case "":
+	case "today":
+		// 今天到期的和已过期的
+		end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1)
+		filter.DueBefore = &end
+	case "overdue":
+		filter.DueBefore = &now
+	default:
+		c.JSON(400, TaskResponse{
+			Success: false,
+			Error:   "due 只能是 today 或 overdue",
+		})
+		return
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "0"))
+
+	tasks, err := h.tasks.ListTasks(filter)
+	if err != nil {
+		h.fail(c, "获取待办失败: ", err)
+		return
+	}
+
+	// 只有截止日期的待办当天仍未过期
+	if c.Query("due") == "overdue" {
+		overdue := []service.Task{}
+		for _, t := range tasks {
+			if t.Overdue(now) {
+				overdue = append(overdue, t)
+			}
+		}
+		tasks = overdue
+	}
+
+	c.JSON(200, TaskResponse{
+		Success: true,
+		Tasks:   tasks,
+		Total:   len(tasks),
+	})
+}
+
+// HandleGet 获取待办详情
+func (h *TaskHandler) HandleGet(c *gin.Context) {
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	task, err := h.tasks.GetTask(id)
+	if err != nil {
+		h.fail(c, "获取待办失败: ", err)
+		return
+	}
+
+	c.JSON(200, TaskResponse{
+		Success: true,
+		Task:    task,
+		Total:   1,
+	})
+}
+
+// HandleComplete 标记待办完成
+func (h *TaskHandler) HandleComplete(c *gin.Context) {
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	task, err := h.tasks.CompleteTask(id)
+	if err != nil {
+		h.fail(c, "完成待办失败: ", err)
+		return
+	}
+
+	c.JSON(200, TaskResponse{
+		Success: true,
+		Task:    task,
+		Total:   1,
+	})
+}
+
+// HandleSnooze 推迟待办，已完成的待办返回 409
+func (h *TaskHandler) HandleSnooze(c *gin.Context) {
+	id, ok := h.parseID(c)
+	if !ok {
+		return
+	}
+
+	var req SnoozeRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, TaskResponse{
+				Success: false,
+				Error:   "请求参数错误: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	now := h.now()
+	var due service.DueDate
+	switch {
+	case req.Until != "":
+		until, err := time.Parse(time.RFC3339, req.Until)
+		if err != nil {
+			c.JSON(400, TaskResponse{
+				Success: false,
+				Error:   "until 必须是 RFC3339 格式的时间",
+			})
+			return
+		}
+		due = service.DueDate{Time: until}
+	case req.Text != "":
+		parsed := service.ParseDueDate(req.Text, now)
+		if parsed == nil {
+			c.JSON(400, TaskResponse{
+				Success: false,
+				Error:   "无法识别时间: " + req.Text,
+			})
+			return
+		}
+		due = *parsed
+	default:
+		due = service.DueDate{Time: now.AddDate(0, 0, 1)}
+	}
+
+	task, err := h.tasks.SnoozeTask(id, due)
+	if err != nil {
+		h.fail(c, "推迟待办失败: ", err)
+		return
+	}
+
+	c.JSON(200, TaskResponse{
+		Success: true,
+		Task:    task,
+		Total:   1,
+	})
+}
+
+func (h *TaskHandler) parseID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, TaskResponse{
+			Success: false,
+			Error:   "无效的待办 ID",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// fail 按错误类型返回 404 / 409 / 500
+func (h *TaskHandler) fail(c *gin.Context, prefix string, err error) {
+	status := 500
+	switch {
+	case errors.Is(err, service.ErrTaskNotFound):
+		status = 404
+	case errors.Is(err, service.ErrTaskDone):
+		status = 409
+	}
+	c.JSON(status, TaskResponse{
+		Success: false,
+		Error:   prefix + err.Error(),
+	})
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"voice-memory/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTaskHandler(t *testing.T) {
+	db, err := service.NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	// 2026-03-11 是周三
+	now := time.Date(2026, 3, 11, 10, 0, 0, 0, time.Local)
+	repo := service.NewKnowledgeRepository(db, nil)
+	k := &service.Knowledge{ID: "kb_1", Title: "计划", CreatedAt: now.AddDate(0, 0, -1),
+		ActionItems: []string{"今天交周报", "后天体检", "整理笔记"}}
+	if err := repo.Save(context.Background(), k); err != nil {
+		t.Fatalf("保存知识失败: %v", err)
+	}
+
+	tasks := service.NewTaskStore(db)
+	list, _ := tasks.ListTasks(service.TaskFilter{})
+	ids := map[string]int64{}
+	for _, task := range list {
+		ids[task.Title] = task.ID
+	}
+
+	h := NewTaskHandler(tasks)
+	h.now = func() time.Time { return now }
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/tasks", h.HandleList)
+	r.GET("/api/tasks/:id", h.HandleGet)
+	r.POST("/api/tasks/:id/complete", h.HandleComplete)
+	r.POST("/api/tasks/:id/snooze", h.HandleSnooze)
+
+	do := func(method, path string, body interface{}) (int, TaskResponse) {
+		var reader *bytes.Reader
+		if body == nil {
+			reader = bytes.NewReader(nil)
+		} else {
+			data, _ := json.Marshal(body)
+			reader = bytes.NewReader(data)
+		}
+		req := httptest.NewRequest(method, path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var resp TaskResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("解析响应失败: %v, body=%s", err, w.Body.String())
+		}
+		return w.Code, resp
+	}
+	taskPath := func(title, action string) string {
+		path := fmt.Sprintf("/api/tasks/%d", ids[title])
+		if action != "" {
+			path += "/" + action
+		}
+		return path
+	}
+
+	t.Run("默认列出未完成的待办", func(t *testing.T) {
+		code, resp := do("GET", "/api/tasks", nil)
+		if code != 200 || resp.Total != 3 {
+			t.Fatalf("期望 3 条, 实际 %d (code %d)", resp.Total, code)
+		}
+	})
+
+	t.Run("列出今天到期的待办", func(t *testing.T) {
+		code, resp := do("GET", "/api/tasks?due=today", nil)
+		if code != 200 || resp.Total != 1 || resp.Tasks[0].Title != "今天交周报" {
+			t.Errorf("结果错误: %+v", resp)
+		}
+	})
+
+	t.Run("参数错误", func(t *testing.T) {
+		if code, _ := do("GET", "/api/tasks?status=unknown", nil); code != 400 {
+			t.Errorf("期望 400, 实际 %d", code)
+		}
+		if code, _ := do("GET", "/api/tasks?due=tomorrow", nil); code != 400 {
+			t.Errorf("期望 400, 实际 %d", code)
+		}
+		if code, _ := do("GET", "/api/tasks/abc", nil); code != 400 {
+			t.Errorf("期望 400, 实际 %d", code)
+		}
+	})
+
+	t.Run("按中文描述推迟", func(t *testing.T) {
+		code, resp := do("POST", taskPath("后天体检", "snooze"), SnoozeRequest{Text: "下周三上午九点"})
+		want := time.Date(2026, 3, 18, 9, 0, 0, 0, time.Local)
+		if code != 200 || resp.Task == nil || !resp.Task.DueAt.Equal(want) {
+			t.Errorf("推迟结果错误: code=%d %+v", code, resp)
+		}
+		if code, _ := do("POST", taskPath("后天体检", "snooze"), SnoozeRequest{Text: "以后再说"}); code != 400 {
+			t.Errorf("无法识别的时间应返回 400, 实际 %d", code)
+		}
+	})
+
+	t.Run("按 RFC3339 时间推迟", func(t *testing.T) {
+		until := "2026-04-01T08:00:00+08:00"
+		code, resp := do("POST", taskPath("整理笔记", "snooze"), SnoozeRequest{Until: until})
+		want, _ := time.Parse(time.RFC3339, until)
+		if code != 200 || !resp.Task.DueAt.Equal(want) {
+			t.Errorf("推迟结果错误: code=%d %+v", code, resp)
+		}
+	})
+
+	t.Run("默认推迟一天", func(t *testing.T) {
+		code, resp := do("POST", taskPath("今天交周报", "snooze"), nil)
+		if code != 200 || !resp.Task.DueAt.Equal(now.AddDate(0, 0, 1)) {
+			t.Errorf("推迟结果错误: code=%d %+v", code, resp)
+		}
+	})
+
+	t.Run("完成待办", func(t *testing.T) {
+		code, resp := do("POST", taskPath("今天交周报", "complete"), nil)
+		if code != 200 || resp.Task.Status != service.TaskDone {
+			t.Fatalf("完成结果错误: code=%d %+v", code, resp)
+		}
+		if code, _ := do("POST", taskPath("今天交周报", "snooze"), nil); code != 409 {
+			t.Errorf("推迟已完成的待办应返回 409, 实际 %d", code)
+		}
+		if _, resp := do("GET", "/api/tasks?status=done", nil); resp.Total != 1 {
+			t.Errorf("期望 1 条已完成, 实际 %d", resp.Total)
+		}
+		if _, resp := do("GET", "/api/tasks?status=all", nil); resp.Total != 3 {
+			t.Errorf("期望共 3 条, 实际 %d", resp.Total)
+		}
+	})
+
+	t.Run("待办不存在", func(t *testing.T) {
+		if code, _ := do("GET", "/api/tasks/999", nil); code != 404 {
+			t.Errorf("期望 404, 实际 %d", code)
+		}
+		if code, _ := do("POST", "/api/tasks/999/complete", nil); code != 404 {
+			t.Errorf("期望 404, 实际 %d", code)
+		}
+	})
+}
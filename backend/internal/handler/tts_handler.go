@@ -61,7 +61,7 @@ func (h *TTSHandler) HandleTTS(c *gin.Context) {
 	}
 
 	// 合成语音并保存到文件
-	filename, err := h.ttsService.SynthesizeToFile(options)
+	filename, err := h.ttsService.SynthesizeToFile(c.Request.Context(), options)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "TTS 合成失败: " + err.Error()})
 		return
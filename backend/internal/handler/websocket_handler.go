@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -9,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"voice-memory/internal/audio"
 	"voice-memory/internal/pipeline"
 	"voice-memory/internal/service"
 
@@ -26,6 +28,18 @@ type WSHandler struct {
 	intentService      service.IntentService
 	knowledgeOrganizer *service.KnowledgeOrganizer
 	db                 *service.Database
+	knowledgeRepo      *service.KnowledgeRepository
+	retrievalService   service.RetrievalService
+	taskStore          *service.TaskStore
+	reminders          *service.ReminderScheduler
+	summarizer         *service.SessionSummarizer
+	titler             *service.SessionTitler
+	sentenceTTS        bool
+	vadConfig          service.VADConfig
+
+	// 在线连接（按用户），用于推送提醒
+	clientsMu sync.Mutex
+	clients   map[string]map[*wsWriter]struct{}
 }
 
 // NewWSHandler 创建 WebSocket 处理器
@@ -51,12 +65,105 @@ func NewWSHandler(
 		intentService:      intent,
 		knowledgeOrganizer: organizer,
 		db:                 db,
+		knowledgeRepo:      service.NewKnowledgeRepository(db, nil),
+		vadConfig:          service.DefaultVADConfig(),
+		clients:            make(map[string]map[*wsWriter]struct{}),
 	}
 }
 
+// SetRetrievalService 设置知识检索服务（未设置时对话不查询知识库）
+func (h *WSHandler) SetRetrievalService(retriever service.RetrievalService) {
+	h.retrievalService = retriever
+}
+
+// SetKnowledgeRepository 设置知识仓库（默认只写数据库，不同步向量库）
+func (h *WSHandler) SetKnowledgeRepository(repo *service.KnowledgeRepository) {
+	h.knowledgeRepo = repo
+}
+
+// SetTaskStore 设置待办存储（未设置时 "今天有什么待办" 按普通对话交给 LLM）
+func (h *WSHandler) SetTaskStore(tasks *service.TaskStore) {
+	h.taskStore = tasks
+}
+
+// SetReminderScheduler 设置提醒调度器：开启 "提醒我…" 语音指令，并把触发的提醒推送给该用户的在线连接
+func (h *WSHandler) SetReminderScheduler(scheduler *service.ReminderScheduler) {
+	h.reminders = scheduler
+	scheduler.AddDeliverer(h)
+}
+
+// DeliverReminder 实现 service.ReminderDeliverer：向该用户所有在线连接推送 reminder 事件
+// 用户不在线或推送全部失败时返回 service.ErrReminderNoRecipient，提醒保留到用户下次连接时重新推送
+func (h *WSHandler) DeliverReminder(ctx context.Context, r *service.Reminder) error {
+	h.clientsMu.Lock()
+	writers := make([]*wsWriter, 0, len(h.clients[r.UserID]))
+	for w := range h.clients[r.UserID] {
+		writers = append(writers, w)
+	}
+	h.clientsMu.Unlock()
+
+	delivered := 0
+	for _, w := range writers {
+		if err := w.WriteJSON(map[string]interface{}{"type": "reminder", "data": r}); err != nil {
+			log.Printf("[WS] 推送提醒 #%d 失败: %v", r.ID, err)
+			continue
+		}
+		delivered++
+	}
+	if delivered == 0 {
+		log.Printf("[WS] 用户 %s 不在线，提醒 #%d 等待上线后推送", r.UserID, r.ID)
+		return service.ErrReminderNoRecipient
+	}
+	return nil
+}
+
+// addClient 登记在线连接，返回注销函数
+func (h *WSHandler) addClient(userID string, w *wsWriter) func() {
+	h.clientsMu.Lock()
+	defer h.clientsMu.Unlock()
+
+	if h.clients[userID] == nil {
+		h.clients[userID] = make(map[*wsWriter]struct{})
+	}
+	h.clients[userID][w] = struct{}{}
+
+	return func() {
+		h.clientsMu.Lock()
+		defer h.clientsMu.Unlock()
+		delete(h.clients[userID], w)
+		if len(h.clients[userID]) == 0 {
+			delete(h.clients, userID)
+		}
+	}
+}
+
+// SetSessionSummarizer 设置会话摘要维护（未设置时把完整历史发给 LLM）
+func (h *WSHandler) SetSessionSummarizer(summarizer *service.SessionSummarizer) {
+	h.summarizer = summarizer
+}
+
+// SetSessionTitler 设置会话标题自动生成（未设置时会话没有自动标题）
+func (h *WSHandler) SetSessionTitler(titler *service.SessionTitler) {
+	h.titler = titler
+}
+
+// SetSentenceTTS 开启/关闭句子级流式 TTS（音频以带序号的二进制帧推送）
+// 只对连接时声明支持序号帧的客户端生效（见 sequencedAudioQuery），其他客户端仍收到整段不带序号的音频
+func (h *WSHandler) SetSentenceTTS(enabled bool) {
+	h.sentenceTTS = enabled
+}
+
+// SetVADConfig 设置连续收音模式下的 VAD 参数
+func (h *WSHandler) SetVADConfig(cfg service.VADConfig) {
+	h.vadConfig = cfg
+}
+
+// sequencedAudioQuery 客户端连接时带上 ?audio_frames=seq 表示能解析带 4 字节序号的音频帧
+const sequencedAudioQuery = "audio_frames"
+
 // WSMessage WebSocket 消息结构
 type WSMessage struct {
-	Type string      `json:"type"` // "config", "interrupt"
+	Type string      `json:"type"` // "config", "interrupt", "text", "audio_start", "audio_end", "playback_end"
 	Data interface{} `json:"data,omitempty"`
 }
 
@@ -70,28 +177,80 @@ func (h *WSHandler) HandleWS(c *gin.Context) {
 	}
 	defer conn.Close()
 
+	// 连接级 context：连接断开时关闭仍在进行的流式识别
+	connCtx, connCancel := context.WithCancel(context.Background())
+	defer connCancel()
+
 	// 2. 获取/创建会话
 	sessionID := c.Query("session_id")
 	if sessionID == "" {
 		sessionID = fmt.Sprintf("sess_%d", time.Now().Unix())
 	}
-	h.sessionManager.GetOrCreateSession(sessionID)
+	if _, err := h.sessionManager.GetOrCreateSession(sessionID); err != nil {
+		log.Printf("[WS] 获取会话失败 (Session: %s): %v", sessionID, err)
+		conn.WriteJSON(map[string]interface{}{"type": "error", "error": "加载会话失败"})
+		return
+	}
 
-	log.Printf("[WS] 新连接建立 (Session: %s)", sessionID)
+	// 用户标识：提醒按用户推送到其所有在线连接
+	userID := c.Query("user_id")
+	if userID == "" {
+		userID = service.DefaultReminderUser
+	}
+
+	log.Printf("[WS] 新连接建立 (Session: %s, User: %s)", sessionID, userID)
+
+	// 所有写操作都经过 writer，避免 TTS 推送协程与主流程并发写连接
+	writer := &wsWriter{conn: conn, sequenced: c.Query(sequencedAudioQuery) == "seq"}
+	defer h.addClient(userID, writer)()
+
+	// 补发用户不在线期间到期的提醒
+	if h.reminders != nil {
+		h.reminders.Redeliver(userID)
+	}
 
 	// 3. 构建 Pipeline
 	// 注意：这里我们为每个连接创建一个 Pipeline 实例
+	llmProcessor := pipeline.NewLLMProcessor(h.llmService, h.sessionManager)
+	taskProcessor := pipeline.NewTaskProcessor(h.taskStore, h.sessionManager)
+	reminderProcessor := pipeline.NewReminderProcessor(h.reminders, userID, h.sessionManager)
+	commandProcessor := pipeline.NewCommandProcessor(h.knowledgeRepo, h.sessionManager)
+	historyProcessor := pipeline.NewHistoryProcessor(h.sessionManager)
+	if h.summarizer != nil {
+		llmProcessor.SetSessionSummarizer(h.summarizer)
+	}
+	if h.titler != nil {
+		llmProcessor.SetSessionTitler(h.titler)
+	}
+	if h.sentenceTTS && writer.sequenced {
+		llmProcessor.SetSentenceTTS(h.ttsService)
+		taskProcessor.SetSentenceTTS(h.ttsService)
+		reminderProcessor.SetSentenceTTS(h.ttsService)
+		commandProcessor.SetSentenceTTS(h.ttsService)
+		historyProcessor.SetSentenceTTS(h.ttsService)
+	}
 	pipe := pipeline.NewPipeline(
 		pipeline.NewSTTProcessor(h.sttService),
 		pipeline.NewIntentProcessor(h.intentService),
-		pipeline.NewLLMProcessor(h.llmService, h.sessionManager),
-		pipeline.NewKnowledgeProcessor(h.knowledgeOrganizer, h.db), // 知识整理 (异步)
+		commandProcessor,  // 清空对话 / 删除知识（语音确认）直接回复并短路
+		taskProcessor,     // 待办查询直接回答并短路
+		reminderProcessor, // 设置提醒直接确认并短路
+		historyProcessor,  // "我之前聊过什么关于…" 检索历史对话，交给 LLM 回答
+		pipeline.NewRetrievalProcessor(h.retrievalService, pipeline.DefaultRetrievalTopK),
+		llmProcessor,
+		pipeline.NewKnowledgeProcessor(h.knowledgeOrganizer, h.knowledgeRepo), // 知识整理 (异步)
 		// pipeline.NewTTSProcessor(h.ttsService), // 开发阶段禁用 TTS，节省资源
 	)
 
 	// 4. 循环读取
+	// 一轮对话进行中 = 流水线仍在执行，或已推送的回复音频客户端还没播放完。
+	// 客户端播放完音频后发送 playback_end，确认此前发送的所有音频帧都已播放；
+	// 不发送的客户端，插话检测会一直认为音频未播放完（只会多打断一次已结束的任务）
 	var (
 		currentCancel context.CancelFunc
+		turnRunning   bool
+		turnID        int
+		playedFrames  int // 客户端确认已播放完的音频帧数
 		mu            sync.Mutex
 	)
 
@@ -99,6 +258,8 @@ func (h *WSHandler) HandleWS(c *gin.Context) {
 	cancelCurrent := func() {
 		mu.Lock()
 		defer mu.Unlock()
+		turnRunning = false
+		playedFrames = writer.BinaryFrames() // 打断后客户端停止播放，已发送的音频不再计入
 		if currentCancel != nil {
 			currentCancel()
 			currentCancel = nil
@@ -106,6 +267,77 @@ func (h *WSHandler) HandleWS(c *gin.Context) {
 		}
 	}
 
+	// 辅助函数：当前是否有进行中的一轮（流水线执行中或音频尚未播放完）
+	isTurnActive := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return turnRunning || writer.BinaryFrames() > playedFrames
+	}
+
+	// 辅助函数：打断上一个任务，并在新的可取消上下文中异步执行 Pipeline
+	// 取消旧任务与登记新任务在同一把锁内完成：读循环和流式识别协程都会调用，不能漏掉任何一个 cancel
+	startTurn := func(run func(ctx context.Context)) {
+		mu.Lock()
+		if currentCancel != nil {
+			currentCancel()
+			log.Printf("[WS] 已触发打断，取消上一个任务")
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		currentCancel = cancel
+		turnRunning = true
+		turnID++
+		id := turnID
+		mu.Unlock()
+
+		// 异步执行 Pipeline (关键修复：必须是 go routine)
+		go func() {
+			run(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if id == turnID {
+				turnRunning = false
+			}
+		}()
+	}
+
+	// 流式语音输入：audio_start 之后的二进制帧是 PCM 分片，直到 audio_end
+	var stream *audioStream
+	defer func() {
+		if stream != nil {
+			stream.Abort()
+		}
+	}()
+
+	// 连续收音模式：客户端发送 {"type":"config","data":{"vad":true}} 后持续上传 PCM，
+	// 由服务端 VAD 切分语音，每句话触发一轮对话
+	var vad *service.VAD
+	handleVADEvents := func(events []service.VADEvent) {
+		for _, event := range events {
+			switch event.Type {
+			case service.VADSpeechStart:
+				// 一轮对话进行中（思考、生成或客户端仍在播放回复）用户开口：自动打断当前回复
+				if isTurnActive() {
+					log.Printf("[WS] 检测到用户插话，打断当前回复")
+					cancelCurrent()
+					sendJSON(writer, "state", "listening")
+				}
+			case service.VADSpeechEnd:
+				audioData := event.Audio
+				startTurn(func(ctx context.Context) {
+					handleAudio(ctx, writer, pipe, sessionID, audioData)
+				})
+			}
+		}
+	}
+
+	// 流式识别结束后，以拼接好的整句开始新一轮对话
+	onFinal := func(text string) {
+		startTurn(func(ctx context.Context) {
+			handleText(ctx, writer, pipe, sessionID, text, h.sessionManager)
+		})
+	}
+
 	for {
 		messageType, data, err := conn.ReadMessage()
 		if err != nil {
@@ -116,33 +348,29 @@ func (h *WSHandler) HandleWS(c *gin.Context) {
 		// 处理不同类型的消息
 		switch messageType {
 		case websocket.BinaryMessage:
-			// 1. 收到新语音 -> 立即打断上一个
-			cancelCurrent()
+			if stream != nil {
+				if err := stream.Write(data); err != nil {
+					log.Printf("[WS] 转发音频分片失败: %v", err)
+				}
+				continue
+			}
 
-			// 2. 创建新上下文
-			mu.Lock()
-			ctx, cancel := context.WithCancel(context.Background())
-			currentCancel = cancel
-			mu.Unlock()
-
-			// 3. 异步执行 Pipeline (关键修复：必须是 go routine)
-			go func(ctx context.Context, audioData []byte) {
-				// 确保任务结束时清理 cancel
-				defer func() {
-					mu.Lock()
-					// 只有当 currentCancel 还是自己时才置空（避免把后来者的 cancel 搞丢）
-					// 这里简单处理，依赖 GC，或者可以不置空，只要逻辑正确
-					mu.Unlock()
-				}()
-				
-				handleAudio(ctx, conn, pipe, sessionID, audioData)
-			}(ctx, data)
+			if vad != nil {
+				handleVADEvents(vad.Write(data))
+				continue
+			}
+
+			// 非流式：每个二进制帧是一段完整语音，收到新语音立即打断上一个
+			startTurn(func(ctx context.Context) {
+				handleAudio(ctx, writer, pipe, sessionID, data)
+			})
 
 		case websocket.TextMessage:
 			// 收到文本指令
 			var msg struct {
-				Type string `json:"type"`
-				Text string `json:"text"`
+				Type string          `json:"type"`
+				Text string          `json:"text"`
+				Data json.RawMessage `json:"data"`
 			}
 			if err := json.Unmarshal(data, &msg); err != nil {
 				// 兼容旧的字符串指令 (比如直接发送 "interrupt")
@@ -158,15 +386,72 @@ func (h *WSHandler) HandleWS(c *gin.Context) {
 			switch msg.Type {
 			case "interrupt":
 				cancelCurrent()
-				sendJSON(conn, "state", "idle")
-			case "text":
-				// 处理纯文本输入
-				cancelCurrent()
+				sendJSON(writer, "state", "idle")
+			case "playback_end":
+				// 客户端播放完目前收到的全部回复音频
 				mu.Lock()
-				ctx, cancel := context.WithCancel(context.Background())
-				currentCancel = cancel
+				playedFrames = writer.BinaryFrames()
 				mu.Unlock()
-				go handleText(ctx, conn, pipe, sessionID, msg.Text, h.sessionManager)
+			case "text":
+				// 处理纯文本输入
+				text := msg.Text
+				startTurn(func(ctx context.Context) {
+					handleText(ctx, writer, pipe, sessionID, text, h.sessionManager)
+				})
+			case "audio_start":
+				if stream != nil {
+					stream.Abort()
+				}
+				// 客户端可声明采集格式 {"sample_rate":48000,"channels":1}，缺省为 16kHz 单声道
+				format := audio.PCM16kMono
+				var opts struct {
+					SampleRate int `json:"sample_rate"`
+					Channels   int `json:"channels"`
+				}
+				if len(msg.Data) > 0 {
+					if err := json.Unmarshal(msg.Data, &opts); err != nil {
+						log.Printf("[WS] 无法解析音频格式: %v", err)
+					}
+				}
+				if opts.SampleRate > 0 {
+					format.SampleRate = opts.SampleRate
+				}
+				if opts.Channels > 0 {
+					format.Channels = opts.Channels
+				}
+				stream = openAudioStream(connCtx, h.sttService, writer, format, onFinal)
+				sendJSON(writer, "state", "listening")
+			case "config":
+				var cfg struct {
+					VAD *bool `json:"vad"`
+				}
+				if err := json.Unmarshal(msg.Data, &cfg); err != nil {
+					log.Printf("[WS] 无法解析配置: %v", err)
+					continue
+				}
+				if cfg.VAD != nil {
+					if *cfg.VAD {
+						vad = service.NewVAD(h.vadConfig)
+					} else {
+						vad = nil
+					}
+				}
+			case "audio_end":
+				// 连续收音模式下停止收音：把尚未结束的语音作为最后一句
+				if stream == nil && vad != nil {
+					handleVADEvents(vad.Flush())
+					continue
+				}
+				if stream == nil {
+					continue
+				}
+				// 不支持流式的 STT：把缓存的整段音频按原来的方式识别
+				if audioData := stream.Finish(); len(audioData) > 0 {
+					startTurn(func(ctx context.Context) {
+						handleAudio(ctx, writer, pipe, sessionID, audioData)
+					})
+				}
+				stream = nil
 			}
 			log.Printf("[WS] 收到指令: %+v", msg)
 		}
@@ -174,11 +459,13 @@ func (h *WSHandler) HandleWS(c *gin.Context) {
 }
 
 // handleText 处理纯文本输入
-func handleText(ctx context.Context, conn *websocket.Conn, pipe *pipeline.Pipeline, sessionID, text string, sm *service.SessionManager) {
+func handleText(ctx context.Context, conn *wsWriter, pipe *pipeline.Pipeline, sessionID, text string, sm *service.SessionManager) {
 	sendJSON(conn, "state", "processing")
 
 	pCtx := pipeline.NewPipelineContext(ctx, sessionID)
 	pCtx.Transcript = text // 直接设置文本，跳过 STT
+	pCtx.Events = newEventSink(ctx, conn)
+	pCtx.AudioSink = newAudioSink(ctx, conn)
 
 	// 我们需要一个不含 STT 的 Pipeline，或者让 STTProcessor 发现有文本时自动跳过
 	// 为了简单起见，我们直接执行现有的 pipe，并在执行前确保 Transcript 已存在
@@ -196,19 +483,22 @@ func handleText(ctx context.Context, conn *websocket.Conn, pipe *pipeline.Pipeli
 	if pCtx.LLMReply != "" {
 		log.Printf("[WS] AI 回复 (Session: %s): %s", sessionID, pCtx.LLMReply)
 		sendJSON(conn, "llm_reply", pCtx.LLMReply)
+		sendSources(conn, pCtx)
 	}
 
 	sendJSON(conn, "state", "idle")
 }
 
 // handleAudio 处理音频输入
-func handleAudio(ctx context.Context, conn *websocket.Conn, pipe *pipeline.Pipeline, sessionID string, audioData []byte) {
+func handleAudio(ctx context.Context, conn *wsWriter, pipe *pipeline.Pipeline, sessionID string, audioData []byte) {
 	// 通知客户端：收到音频，开始思考
 	sendJSON(conn, "state", "processing")
 
 	// 创建 Pipeline 上下文 (使用传入的可取消 Context)
 	pCtx := pipeline.NewPipelineContext(ctx, sessionID)
 	pCtx.InputAudio = audioData
+	pCtx.Events = newEventSink(ctx, conn)
+	pCtx.AudioSink = newAudioSink(ctx, conn)
 
 	// 执行流水线
 	if err := pipe.Execute(pCtx); err != nil {
@@ -228,14 +518,14 @@ func handleAudio(ctx context.Context, conn *websocket.Conn, pipe *pipeline.Pipel
 		return
 	}
 
-	// 发送 STT 结果
+	// STT 结果已由 STTProcessor 通过 stt_final 事件实时推送
 	log.Printf("[WS] 用户输入 (Session: %s): %s", sessionID, pCtx.Transcript)
-	sendJSON(conn, "stt_final", pCtx.Transcript)
 
 	// 发送 LLM 回复文本 (替代 TTS)
 	if pCtx.LLMReply != "" {
 		log.Printf("[WS] AI 回复 (Session: %s): %s", sessionID, pCtx.LLMReply)
 		sendJSON(conn, "llm_reply", pCtx.LLMReply)
+		sendSources(conn, pCtx)
 	}
 
 	// 发送 TTS 音频 (如果有)
@@ -245,7 +535,12 @@ func handleAudio(ctx context.Context, conn *websocket.Conn, pipe *pipeline.Pipel
 			return
 		}
 		sendJSON(conn, "state", "speaking")
-		if err := conn.WriteMessage(websocket.BinaryMessage, pCtx.OutputAudio); err != nil {
+		// 没有声明支持序号帧的旧客户端收到原格式的整段音频
+		frame := pCtx.OutputAudio
+		if conn.sequenced {
+			frame = encodeAudioFrame(0, frame)
+		}
+		if err := conn.WriteBinary(frame); err != nil {
 			log.Printf("[WS] 发送音频失败: %v", err)
 		}
 	}
@@ -253,8 +548,86 @@ func handleAudio(ctx context.Context, conn *websocket.Conn, pipe *pipeline.Pipel
 	sendJSON(conn, "state", "idle")
 }
 
+// newEventSink 把流水线事件转发为 JSON 消息（llm_delta、llm_done 等）
+// 写操作经过 wsWriter 串行化；任务被打断后丢弃残留事件，避免旧回复混入新一轮对话
+func newEventSink(ctx context.Context, conn *wsWriter) pipeline.EventSink {
+	return pipeline.EventSinkFunc(func(event pipeline.Event) {
+		if ctx.Err() != nil {
+			return
+		}
+		if event.Data != nil {
+			sendJSON(conn, event.Type, event.Data)
+		} else {
+			sendJSON(conn, event.Type, event.Text)
+		}
+	})
+}
+
+// newAudioSink 把句子级 TTS 分片写成二进制帧：前 4 字节为大端序号，其后为音频数据
+// 首个分片发出前通知客户端进入 speaking 状态；任务被打断后不再发送残留音频
+func newAudioSink(ctx context.Context, conn *wsWriter) pipeline.AudioSink {
+	speaking := false
+	return func(chunk pipeline.AudioChunk) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !speaking {
+			speaking = true
+			sendJSON(conn, "state", "speaking")
+		}
+		return conn.WriteBinary(encodeAudioFrame(chunk.Seq, chunk.Audio))
+	}
+}
+
+// encodeAudioFrame 编码带序号的音频帧
+func encodeAudioFrame(seq int, audio []byte) []byte {
+	frame := make([]byte, 4+len(audio))
+	binary.BigEndian.PutUint32(frame, uint32(seq))
+	copy(frame[4:], audio)
+	return frame
+}
+
+// wsWriter 串行化 WebSocket 写操作（gorilla/websocket 不支持并发写）
+// 同时记录已发送的音频帧数，用于判断客户端是否还在播放回复
+type wsWriter struct {
+	conn      *websocket.Conn
+	sequenced bool // 客户端能解析带序号的音频帧（连接时声明，之后不变）
+	mu        sync.Mutex
+	frames    int
+}
+
+// WriteJSON 写入 JSON 文本帧
+func (w *wsWriter) WriteJSON(v interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn.WriteJSON(v)
+}
+
+// BinaryFrames 返回已发送的二进制（音频）帧数
+func (w *wsWriter) BinaryFrames() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.frames
+}
+
+// WriteBinary 写入二进制帧
+func (w *wsWriter) WriteBinary(data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.frames++
+	return w.conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+// sendSources 发送本轮回复引用的知识来源（没有检索结果时不发送）
+func sendSources(conn *wsWriter, pCtx *pipeline.PipelineContext) {
+	if len(pCtx.Retrieved) == 0 {
+		return
+	}
+	sendJSON(conn, "sources", pipeline.BuildCitations(pCtx.Retrieved))
+}
+
 // sendJSON 发送 JSON 消息辅助函数
-func sendJSON(conn *websocket.Conn, msgType string, payload interface{}) {
+func sendJSON(conn *wsWriter, msgType string, payload interface{}) {
 	msg := map[string]interface{}{
 		"type": msgType,
 	}
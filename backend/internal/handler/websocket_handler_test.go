@@ -1,11 +1,16 @@
 package handler
 
 import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
+	"voice-memory/internal/audio"
 	"voice-memory/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -15,14 +20,46 @@ import (
 // MockSTTService 模拟 STT
 type MockSTTService struct{}
 
-func (m *MockSTTService) Recognize(req *service.RecognizeRequest) ([]string, error) {
+func (m *MockSTTService) Recognize(ctx context.Context, req *service.RecognizeRequest) ([]string, error) {
 	return []string{"hello"}, nil
 }
 
+// MockStreamingSTTService 模拟流式 STT：每收到一个分片返回一次部分结果，
+// 结束时把一句话切成两个最终分段返回（与 sherpa 在句中停顿时的行为一致）
+type MockStreamingSTTService struct {
+	MockSTTService
+}
+
+func (m *MockStreamingSTTService) NewStream(ctx context.Context) (service.STTStream, error) {
+	return &mockSTTStream{results: make(chan service.STTResult, 8)}, nil
+}
+
+type mockSTTStream struct {
+	results chan service.STTResult
+	chunks  int
+}
+
+func (s *mockSTTStream) Write(pcm []byte) error {
+	s.chunks++
+	s.results <- service.STTResult{Text: strings.Repeat("你", s.chunks)}
+	return nil
+}
+
+func (s *mockSTTStream) Results() <-chan service.STTResult {
+	return s.results
+}
+
+func (s *mockSTTStream) Close() error {
+	s.results <- service.STTResult{Text: "你好", Segment: 0, IsFinal: true}
+	s.results <- service.STTResult{Text: "世界", Segment: 1, IsFinal: true}
+	close(s.results)
+	return nil
+}
+
 // MockLLMService 模拟 LLM
 type MockLLMService struct{}
 
-func (m *MockLLMService) SendMessage(req service.ChatRequest) (*service.ChatResponse, error) {
+func (m *MockLLMService) SendMessage(ctx context.Context, req service.ChatRequest) (*service.ChatResponse, error) {
 	return &service.ChatResponse{
 		Type: "message",
 		Content: []service.Content{
@@ -31,7 +68,7 @@ func (m *MockLLMService) SendMessage(req service.ChatRequest) (*service.ChatResp
 	}, nil
 }
 
-func (m *MockLLMService) SendMessageStream(req service.ChatRequest, callback func(service.StreamChunk)) error {
+func (m *MockLLMService) SendMessageStream(ctx context.Context, req service.ChatRequest, callback func(service.StreamChunk)) error {
 	callback(service.StreamChunk{Delta: "world"})
 	callback(service.StreamChunk{Done: true})
 	return nil
@@ -40,11 +77,11 @@ func (m *MockLLMService) SendMessageStream(req service.ChatRequest, callback fun
 // MockTTSService 模拟 TTS
 type MockTTSService struct{}
 
-func (m *MockTTSService) Synthesize(options service.TTSOptions) ([]byte, error) {
+func (m *MockTTSService) Synthesize(ctx context.Context, options service.TTSOptions) ([]byte, error) {
 	return []byte{1, 2, 3, 4}, nil
 }
 
-func (m *MockTTSService) SynthesizeToFile(options service.TTSOptions) (string, error) {
+func (m *MockTTSService) SynthesizeToFile(ctx context.Context, options service.TTSOptions) (string, error) {
 	return "mock_audio.mp3", nil
 }
 
@@ -55,13 +92,16 @@ func (m *MockTTSService) ServeAudio(filename string) ([]byte, string, error) {
 // MockIntentService 模拟意图
 type MockIntentService struct{}
 
-func (m *MockIntentService) Recognize(text string) service.IntentResult {
+func (m *MockIntentService) Recognize(ctx context.Context, text string) service.IntentResult {
 	return service.IntentResult{Intent: service.IntentChat}
 }
 
 func setupWSServer(t *testing.T) (*httptest.Server, *service.SessionManager) {
+	return setupWSServerWithSTT(t, &MockSTTService{})
+}
+
+func setupWSServerWithSTT(t *testing.T, stt service.STTService) (*httptest.Server, *service.SessionManager) {
 	// 创建 Mock 服务
-	stt := &MockSTTService{}
 	llm := &MockLLMService{}
 	tts := &MockTTSService{}
 	intent := &MockIntentService{}
@@ -108,9 +148,11 @@ func TestWSHandler_Connection(t *testing.T) {
 	}
 
 	// 2. 接收响应循环
-	// 我们期望收到一系列状态更新：processing -> stt_final -> llm_reply -> idle
+	// 我们期望收到一系列状态更新：processing -> stt_final -> llm_delta... -> llm_done -> llm_reply -> idle
 	receivedStates := make(map[string]bool)
 	receivedLLM := false
+	var deltas strings.Builder
+	receivedDone := false
 	timeout := time.After(2 * time.Second)
 
 	for {
@@ -138,6 +180,17 @@ func TestWSHandler_Connection(t *testing.T) {
 					t.Errorf("STT 结果错误: %s", text)
 				}
 				t.Logf("收到 STT: %s", text)
+			} else if msgType == "llm_delta" {
+				if receivedDone {
+					t.Errorf("llm_done 之后不应再收到 llm_delta")
+				}
+				text, _ := msg["text"].(string)
+				deltas.WriteString(text)
+			} else if msgType == "llm_done" {
+				receivedDone = true
+				if deltas.String() != "world" {
+					t.Errorf("增量拼接结果错误: %s", deltas.String())
+				}
 			} else if msgType == "llm_reply" {
 				text, _ := msg["text"].(string)
 				if text != "world" {
@@ -148,9 +201,520 @@ func TestWSHandler_Connection(t *testing.T) {
 			}
 			
 			// 如果收到了 idle 且收到了 LLM 回复，说明一轮对话结束
-			if receivedStates["idle"] && receivedLLM {
+			if receivedStates["idle"] && receivedLLM && receivedDone {
 				return // 测试通过
 			}
 		}
 	}
-}
\ No newline at end of file
+}
+func TestWSHandler_StreamingSTT(t *testing.T) {
+	ts, sm := setupWSServerWithSTT(t, &MockStreamingSTTService{})
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?session_id=sess_stream"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("连接 WebSocket 失败: %v", err)
+	}
+	defer conn.Close()
+
+	// audio_start -> 两个 PCM 分片 -> audio_end
+	conn.WriteJSON(map[string]string{"type": "audio_start"})
+	conn.WriteMessage(websocket.BinaryMessage, []byte{0, 0, 0, 0})
+	conn.WriteMessage(websocket.BinaryMessage, []byte{0, 0, 0, 0})
+	conn.WriteJSON(map[string]string{"type": "audio_end"})
+
+	var partials []string
+	receivedFinal := false
+	replies := 0
+	timeout := time.After(2 * time.Second)
+
+	for {
+		select {
+		case <-timeout:
+			t.Fatalf("测试超时，partials=%v final=%v", partials, receivedFinal)
+		default:
+			var msg map[string]interface{}
+			if err := conn.ReadJSON(&msg); err != nil {
+				if websocket.IsUnexpectedCloseError(err) {
+					t.Fatalf("连接意外关闭: %v", err)
+				}
+				continue
+			}
+
+			text, _ := msg["text"].(string)
+			switch msg["type"] {
+			case "stt_partial":
+				if receivedFinal {
+					t.Errorf("stt_final 之后不应再收到 stt_partial")
+				}
+				partials = append(partials, text)
+			case "stt_final":
+				if receivedFinal {
+					t.Errorf("多个分段应合并为一个 stt_final")
+				}
+				receivedFinal = true
+				if text != "你好世界" {
+					t.Errorf("STT 最终结果错误: %s", text)
+				}
+			case "llm_reply":
+				if !receivedFinal {
+					t.Fatalf("对话应在最终结果之后才开始")
+				}
+				if len(partials) < 2 || partials[0] != "你" || partials[1] != "你你" {
+					t.Errorf("部分结果错误: %v", partials)
+				}
+				replies++
+			case "state":
+				// 一句话只应有一轮对话，且不被第二个分段打断
+				if msg["status"] == "idle" && replies > 0 {
+					if replies != 1 {
+						t.Errorf("期望 1 轮对话, 实际 %d", replies)
+					}
+					for _, m := range sm.GetMessages("sess_stream") {
+						if text, _ := m.Content.(string); strings.Contains(text, "[interrupted]") {
+							t.Errorf("回复不应被打断: %+v", m)
+						}
+					}
+					return // 测试通过
+				}
+			}
+		}
+	}
+}
+
+// recordingSTTService 记录流式识别收到的全部 PCM
+type recordingSTTService struct {
+	MockSTTService
+	stream *recordingSTTStream
+}
+
+func (m *recordingSTTService) NewStream(ctx context.Context) (service.STTStream, error) {
+	m.stream = &recordingSTTStream{results: make(chan service.STTResult)}
+	return m.stream, nil
+}
+
+type recordingSTTStream struct {
+	results chan service.STTResult
+	pcm     []byte
+}
+
+func (s *recordingSTTStream) Write(pcm []byte) error {
+	s.pcm = append(s.pcm, pcm...)
+	return nil
+}
+
+func (s *recordingSTTStream) Results() <-chan service.STTResult {
+	return s.results
+}
+
+func (s *recordingSTTStream) Close() error {
+	close(s.results)
+	return nil
+}
+
+func TestAudioStream_ResamplesDeclaredRate(t *testing.T) {
+	stt := &recordingSTTService{}
+	format := audio.Format{SampleRate: 48000, Channels: 1, BitsPerSample: 16}
+	stream := openAudioStream(context.Background(), stt, &wsWriter{}, format, func(string) {})
+	defer stream.Abort()
+
+	// 1 秒 48kHz PCM，按浏览器 ScriptProcessor 的 4096 采样一片上传，最后一片带半个采样
+	pcm := make([]byte, 48000*2+1)
+	for i := 0; i < 48000; i++ {
+		v := 0.3 * math.Sin(2*math.Pi*200*float64(i)/48000)
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(v*32767)))
+	}
+	for i := 0; i < len(pcm); i += 8192 {
+		end := i + 8192
+		if end > len(pcm) {
+			end = len(pcm)
+		}
+		if err := stream.Write(pcm[i:end]); err != nil {
+			t.Fatalf("写入失败: %v", err)
+		}
+	}
+
+	got := len(stt.stream.pcm) / 2
+	if got < 15990 || got > 16000 {
+		t.Errorf("期望约 16000 个 16kHz 采样, 实际 %d", got)
+	}
+}
+
+// genTonePCM 生成 16kHz 16bit 正弦波 PCM（模拟说话）
+func genTonePCM(ms int) []byte {
+	n := 16000 * ms / 1000
+	pcm := make([]byte, n*2)
+	for i := 0; i < n; i++ {
+		v := 0.3 * math.Sin(2*math.Pi*200*float64(i)/16000)
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(v*32767)))
+	}
+	return pcm
+}
+
+// sendPCM 按 ScriptProcessor 的节奏分片发送 PCM
+func sendPCM(t *testing.T, conn *websocket.Conn, pcm []byte) {
+	for i := 0; i < len(pcm); i += 8192 {
+		end := i + 8192
+		if end > len(pcm) {
+			end = len(pcm)
+		}
+		if err := conn.WriteMessage(websocket.BinaryMessage, pcm[i:end]); err != nil {
+			t.Fatalf("发送音频失败: %v", err)
+		}
+	}
+}
+
+func TestWSHandler_VADContinuousListening(t *testing.T) {
+	ts, _ := setupWSServer(t)
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("连接 WebSocket 失败: %v", err)
+	}
+	defer conn.Close()
+
+	conn.WriteJSON(map[string]interface{}{"type": "config", "data": map[string]bool{"vad": true}})
+
+	// 一段语音 + 足够长的静音，服务端应自行判定这句话结束
+	sendPCM(t, conn, genTonePCM(800))
+	sendPCM(t, conn, make([]byte, 16000*2))
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case <-timeout:
+			t.Fatal("测试超时，VAD 未触发对话")
+		default:
+			var msg map[string]interface{}
+			if err := conn.ReadJSON(&msg); err != nil {
+				if websocket.IsUnexpectedCloseError(err) {
+					t.Fatalf("连接意外关闭: %v", err)
+				}
+				continue
+			}
+			if msg["type"] == "llm_reply" {
+				return // 测试通过
+			}
+		}
+	}
+}
+
+// BlockingLLMService 先输出一句话，然后一直生成直到被打断
+type BlockingLLMService struct {
+	MockLLMService
+	interrupted chan struct{}
+}
+
+func (m *BlockingLLMService) SendMessageStream(ctx context.Context, req service.ChatRequest, callback func(service.StreamChunk)) error {
+	callback(service.StreamChunk{Delta: "好的，我来详细说说。"})
+	<-ctx.Done()
+	close(m.interrupted)
+	return ctx.Err()
+}
+
+func TestWSHandler_VADBargeIn(t *testing.T) {
+	db, _ := service.NewDatabase(t.TempDir())
+	sm := service.NewSessionManagerWithDB(db)
+	llm := &BlockingLLMService{interrupted: make(chan struct{})}
+	wsHandler := NewWSHandler(sm, &MockSTTService{}, llm, &MockTTSService{}, &MockIntentService{}, service.NewKnowledgeOrganizer(llm), db)
+	wsHandler.SetSentenceTTS(true)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/ws", wsHandler.HandleWS)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws?audio_frames=seq", nil)
+	if err != nil {
+		t.Fatalf("连接 WebSocket 失败: %v", err)
+	}
+	defer conn.Close()
+
+	conn.WriteJSON(map[string]interface{}{"type": "config", "data": map[string]bool{"vad": true}})
+	conn.WriteJSON(map[string]string{"type": "text", "text": "介绍一下你自己"})
+
+	// 等待助手开始说话（收到第一个音频分片）
+	timeout := time.After(2 * time.Second)
+	for speaking := false; !speaking; {
+		select {
+		case <-timeout:
+			t.Fatal("等待助手说话超时")
+		default:
+			msgType, _, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("读取消息失败: %v", err)
+			}
+			speaking = msgType == websocket.BinaryMessage
+		}
+	}
+
+	// 用户插话
+	sendPCM(t, conn, genTonePCM(500))
+
+	select {
+	case <-llm.interrupted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("用户插话后应自动打断正在生成的回复")
+	}
+}
+
+func TestWSHandler_VADBargeInWithoutTTS(t *testing.T) {
+	db, _ := service.NewDatabase(t.TempDir())
+	sm := service.NewSessionManagerWithDB(db)
+	llm := &BlockingLLMService{interrupted: make(chan struct{})}
+	wsHandler := NewWSHandler(sm, &MockSTTService{}, llm, &MockTTSService{}, &MockIntentService{}, service.NewKnowledgeOrganizer(llm), db)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/ws", wsHandler.HandleWS)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("连接 WebSocket 失败: %v", err)
+	}
+	defer conn.Close()
+
+	conn.WriteJSON(map[string]interface{}{"type": "config", "data": map[string]bool{"vad": true}})
+	conn.WriteJSON(map[string]string{"type": "text", "text": "介绍一下你自己"})
+
+	// 不开 TTS 时状态一直是 processing，收到第一段回复文本后插话
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var msg map[string]interface{}
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("等待回复超时: %v", err)
+		}
+		if msg["type"] == "llm_delta" {
+			break
+		}
+	}
+
+	sendPCM(t, conn, genTonePCM(500))
+
+	select {
+	case <-llm.interrupted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("生成回复期间用户插话应打断")
+	}
+}
+
+func TestWSHandler_SentenceTTSRequiresSequencedClient(t *testing.T) {
+	db, _ := service.NewDatabase(t.TempDir())
+	sm := service.NewSessionManagerWithDB(db)
+	llm := &MockLLMService{}
+	wsHandler := NewWSHandler(sm, &MockSTTService{}, llm, &MockTTSService{}, &MockIntentService{}, service.NewKnowledgeOrganizer(llm), db)
+	wsHandler.SetSentenceTTS(true)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/ws", wsHandler.HandleWS)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	// 没有声明支持序号帧的旧客户端不会收到句子级音频
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("连接 WebSocket 失败: %v", err)
+	}
+	defer conn.Close()
+
+	conn.WriteJSON(map[string]string{"type": "text", "text": "你好"})
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for replied := false; ; {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("等待回复失败: %v", err)
+		}
+		if msgType == websocket.BinaryMessage {
+			t.Fatalf("旧客户端不应收到带序号的音频帧: %v", data)
+		}
+		var msg map[string]interface{}
+		json.Unmarshal(data, &msg)
+		replied = replied || msg["type"] == "llm_reply"
+		if replied && msg["status"] == "idle" {
+			return
+		}
+	}
+}
+
+func TestWSHandler_VADBargeInDuringPlayback(t *testing.T) {
+	db, _ := service.NewDatabase(t.TempDir())
+	sm := service.NewSessionManagerWithDB(db)
+	llm := &MockLLMService{}
+	wsHandler := NewWSHandler(sm, &MockSTTService{}, llm, &MockTTSService{}, &MockIntentService{}, service.NewKnowledgeOrganizer(llm), db)
+	wsHandler.SetSentenceTTS(true)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/ws", wsHandler.HandleWS)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws?audio_frames=seq", nil)
+	if err != nil {
+		t.Fatalf("连接 WebSocket 失败: %v", err)
+	}
+	defer conn.Close()
+
+	// readStates 读取状态消息直到 want，返回途中收到的所有状态
+	readStates := func(want string) []string {
+		t.Helper()
+		var states []string
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("等待 %s 状态失败: %v (已收到 %v)", want, err, states)
+			}
+			if msgType != websocket.TextMessage {
+				continue
+			}
+			var msg map[string]interface{}
+			json.Unmarshal(data, &msg)
+			if msg["type"] != "state" {
+				continue
+			}
+			status, _ := msg["status"].(string)
+			states = append(states, status)
+			if status == want {
+				return states
+			}
+		}
+	}
+	contains := func(states []string, state string) bool {
+		for _, s := range states {
+			if s == state {
+				return true
+			}
+		}
+		return false
+	}
+
+	conn.WriteJSON(map[string]interface{}{"type": "config", "data": map[string]bool{"vad": true}})
+	conn.WriteJSON(map[string]string{"type": "text", "text": "你好"})
+	readStates("idle") // 服务端已发完音频，客户端仍在播放
+
+	// 播放期间开口：视为插话
+	sendPCM(t, conn, genTonePCM(500))
+	sendPCM(t, conn, make([]byte, 16000*2))
+	if states := readStates("processing"); !contains(states, "listening") {
+		t.Errorf("客户端播放回复时开口应触发插话: %v", states)
+	}
+	readStates("idle")
+
+	// 客户端报告播放完毕后开口：正常的新一轮，不是插话
+	conn.WriteJSON(map[string]string{"type": "playback_end"})
+	sendPCM(t, conn, genTonePCM(500))
+	sendPCM(t, conn, make([]byte, 16000*2))
+	if states := readStates("processing"); contains(states, "listening") {
+		t.Errorf("播放结束后开口不应视为插话: %v", states)
+	}
+}
+
+func TestWSHandler_Reminders(t *testing.T) {
+	db, _ := service.NewDatabase(t.TempDir())
+	defer db.Close()
+	sm := service.NewSessionManagerWithDB(db)
+	llm := &MockLLMService{}
+
+	scheduler := service.NewReminderScheduler(db)
+	wsHandler := NewWSHandler(sm, &MockSTTService{}, llm, &MockTTSService{}, service.NewIntentRecognizer(),
+		service.NewKnowledgeOrganizer(llm), db)
+	wsHandler.SetReminderScheduler(scheduler)
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/ws", wsHandler.HandleWS)
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	dial := func(userID string) *websocket.Conn {
+		wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?user_id=" + userID
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		if err != nil {
+			t.Fatalf("连接 WebSocket 失败: %v", err)
+		}
+		return conn
+	}
+	// readUntil 读取消息直到出现指定类型
+	readUntil := func(conn *websocket.Conn, msgType string) map[string]interface{} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for {
+			var msg map[string]interface{}
+			if err := conn.ReadJSON(&msg); err != nil {
+				t.Fatalf("等待 %s 消息失败: %v", msgType, err)
+			}
+			if msg["type"] == msgType {
+				return msg
+			}
+		}
+	}
+
+	alice := dial("alice")
+	defer alice.Close()
+	bob := dial("bob")
+	defer bob.Close()
+
+	t.Run("语音指令创建提醒", func(t *testing.T) {
+		alice.WriteJSON(map[string]string{"type": "text", "text": "两小时后提醒我喝水"})
+		msg := readUntil(alice, "llm_reply")
+		if text, _ := msg["text"].(string); !strings.HasPrefix(text, "好的，") || !strings.HasSuffix(text, "提醒你喝水。") {
+			t.Errorf("确认回复错误: %s", text)
+		}
+
+		pending, _ := scheduler.List(service.ReminderFilter{UserID: "alice", Status: service.ReminderPending})
+		if len(pending) != 1 || pending[0].Text != "喝水" {
+			t.Errorf("应为 alice 创建提醒: %+v", pending)
+		}
+	})
+
+	t.Run("触发时推送给该用户的连接", func(t *testing.T) {
+		if _, err := scheduler.Create("alice", "", "开会", time.Now().Add(-time.Second)); err != nil {
+			t.Fatalf("创建失败: %v", err)
+		}
+		msg := readUntil(alice, "reminder")
+		data, _ := msg["data"].(map[string]interface{})
+		if data["text"] != "开会" || data["status"] != service.ReminderFired {
+			t.Errorf("推送内容错误: %+v", msg)
+		}
+
+		bob.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		var other map[string]interface{}
+		if err := bob.ReadJSON(&other); err == nil {
+			t.Errorf("其他用户不应收到提醒: %+v", other)
+		}
+	})
+
+	t.Run("不在线时到期的提醒在连接后补发", func(t *testing.T) {
+		r, err := scheduler.Create("carol", "", "取快递", time.Now().Add(-time.Second))
+		if err != nil {
+			t.Fatalf("创建失败: %v", err)
+		}
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			got, _ := scheduler.Get(r.ID)
+			if got.Status == service.ReminderDelivering {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("用户不在线时提醒应等待投递, 实际 %s", got.Status)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		carol := dial("carol")
+		defer carol.Close()
+		msg := readUntil(carol, "reminder")
+		if data, _ := msg["data"].(map[string]interface{}); data["text"] != "取快递" {
+			t.Errorf("补发内容错误: %+v", msg)
+		}
+	})
+}
@@ -0,0 +1,268 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"voice-memory/internal/service"
+)
+
+// deleteConfirmTimeout 删除确认的有效期，超时后用户的回答按普通对话处理
+const deleteConfirmTimeout = time.Minute
+
+// 删除确认时的肯定/否定说法（先判断否定，"不要删" 不会被当成确认）
+// 否定只要出现就放弃删除；肯定必须整句都由这些词组成，"嗯，明天天气怎么样" 不算确认
+var (
+	confirmCancelWords = []string{"取消", "不要", "不用", "算了", "不删", "别删", "不是", "不对"}
+	confirmWords       = []string{"确认", "确定", "是的", "对的", "好的", "删吧", "删除吧", "删掉吧", "嗯", "可以", "对", "好"}
+)
+
+// confirmPunctuation 判断整句确认前去掉的标点和空白
+const confirmPunctuation = " \t\n，。！？、,.!?~～…"
+
+// deleteVerbs 明确的删除说法。关键词识别只凭 "不要" 也会判为删除（如 "我不要吃辣"），
+// 所以 LLM 没有提取出删除目标时，整句必须含有这些词才执行删除，否则按普通对话处理
+var deleteVerbs = []string{"删除", "删掉", "删了", "移除", "去掉"}
+
+// 删除目标的匹配门槛：检索结果的向量相似度或关键词覆盖率（目标的查询词出现在知识中的比例）
+// 至少达到其一才请用户确认，避免把名次第一但并不相关的知识当成要删的那条
+const (
+	deleteMinSimilarity = 0.75
+	deleteMinCoverage   = 0.5
+	deleteCandidates    = 3 // 从前几条检索结果中取第一条达到门槛的
+)
+
+// deleteTargetFillers 从 "帮我把那条周报笔记删掉" 中提取删除目标时去掉的词
+var deleteTargetFillers = []string{
+	"帮我", "请", "给我", "把", "将", "删除", "删掉", "移除", "去掉", "不要",
+	"那条", "这条", "一下", "吧", "了",
+}
+
+// pendingDelete 等待用户确认的删除
+type pendingDelete struct {
+	knowledgeID string
+	title       string
+	expiresAt   time.Time
+}
+
+// CommandProcessor 指令处理器
+// 位于 Intent 之后：执行清空对话和删除知识两类指令，直接回复确认并短路，不再调用 LLM。
+// 删除先检索要删的知识并请用户语音确认，下一轮说 "确认" 才真正删除（数据库 + 向量库）。
+// 确认状态保存在处理器中，每个连接各自创建处理器
+type CommandProcessor struct {
+	knowledgeRepo  *service.KnowledgeRepository
+	sessionManager *service.SessionManager
+	ttsService     service.TTSService // 可选：设置后把回复合成语音通过 ctx.AudioSink 推送
+	now            func() time.Time
+
+	mu      sync.Mutex // 保护 pending：被打断的一轮和新的一轮可能同时执行
+	pending *pendingDelete
+}
+
+// NewCommandProcessor 创建指令处理器
+// knowledgeRepo 为 nil 时不处理删除指令，按普通对话交给 LLM
+func NewCommandProcessor(knowledgeRepo *service.KnowledgeRepository, sessionManager *service.SessionManager) *CommandProcessor {
+	return &CommandProcessor{
+		knowledgeRepo:  knowledgeRepo,
+		sessionManager: sessionManager,
+		now:            time.Now,
+	}
+}
+
+// SetSentenceTTS 开启语音播报（与 LLMProcessor 共用句子级 TTS 的音频通道）
+func (p *CommandProcessor) SetSentenceTTS(ttsService service.TTSService) {
+	p.ttsService = ttsService
+}
+
+func (p *CommandProcessor) Name() string {
+	return "Command"
+}
+
+func (p *CommandProcessor) Process(ctx *PipelineContext) (bool, error) {
+	if pending := p.takePending(); pending != nil {
+		if p.now().Before(pending.expiresAt) {
+			switch {
+			case containsAny(ctx.Transcript, confirmCancelWords):
+				log.Printf("[Command] 用户取消删除: %s", pending.knowledgeID)
+				replyDirectly(ctx, p.sessionManager, p.ttsService, "好的，不删除了。")
+				return false, nil
+			case isConfirmation(ctx.Transcript):
+				return false, p.confirmDelete(ctx, pending)
+			}
+		}
+		// 超时或答非所问：放弃这次删除，按新的一句话处理
+		log.Printf("[Command] 未确认删除，已放弃: %s", pending.knowledgeID)
+	}
+
+	switch ctx.Intent.Intent {
+	case service.IntentClear:
+		return false, p.clear(ctx)
+	case service.IntentDelete:
+		if p.knowledgeRepo == nil {
+			return true, nil
+		}
+		if ctx.Intent.Entities[service.SlotTarget] == "" && !containsAny(ctx.Transcript, deleteVerbs) {
+			log.Printf("[Command] 没有明确的删除说法，按普通对话处理: %s", ctx.Transcript)
+			return true, nil
+		}
+		return false, p.requestDelete(ctx)
+	}
+	return true, nil
+}
+
+// takePending 取出待确认的删除，每次删除确认只被一轮对话消费
+func (p *CommandProcessor) takePending() *pendingDelete {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pending := p.pending
+	p.pending = nil
+	return pending
+}
+
+// clear 清空当前会话的对话上下文（消息存档保留）
+// 回复不写入会话，清空后的会话从下一句重新开始
+func (p *CommandProcessor) clear(ctx *PipelineContext) error {
+	if p.sessionManager != nil {
+		p.sessionManager.ClearSession(ctx.SessionID)
+	}
+	log.Printf("[Command] 已清空会话: %s", ctx.SessionID)
+	speakDirectly(ctx, p.ttsService, "好的，已清空当前对话，我们重新开始吧。")
+	return nil
+}
+
+// requestDelete 检索要删除的知识并请用户确认
+func (p *CommandProcessor) requestDelete(ctx *PipelineContext) error {
+	target := ctx.Intent.Entities[service.SlotTarget]
+	if target == "" {
+		target = deleteTarget(ctx.Transcript)
+	}
+	if target == "" {
+		replyDirectly(ctx, p.sessionManager, p.ttsService, "要删除哪条知识呢？可以说 \"删除关于周报的笔记\"。")
+		return nil
+	}
+
+	hits, err := p.knowledgeRepo.Search(ctx.Context(), target, deleteCandidates, nil)
+	if err != nil {
+		return fmt.Errorf("search delete target failed: %w", err)
+	}
+	var hit *service.SearchHit
+	for i := range hits {
+		if matchesDeleteTarget(&hits[i], target) {
+			hit = &hits[i]
+			break
+		}
+	}
+	if hit == nil {
+		replyDirectly(ctx, p.sessionManager, p.ttsService, fmt.Sprintf("没有找到和 \"%s\" 相关的知识。", target))
+		return nil
+	}
+
+	title := knowledgeTitle(&hit.Knowledge)
+	p.mu.Lock()
+	p.pending = &pendingDelete{
+		knowledgeID: hit.ID,
+		title:       title,
+		expiresAt:   p.now().Add(deleteConfirmTimeout),
+	}
+	p.mu.Unlock()
+	log.Printf("[Command] 等待确认删除: %s (%s)", hit.ID, title)
+	reply := fmt.Sprintf("要删除「%s」吗？说 \"确认\" 删除，说 \"取消\" 放弃。", title)
+	replyDirectly(ctx, p.sessionManager, p.ttsService, reply)
+	return nil
+}
+
+// confirmDelete 用户确认后删除知识及其向量
+func (p *CommandProcessor) confirmDelete(ctx *PipelineContext, pending *pendingDelete) error {
+	err := p.knowledgeRepo.Delete(ctx.Context(), pending.knowledgeID)
+	switch {
+	case errors.Is(err, service.ErrKnowledgeNotFound):
+		replyDirectly(ctx, p.sessionManager, p.ttsService, fmt.Sprintf("「%s」已经不存在了。", pending.title))
+		return nil
+	case err != nil:
+		return fmt.Errorf("delete knowledge failed: %w", err)
+	}
+
+	log.Printf("[Command] 已删除知识: %s (%s)", pending.knowledgeID, pending.title)
+	replyDirectly(ctx, p.sessionManager, p.ttsService, fmt.Sprintf("已删除「%s」。", pending.title))
+	return nil
+}
+
+// deleteTarget 去掉指令词后剩下的删除目标，如 "帮我把周报笔记删掉" → "周报笔记"
+func deleteTarget(text string) string {
+	for _, filler := range deleteTargetFillers {
+		text = strings.ReplaceAll(text, filler, "")
+	}
+	return strings.Trim(text, " ，。！？,.!?")
+}
+
+// matchesDeleteTarget 检索结果是否足够相关：向量相似度达到 deleteMinSimilarity，
+// 或目标的查询词至少有 deleteMinCoverage 出现在知识的标题、摘要、正文或标签中
+func matchesDeleteTarget(hit *service.SearchHit, target string) bool {
+	if hit.VectorScore >= deleteMinSimilarity {
+		return true
+	}
+	tokens := service.SearchTokens(target)
+	if len(tokens) == 0 {
+		return false
+	}
+	k := &hit.Knowledge
+	text := strings.ToLower(strings.Join([]string{k.Title, k.Summary, k.Content, strings.Join(k.Tags, " ")}, "\n"))
+	matched := 0
+	for _, t := range tokens {
+		if strings.Contains(text, t) {
+			matched++
+		}
+	}
+	return float64(matched)/float64(len(tokens)) >= deleteMinCoverage
+}
+
+// knowledgeTitle 适合朗读的知识标题，没有标题时取正文开头
+func knowledgeTitle(k *service.Knowledge) string {
+	if k.Title != "" {
+		return k.Title
+	}
+	runes := []rune(strings.TrimSpace(k.Content))
+	if len(runes) > 20 {
+		return string(runes[:20]) + "…"
+	}
+	return string(runes)
+}
+
+// isConfirmation 整句（去掉标点后）只由确认词组成，如 "确认"、"嗯，好的"
+func isConfirmation(text string) bool {
+	rest := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(confirmPunctuation, r) {
+			return -1
+		}
+		return r
+	}, text)
+	if rest == "" {
+		return false
+	}
+	for rest != "" {
+		matched := false
+		for _, w := range confirmWords {
+			if strings.HasPrefix(rest, w) {
+				rest = rest[len(w):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAny(text string, words []string) bool {
+	for _, w := range words {
+		if strings.Contains(text, w) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+	"voice-memory/internal/service"
+)
+
+func TestCommandProcessor_Process(t *testing.T) {
+	now := time.Date(2026, 3, 11, 10, 0, 0, 0, time.Local)
+
+	db, err := service.NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	repo := service.NewKnowledgeRepository(db, nil)
+	for _, k := range []*service.Knowledge{
+		{ID: "kb_1", Title: "周报模板", Content: "周报按 本周进展 下周计划 风险 三段来写", CreatedAt: now},
+		{ID: "kb_2", Title: "买菜清单", Content: "西红柿 鸡蛋 牛奶", CreatedAt: now},
+	} {
+		if err := repo.Save(context.Background(), k); err != nil {
+			t.Fatalf("保存知识失败: %v", err)
+		}
+	}
+
+	sm := service.NewSessionManagerWithDB(db)
+	sm.GetOrCreateSession("sess_1")
+
+	proc := NewCommandProcessor(repo, sm)
+	proc.now = func() time.Time { return now }
+
+	say := func(text string, intent service.Intent, entities map[string]string) (*PipelineContext, bool) {
+		t.Helper()
+		ctx := &PipelineContext{
+			SessionID:  "sess_1",
+			Transcript: text,
+			Intent:     service.IntentResult{Intent: intent, Entities: entities},
+		}
+		cont, err := proc.Process(ctx)
+		if err != nil {
+			t.Fatalf("意外错误: %v", err)
+		}
+		return ctx, cont
+	}
+
+	t.Run("清空对话", func(t *testing.T) {
+		sm.AddMessage("sess_1", "user", "你好")
+		sm.AddMessage("sess_1", "assistant", "你好，有什么可以帮你？")
+
+		var events []Event
+		ctx := &PipelineContext{
+			SessionID:  "sess_1",
+			Transcript: "清空对话",
+			Intent:     service.IntentResult{Intent: service.IntentClear},
+			Events:     EventSinkFunc(func(e Event) { events = append(events, e) }),
+		}
+		cont, err := proc.Process(ctx)
+		if err != nil {
+			t.Fatalf("意外错误: %v", err)
+		}
+		if cont {
+			t.Errorf("清空对话应该短路(不交给LLM)")
+		}
+		if ctx.LLMReply != "好的，已清空当前对话，我们重新开始吧。" {
+			t.Errorf("回复错误: %s", ctx.LLMReply)
+		}
+		if len(events) != 1 || events[0].Type != EventLLMDone {
+			t.Errorf("应推送 llm_done 事件: %+v", events)
+		}
+		if msgs := sm.GetMessages("sess_1"); len(msgs) != 0 {
+			t.Errorf("会话应被清空, 实际 %d 条消息", len(msgs))
+		}
+	})
+
+	t.Run("删除前先确认，确认后删除", func(t *testing.T) {
+		ctx, cont := say("删除周报模板", service.IntentDelete, nil)
+		if cont {
+			t.Errorf("删除指令应该短路(不交给LLM)")
+		}
+		if ctx.LLMReply != "要删除「周报模板」吗？说 \"确认\" 删除，说 \"取消\" 放弃。" {
+			t.Errorf("回复错误: %s", ctx.LLMReply)
+		}
+		if k, _ := repo.Get("kb_1"); k == nil {
+			t.Fatalf("确认前不应删除")
+		}
+
+		ctx, cont = say("确认", service.IntentChat, nil)
+		if cont {
+			t.Errorf("确认删除应该短路(不交给LLM)")
+		}
+		if ctx.LLMReply != "已删除「周报模板」。" {
+			t.Errorf("回复错误: %s", ctx.LLMReply)
+		}
+		if k, _ := repo.Get("kb_1"); k != nil {
+			t.Errorf("知识应被删除")
+		}
+	})
+
+	t.Run("使用 LLM 提取的删除目标", func(t *testing.T) {
+		ctx, _ := say("把昨天那个买东西的记录去掉", service.IntentDelete,
+			map[string]string{service.SlotTarget: "买菜清单"})
+		if ctx.LLMReply != "要删除「买菜清单」吗？说 \"确认\" 删除，说 \"取消\" 放弃。" {
+			t.Errorf("回复错误: %s", ctx.LLMReply)
+		}
+
+		ctx, _ = say("不要删了", service.IntentDelete, nil)
+		if ctx.LLMReply != "好的，不删除了。" {
+			t.Errorf("回复错误: %s", ctx.LLMReply)
+		}
+		if k, _ := repo.Get("kb_2"); k == nil {
+			t.Errorf("取消后不应删除")
+		}
+
+		// 取消后再说 "确认" 按普通对话处理
+		if _, cont := say("确认", service.IntentChat, nil); !cont {
+			t.Errorf("没有待确认的删除时应继续执行")
+		}
+	})
+
+	t.Run("带确认词的其他问题不算确认", func(t *testing.T) {
+		say("删除买菜清单", service.IntentDelete, nil)
+
+		if _, cont := say("嗯，明天天气怎么样", service.IntentChat, nil); !cont {
+			t.Errorf("答非所问时应继续执行")
+		}
+		if k, _ := repo.Get("kb_2"); k == nil {
+			t.Errorf("未确认不应删除")
+		}
+	})
+
+	t.Run("确认超时后按普通对话处理", func(t *testing.T) {
+		say("删除买菜清单", service.IntentDelete, nil)
+
+		proc.now = func() time.Time { return now.Add(2 * deleteConfirmTimeout) }
+		defer func() { proc.now = func() time.Time { return now } }()
+
+		if _, cont := say("好的", service.IntentChat, nil); !cont {
+			t.Errorf("确认超时后应继续执行")
+		}
+		if k, _ := repo.Get("kb_2"); k == nil {
+			t.Errorf("超时后不应删除")
+		}
+	})
+
+	t.Run("找不到要删除的知识", func(t *testing.T) {
+		ctx, _ := say("删除关于量子力学的笔记", service.IntentDelete,
+			map[string]string{service.SlotTarget: "量子力学"})
+		if ctx.LLMReply != "没有找到和 \"量子力学\" 相关的知识。" {
+			t.Errorf("回复错误: %s", ctx.LLMReply)
+		}
+	})
+
+	t.Run("只和目标沾边的知识不算找到", func(t *testing.T) {
+		ctx, _ := say("删除周报里的天气情况", service.IntentDelete, nil)
+		if ctx.LLMReply != "没有找到和 \"周报里的天气情况\" 相关的知识。" {
+			t.Errorf("回复错误: %s", ctx.LLMReply)
+		}
+	})
+
+	t.Run("没有删除说法的 \"不要\" 按普通对话处理", func(t *testing.T) {
+		ctx, cont := say("我不要吃辣", service.IntentDelete, nil)
+		if !cont || ctx.LLMReply != "" {
+			t.Errorf("\"我不要吃辣\" 不应当成删除指令: %s", ctx.LLMReply)
+		}
+		if _, cont := say("好的", service.IntentChat, nil); !cont {
+			t.Errorf("不应产生待确认的删除")
+		}
+	})
+
+	t.Run("没有说删除什么", func(t *testing.T) {
+		ctx, _ := say("删除", service.IntentDelete, nil)
+		if ctx.LLMReply != "要删除哪条知识呢？可以说 \"删除关于周报的笔记\"。" {
+			t.Errorf("回复错误: %s", ctx.LLMReply)
+		}
+	})
+
+	t.Run("未配置知识库时删除交给LLM", func(t *testing.T) {
+		proc := NewCommandProcessor(nil, sm)
+		ctx := &PipelineContext{
+			Transcript: "删除周报",
+			Intent:     service.IntentResult{Intent: service.IntentDelete},
+		}
+		if cont, _ := proc.Process(ctx); !cont || ctx.LLMReply != "" {
+			t.Errorf("未配置知识库时不应处理删除")
+		}
+	})
+}
+
+func TestIsConfirmation(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"确认", true},
+		{"嗯，好的。", true},
+		{"对的！", true},
+		{"嗯，明天天气怎么样", false},
+		{"好的，帮我查一下周报", false},
+		{"，。", false},
+	}
+	for _, tt := range tests {
+		if got := isConfirmation(tt.text); got != tt.want {
+			t.Errorf("isConfirmation(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDeleteTarget(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"帮我把那条周报笔记删掉", "周报笔记"},
+		{"删除买菜清单。", "买菜清单"},
+		{"删除", ""},
+	}
+	for _, tt := range tests {
+		if got := deleteTarget(tt.text); got != tt.want {
+			t.Errorf("deleteTarget(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
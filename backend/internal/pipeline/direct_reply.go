@@ -0,0 +1,47 @@
+package pipeline
+
+import (
+	"log"
+	"voice-memory/internal/service"
+)
+
+// replyDirectly 不经过 LLM 直接回复：写入 ctx.LLMReply、推送 llm_done、记录问答到会话，
+// 设置了 TTS 且有音频通道时合成语音播报
+func replyDirectly(ctx *PipelineContext, sessionManager *service.SessionManager, ttsService service.TTSService, reply string) {
+	if sessionManager != nil {
+		recordMessage(ctx, sessionManager, &service.SessionMessage{
+			Role:    "user",
+			Content: ctx.Transcript,
+			Intent:  string(ctx.Intent.Intent),
+		})
+		recordMessage(ctx, sessionManager, &service.SessionMessage{
+			Role:    "assistant",
+			Content: reply,
+		})
+	}
+	speakDirectly(ctx, ttsService, reply)
+}
+
+// recordMessage 把消息写入会话并记下消息 ID，写入失败只记录日志
+func recordMessage(ctx *PipelineContext, sessionManager *service.SessionManager, msg *service.SessionMessage) {
+	msg.SessionID = ctx.SessionID
+	if err := sessionManager.AppendMessage(msg); err != nil {
+		log.Printf("[Pipeline] 保存消息失败 (Session: %s): %v", ctx.SessionID, err)
+		return
+	}
+	if msg.ID > 0 {
+		ctx.MessageIDs = append(ctx.MessageIDs, msg.ID)
+	}
+}
+
+// speakDirectly 与 replyDirectly 相同，但不记录到会话（如清空对话后的确认）
+func speakDirectly(ctx *PipelineContext, ttsService service.TTSService, reply string) {
+	ctx.LLMReply = reply
+	ctx.Emit(Event{Type: EventLLMDone, Text: reply})
+
+	if ttsService != nil && ctx.AudioSink != nil {
+		synthesizer := NewSentenceSynthesizer(ctx.Context(), ttsService, ctx.AudioSink)
+		synthesizer.Enqueue(reply)
+		synthesizer.Close()
+	}
+}
@@ -0,0 +1,37 @@
+package pipeline
+
+// 流水线事件类型（与 WebSocket 消息的 type 字段一致）
+const (
+	EventSTTPartial = "stt_partial" // 流式识别的部分结果（随后续音频不断修正）
+	EventSTTFinal   = "stt_final"   // 语音识别完成
+	EventLLMDelta   = "llm_delta"   // LLM 增量文本
+	EventLLMDone    = "llm_done"    // LLM 生成结束，Text 为完整回复
+)
+
+// Event 处理器在执行过程中产生的中间结果
+type Event struct {
+	Type string      `json:"type"`
+	Text string      `json:"text,omitempty"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// EventSink 事件接收方
+// 可能被多个协程同时调用，实现方需要自行保证并发安全
+type EventSink interface {
+	Emit(event Event)
+}
+
+// EventSinkFunc 函数适配器，方便用闭包实现 EventSink
+type EventSinkFunc func(event Event)
+
+// Emit 实现 EventSink 接口
+func (f EventSinkFunc) Emit(event Event) {
+	f(event)
+}
+
+// Emit 向上下文的事件接收方发送事件，未设置接收方时静默丢弃
+func (c *PipelineContext) Emit(event Event) {
+	if c.Events != nil {
+		c.Events.Emit(event)
+	}
+}
@@ -0,0 +1,123 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"voice-memory/internal/service"
+)
+
+// DefaultHistoryTopK 历史对话检索默认带给 LLM 的片段数
+const DefaultHistoryTopK = 5
+
+// historyQueryFillers 从 "我之前聊过什么关于 Redis 的" 中提取检索词时去掉的词
+var historyQueryFillers = []string{
+	"我们", "我", "之前", "以前", "上次", "聊过", "说过", "聊的", "说的", "历史对话",
+	"什么", "有关", "那个", "一下", "吗", "呢", "吧",
+}
+
+// HistoryProcessor 历史对话检索处理器
+// 位于 Intent 与 LLM 之间：对 "我之前聊过什么关于…" 这类问题全文检索过去的会话消息，
+// 命中的片段放入上下文由 LLM 据此回答；没有找到时直接回复并短路
+type HistoryProcessor struct {
+	sessionManager *service.SessionManager
+	ttsService     service.TTSService // 可选：设置后把回复合成语音通过 ctx.AudioSink 推送
+	topK           int
+}
+
+// NewHistoryProcessor 创建历史对话检索处理器
+func NewHistoryProcessor(sessionManager *service.SessionManager) *HistoryProcessor {
+	return &HistoryProcessor{
+		sessionManager: sessionManager,
+		topK:           DefaultHistoryTopK,
+	}
+}
+
+// SetSentenceTTS 开启语音播报（与 LLMProcessor 共用句子级 TTS 的音频通道）
+func (p *HistoryProcessor) SetSentenceTTS(ttsService service.TTSService) {
+	p.ttsService = ttsService
+}
+
+func (p *HistoryProcessor) Name() string {
+	return "History"
+}
+
+func (p *HistoryProcessor) Process(ctx *PipelineContext) (bool, error) {
+	if p.sessionManager == nil || ctx.Intent.Intent != service.IntentHistory {
+		return true, nil
+	}
+
+	query := ctx.Intent.Entities[service.SlotQuery]
+	if query == "" {
+		query = historyQuery(ctx.Transcript)
+	}
+	if query == "" {
+		replyDirectly(ctx, p.sessionManager, p.ttsService, "想找之前聊过的哪方面内容呢？可以说 \"我之前聊过什么关于 Redis 的\"。")
+		return false, nil
+	}
+
+	hits, err := p.sessionManager.SearchMessages(query, service.MessageSearchOptions{
+		ExcludeIntents: []string{string(service.IntentHistory)}, // 不把之前的历史检索问答当作结果
+		Context:        1,
+		Limit:          p.topK,
+	})
+	if err != nil {
+		// 检索失败不影响对话，LLM 仍然可以根据当前会话回答
+		log.Printf("[History] 检索历史对话失败: %v", err)
+		return true, nil
+	}
+	if len(hits) == 0 {
+		replyDirectly(ctx, p.sessionManager, p.ttsService, fmt.Sprintf("没有找到之前聊过「%s」的对话。", query))
+		return false, nil
+	}
+
+	ctx.History = hits
+	log.Printf("[History] 检索到 %d 条相关历史消息 (检索词: %s)", len(hits), query)
+	return true, nil
+}
+
+// historyQuery 从问句中提取检索词：优先取 "关于" 之后的部分，否则去掉口语词
+func historyQuery(text string) string {
+	if i := strings.Index(text, "关于"); i >= 0 {
+		text = text[i+len("关于"):]
+	}
+	for _, filler := range historyQueryFillers {
+		text = strings.ReplaceAll(text, filler, "")
+	}
+	return strings.Trim(text, " ，。！？,.!?的")
+}
+
+// buildHistoryPrompt 构建注入 System Prompt 的历史对话片段
+func buildHistoryPrompt(hits []service.MessageSearchHit) string {
+	if len(hits) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\n【历史对话检索结果】\n")
+	sb.WriteString("用户在问以前和你聊过的内容。以下是从过去的对话中检索到的相关片段（按相关度排序），")
+	sb.WriteString("请据此概括当时聊了什么、大概是什么时候；片段中没有的内容不要编造。\n")
+	for i, hit := range hits {
+		title := hit.SessionTitle
+		if title == "" {
+			title = "未命名对话"
+		}
+		sb.WriteString(fmt.Sprintf("\n[%d] 「%s」 %s\n", i+1, title, hit.Message.CreatedAt.Format("2006-01-02 15:04")))
+		for _, m := range hit.Before {
+			sb.WriteString(historyLine(m))
+		}
+		sb.WriteString(historyLine(hit.Message))
+		for _, m := range hit.After {
+			sb.WriteString(historyLine(m))
+		}
+	}
+	return sb.String()
+}
+
+func historyLine(m service.SessionMessage) string {
+	role := "用户"
+	if m.Role == "assistant" {
+		role = "你"
+	}
+	return fmt.Sprintf("%s: %s\n", role, truncateRunes(m.Content, 200))
+}
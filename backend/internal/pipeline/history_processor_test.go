@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+	"voice-memory/internal/service"
+)
+
+func TestHistoryProcessor_Process(t *testing.T) {
+	db, err := service.NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	sm := service.NewSessionManagerWithDB(db)
+	sm.GetOrCreateSession("old")
+	sm.AddMessage("old", "user", "Redis 持久化用 RDB 还是 AOF")
+	sm.AddMessage("old", "assistant", "一般两者结合使用")
+	sm.GetOrCreateSession("now")
+	proc := NewHistoryProcessor(sm)
+
+	t.Run("命中时放入上下文继续执行", func(t *testing.T) {
+		ctx := &PipelineContext{
+			SessionID:  "now",
+			Transcript: "我之前聊过什么关于Redis的",
+			Intent:     service.IntentResult{Intent: service.IntentHistory},
+		}
+		cont, err := proc.Process(ctx)
+		if err != nil || !cont {
+			t.Fatalf("应继续交给LLM: %v", err)
+		}
+		if len(ctx.History) != 1 || ctx.History[0].Message.SessionID != "old" || len(ctx.History[0].After) != 1 {
+			t.Fatalf("检索结果错误: %+v", ctx.History)
+		}
+		prompt := buildHistoryPrompt(ctx.History)
+		if !strings.Contains(prompt, "用户: Redis 持久化用 RDB 还是 AOF") || !strings.Contains(prompt, "你: 一般两者结合使用") {
+			t.Errorf("历史片段未注入: %s", prompt)
+		}
+	})
+
+	t.Run("没有命中时直接回复", func(t *testing.T) {
+		ctx := &PipelineContext{
+			SessionID:  "now",
+			Transcript: "上次聊的Kafka",
+			Intent:     service.IntentResult{Intent: service.IntentHistory},
+		}
+		if cont, _ := proc.Process(ctx); cont {
+			t.Errorf("没有命中应该短路")
+		}
+		if ctx.LLMReply != "没有找到之前聊过「Kafka」的对话。" {
+			t.Errorf("回复错误: %s", ctx.LLMReply)
+		}
+	})
+
+	t.Run("没有检索词时追问", func(t *testing.T) {
+		ctx := &PipelineContext{
+			SessionID:  "now",
+			Transcript: "我之前聊过什么",
+			Intent:     service.IntentResult{Intent: service.IntentHistory},
+		}
+		if cont, _ := proc.Process(ctx); cont || !strings.HasPrefix(ctx.LLMReply, "想找之前聊过的哪方面内容呢") {
+			t.Errorf("应追问检索内容: %s", ctx.LLMReply)
+		}
+	})
+
+	t.Run("非历史意图继续执行", func(t *testing.T) {
+		ctx := &PipelineContext{
+			Transcript: "Redis 是什么",
+			Intent:     service.IntentResult{Intent: service.IntentChat},
+		}
+		if cont, _ := proc.Process(ctx); !cont || ctx.History != nil {
+			t.Errorf("非历史意图不应处理")
+		}
+	})
+}
+
+func TestHistoryQuery(t *testing.T) {
+	tests := map[string]string{
+		"我之前聊过什么关于Redis的": "Redis",
+		"上次聊的部署方案":        "部署方案",
+		"我们以前说过的 Go 并发吗？": "Go 并发",
+		"我之前聊过什么":         "",
+	}
+	for text, want := range tests {
+		if got := historyQuery(text); got != want {
+			t.Errorf("historyQuery(%q) = %q, 期望 %q", text, got, want)
+		}
+	}
+}
@@ -27,7 +27,7 @@ func (p *IntentProcessor) Process(ctx *PipelineContext) (bool, error) {
 	}
 
 	// 识别意图
-	result := p.intentService.Recognize(ctx.Transcript)
+	result := p.intentService.Recognize(ctx.Context(), ctx.Transcript)
 	ctx.Intent = result
 
 	log.Printf("[Intent] 识别结果: %s (置信度: %.2f)", result.Intent, result.Confidence)
@@ -35,19 +35,15 @@ func (p *IntentProcessor) Process(ctx *PipelineContext) (bool, error) {
 	// 处理特定意图
 	switch result.Intent {
 	case service.IntentDelete, service.IntentClear:
-		// 这些是“指令型”意图
-		log.Printf("[Intent] 触发指令: %s，停止后续 LLM 生成", result.Intent)
-		
-		// 我们可以在这里生成一个简单的系统反馈语音（可选）
-		// ctx.LLMReply = "好的，已执行" 
-		// 但为了 MVP 纯粹性，我们先直接短路
-		return false, nil
+		// 这些是“指令型”意图，交给 CommandProcessor 执行并回复确认
+		log.Printf("[Intent] 触发指令: %s", result.Intent)
+		return true, nil
 
 	case service.IntentChat, service.IntentQuestion:
 		// 继续执行，交给 LLM
 		return true, nil
 		
-	case service.IntentSearch, service.IntentRecord:
+	case service.IntentSearch, service.IntentRecord, service.IntentTodo, service.IntentRemind, service.IntentHistory:
 		// 知识库相关，也继续执行，交给 Memory/LLM 层处理
 		return true, nil
 	}
@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"context"
 	"testing"
 	"voice-memory/internal/service"
 )
@@ -10,7 +11,7 @@ type MockIntentService struct {
 	Result service.IntentResult
 }
 
-func (m *MockIntentService) Recognize(text string) service.IntentResult {
+func (m *MockIntentService) Recognize(ctx context.Context, text string) service.IntentResult {
 	return m.Result
 }
 
@@ -31,7 +32,7 @@ func TestIntentProcessor_Process(t *testing.T) {
 		}
 	})
 
-	t.Run("停止/清除意图", func(t *testing.T) {
+	t.Run("清除意图交给指令处理器", func(t *testing.T) {
 		mockIntent := &MockIntentService{
 			Result: service.IntentResult{Intent: service.IntentClear},
 		}
@@ -42,8 +43,11 @@ func TestIntentProcessor_Process(t *testing.T) {
 		if err != nil {
 			t.Fatalf("意外错误: %v", err)
 		}
-		if cont {
-			t.Errorf("清空意图应该短路(不交给LLM)")
+		if !cont {
+			t.Errorf("清空意图应该继续执行(交给CommandProcessor)")
+		}
+		if ctx.Intent.Intent != service.IntentClear {
+			t.Errorf("意图未写入上下文: %s", ctx.Intent.Intent)
 		}
 	})
 }
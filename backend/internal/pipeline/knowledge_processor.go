@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"time"
@@ -9,18 +10,21 @@ import (
 	"github.com/google/uuid"
 )
 
+// knowledgeOrganizeTimeout 异步知识整理的超时时间
+const knowledgeOrganizeTimeout = 60 * time.Second
+
 // KnowledgeProcessor 知识整理处理器
 // 负责在对话结束后，异步调用 LLM 提取知识并存入数据库
 type KnowledgeProcessor struct {
 	organizer *service.KnowledgeOrganizer
-	db        *service.Database
+	repo      *service.KnowledgeRepository
 }
 
 // NewKnowledgeProcessor 创建知识整理处理器
-func NewKnowledgeProcessor(organizer *service.KnowledgeOrganizer, db *service.Database) *KnowledgeProcessor {
+func NewKnowledgeProcessor(organizer *service.KnowledgeOrganizer, repo *service.KnowledgeRepository) *KnowledgeProcessor {
 	return &KnowledgeProcessor{
 		organizer: organizer,
-		db:        db,
+		repo:      repo,
 	}
 }
 
@@ -39,7 +43,7 @@ func (p *KnowledgeProcessor) Process(ctx *PipelineContext) (bool, error) {
 	// 注意：这里使用 context.Background() 或者是独立的 context，
 	// 因为 ctx.Ctx 可能会在 WebSocket 连接断开时被取消，而我们希望知识整理能完成。
 	// 但为了避免 goroutine 泄漏，最好有一个全局的 worker pool，这里简化处理直接 go func
-	go func(sessionID, userText, aiText string) {
+	go func(sessionID, userText, aiText string, messageIDs []int64) {
 		// 1. 构建要分析的对话片段
 		// 目前我们只分析当前这一轮对话，未来可以扩展为分析整个 Session 的 buffer
 		contentToAnalyze := fmt.Sprintf("User: %s\nAI: %s", userText, aiText)
@@ -47,9 +51,10 @@ func (p *KnowledgeProcessor) Process(ctx *PipelineContext) (bool, error) {
 		log.Printf("[Knowledge] 开始整理知识 (Session: %s)...", sessionID)
 		start := time.Now()
 
-		// 2. 调用 KnowledgeOrganizer
-		// TODO: 这里应该传入 context 以便超时控制
-		result, err := p.organizer.Organize(contentToAnalyze)
+		// 2. 调用 KnowledgeOrganizer（独立的超时 context，不受连接断开影响）
+		organizeCtx, cancel := context.WithTimeout(context.Background(), knowledgeOrganizeTimeout)
+		defer cancel()
+		result, err := p.organizer.Organize(organizeCtx, contentToAnalyze)
 		if err != nil {
 			log.Printf("[Knowledge] 整理失败: %v", err)
 			return
@@ -72,12 +77,13 @@ func (p *KnowledgeProcessor) Process(ctx *PipelineContext) (bool, error) {
 			Sentiment:    result.Sentiment,
 			Source:       "voice_chat",
 			SessionID:    sessionID,
+			MessageIDs:   messageIDs,
 			CreatedAt:    time.Now(),
 			UpdatedAt:    time.Now(),
 		}
 
-		// 4. 存入数据库
-		if err := p.db.SaveKnowledge(knowledge); err != nil {
+		// 4. 存入数据库并同步向量库
+		if err := p.repo.Save(organizeCtx, knowledge); err != nil {
 			log.Printf("[Knowledge] 入库失败: %v", err)
 			return
 		}
@@ -85,7 +91,7 @@ func (p *KnowledgeProcessor) Process(ctx *PipelineContext) (bool, error) {
 		duration := time.Since(start)
 		log.Printf("[Knowledge] 知识整理完成并入库 (耗时: %v, ID: %s)", duration, knowledge.ID)
 
-	}(ctx.SessionID, ctx.Transcript, ctx.LLMReply)
+	}(ctx.SessionID, ctx.Transcript, ctx.LLMReply, append([]int64(nil), ctx.MessageIDs...))
 
 	return true, nil
 }
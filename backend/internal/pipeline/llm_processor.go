@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 	"voice-memory/internal/service"
 )
 
@@ -11,6 +12,9 @@ import (
 type LLMProcessor struct {
 	llmService     service.LLMService
 	sessionManager *service.SessionManager
+	ttsService     service.TTSService         // 可选：设置后在流式生成过程中做句子级 TTS
+	summarizer     *service.SessionSummarizer // 可选：设置后用会话摘要压缩历史
+	titler         *service.SessionTitler     // 可选：设置后在前几轮对话后自动生成会话标题
 }
 
 func NewLLMProcessor(llmService service.LLMService, sessionManager *service.SessionManager) *LLMProcessor {
@@ -20,6 +24,22 @@ func NewLLMProcessor(llmService service.LLMService, sessionManager *service.Sess
 	}
 }
 
+// SetSentenceTTS 开启句子级流式 TTS：LLM 每生成一个完整句子就立即合成并通过 ctx.AudioSink 推送
+func (p *LLMProcessor) SetSentenceTTS(ttsService service.TTSService) {
+	p.ttsService = ttsService
+}
+
+// SetSessionSummarizer 开启上下文压缩：已摘要的历史用摘要代替，其余消息按 token 预算保留，
+// 每次回复后在后台增量更新摘要
+func (p *LLMProcessor) SetSessionSummarizer(summarizer *service.SessionSummarizer) {
+	p.summarizer = summarizer
+}
+
+// SetSessionTitler 开启会话标题自动生成：每次回复后检查会话是否需要标题
+func (p *LLMProcessor) SetSessionTitler(titler *service.SessionTitler) {
+	p.titler = titler
+}
+
 func (p *LLMProcessor) Name() string {
 	return "LLM"
 }
@@ -30,11 +50,21 @@ func (p *LLMProcessor) Process(ctx *PipelineContext) (bool, error) {
 	}
 
 	// 1. 立即保存用户消息 (防止 LLM 失败导致数据丢失)
-	p.sessionManager.AddMessage(ctx.SessionID, "user", ctx.Transcript)
+	recordMessage(ctx, p.sessionManager, &service.SessionMessage{
+		Role:    "user",
+		Content: ctx.Transcript,
+		Intent:  string(ctx.Intent.Intent),
+	})
 
 	// 2. 获取包含最新消息的历史记录
 	// 这里获取到的 messages 已经包含了刚刚存入的 user message
-	history := p.sessionManager.GetMessages(ctx.SessionID)
+	// 启用压缩时由摘要 + token 预算决定上下文，否则只取最近 DefaultHistoryMessages 条
+	var history []service.Message
+	if p.summarizer != nil {
+		history = p.summarizer.BuildMessages(ctx.SessionID, p.sessionManager.GetMessages(ctx.SessionID))
+	} else {
+		history = p.sessionManager.GetRecentMessages(ctx.SessionID, service.DefaultHistoryMessages)
+	}
 
 	// 定义 System Prompt (Enhanced)
 	systemPrompt := `你是 Voice Memory，一个温暖贴心、有幽默感的 AI 语音助手兼知识管家。
@@ -89,6 +119,11 @@ func (p *LLMProcessor) Process(ctx *PipelineContext) (bool, error) {
 2. **诚实透明**: 不知道就不知道，不编造
 3. **保持个性**: 温暖、幽默、贴心的一致性格`
 
+	// 注入检索到的知识（由 RetrievalProcessor 填充）
+	systemPrompt += buildKnowledgePrompt(ctx.Retrieved)
+	// 注入检索到的历史对话（由 HistoryProcessor 填充）
+	systemPrompt += buildHistoryPrompt(ctx.History)
+
 	// --- Debug: 打印发送给 LLM 的完整 Prompt ---
 	log.Printf("=== [LLM Request Debug] Session: %s ===", ctx.SessionID)
 	log.Printf("  [SYSTEM]: %s", systemPrompt)
@@ -113,30 +148,95 @@ func (p *LLMProcessor) Process(ctx *PipelineContext) (bool, error) {
 		TopP:        0.8,
 	}
 
+	// 句子级 TTS：边生成边合成，首句合成完即可开始播放
+	var (
+		synthesizer *SentenceSynthesizer
+		segmenter   *SentenceSegmenter
+	)
+	if p.ttsService != nil && ctx.AudioSink != nil {
+		synthesizer = NewSentenceSynthesizer(ctx.Context(), p.ttsService, ctx.AudioSink)
+		segmenter = NewSentenceSegmenter()
+	}
+
 	log.Printf("[LLM] 开始请求 LLM (Session: %s)", ctx.SessionID)
+	start := time.Now()
+	var usage service.Usage
 
-	err := p.llmService.SendMessageStream(req, func(chunk service.StreamChunk) {
+	err := p.llmService.SendMessageStream(ctx.Context(), req, func(chunk service.StreamChunk) {
 		if chunk.Error != "" {
 			log.Printf("[LLM] 流式响应出错: %s", chunk.Error)
 			return
 		}
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
 		if chunk.Delta != "" {
 			fullReply.WriteString(chunk.Delta)
-			// 未来这里可以触发“句子级” TTS 合成
+			ctx.Emit(Event{Type: EventLLMDelta, Text: chunk.Delta})
+			if segmenter != nil {
+				for _, sentence := range segmenter.Push(chunk.Delta) {
+					synthesizer.Enqueue(sentence)
+				}
+			}
 		}
 	})
 
+	if synthesizer != nil {
+		if err == nil {
+			synthesizer.Enqueue(segmenter.Flush())
+		}
+		// 等待剩余句子合成并发送完毕（被打断时会立即返回）
+		chunks := synthesizer.Close()
+		log.Printf("[LLM] 句子级 TTS 已发送 %d 个音频分片", chunks)
+	}
+
 	if err != nil {
+		if ctxErr := ctx.Context().Err(); ctxErr != nil {
+			// 被用户打断：只保留已生成的部分并标记，避免把完整回复写进会话历史
+			partial := interruptedReply(fullReply.String())
+			recordMessage(ctx, p.sessionManager, &service.SessionMessage{
+				Role:      "assistant",
+				Content:   partial,
+				LatencyMs: time.Since(start).Milliseconds(),
+			})
+			log.Printf("[LLM] 生成被打断，已保存截断回复 (长度: %d)", len(partial))
+			return false, ctxErr
+		}
 		return false, fmt.Errorf("llm request failed: %w", err)
 	}
 
 	reply := fullReply.String()
 	ctx.LLMReply = reply
-
-	// 4. 将 AI 回复存入会话管理器
-	p.sessionManager.AddMessage(ctx.SessionID, "assistant", reply)
+	ctx.Emit(Event{Type: EventLLMDone, Text: reply})
+
+	// 4. 将 AI 回复存入会话管理器（附带生成耗时和 token 用量）
+	recordMessage(ctx, p.sessionManager, &service.SessionMessage{
+		Role:         "assistant",
+		Content:      reply,
+		LatencyMs:    time.Since(start).Milliseconds(),
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+	})
+	if p.summarizer != nil {
+		p.summarizer.Schedule(ctx.SessionID)
+	}
+	if p.titler != nil {
+		p.titler.Schedule(ctx.SessionID)
+	}
 
 	log.Printf("[LLM] 生成回复完毕 (长度: %d)", len(reply))
 
 	return true, nil
 }
+
+// interruptedMarker 被打断的助手回复末尾追加的标记
+const interruptedMarker = "[interrupted]"
+
+// interruptedReply 构造被打断时写入会话的截断回复
+func interruptedReply(partial string) string {
+	partial = strings.TrimSpace(partial)
+	if partial == "" {
+		return interruptedMarker
+	}
+	return partial + " " + interruptedMarker
+}
@@ -2,21 +2,24 @@ package pipeline
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"voice-memory/internal/service"
 )
 
 // MockLLMService 模拟的 LLM 服务
 type MockLLMService struct {
-	Reply string
+	Reply       string
+	LastRequest service.ChatRequest // 最近一次收到的请求，用于断言 Prompt 内容
 }
 
-func (m *MockLLMService) SendMessage(req service.ChatRequest) (*service.ChatResponse, error) {
+func (m *MockLLMService) SendMessage(ctx context.Context, req service.ChatRequest) (*service.ChatResponse, error) {
 	// Dummy implementation for interface satisfaction
 	return nil, nil
 }
 
-func (m *MockLLMService) SendMessageStream(req service.ChatRequest, callback func(service.StreamChunk)) error {
+func (m *MockLLMService) SendMessageStream(ctx context.Context, req service.ChatRequest, callback func(service.StreamChunk)) error {
+	m.LastRequest = req
 	// 模拟流式发送几个词
 	words := []string{"你好", "，我是", "AI", "助手"}
 	for _, word := range words {
@@ -66,4 +69,203 @@ func TestLLMProcessor_Process(t *testing.T) {
 	if len(msgs) != 2 {
 		t.Errorf("SessionManager 应该存入 2 条消息，实际 %d", len(msgs))
 	}
-}
\ No newline at end of file
+}
+
+func TestLLMProcessor_InjectsRetrievedKnowledge(t *testing.T) {
+	mockLLM := &MockLLMService{}
+	db, err := service.NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create temp database: %v", err)
+	}
+	defer db.Close()
+	sm := service.NewSessionManagerWithDB(db)
+
+	proc := NewLLMProcessor(mockLLM, sm)
+	sm.GetOrCreateSession("rag-session")
+
+	ctx := NewPipelineContext(context.Background(), "rag-session")
+	ctx.Transcript = "Redis 缓存怎么设计？"
+	ctx.Retrieved = []*service.RetrievalResult{
+		{ID: "kb_1", Content: "热点数据使用 Redis 缓存，过期时间 10 分钟", Score: 0.87, Metadata: map[string]interface{}{"title": "Redis缓存设计"}},
+	}
+
+	if _, err := proc.Process(ctx); err != nil {
+		t.Fatalf("意外错误: %v", err)
+	}
+
+	system := mockLLM.LastRequest.System
+	if !strings.Contains(system, "【知识库检索结果】") {
+		t.Fatalf("System Prompt 应包含知识库片段")
+	}
+	if !strings.Contains(system, "[1] Redis缓存设计") || !strings.Contains(system, "过期时间 10 分钟") {
+		t.Errorf("System Prompt 应包含带编号的知识内容, 实际: %s", system)
+	}
+}
+
+func TestLLMProcessor_SentenceTTS(t *testing.T) {
+	db, err := service.NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create temp database: %v", err)
+	}
+	defer db.Close()
+	sm := service.NewSessionManagerWithDB(db)
+
+	proc := NewLLMProcessor(&MockLLMService{}, sm)
+	proc.SetSentenceTTS(&MockTTSService{})
+	sm.GetOrCreateSession("tts-session")
+
+	var chunks []AudioChunk
+	ctx := NewPipelineContext(context.Background(), "tts-session")
+	ctx.Transcript = "你好"
+	ctx.AudioSink = func(c AudioChunk) error {
+		chunks = append(chunks, c)
+		return nil
+	}
+
+	if _, err := proc.Process(ctx); err != nil {
+		t.Fatalf("意外错误: %v", err)
+	}
+
+	// MockLLMService 的回复没有句末标点，流结束时应把剩余文本作为一句合成
+	if len(chunks) != 1 || chunks[0].Text != "你好，我是AI助手" {
+		t.Errorf("期望 1 个完整分片, 实际 %+v", chunks)
+	}
+}
+
+func TestLLMProcessor_EmitsDeltaEvents(t *testing.T) {
+	db, err := service.NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create temp database: %v", err)
+	}
+	defer db.Close()
+	sm := service.NewSessionManagerWithDB(db)
+
+	proc := NewLLMProcessor(&MockLLMService{}, sm)
+	sm.GetOrCreateSession("event-session")
+
+	var events []Event
+	ctx := NewPipelineContext(context.Background(), "event-session")
+	ctx.Transcript = "你好"
+	ctx.Events = EventSinkFunc(func(e Event) {
+		events = append(events, e)
+	})
+
+	if _, err := proc.Process(ctx); err != nil {
+		t.Fatalf("意外错误: %v", err)
+	}
+
+	// 4 个增量 + 1 个结束事件
+	if len(events) != 5 {
+		t.Fatalf("期望 5 个事件, 实际 %d: %+v", len(events), events)
+	}
+	var deltas strings.Builder
+	for _, e := range events[:4] {
+		if e.Type != EventLLMDelta {
+			t.Errorf("期望 llm_delta, 实际 %s", e.Type)
+		}
+		deltas.WriteString(e.Text)
+	}
+	if deltas.String() != "你好，我是AI助手" {
+		t.Errorf("增量拼接结果错误: %s", deltas.String())
+	}
+	if last := events[4]; last.Type != EventLLMDone || last.Text != "你好，我是AI助手" {
+		t.Errorf("最后一个事件应为 llm_done 且携带完整回复: %+v", last)
+	}
+}
+
+// InterruptLLMService 输出部分增量后模拟用户打断
+type InterruptLLMService struct {
+	MockLLMService
+	cancel context.CancelFunc
+}
+
+func (m *InterruptLLMService) SendMessageStream(ctx context.Context, req service.ChatRequest, callback func(service.StreamChunk)) error {
+	callback(service.StreamChunk{Delta: "我们选择了"})
+	m.cancel()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestLLMProcessor_Interrupted(t *testing.T) {
+	db, err := service.NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create temp database: %v", err)
+	}
+	defer db.Close()
+	sm := service.NewSessionManagerWithDB(db)
+	sm.GetOrCreateSession("interrupt-session")
+
+	ctx := NewPipelineContext(context.Background(), "interrupt-session")
+	ctx.Transcript = "我们选的是什么框架？"
+
+	var events []Event
+	ctx.Events = EventSinkFunc(func(e Event) {
+		events = append(events, e)
+	})
+
+	proc := NewLLMProcessor(&InterruptLLMService{cancel: ctx.Cancel}, sm)
+
+	cont, err := proc.Process(ctx)
+	if cont || err != context.Canceled {
+		t.Fatalf("被打断时应短路并返回 context.Canceled: cont=%v err=%v", cont, err)
+	}
+	if ctx.LLMReply != "" {
+		t.Errorf("被打断时不应设置 LLMReply: %s", ctx.LLMReply)
+	}
+	for _, e := range events {
+		if e.Type == EventLLMDone {
+			t.Errorf("被打断时不应发送 llm_done")
+		}
+	}
+
+	messages := sm.GetMessages("interrupt-session")
+	if len(messages) != 2 {
+		t.Fatalf("期望 2 条消息, 实际 %d", len(messages))
+	}
+	if got := messages[1].Content; got != "我们选择了 [interrupted]" {
+		t.Errorf("助手消息应为截断回复, 实际: %v", got)
+	}
+}
+
+func TestLLMProcessor_UsesSessionSummary(t *testing.T) {
+	mockLLM := &MockLLMService{}
+	db, err := service.NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create temp database: %v", err)
+	}
+	defer db.Close()
+	sm := service.NewSessionManagerWithDB(db)
+
+	sessionID := "summary-session"
+	sm.GetOrCreateSession(sessionID)
+	for _, text := range []string{"我叫小明", "你好小明", "我喜欢爬山", "爬山很棒"} {
+		role := "user"
+		if strings.HasPrefix(text, "你好") || strings.HasPrefix(text, "爬山") {
+			role = "assistant"
+		}
+		sm.AddMessage(sessionID, role, text)
+	}
+	sm.UpdateSummary(sessionID, &service.SessionSummary{Content: "用户叫小明", MessageCount: 2})
+
+	summarizer := service.NewSessionSummarizer(service.NewContextCompressor(mockLLM, service.DefaultContextConfig()), sm)
+	proc := NewLLMProcessor(mockLLM, sm)
+	proc.SetSessionSummarizer(summarizer)
+
+	ctx := NewPipelineContext(context.Background(), sessionID)
+	ctx.Transcript = "周末去哪"
+	if _, err := proc.Process(ctx); err != nil {
+		t.Fatalf("意外错误: %v", err)
+	}
+	summarizer.Wait()
+
+	msgs := mockLLM.LastRequest.Messages
+	if len(msgs) != 4 {
+		t.Fatalf("应发送 摘要 + 2 条未摘要消息 + 当前消息, 实际 %d 条: %+v", len(msgs), msgs)
+	}
+	if !strings.Contains(msgs[0].Content.(string), "用户叫小明") {
+		t.Errorf("第一条应为历史摘要: %v", msgs[0].Content)
+	}
+	if msgs[1].Content != "我喜欢爬山" || msgs[3].Content != "周末去哪" {
+		t.Errorf("消息错误: %+v", msgs)
+	}
+}
@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"time"
+	"voice-memory/internal/service"
+)
+
+// ReminderProcessor 设置提醒处理器
+// 位于 Intent 之后：识别到 "提醒我明天九点开会" 时创建提醒、直接回复确认并短路，不再调用 LLM
+type ReminderProcessor struct {
+	scheduler      *service.ReminderScheduler
+	userID         string
+	sessionManager *service.SessionManager
+	ttsService     service.TTSService // 可选：设置后把回复合成语音通过 ctx.AudioSink 推送
+	now            func() time.Time
+}
+
+// NewReminderProcessor 创建设置提醒处理器，userID 为提醒触发时推送的目标用户
+// scheduler 为 nil 时处理器不做任何事，提醒指令按普通对话交给 LLM
+func NewReminderProcessor(scheduler *service.ReminderScheduler, userID string, sessionManager *service.SessionManager) *ReminderProcessor {
+	return &ReminderProcessor{
+		scheduler:      scheduler,
+		userID:         userID,
+		sessionManager: sessionManager,
+		now:            time.Now,
+	}
+}
+
+// SetSentenceTTS 开启语音播报（与 LLMProcessor 共用句子级 TTS 的音频通道）
+func (p *ReminderProcessor) SetSentenceTTS(ttsService service.TTSService) {
+	p.ttsService = ttsService
+}
+
+func (p *ReminderProcessor) Name() string {
+	return "Reminder"
+}
+
+func (p *ReminderProcessor) Process(ctx *PipelineContext) (bool, error) {
+	if p.scheduler == nil || ctx.Intent.Intent != service.IntentRemind {
+		return true, nil
+	}
+
+	now := p.now()
+	content, at, ok := service.ParseReminder(ctx.Transcript, now)
+	if !ok {
+		reply := fmt.Sprintf("要在什么时候提醒你%s呢？可以说 \"明天九点提醒我%s\"。", content, content)
+		replyDirectly(ctx, p.sessionManager, p.ttsService, reply)
+		return false, nil
+	}
+
+	reminder, err := p.scheduler.Create(p.userID, ctx.SessionID, content, at)
+	if err != nil {
+		return false, fmt.Errorf("create reminder failed: %w", err)
+	}
+
+	log.Printf("[Reminder] 已创建提醒 #%d: %s @ %s", reminder.ID, reminder.Text, at.Format("2006-01-02 15:04"))
+	reply := fmt.Sprintf("好的，%s提醒你%s。", describeReminderTime(at, now), content)
+	replyDirectly(ctx, p.sessionManager, p.ttsService, reply)
+	return false, nil
+}
+
+// describeReminderTime 适合朗读的提醒时间，如 "20 分钟后"、"明天 09:00 "、"3月18日 09:00 "
+func describeReminderTime(at, now time.Time) string {
+	if d := at.Sub(now); d < time.Hour {
+		return fmt.Sprintf("%d 分钟后", max(1, int(d.Round(time.Minute)/time.Minute)))
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, now.Location())
+	clock := at.Format("15:04")
+	switch {
+	case day.Equal(today):
+		return "今天 " + clock + " "
+	case day.Equal(today.AddDate(0, 0, 1)):
+		return "明天 " + clock + " "
+	case day.Equal(today.AddDate(0, 0, 2)):
+		return "后天 " + clock + " "
+	default:
+		return fmt.Sprintf("%d月%d日 %s ", at.Month(), at.Day(), clock)
+	}
+}
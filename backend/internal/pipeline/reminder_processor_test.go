@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+	"voice-memory/internal/service"
+)
+
+func TestReminderProcessor_Process(t *testing.T) {
+	// 2026-03-11 是周三
+	now := time.Date(2026, 3, 11, 10, 0, 0, 0, time.Local)
+
+	db, err := service.NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	// 调度器不启动，只验证提醒被持久化
+	scheduler := service.NewReminderScheduler(db)
+	proc := NewReminderProcessor(scheduler, "alice", nil)
+	proc.now = func() time.Time { return now }
+
+	t.Run("创建提醒并确认", func(t *testing.T) {
+		ctx := &PipelineContext{
+			SessionID:  "sess_1",
+			Transcript: "提醒我明天九点开会",
+			Intent:     service.IntentResult{Intent: service.IntentRemind},
+		}
+		cont, err := proc.Process(ctx)
+		if err != nil {
+			t.Fatalf("意外错误: %v", err)
+		}
+		if cont {
+			t.Errorf("设置提醒应该短路(不交给LLM)")
+		}
+		if ctx.LLMReply != "好的，明天 09:00 提醒你开会。" {
+			t.Errorf("回复错误: %s", ctx.LLMReply)
+		}
+
+		reminders, _ := scheduler.List(service.ReminderFilter{UserID: "alice"})
+		if len(reminders) != 1 || reminders[0].Text != "开会" || reminders[0].SessionID != "sess_1" {
+			t.Errorf("提醒未保存: %+v", reminders)
+		}
+	})
+
+	t.Run("没有时间时追问", func(t *testing.T) {
+		ctx := &PipelineContext{
+			Transcript: "提醒我买牛奶",
+			Intent:     service.IntentResult{Intent: service.IntentRemind},
+		}
+		proc.Process(ctx)
+		if ctx.LLMReply != "要在什么时候提醒你买牛奶呢？可以说 \"明天九点提醒我买牛奶\"。" {
+			t.Errorf("回复错误: %s", ctx.LLMReply)
+		}
+		if reminders, _ := scheduler.List(service.ReminderFilter{}); len(reminders) != 1 {
+			t.Errorf("不应创建提醒, 实际 %d 条", len(reminders))
+		}
+	})
+
+	t.Run("非提醒意图继续执行", func(t *testing.T) {
+		ctx := &PipelineContext{
+			Transcript: "你好",
+			Intent:     service.IntentResult{Intent: service.IntentChat},
+		}
+		if cont, _ := proc.Process(ctx); !cont || ctx.LLMReply != "" {
+			t.Errorf("非提醒意图不应处理")
+		}
+	})
+}
+
+func TestDescribeReminderTime(t *testing.T) {
+	now := time.Date(2026, 3, 11, 10, 0, 0, 0, time.Local)
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{now.Add(20 * time.Minute), "20 分钟后"},
+		{now.Add(10 * time.Second), "1 分钟后"},
+		{now.Add(5 * time.Hour), "今天 15:00 "},
+		{now.Add(48 * time.Hour), "后天 10:00 "},
+		{time.Date(2026, 3, 18, 9, 0, 0, 0, time.Local), "3月18日 09:00 "},
+	}
+	for _, tt := range tests {
+		if got := describeReminderTime(tt.at, now); got != tt.want {
+			t.Errorf("%v: 期望 %q, 实际 %q", tt.at, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"voice-memory/internal/service"
+)
+
+// DefaultRetrievalTopK 默认检索的知识条数
+const DefaultRetrievalTopK = 3
+
+// RetrievalProcessor 知识检索处理器
+// 位于 Intent 与 LLM 之间：对问答/搜索意图检索知识库，把结果放入上下文供 LLM 引用
+type RetrievalProcessor struct {
+	retriever service.RetrievalService
+	topK      int
+}
+
+// NewRetrievalProcessor 创建知识检索处理器
+// retriever 为 nil 时处理器不做任何事，便于在未配置 RAG 的环境下复用同一条流水线
+func NewRetrievalProcessor(retriever service.RetrievalService, topK int) *RetrievalProcessor {
+	if topK <= 0 {
+		topK = DefaultRetrievalTopK
+	}
+	return &RetrievalProcessor{
+		retriever: retriever,
+		topK:      topK,
+	}
+}
+
+func (p *RetrievalProcessor) Name() string {
+	return "Retrieval"
+}
+
+func (p *RetrievalProcessor) Process(ctx *PipelineContext) (bool, error) {
+	if p.retriever == nil || ctx.Transcript == "" {
+		return true, nil
+	}
+
+	if !p.retriever.ShouldUseRAG(ctx.Intent.Intent, ctx.Intent.Confidence) {
+		return true, nil
+	}
+
+	// "搜索上周的工作笔记" 这类说法带有分类/时间条件，只在满足条件的知识中检索
+	filter := service.ParseSearchFilter(ctx.Transcript, time.Now())
+
+	// LLM 意图识别提取了检索词时用检索词，避免 "帮我找找" 这类口语干扰向量检索
+	query := ctx.Transcript
+	if slot := ctx.Intent.Entities[service.SlotQuery]; slot != "" {
+		query = slot
+	}
+	results, err := p.retriever.Retrieve(ctx.Context(), query, p.topK, filter)
+	if err != nil {
+		// 检索失败不影响对话，LLM 仍然可以直接回答
+		log.Printf("[Retrieval] 检索失败，跳过知识增强: %v", err)
+		return true, nil
+	}
+
+	ctx.Retrieved = results
+	if filter != nil {
+		log.Printf("[Retrieval] 检索到 %d 条相关知识 (过滤条件: %s)", len(results), filter)
+	} else {
+		log.Printf("[Retrieval] 检索到 %d 条相关知识", len(results))
+	}
+
+	return true, nil
+}
+
+// Citation 回复引用的知识来源（随 sources 事件发送给客户端）
+type Citation struct {
+	Index   int     `json:"index"` // 与 System Prompt 中的 [n] 编号一致
+	ID      string  `json:"id"`
+	Title   string  `json:"title"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+	Reason  string  `json:"reason,omitempty"` // 入选原因
+}
+
+// BuildCitations 将检索结果转换为引用列表，编号从 1 开始
+func BuildCitations(results []*service.RetrievalResult) []Citation {
+	citations := make([]Citation, 0, len(results))
+	for i, r := range results {
+		citations = append(citations, Citation{
+			Index:   i + 1,
+			ID:      r.ID,
+			Title:   citationTitle(r),
+			Score:   r.Score,
+			Snippet: truncateRunes(r.Content, 80),
+			Reason:  r.Reason,
+		})
+	}
+	return citations
+}
+
+// buildKnowledgePrompt 构建注入 System Prompt 的知识库片段
+func buildKnowledgePrompt(results []*service.RetrievalResult) string {
+	if len(results) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\n【知识库检索结果】\n")
+	sb.WriteString("以下是与用户问题相关的知识条目。回答时优先参考这些内容，引用时在句末用 [编号] 标注来源；与问题无关的条目请忽略。\n")
+	sb.WriteString("「入选原因」说明条目为何被检索到：经由实体关系关联的条目（如依赖、组成部分）可用于补充前置条件或相关背景。\n")
+	for i, r := range results {
+		sb.WriteString(fmt.Sprintf("\n[%d] %s (相关度: %.2f)\n", i+1, citationTitle(r), r.Score))
+		if r.Reason != "" {
+			sb.WriteString(fmt.Sprintf("入选原因: %s\n", r.Reason))
+		}
+		sb.WriteString(r.Content)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// citationTitle 优先使用标题，其次摘要，最后截取正文
+func citationTitle(r *service.RetrievalResult) string {
+	for _, key := range []string{"title", "summary"} {
+		if v, ok := r.Metadata[key].(string); ok && v != "" {
+			return v
+		}
+	}
+	return truncateRunes(r.Content, 20)
+}
+
+// truncateRunes 按字符（而非字节）截断，避免切断中文
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"voice-memory/internal/service"
+)
+
+// MockRetrievalService 模拟的知识检索服务
+type MockRetrievalService struct {
+	Results []*service.RetrievalResult
+	Err     error
+	Queries []string
+	Filters []*service.VectorFilter
+}
+
+func (m *MockRetrievalService) ShouldUseRAG(intent service.Intent, confidence float64) bool {
+	return intent == service.IntentQuestion || intent == service.IntentSearch
+}
+
+func (m *MockRetrievalService) Retrieve(ctx context.Context, query string, topK int, filter *service.VectorFilter) ([]*service.RetrievalResult, error) {
+	m.Queries = append(m.Queries, query)
+	m.Filters = append(m.Filters, filter)
+	return m.Results, m.Err
+}
+
+func TestRetrievalProcessor_Process(t *testing.T) {
+	knowledge := []*service.RetrievalResult{
+		{ID: "kb_1", Content: "gin 框架性能优于 FastAPI", Score: 0.91, Metadata: map[string]interface{}{"title": "Go框架选型"}},
+		{ID: "kb_2", Content: "用户服务 QPS 5000", Score: 0.72, Metadata: map[string]interface{}{}},
+	}
+
+	t.Run("问答意图触发检索", func(t *testing.T) {
+		mock := &MockRetrievalService{Results: knowledge}
+		proc := NewRetrievalProcessor(mock, 3)
+		ctx := &PipelineContext{
+			Transcript: "我们选的是什么框架？",
+			Intent:     service.IntentResult{Intent: service.IntentQuestion, Confidence: 0.2},
+		}
+
+		cont, err := proc.Process(ctx)
+		if err != nil || !cont {
+			t.Fatalf("应该继续执行且无错误: cont=%v err=%v", cont, err)
+		}
+		if len(ctx.Retrieved) != 2 {
+			t.Errorf("期望 2 条检索结果, 实际 %d", len(ctx.Retrieved))
+		}
+		if len(mock.Queries) != 1 || mock.Queries[0] != ctx.Transcript {
+			t.Errorf("应使用转写文本作为查询: %v", mock.Queries)
+		}
+	})
+
+	t.Run("优先使用意图槽位中的检索词", func(t *testing.T) {
+		mock := &MockRetrievalService{Results: knowledge}
+		proc := NewRetrievalProcessor(mock, 3)
+		ctx := &PipelineContext{
+			Transcript: "帮我找找之前记的那个框架选型",
+			Intent: service.IntentResult{Intent: service.IntentSearch, Confidence: 0.9,
+				Entities: map[string]string{service.SlotQuery: "框架选型"}},
+		}
+
+		proc.Process(ctx)
+		if len(mock.Queries) != 1 || mock.Queries[0] != "框架选型" {
+			t.Errorf("应使用槽位中的检索词: %v", mock.Queries)
+		}
+	})
+
+	t.Run("从查询中提取过滤条件", func(t *testing.T) {
+		mock := &MockRetrievalService{Results: knowledge}
+		proc := NewRetrievalProcessor(mock, 3)
+		ctx := &PipelineContext{
+			Transcript: "搜索上周的工作笔记",
+			Intent:     service.IntentResult{Intent: service.IntentSearch, Confidence: 0.2},
+		}
+
+		proc.Process(ctx)
+		if len(mock.Filters) != 1 || mock.Filters[0] == nil {
+			t.Fatalf("应传入过滤条件")
+		}
+		if f := mock.Filters[0]; f.Category != "工作" || f.Since == nil || f.Until == nil {
+			t.Errorf("过滤条件错误: %s", f)
+		}
+	})
+
+	t.Run("普通聊天不检索", func(t *testing.T) {
+		mock := &MockRetrievalService{Results: knowledge}
+		proc := NewRetrievalProcessor(mock, 3)
+		ctx := &PipelineContext{
+			Transcript: "你好",
+			Intent:     service.IntentResult{Intent: service.IntentChat, Confidence: 0.5},
+		}
+
+		if _, err := proc.Process(ctx); err != nil {
+			t.Fatalf("意外错误: %v", err)
+		}
+		if len(mock.Queries) != 0 || ctx.Retrieved != nil {
+			t.Errorf("聊天意图不应该触发检索")
+		}
+	})
+
+	t.Run("检索失败不阻断流水线", func(t *testing.T) {
+		mock := &MockRetrievalService{Err: errors.New("embedding api down")}
+		proc := NewRetrievalProcessor(mock, 3)
+		ctx := &PipelineContext{
+			Transcript: "搜索一下部署文档",
+			Intent:     service.IntentResult{Intent: service.IntentSearch, Confidence: 0.2},
+		}
+
+		cont, err := proc.Process(ctx)
+		if err != nil || !cont {
+			t.Errorf("检索失败时应继续执行: cont=%v err=%v", cont, err)
+		}
+	})
+
+	t.Run("未配置检索服务时跳过", func(t *testing.T) {
+		proc := NewRetrievalProcessor(nil, 0)
+		ctx := &PipelineContext{
+			Transcript: "什么是 RAG？",
+			Intent:     service.IntentResult{Intent: service.IntentQuestion, Confidence: 0.2},
+		}
+
+		cont, err := proc.Process(ctx)
+		if err != nil || !cont {
+			t.Errorf("未配置时应直接继续: cont=%v err=%v", cont, err)
+		}
+	})
+}
+
+func TestBuildCitations(t *testing.T) {
+	results := []*service.RetrievalResult{
+		{ID: "kb_1", Content: "gin 框架性能优于 FastAPI", Score: 0.91, Metadata: map[string]interface{}{"title": "Go框架选型"}, Reason: "与问题语义相似"},
+		{ID: "kb_2", Content: "这是一段没有标题和摘要的知识内容，需要截取正文作为标题", Score: 0.5, Metadata: map[string]interface{}{}},
+	}
+
+	citations := BuildCitations(results)
+	if len(citations) != 2 {
+		t.Fatalf("期望 2 条引用, 实际 %d", len(citations))
+	}
+	if citations[0].Index != 1 || citations[0].Title != "Go框架选型" || citations[0].Reason != "与问题语义相似" {
+		t.Errorf("第一条引用错误: %+v", citations[0])
+	}
+	if citations[1].Index != 2 || citations[1].Title != "这是一段没有标题和摘要的知识内容，需要截…" {
+		t.Errorf("无标题时应截取正文: %+v", citations[1])
+	}
+}
@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// minSentenceRunes 过短的句子（如 "嗯。"）并入下一句，避免产生大量碎片化的 TTS 请求
+	minSentenceRunes = 4
+	// maxSentenceRunes 超长且迟迟没有句末标点时，在逗号处提前切分，降低首包延迟
+	maxSentenceRunes = 60
+)
+
+// SentenceSegmenter 将 LLM 流式增量切分为完整句子
+// 支持中文（。！？；）与英文（. ! ? ;）句末标点，英文句点需后跟空白才算句末，避免切断 "3.14"、"v1.2"
+type SentenceSegmenter struct {
+	buf []rune
+}
+
+// NewSentenceSegmenter 创建句子切分器
+func NewSentenceSegmenter() *SentenceSegmenter {
+	return &SentenceSegmenter{}
+}
+
+// Push 追加一段增量文本，返回其中已经完整的句子
+func (s *SentenceSegmenter) Push(delta string) []string {
+	s.buf = append(s.buf, []rune(delta)...)
+
+	var sentences []string
+	start := 0
+	for i := 0; i < len(s.buf); i++ {
+		if !s.isBoundary(i) {
+			continue
+		}
+		end := i + 1
+		// 吞掉紧随其后的右引号/括号，例如 `他说："好的。"`
+		for end < len(s.buf) && isClosingPunct(s.buf[end]) {
+			end++
+		}
+		if sentence := strings.TrimSpace(string(s.buf[start:end])); len([]rune(sentence)) >= minSentenceRunes {
+			sentences = append(sentences, sentence)
+			start = end
+		}
+		i = end - 1
+	}
+
+	// 长句兜底：在最后一个逗号处切开
+	if len(s.buf)-start > maxSentenceRunes {
+		if cut := lastSoftBoundary(s.buf[start:]); cut > 0 {
+			sentences = append(sentences, strings.TrimSpace(string(s.buf[start:start+cut])))
+			start += cut
+		}
+	}
+
+	s.buf = append([]rune(nil), s.buf[start:]...)
+	return sentences
+}
+
+// Flush 返回缓冲区中剩余的文本（流结束时调用）
+func (s *SentenceSegmenter) Flush() string {
+	rest := strings.TrimSpace(string(s.buf))
+	s.buf = nil
+	return rest
+}
+
+// isBoundary 判断 buf[i] 是否为句末
+func (s *SentenceSegmenter) isBoundary(i int) bool {
+	switch s.buf[i] {
+	case '。', '！', '？', '；', '!', '?', ';', '\n':
+		return true
+	case '.':
+		// 英文句点：必须看到下一个字符是空白才能确认，否则等待更多输入
+		return i+1 < len(s.buf) && unicode.IsSpace(s.buf[i+1])
+	}
+	return false
+}
+
+// isClosingPunct 句末标点后可能紧跟的闭合符号
+func isClosingPunct(r rune) bool {
+	switch r {
+	case '”', '’', '"', '\'', '」', '』', '）', ')', '】':
+		return true
+	}
+	return false
+}
+
+// lastSoftBoundary 返回最后一个逗号类标点之后的位置，没有则返回 0
+func lastSoftBoundary(runes []rune) int {
+	for i := len(runes) - 1; i >= minSentenceRunes; i-- {
+		switch runes[i] {
+		case '，', '、', '：', ',', ':':
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSentenceSegmenter_Push(t *testing.T) {
+	tests := []struct {
+		name   string
+		deltas []string
+		want   []string
+		rest   string
+	}{
+		{
+			name:   "中文句末标点",
+			deltas: []string{"你好呀，", "今天天气不错。我们", "去公园吧！好不好"},
+			want:   []string{"你好呀，今天天气不错。", "我们去公园吧！"},
+			rest:   "好不好",
+		},
+		{
+			name:   "英文句点需后跟空白",
+			deltas: []string{"Go version is 1.21. It", " works well."},
+			want:   []string{"Go version is 1.21."},
+			rest:   "It works well.",
+		},
+		{
+			name:   "过短句子并入下一句",
+			deltas: []string{"嗯。", "这个问题我来解释一下。"},
+			want:   []string{"嗯。这个问题我来解释一下。"},
+			rest:   "",
+		},
+		{
+			name:   "闭合引号跟随句末",
+			deltas: []string{"他说：“明天见。”然后走了。"},
+			want:   []string{"他说：“明天见。”", "然后走了。"},
+			rest:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seg := NewSentenceSegmenter()
+			var got []string
+			for _, d := range tt.deltas {
+				got = append(got, seg.Push(d)...)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("句子切分错误\n期望: %q\n实际: %q", tt.want, got)
+			}
+			if rest := seg.Flush(); rest != tt.rest {
+				t.Errorf("剩余文本错误, 期望 %q, 实际 %q", tt.rest, rest)
+			}
+		})
+	}
+}
+
+func TestSentenceSegmenter_LongSentenceSplitsAtComma(t *testing.T) {
+	seg := NewSentenceSegmenter()
+	long := "这是一个非常非常长的句子，它一直没有句号，因为模型喜欢用逗号把很多内容连在一起，我们希望在这种情况下也能尽快开始播放语音，而不是等到最后"
+	got := seg.Push(long)
+	if len(got) != 1 {
+		t.Fatalf("超长文本应在逗号处切出一句, 实际 %q", got)
+	}
+	if last := []rune(got[0]); last[len(last)-1] != '，' {
+		t.Errorf("应该在逗号后切分, 实际 %q", got[0])
+	}
+}
@@ -0,0 +1,150 @@
+package pipeline
+
+import (
+	"context"
+	"log"
+	"voice-memory/internal/service"
+)
+
+const (
+	// sentenceTTSConcurrency 同时进行的句子合成请求数
+	sentenceTTSConcurrency = 3
+	// sentenceQueueSize 待发送句子队列长度，队列满时会反压 LLM 流回调
+	sentenceQueueSize = 32
+)
+
+// AudioChunk 句子级合成的音频分片
+type AudioChunk struct {
+	Seq   int    // 从 0 开始递增的序号，客户端按序号顺序播放
+	Text  string // 对应的句子文本
+	Audio []byte // 合成的音频数据
+}
+
+// AudioSink 音频分片的接收方（通常由 WebSocket 处理器实现，把分片写成二进制帧）
+type AudioSink func(chunk AudioChunk) error
+
+// pendingSentence 等待合成结果的句子
+type pendingSentence struct {
+	seq    int
+	text   string
+	result chan synthResult
+}
+
+type synthResult struct {
+	audio []byte
+	err   error
+}
+
+// SentenceSynthesizer 句子级并发合成器
+// 句子按到达顺序入队，多个句子并发合成，但始终按序号顺序交给 AudioSink，
+// 这样首句合成完就能开始播放，而不必等整段回复生成完毕。
+type SentenceSynthesizer struct {
+	ctx     context.Context
+	tts     service.TTSService
+	sink    AudioSink
+	sem     chan struct{}
+	queue   chan *pendingSentence
+	done    chan struct{}
+	nextSeq int
+	emitted int
+}
+
+// NewSentenceSynthesizer 创建句子合成器，并启动按序发送的协程
+// ctx 被取消（用户打断）时，未开始的合成会被跳过，已合成但未发送的音频会被丢弃
+func NewSentenceSynthesizer(ctx context.Context, tts service.TTSService, sink AudioSink) *SentenceSynthesizer {
+	s := &SentenceSynthesizer{
+		ctx:   ctx,
+		tts:   tts,
+		sink:  sink,
+		sem:   make(chan struct{}, sentenceTTSConcurrency),
+		queue: make(chan *pendingSentence, sentenceQueueSize),
+		done:  make(chan struct{}),
+	}
+	go s.emitLoop()
+	return s
+}
+
+// Enqueue 提交一个句子进行合成（非阻塞，除非队列已满）
+func (s *SentenceSynthesizer) Enqueue(text string) {
+	if text == "" || s.ctx.Err() != nil {
+		return
+	}
+
+	p := &pendingSentence{
+		seq:    s.nextSeq,
+		text:   text,
+		result: make(chan synthResult, 1),
+	}
+	s.nextSeq++
+
+	go s.synthesize(p)
+
+	select {
+	case s.queue <- p:
+	case <-s.ctx.Done():
+	}
+}
+
+// Close 表示不再有新句子，等待已入队的句子全部发送（或被打断）后返回已发送的分片数
+func (s *SentenceSynthesizer) Close() int {
+	close(s.queue)
+	<-s.done
+	return s.emitted
+}
+
+// synthesize 在并发限制内合成单个句子
+func (s *SentenceSynthesizer) synthesize(p *pendingSentence) {
+	select {
+	case s.sem <- struct{}{}:
+		defer func() { <-s.sem }()
+	case <-s.ctx.Done():
+		p.result <- synthResult{err: s.ctx.Err()}
+		return
+	}
+
+	if err := s.ctx.Err(); err != nil {
+		p.result <- synthResult{err: err}
+		return
+	}
+
+	audio, err := s.tts.Synthesize(s.ctx, service.DefaultTTSOptions(p.text))
+	p.result <- synthResult{audio: audio, err: err}
+}
+
+// emitLoop 按序号顺序等待合成结果并交给 sink
+func (s *SentenceSynthesizer) emitLoop() {
+	defer close(s.done)
+
+	for p := range s.queue {
+		var r synthResult
+		select {
+		case r = <-p.result:
+		case <-s.ctx.Done():
+			s.drain()
+			return
+		}
+
+		if r.err != nil {
+			if s.ctx.Err() != nil {
+				s.drain()
+				return
+			}
+			// 单句合成失败只跳过该句，不影响后续句子
+			log.Printf("[TTS] 句子 #%d 合成失败，已跳过: %v", p.seq, r.err)
+			continue
+		}
+
+		if err := s.sink(AudioChunk{Seq: p.seq, Text: p.text, Audio: r.audio}); err != nil {
+			log.Printf("[TTS] 音频分片 #%d 发送失败: %v", p.seq, err)
+			s.drain()
+			return
+		}
+		s.emitted++
+	}
+}
+
+// drain 丢弃剩余队列，保证 Enqueue/Close 不会因为队列满而阻塞
+func (s *SentenceSynthesizer) drain() {
+	for range s.queue {
+	}
+}
@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"voice-memory/internal/service"
+)
+
+// DelayTTSService 按文本设定不同合成耗时，用于验证乱序完成时仍按序发送
+type DelayTTSService struct {
+	MockTTSService
+	Delays map[string]time.Duration
+}
+
+func (m *DelayTTSService) Synthesize(ctx context.Context, options service.TTSOptions) ([]byte, error) {
+	time.Sleep(m.Delays[options.Text])
+	return []byte(options.Text), nil
+}
+
+func TestSentenceSynthesizer_EmitsInOrder(t *testing.T) {
+	tts := &DelayTTSService{Delays: map[string]time.Duration{
+		"第一句很慢。": 50 * time.Millisecond,
+		"第二句很快。": 0,
+		"第三句一般。": 10 * time.Millisecond,
+	}}
+
+	var (
+		mu     sync.Mutex
+		chunks []AudioChunk
+	)
+	synth := NewSentenceSynthesizer(context.Background(), tts, func(c AudioChunk) error {
+		mu.Lock()
+		defer mu.Unlock()
+		chunks = append(chunks, c)
+		return nil
+	})
+
+	synth.Enqueue("第一句很慢。")
+	synth.Enqueue("第二句很快。")
+	synth.Enqueue("第三句一般。")
+
+	if n := synth.Close(); n != 3 {
+		t.Fatalf("期望发送 3 个分片, 实际 %d", n)
+	}
+	for i, c := range chunks {
+		if c.Seq != i {
+			t.Errorf("分片顺序错误: 位置 %d 的序号为 %d", i, c.Seq)
+		}
+		if string(c.Audio) != c.Text {
+			t.Errorf("分片 #%d 音频与文本不匹配", c.Seq)
+		}
+	}
+}
+
+func TestSentenceSynthesizer_StopsOnCancel(t *testing.T) {
+	tts := &DelayTTSService{Delays: map[string]time.Duration{
+		"第一句。": 0,
+		"第二句。": 100 * time.Millisecond,
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var sent int
+	synth := NewSentenceSynthesizer(ctx, tts, func(c AudioChunk) error {
+		sent++
+		if c.Seq == 0 {
+			cancel() // 第一句播放时用户打断
+		}
+		return nil
+	})
+
+	synth.Enqueue("第一句。")
+	synth.Enqueue("第二句。")
+	synth.Enqueue("第三句。")
+
+	done := make(chan int)
+	go func() { done <- synth.Close() }()
+
+	select {
+	case n := <-done:
+		if n != 1 || sent != 1 {
+			t.Errorf("打断后不应再发送音频, 实际发送 %d", sent)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("打断后 Close 应立即返回")
+	}
+}
@@ -3,6 +3,7 @@ package pipeline
 import (
 	"fmt"
 	"strings"
+	"voice-memory/internal/audio"
 	"voice-memory/internal/service"
 )
 
@@ -34,11 +35,17 @@ func (p *STTProcessor) Process(ctx *PipelineContext) (bool, error) {
 		return false, fmt.Errorf("input audio is empty")
 	}
 
+	// 统一转换为 16kHz 单声道 PCM（WAV 按文件头解析，裸数据视为 16kHz PCM）
+	pcm, err := audio.Normalize(ctx.InputAudio, audio.PCM16kMono)
+	if err != nil {
+		return false, fmt.Errorf("invalid input audio: %w", err)
+	}
+
 	// 调用通用 STT 接口
-	results, err := p.sttService.Recognize(&service.RecognizeRequest{
-		AudioData: ctx.InputAudio,
-		Format:    "wav", // 未来这里可以从 ctx 中获取格式信息
-		Rate:      16000,
+	results, err := p.sttService.Recognize(ctx.Context(), &service.RecognizeRequest{
+		AudioData: pcm,
+		Format:    "pcm",
+		Rate:      audio.TargetSampleRate,
 	})
 
 	if err != nil {
@@ -54,5 +61,8 @@ func (p *STTProcessor) Process(ctx *PipelineContext) (bool, error) {
 		return false, nil
 	}
 
+	// 识别完成立即通知客户端，不必等整条流水线结束
+	ctx.Emit(Event{Type: EventSTTFinal, Text: ctx.Transcript})
+
 	return true, nil
 }
\ No newline at end of file
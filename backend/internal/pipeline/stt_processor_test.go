@@ -1,17 +1,22 @@
 package pipeline
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"voice-memory/internal/audio"
 	"voice-memory/internal/service"
 )
 
 // MockSTTService 模拟的 STT 服务
 type MockSTTService struct {
-	Result []string
-	Err    error
+	Result      []string
+	Err         error
+	LastRequest *service.RecognizeRequest
 }
 
-func (m *MockSTTService) Recognize(req *service.RecognizeRequest) ([]string, error) {
+func (m *MockSTTService) Recognize(ctx context.Context, req *service.RecognizeRequest) ([]string, error) {
+	m.LastRequest = req
 	return m.Result, m.Err
 }
 
@@ -65,4 +70,37 @@ func TestSTTProcessor_Process(t *testing.T) {
 			t.Errorf("识别结果为空时应该短路(返回false)")
 		}
 	})
-}
\ No newline at end of file
+
+	t.Run("WAV 输入转换为 16kHz PCM", func(t *testing.T) {
+		mockSTT := &MockSTTService{Result: []string{"你好"}}
+		proc := NewSTTProcessor(mockSTT)
+		// 0.1 秒 48kHz 静音 WAV
+		ctx := &PipelineContext{
+			InputAudio: audio.EncodeWAV(make([]byte, 4800*2), 48000),
+		}
+
+		if _, err := proc.Process(ctx); err != nil {
+			t.Fatalf("意外错误: %v", err)
+		}
+		req := mockSTT.LastRequest
+		if req.Format != "pcm" || req.Rate != 16000 || len(req.AudioData) != 1600*2 {
+			t.Errorf("应以 16kHz PCM 调用 STT: format=%s rate=%d len=%d", req.Format, req.Rate, len(req.AudioData))
+		}
+	})
+
+	t.Run("无效 WAV 返回格式错误", func(t *testing.T) {
+		mockSTT := &MockSTTService{Result: []string{"你好"}}
+		proc := NewSTTProcessor(mockSTT)
+		ctx := &PipelineContext{
+			InputAudio: []byte("RIFF\x00\x00\x00\x00WAVEjunk"),
+		}
+
+		_, err := proc.Process(ctx)
+		if !errors.Is(err, audio.ErrMalformed) {
+			t.Errorf("期望 ErrMalformed, 实际 %v", err)
+		}
+		if mockSTT.LastRequest != nil {
+			t.Errorf("无效音频不应调用 STT")
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"voice-memory/internal/service"
+)
+
+// maxSpokenTasks 语音播报的待办条数上限，其余只报数量
+const maxSpokenTasks = 5
+
+// TaskProcessor 待办查询处理器
+// 位于 Intent 之后：识别到 "我今天有什么待办" 这类查询时直接读出待办并短路，不再调用 LLM
+type TaskProcessor struct {
+	tasks          *service.TaskStore
+	sessionManager *service.SessionManager
+	ttsService     service.TTSService // 可选：设置后把回复合成语音通过 ctx.AudioSink 推送
+	now            func() time.Time
+}
+
+// NewTaskProcessor 创建待办查询处理器
+// tasks 为 nil 时处理器不做任何事，待办查询按普通对话交给 LLM
+func NewTaskProcessor(tasks *service.TaskStore, sessionManager *service.SessionManager) *TaskProcessor {
+	return &TaskProcessor{
+		tasks:          tasks,
+		sessionManager: sessionManager,
+		now:            time.Now,
+	}
+}
+
+// SetSentenceTTS 开启语音播报（与 LLMProcessor 共用句子级 TTS 的音频通道）
+func (p *TaskProcessor) SetSentenceTTS(ttsService service.TTSService) {
+	p.ttsService = ttsService
+}
+
+func (p *TaskProcessor) Name() string {
+	return "Task"
+}
+
+func (p *TaskProcessor) Process(ctx *PipelineContext) (bool, error) {
+	if p.tasks == nil || ctx.Intent.Intent != service.IntentTodo {
+		return true, nil
+	}
+
+	now := p.now()
+	label, until := todoWindow(ctx.Transcript, now)
+	tasks, err := p.tasks.ListTasks(service.TaskFilter{
+		Status:         service.TaskPending,
+		DueBefore:      &until,
+		IncludeUndated: true,
+	})
+	if err != nil {
+		return false, fmt.Errorf("query tasks failed: %w", err)
+	}
+
+	replyDirectly(ctx, p.sessionManager, p.ttsService, buildTaskReply(label, tasks, now))
+	log.Printf("[Task] 已播报%s的待办 (%d 条)", label, len(tasks))
+	return false, nil
+}
+
+// todoWindow 根据问法确定查询范围：默认今天，"这周" 到本周日，"明天"、"下周三" 等到那天结束
+// 范围内包含已过期的待办
+func todoWindow(text string, now time.Time) (string, time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	if strings.Contains(text, "这周") || strings.Contains(text, "本周") {
+		weekStart := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		return "这周", weekStart.AddDate(0, 0, 7)
+	}
+
+	if due := service.ParseDueDate(text, now); due != nil {
+		day := time.Date(due.Time.Year(), due.Time.Month(), due.Time.Day(), 0, 0, 0, 0, now.Location())
+		switch {
+		case day.Equal(today):
+			return "今天", today.AddDate(0, 0, 1)
+		case day.Equal(today.AddDate(0, 0, 1)):
+			return "明天", day.AddDate(0, 0, 1)
+		case day.After(today):
+			return fmt.Sprintf("到%d月%d日", day.Month(), day.Day()), day.AddDate(0, 0, 1)
+		}
+	}
+	return "今天", today.AddDate(0, 0, 1)
+}
+
+// buildTaskReply 构造适合朗读的待办回复
+func buildTaskReply(label string, tasks []service.Task, now time.Time) string {
+	var dated, undated []service.Task
+	for _, t := range tasks {
+		if t.DueAt == nil {
+			undated = append(undated, t)
+		} else {
+			dated = append(dated, t)
+		}
+	}
+
+	if len(dated) == 0 && len(undated) == 0 {
+		return label + "没有待办事项，可以轻松一下～"
+	}
+
+	var sb strings.Builder
+	if len(dated) == 0 {
+		sb.WriteString(label + "没有到期的待办")
+	} else {
+		sb.WriteString(fmt.Sprintf("%s有 %d 件待办：", label, len(dated)))
+		items := make([]string, 0, maxSpokenTasks)
+		for i, t := range dated {
+			if i == maxSpokenTasks {
+				break
+			}
+			items = append(items, fmt.Sprintf("%d. %s%s", i+1, t.Title, taskDueNote(t, now)))
+		}
+		sb.WriteString(strings.Join(items, "；"))
+		if len(dated) > maxSpokenTasks {
+			sb.WriteString(fmt.Sprintf("；还有 %d 件就不一一念了", len(dated)-maxSpokenTasks))
+		}
+	}
+	sb.WriteString("。")
+
+	if len(undated) > 0 {
+		sb.WriteString(fmt.Sprintf("另外还有 %d 件没定截止时间", len(undated)))
+		if len(dated) == 0 {
+			titles := make([]string, 0, 3)
+			for i, t := range undated {
+				if i == 3 {
+					break
+				}
+				titles = append(titles, t.Title)
+			}
+			sb.WriteString("，比如" + strings.Join(titles, "、"))
+		}
+		sb.WriteString("。")
+	}
+	return sb.String()
+}
+
+// taskDueNote 截止时间说明，如 "（已过期）"、"（15:00）"、"（3月15日）"
+func taskDueNote(t service.Task, now time.Time) string {
+	if t.Overdue(now) {
+		return "（已过期）"
+	}
+	due := *t.DueAt
+	sameDay := due.Year() == now.Year() && due.YearDay() == now.YearDay()
+	switch {
+	case t.AllDay && sameDay:
+		return ""
+	case t.AllDay:
+		return fmt.Sprintf("（%d月%d日）", due.Month(), due.Day())
+	case sameDay:
+		return fmt.Sprintf("（%s）", due.Format("15:04"))
+	default:
+		return fmt.Sprintf("（%d月%d日 %s）", due.Month(), due.Day(), due.Format("15:04"))
+	}
+}
@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"voice-memory/internal/service"
+)
+
+func TestTaskProcessor_Process(t *testing.T) {
+	// 2026-03-11 是周三
+	now := time.Date(2026, 3, 11, 10, 0, 0, 0, time.Local)
+
+	db, err := service.NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	repo := service.NewKnowledgeRepository(db, nil)
+	for _, k := range []*service.Knowledge{
+		{ID: "kb_1", Title: "周会", CreatedAt: now.AddDate(0, 0, -2),
+			ActionItems: []string{"明天提交报销"}},
+		{ID: "kb_2", Title: "计划", CreatedAt: now,
+			ActionItems: []string{"下午3点开评审会", "今天给妈妈打电话", "周五交周报", "整理读书笔记"}},
+	} {
+		if err := repo.Save(context.Background(), k); err != nil {
+			t.Fatalf("保存知识失败: %v", err)
+		}
+	}
+
+	newProcessor := func() (*TaskProcessor, *service.SessionManager) {
+		sm := service.NewSessionManagerWithDB(db)
+		sm.GetOrCreateSession("sess_1")
+		proc := NewTaskProcessor(service.NewTaskStore(db), sm)
+		proc.now = func() time.Time { return now }
+		return proc, sm
+	}
+
+	t.Run("读出今天的待办并短路", func(t *testing.T) {
+		proc, sm := newProcessor()
+		var events []Event
+		ctx := &PipelineContext{
+			SessionID:  "sess_1",
+			Transcript: "我今天有什么待办",
+			Intent:     service.IntentResult{Intent: service.IntentTodo},
+			Events:     EventSinkFunc(func(e Event) { events = append(events, e) }),
+		}
+
+		cont, err := proc.Process(ctx)
+		if err != nil {
+			t.Fatalf("意外错误: %v", err)
+		}
+		if cont {
+			t.Errorf("待办查询应该短路(不交给LLM)")
+		}
+
+		want := "今天有 3 件待办：1. 明天提交报销（已过期）；2. 今天给妈妈打电话；3. 下午3点开评审会（15:00）。另外还有 1 件没定截止时间。"
+		if ctx.LLMReply != want {
+			t.Errorf("回复错误:\n期望 %s\n实际 %s", want, ctx.LLMReply)
+		}
+		if len(events) != 1 || events[0].Type != EventLLMDone || events[0].Text != want {
+			t.Errorf("应推送完整回复: %+v", events)
+		}
+		if history := sm.GetMessages("sess_1"); len(history) != 2 {
+			t.Errorf("应记录问答到会话, 实际 %d 条", len(history))
+		}
+	})
+
+	t.Run("按问法扩大查询范围", func(t *testing.T) {
+		proc, _ := newProcessor()
+		ctx := &PipelineContext{
+			Transcript: "这周还有哪些要做的事",
+			Intent:     service.IntentResult{Intent: service.IntentTodo},
+		}
+		proc.Process(ctx)
+		if !strings.HasPrefix(ctx.LLMReply, "这周有 4 件待办") || !strings.Contains(ctx.LLMReply, "周五交周报（3月13日）") {
+			t.Errorf("回复错误: %s", ctx.LLMReply)
+		}
+	})
+
+	t.Run("非待办意图继续执行", func(t *testing.T) {
+		proc, _ := newProcessor()
+		ctx := &PipelineContext{
+			Transcript: "今天天气怎么样",
+			Intent:     service.IntentResult{Intent: service.IntentChat},
+		}
+		cont, _ := proc.Process(ctx)
+		if !cont || ctx.LLMReply != "" {
+			t.Errorf("非待办意图不应处理")
+		}
+	})
+
+	t.Run("未配置待办存储时交给LLM", func(t *testing.T) {
+		proc := NewTaskProcessor(nil, nil)
+		ctx := &PipelineContext{
+			Transcript: "我今天有什么待办",
+			Intent:     service.IntentResult{Intent: service.IntentTodo},
+		}
+		if cont, _ := proc.Process(ctx); !cont {
+			t.Errorf("未配置待办存储时应继续执行")
+		}
+	})
+}
+
+func TestBuildTaskReply(t *testing.T) {
+	now := time.Date(2026, 3, 11, 10, 0, 0, 0, time.Local)
+
+	t.Run("没有待办", func(t *testing.T) {
+		if got := buildTaskReply("今天", nil, now); got != "今天没有待办事项，可以轻松一下～" {
+			t.Errorf("回复错误: %s", got)
+		}
+	})
+
+	t.Run("只有没定时间的待办", func(t *testing.T) {
+		tasks := []service.Task{{Title: "整理笔记"}, {Title: "学吉他"}}
+		want := "今天没有到期的待办。另外还有 2 件没定截止时间，比如整理笔记、学吉他。"
+		if got := buildTaskReply("今天", tasks, now); got != want {
+			t.Errorf("回复错误: %s", got)
+		}
+	})
+
+	t.Run("超过播报上限只报数量", func(t *testing.T) {
+		var tasks []service.Task
+		for i := 0; i < maxSpokenTasks+2; i++ {
+			due := now.Add(time.Duration(i+1) * time.Minute)
+			tasks = append(tasks, service.Task{Title: "事项", Status: service.TaskPending, DueAt: &due})
+		}
+		if got := buildTaskReply("今天", tasks, now); !strings.HasSuffix(got, "还有 2 件就不一一念了。") {
+			t.Errorf("回复错误: %s", got)
+		}
+	})
+}
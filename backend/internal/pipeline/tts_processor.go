@@ -31,7 +31,7 @@ func (p *TTSProcessor) Process(ctx *PipelineContext) (bool, error) {
 	// 使用默认选项进行合成
 	options := service.DefaultTTSOptions(ctx.LLMReply)
 	
-	audioData, err := p.ttsService.Synthesize(options)
+	audioData, err := p.ttsService.Synthesize(ctx.Context(), options)
 	if err != nil {
 		return false, fmt.Errorf("tts synthesis failed: %w", err)
 	}
@@ -9,10 +9,18 @@ import (
 // MockTTSService 模拟的 TTS 服务
 type MockTTSService struct{}
 
-func (m *MockTTSService) Synthesize(options service.TTSOptions) ([]byte, error) {
+func (m *MockTTSService) Synthesize(ctx context.Context, options service.TTSOptions) ([]byte, error) {
 	return []byte("fake-audio-data"), nil
 }
 
+func (m *MockTTSService) SynthesizeToFile(ctx context.Context, options service.TTSOptions) (string, error) {
+	return "fake.mp3", nil
+}
+
+func (m *MockTTSService) ServeAudio(filename string) ([]byte, string, error) {
+	return []byte("fake-audio-data"), "audio/mpeg", nil
+}
+
 func TestTTSProcessor_Process(t *testing.T) {
 	mockTTS := &MockTTSService{}
 	proc := NewTTSProcessor(mockTTS)
@@ -13,11 +13,18 @@ type PipelineContext struct {
 	SessionID string             // 当前会话 ID
 
 	// 数据槽位
-	InputAudio  []byte               // 输入音频原始数据
-	Transcript  string               // STT 转写后的文本内容
-	Intent      service.IntentResult // 意图识别结果
-	LLMReply    string               // LLM 生成的文本回复内容
-	OutputAudio []byte               // TTS 合成后的音频数据（可选，如果是流式播放则可能在 Processor 内部直接发送）
+	InputAudio  []byte                     // 输入音频原始数据
+	Transcript  string                     // STT 转写后的文本内容
+	Intent      service.IntentResult       // 意图识别结果
+	Retrieved   []*service.RetrievalResult // RAG 检索到的相关知识（LLM 据此回答并标注引用）
+	History     []service.MessageSearchHit // 检索到的相关历史对话（"我之前聊过什么…" 时由 LLM 据此回答）
+	LLMReply    string                     // LLM 生成的文本回复内容
+	OutputAudio []byte                     // TTS 合成后的音频数据（可选，如果是流式播放则可能在 Processor 内部直接发送）
+	MessageIDs  []int64                    // 本轮写入会话的消息 ID（用户消息在前），整理出的知识据此引用来源
+
+	// 输出通道
+	Events    EventSink // 中间结果（增量文本等）的接收方，为 nil 时不推送
+	AudioSink AudioSink // 句子级 TTS 音频分片的发送通道（为 nil 时不做流式合成）
 }
 
 // NewPipelineContext 创建一个新的流水线上下文
@@ -30,6 +37,14 @@ func NewPipelineContext(parent context.Context, sessionID string) *PipelineConte
 	}
 }
 
+// Context 返回传给下游服务调用的 context（未设置 Ctx 时返回 Background）
+func (c *PipelineContext) Context() context.Context {
+	if c.Ctx == nil {
+		return context.Background()
+	}
+	return c.Ctx
+}
+
 // Processor 处理器接口。每一个环节（如 STTProcessor, LLMProcessor）都要实现这个接口。
 type Processor interface {
 	// Name 返回处理器的名称
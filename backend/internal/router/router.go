@@ -12,6 +12,9 @@ type RouterConfig struct {
 	STTHandler       *handler.STTHandler
 	KnowledgeHandler *handler.KnowledgeHandler
 	SessionHandler   *handler.SessionHandler
+	GraphHandler     *handler.GraphHandler
+	TaskHandler      *handler.TaskHandler
+	ReminderHandler  *handler.ReminderHandler
 	TTSHandler       *handler.TTSHandler
 	WSHandler        *handler.WSHandler
 }
@@ -23,7 +26,7 @@ func Setup(cfg RouterConfig) *gin.Engine {
 	// 配置 CORS
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
@@ -47,6 +50,44 @@ func Setup(cfg RouterConfig) *gin.Engine {
 		knowledge.POST("/record", cfg.KnowledgeHandler.HandleRecord)
 		knowledge.GET("/list", cfg.KnowledgeHandler.HandleList)
 		knowledge.POST("/search", cfg.KnowledgeHandler.HandleSearch)
+		knowledge.POST("/reconcile", cfg.KnowledgeHandler.HandleReconcile)
+		knowledge.GET("/index", cfg.KnowledgeHandler.HandleIndexStatus)
+		knowledge.POST("/index", cfg.KnowledgeHandler.HandleIndexStart)
+		knowledge.GET("/:id", cfg.KnowledgeHandler.HandleGet)
+		knowledge.GET("/:id/messages", cfg.KnowledgeHandler.HandleMessages)
+		knowledge.PUT("/:id", cfg.KnowledgeHandler.HandleReplace)
+		knowledge.PATCH("/:id", cfg.KnowledgeHandler.HandlePatch)
+		knowledge.DELETE("/:id", cfg.KnowledgeHandler.HandleDelete)
+	}
+
+	// 知识图谱路由 (:id 可以是实体 ID 或名称)
+	graph := router.Group("/api/graph")
+	{
+		graph.GET("/entities", cfg.GraphHandler.HandleListEntities)
+		graph.GET("/entities/:id", cfg.GraphHandler.HandleGetEntity)
+		graph.GET("/entities/:id/neighbors", cfg.GraphHandler.HandleNeighbors)
+		graph.GET("/entities/:id/graph", cfg.GraphHandler.HandleTraverse)
+		graph.GET("/entities/:id/knowledge", cfg.GraphHandler.HandleMentions)
+		graph.POST("/entities/:id/aliases", cfg.GraphHandler.HandleAddAlias)
+		graph.POST("/entities/:id/merge", cfg.GraphHandler.HandleMerge)
+	}
+
+	// 待办路由
+	tasks := router.Group("/api/tasks")
+	{
+		tasks.GET("", cfg.TaskHandler.HandleList)
+		tasks.GET("/:id", cfg.TaskHandler.HandleGet)
+		tasks.POST("/:id/complete", cfg.TaskHandler.HandleComplete)
+		tasks.POST("/:id/snooze", cfg.TaskHandler.HandleSnooze)
+	}
+
+	// 定时提醒路由
+	reminders := router.Group("/api/reminders")
+	{
+		reminders.POST("", cfg.ReminderHandler.HandleCreate)
+		reminders.GET("", cfg.ReminderHandler.HandleList)
+		reminders.GET("/:id", cfg.ReminderHandler.HandleGet)
+		reminders.DELETE("/:id", cfg.ReminderHandler.HandleCancel)
 	}
 
 	// 会话历史路由 (用于前端展示归档)
@@ -54,6 +95,9 @@ func Setup(cfg RouterConfig) *gin.Engine {
 	{
 		sessions.GET("", cfg.SessionHandler.HandleListSessions)
 		sessions.GET("/get", cfg.SessionHandler.HandleGetSession)
+		sessions.GET("/search", cfg.SessionHandler.HandleSearchMessages)
+		sessions.GET("/:id/messages", cfg.SessionHandler.HandleListMessages)
+		sessions.PATCH("/:id", cfg.SessionHandler.HandleUpdateSession)
 		sessions.DELETE("", cfg.SessionHandler.HandleDeleteSession)
 	}
 
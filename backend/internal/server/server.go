@@ -0,0 +1,250 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"voice-memory/internal/config"
+	"voice-memory/internal/handler"
+	"voice-memory/internal/router"
+	"voice-memory/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Server 服务器
+type Server struct {
+	config      *config.Config
+	database    *service.Database
+	vectorStore service.VectorStore
+	indexer     *service.KnowledgeIndexer
+	reminders   *service.ReminderScheduler
+	summarizer  *service.SessionSummarizer
+	titler      *service.SessionTitler
+	httpServer  *gin.Engine
+}
+
+// New 创建服务器
+func New(cfg *config.Config) (*Server, error) {
+	// 数据目录
+	dataDir := "./data"
+
+	// 创建数据库
+	database, err := service.NewDatabase(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("初始化数据库失败: %w", err)
+	}
+
+	// 音频目录
+	audioDir := fmt.Sprintf("%s/audio", dataDir)
+
+	// 创建基础服务
+	var sttService service.STTService
+	if cfg.STTProvider == "sherpa" {
+		fmt.Printf("🎤 使用 Sherpa STT: %s\n", cfg.SherpaSTTAddr)
+		sttService = service.NewSherpaSTT(cfg.SherpaSTTAddr)
+	} else {
+		fmt.Printf("🎤 使用 Baidu STT\n")
+		sttService = service.NewBaiduSTT(cfg.BaiduAPIKey, cfg.BaiduSecretKey)
+	}
+
+	var ttsService service.TTSService
+	if cfg.TTSProvider == "sherpa" {
+		fmt.Printf("🔊 使用 Sherpa TTS: %s\n", cfg.SherpaTTSAddr)
+		ttsService = service.NewSherpaTTSWithDir(cfg.SherpaTTSAddr, audioDir)
+	} else {
+		fmt.Printf("🔊 使用 Baidu TTS\n")
+		ttsService = service.NewBaiduTTSWithDir(cfg.BaiduAPIKey, cfg.BaiduSecretKey, audioDir)
+	}
+
+	glmClient := service.NewGLMClient(cfg.GLMAPIKey)
+
+	// 意图识别：关键词优先，不确定时（如 "我不要吃辣" 只命中 "不要"）交给 LLM，LLM 失败时退回关键词结果
+	var intentService service.IntentService = service.NewIntentRecognizer()
+	if cfg.IntentLLM {
+		rules := service.NewIntentRecognizer()
+		intentService = service.NewCompositeIntentRecognizer(rules, service.NewLLMIntentClassifier(glmClient, rules))
+		fmt.Printf("🧠 意图识别: 关键词 + %s\n", service.IntentClassifierModel)
+	}
+
+	// 创建向量存储
+	vectorStore, err := newVectorStore(cfg, dataDir, database)
+	if err != nil {
+		return nil, fmt.Errorf("创建向量存储失败: %w", err)
+	}
+	ragService := service.NewRAGService(cfg.GLMAPIKey, vectorStore)
+
+	knowledgeRepo := service.NewKnowledgeRepository(database, ragService)
+
+	// 知识图谱：为升级前保存的知识补建实体和关系
+	knowledgeGraph := service.NewKnowledgeGraph(database)
+	ragService.SetKnowledgeGraph(knowledgeGraph)
+	if count, err := knowledgeGraph.Backfill(); err != nil {
+		fmt.Printf("⚠️ 补建知识图谱失败: %v\n", err)
+	} else if count > 0 {
+		fmt.Printf("🕸️ 已为 %d 条知识补建图谱\n", count)
+	}
+
+	// 待办：为升级前保存的知识中的行动项生成待办
+	taskStore := service.NewTaskStore(database)
+	if count, err := taskStore.Backfill(); err != nil {
+		fmt.Printf("⚠️ 生成待办失败: %v\n", err)
+	} else if count > 0 {
+		fmt.Printf("✅ 已为 %d 条知识生成待办\n", count)
+	}
+
+	// 定时提醒：触发时推送到 WebSocket（由 wsHandler 注册）和可选的 Webhook
+	reminderScheduler := service.NewReminderScheduler(database)
+	if cfg.ReminderWebhookURL != "" {
+		reminderScheduler.AddDeliverer(service.NewWebhookDeliverer(cfg.ReminderWebhookURL))
+		fmt.Printf("🔔 提醒 Webhook: %s\n", cfg.ReminderWebhookURL)
+	}
+
+	// 后台索引：只向量化新增或正文变化的知识，清理已删除知识的向量，不阻塞启动
+	indexer := service.NewKnowledgeIndexer(knowledgeRepo)
+	indexer.Start()
+
+	// 创建会话管理器（带数据库）
+	sessionManager := service.NewSessionManagerWithDB(database)
+
+	// 上下文压缩：较早的对话在后台增量生成摘要，LLM 请求只带摘要和最近的消息
+	compressor := service.NewContextCompressor(glmClient, service.DefaultContextConfig())
+	summarizer := service.NewSessionSummarizer(compressor, sessionManager)
+
+	// 创建知识组织器
+	knowledgeOrganizer := service.NewKnowledgeOrganizer(glmClient)
+
+	// 会话标题：前几轮对话后在后台自动生成
+	titler := service.NewSessionTitler(knowledgeOrganizer, sessionManager)
+
+	// 创建处理器 (仅保留必要的)
+	sttHandler := handler.NewSTTHandler(sttService)
+	knowledgeHandler := handler.NewKnowledgeHandler(sttService, knowledgeOrganizer, database, audioDir)
+	knowledgeHandler.SetKnowledgeRepository(knowledgeRepo)
+	knowledgeHandler.SetIndexer(indexer)
+	sessionHandler := handler.NewSessionHandler(sessionManager)
+	graphHandler := handler.NewGraphHandler(knowledgeGraph, database)
+	taskHandler := handler.NewTaskHandler(taskStore)
+	reminderHandler := handler.NewReminderHandler(reminderScheduler)
+	ttsHandler := handler.NewTTSHandler(ttsService)
+
+	// WebSocket 处理器 (核心)
+	wsHandler := handler.NewWSHandler(
+		sessionManager,
+		sttService,
+		glmClient,
+		ttsService,
+		intentService,
+		knowledgeOrganizer,
+		database,
+	)
+	wsHandler.SetKnowledgeRepository(knowledgeRepo)
+	wsHandler.SetRetrievalService(knowledgeRepo) // 对话检索与知识搜索共用混合检索（BM25 + 向量）
+	wsHandler.SetTaskStore(taskStore)
+	wsHandler.SetReminderScheduler(reminderScheduler)
+	wsHandler.SetSessionSummarizer(summarizer)
+	wsHandler.SetSessionTitler(titler)
+	reminderScheduler.Start() // 投递渠道注册完毕后再启动，停机期间错过的提醒立即触发
+	wsHandler.SetSentenceTTS(cfg.TTSStreaming)
+	wsHandler.SetVADConfig(service.VADConfig{
+		EnergyThreshold: cfg.VADEnergyThreshold,
+		ZCRThreshold:    cfg.VADZCRThreshold,
+		HangoverMs:      cfg.VADHangoverMs,
+		MinSpeechMs:     cfg.VADMinSpeechMs,
+		MaxSpeechMs:     cfg.VADMaxSpeechMs,
+		PreRollMs:       service.DefaultVADConfig().PreRollMs,
+	})
+
+	// 配置路由
+	httpServer := router.Setup(router.RouterConfig{
+		STTHandler:       sttHandler,
+		KnowledgeHandler: knowledgeHandler,
+		SessionHandler:   sessionHandler,
+		GraphHandler:     graphHandler,
+		TaskHandler:      taskHandler,
+		ReminderHandler:  reminderHandler,
+		TTSHandler:       ttsHandler,
+		WSHandler:        wsHandler,
+	})
+
+	return &Server{
+		config:      cfg,
+		database:    database,
+		vectorStore: vectorStore,
+		indexer:     indexer,
+		reminders:   reminderScheduler,
+		summarizer:  summarizer,
+		titler:      titler,
+		httpServer:  httpServer,
+	}, nil
+}
+
+// Run 启动服务器
+func (s *Server) Run() error {
+	addr := fmt.Sprintf(":%s", s.config.ServerPort)
+	s.printRoutes(addr)
+	if err := s.httpServer.Run(addr); err != nil {
+		return fmt.Errorf("启动服务器失败: %w", err)
+	}
+	return nil
+}
+
+// Close 关闭服务器
+func (s *Server) Close() error {
+	s.indexer.Stop()
+	s.reminders.Stop()
+	s.summarizer.Wait()
+	s.titler.Wait()
+	if closer, ok := s.vectorStore.(io.Closer); ok {
+		closer.Close()
+	}
+	return s.database.Close()
+}
+
+// newVectorStore 按配置创建向量存储
+func newVectorStore(cfg *config.Config, dataDir string, database *service.Database) (service.VectorStore, error) {
+	switch cfg.VectorStore {
+	case "hnsw":
+		fmt.Printf("🧭 使用 HNSW 向量索引\n")
+		store, err := service.NewHNSWVectorStore(dataDir, service.HNSWConfig{
+			M:              cfg.HNSWM,
+			EfConstruction: cfg.HNSWEfConstruction,
+			EfSearch:       cfg.HNSWEfSearch,
+		})
+		if err != nil {
+			return nil, err
+		}
+		importLegacyVectors(store, dataDir)
+		return store, nil
+	case "sqlite":
+		fmt.Printf("🗄️  使用 SQLite 向量存储 (knowledge_vectors)\n")
+		store := service.NewSQLiteVectorStore(database)
+		importLegacyVectors(store, dataDir)
+		return store, nil
+	default:
+		return service.NewSimpleVectorStore(dataDir)
+	}
+}
+
+// importLegacyVectors 首次从 simple 切换时从 vectors.json 导入，避免重新调用 Embedding
+func importLegacyVectors(store service.VectorStore, dataDir string) {
+	if ids, err := store.IDs(); err != nil || len(ids) > 0 {
+		return
+	}
+	legacy, err := service.NewSimpleVectorStore(dataDir)
+	if err != nil {
+		return
+	}
+	if n, err := service.MigrateVectors(store, legacy); err != nil {
+		fmt.Printf("⚠️  导入 vectors.json 失败: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("📦 已从 vectors.json 导入 %d 条向量\n", n)
+	}
+}
+
+// printRoutes 打印路由信息
+func (s *Server) printRoutes(addr string) {
+	fmt.Printf("🚀 Voice Memory Backend 启动成功 (Phase 2 Architecture)\n")
+	fmt.Printf("📍 服务地址: http://localhost%s\n", addr)
+	fmt.Printf("🔌 WebSocket: ws://localhost%s/ws\n", addr)
+	fmt.Printf("📋 其他接口已清理，请优先使用 WebSocket 进行交互\n\n")
+}
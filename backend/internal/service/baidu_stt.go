@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -89,7 +90,7 @@ func (b *BaiduSTT) saveTokenToFile(expiresAt time.Time) error {
 }
 
 // getAccessToken 获取访问令牌
-func (b *BaiduSTT) getAccessToken() (string, error) {
+func (b *BaiduSTT) getAccessToken(ctx context.Context) (string, error) {
 	// 如果内存中 token 为空，尝试从文件加载
 	if b.token == "" {
 		if b.loadTokenFromFile() {
@@ -109,7 +110,13 @@ func (b *BaiduSTT) getAccessToken() (string, error) {
 	url := fmt.Sprintf("https://aip.baidubce.com/oauth/2.0/token?grant_type=client_credentials&client_id=%s&client_secret=%s",
 		b.apiKey, b.secretKey)
 
-	resp, err := b.client.Post(url, "application/json", nil)
+	tokenReq, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("创建 token 请求失败: %w", err)
+	}
+	tokenReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(tokenReq)
 	if err != nil {
 		return "", fmt.Errorf("请求 token 失败: %w", err)
 	}
@@ -162,9 +169,9 @@ type RecognizeResponse struct {
 }
 
 // Recognize 语音识别
-func (b *BaiduSTT) Recognize(req *RecognizeRequest) ([]string, error) {
+func (b *BaiduSTT) Recognize(ctx context.Context, req *RecognizeRequest) ([]string, error) {
 	// 获取 access_token
-	token, err := b.getAccessToken()
+	token, err := b.getAccessToken(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -187,7 +194,13 @@ func (b *BaiduSTT) Recognize(req *RecognizeRequest) ([]string, error) {
 
 	// 发送识别请求
 	url := "https://vop.baidu.com/server_api"
-	httpResp, err := b.client.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建识别请求失败: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := b.client.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("识别请求失败: %w", err)
 	}
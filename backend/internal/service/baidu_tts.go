@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
@@ -11,6 +12,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -128,7 +130,7 @@ func (b *BaiduTTS) saveTokenToFile(expiresAt time.Time) error {
 }
 
 // getAccessToken 获取访问令牌（复用 STT 的 token）
-func (b *BaiduTTS) getAccessToken() (string, error) {
+func (b *BaiduTTS) getAccessToken(ctx context.Context) (string, error) {
 	// 如果内存中 token 为空，尝试从文件加载
 	if b.token == "" {
 		if b.loadTokenFromFile() {
@@ -145,7 +147,13 @@ func (b *BaiduTTS) getAccessToken() (string, error) {
 	reqURL := fmt.Sprintf("https://aip.baidubce.com/oauth/2.0/token?grant_type=client_credentials&client_id=%s&client_secret=%s",
 		b.apiKey, b.secretKey)
 
-	resp, err := b.client.Post(reqURL, "application/json", nil)
+	tokenReq, err := http.NewRequestWithContext(ctx, "POST", reqURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("创建 token 请求失败: %w", err)
+	}
+	tokenReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(tokenReq)
 	if err != nil {
 		return "", fmt.Errorf("请求 token 失败: %w", err)
 	}
@@ -181,9 +189,9 @@ func (b *BaiduTTS) getAccessToken() (string, error) {
 }
 
 // Synthesize 合成语音，返回音频数据
-func (b *BaiduTTS) Synthesize(options TTSOptions) ([]byte, error) {
+func (b *BaiduTTS) Synthesize(ctx context.Context, options TTSOptions) ([]byte, error) {
 	// 获取 access_token
-	token, err := b.getAccessToken()
+	token, err := b.getAccessToken(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -216,7 +224,13 @@ func (b *BaiduTTS) Synthesize(options TTSOptions) ([]byte, error) {
 
 	// 发送 TTS 请求
 	ttsURL := "https://tsn.baidu.com/text2audio"
-	resp, err := http.PostForm(ttsURL, params)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", ttsURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("创建 TTS 请求失败: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := b.client.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("TTS 请求失败: %w", err)
 	}
@@ -243,8 +257,8 @@ func (b *BaiduTTS) Synthesize(options TTSOptions) ([]byte, error) {
 }
 
 // SynthesizeToFile 合成语音并保存到文件
-func (b *BaiduTTS) SynthesizeToFile(options TTSOptions) (string, error) {
-	audioData, err := b.Synthesize(options)
+func (b *BaiduTTS) SynthesizeToFile(ctx context.Context, options TTSOptions) (string, error) {
+	audioData, err := b.Synthesize(ctx, options)
 	if err != nil {
 		return "", err
 	}
@@ -288,8 +302,8 @@ func (b *BaiduTTS) ServeAudio(filename string) ([]byte, string, error) {
 }
 
 // SynthesizeBase64 合成语音并返回 Base64 编码
-func (b *BaiduTTS) SynthesizeBase64(options TTSOptions) (string, error) {
-	audioData, err := b.Synthesize(options)
+func (b *BaiduTTS) SynthesizeBase64(ctx context.Context, options TTSOptions) (string, error) {
+	audioData, err := b.Synthesize(ctx, options)
 	if err != nil {
 		return "", err
 	}
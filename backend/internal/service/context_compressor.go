@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -9,30 +10,32 @@ import (
 
 // ContextConfig 上下文配置
 type ContextConfig struct {
-	MaxRecentMessages  int           // 保留最近多少条原始消息
-	MaxTotalTokens     int           // 最大 token 数量
-	SummaryThreshold   int           // 触发压缩的消息数量阈值
-	SummaryMaxAge      time.Duration // 摘要最大有效期
+	MaxRecentMessages int           // 保留最近多少条原始消息
+	MaxTotalTokens    int           // 最大 token 数量
+	SummaryThreshold  int           // 触发压缩的消息数量阈值
+	SummaryBatch      int           // 增量摘要时至少累积多少条未摘要的消息才更新
+	SummaryMaxAge     time.Duration // 摘要最大有效期
 }
 
 // DefaultContextConfig 默认上下文配置
 func DefaultContextConfig() ContextConfig {
 	return ContextConfig{
-		MaxRecentMessages: 6,  // 保留最近 6 条原始消息
-		MaxTotalTokens:     4000, // 最大 4000 tokens
-		SummaryThreshold:   10,  // 超过 10 条消息触发压缩
-		SummaryMaxAge:      1 * time.Hour,
+		MaxRecentMessages: 6,    // 保留最近 6 条原始消息
+		MaxTotalTokens:    4000, // 最大 4000 tokens
+		SummaryThreshold:  10,   // 超过 10 条消息触发压缩
+		SummaryBatch:      4,    // 每累积 2 轮对话更新一次摘要
+		SummaryMaxAge:     1 * time.Hour,
 	}
 }
 
 // SessionSummary 会话摘要
 type SessionSummary struct {
-	Content       string    `json:"content"`        // 摘要内容
-	KeyPoints     []string  `json:"key_points"`     // 关键点
-	Topics        []string  `json:"topics"`         // 涉及的主题
-	MessageCount  int       `json:"message_count"`  // 摘要的消息数量
-	CreatedAt     time.Time `json:"created_at"`     // 创建时间
-	UpdatedAt     time.Time `json:"updated_at"`     // 更新时间
+	Content      string    `json:"content"`       // 摘要内容
+	KeyPoints    []string  `json:"key_points"`    // 关键点
+	Topics       []string  `json:"topics"`        // 涉及的主题
+	MessageCount int       `json:"message_count"` // 摘要覆盖的消息数量（从会话第一条消息算起）
+	CreatedAt    time.Time `json:"created_at"`    // 创建时间
+	UpdatedAt    time.Time `json:"updated_at"`    // 更新时间
 }
 
 // CompressedContext 压缩后的上下文
@@ -44,15 +47,15 @@ type CompressedContext struct {
 
 // ContextCompressor 上下文压缩器
 type ContextCompressor struct {
-	config   ContextConfig
-	glmClient *GLMClient
+	config ContextConfig
+	llm    LLMService
 }
 
-// NewContextCompressor 创建上下文压缩器
-func NewContextCompressor(glmClient *GLMClient, config ContextConfig) *ContextCompressor {
+// NewContextCompressor 创建上下文压缩器，llm 通常为 GLMClient
+func NewContextCompressor(llm LLMService, config ContextConfig) *ContextCompressor {
 	return &ContextCompressor{
-		config:    config,
-		glmClient: glmClient,
+		config: config,
+		llm:    llm,
 	}
 }
 
@@ -62,7 +65,7 @@ func (cc *ContextCompressor) ShouldCompress(messages []Message) bool {
 }
 
 // Compress 压缩上下文
-func (cc *ContextCompressor) Compress(messages []Message) (*CompressedContext, error) {
+func (cc *ContextCompressor) Compress(ctx context.Context, messages []Message) (*CompressedContext, error) {
 	if len(messages) == 0 {
 		return &CompressedContext{
 			RecentMessages: []Message{},
@@ -88,7 +91,7 @@ func (cc *ContextCompressor) Compress(messages []Message) (*CompressedContext, e
 	recentMessages := messages[splitIndex:]
 
 	// 生成摘要
-	summary, err := cc.generateSummary(historyMessages)
+	summary, err := cc.generateSummary(ctx, nil, historyMessages)
 	if err != nil {
 		// 摘要生成失败，返回原始消息
 		return &CompressedContext{
@@ -104,15 +107,79 @@ func (cc *ContextCompressor) Compress(messages []Message) (*CompressedContext, e
 	}, nil
 }
 
-// generateSummary 生成历史摘要
-func (cc *ContextCompressor) generateSummary(messages []Message) (*SessionSummary, error) {
+// CompressWithSummary 用已有摘要压缩上下文，不调用 LLM，可以放在请求路径上
+// 摘要覆盖的消息用摘要代替，其余消息从最新往前保留，直到摘要加消息超出 MaxTotalTokens；最新一条消息总是保留
+func (cc *ContextCompressor) CompressWithSummary(summary *SessionSummary, messages []Message) *CompressedContext {
+	compressed := &CompressedContext{
+		RecentMessages: messages,
+		TotalMessages:  len(messages),
+	}
+
+	budget := cc.config.MaxTotalTokens
+	// 会话被清空或裁剪后摘要可能对不上，此时忽略摘要
+	if summary != nil && summary.Content != "" && summary.MessageCount > 0 && summary.MessageCount < len(messages) {
+		compressed.Summary = summary
+		compressed.RecentMessages = messages[summary.MessageCount:]
+		budget -= cc.EstimateTokenCount([]Message{{Role: "user", Content: summary.Content}})
+	}
+
+	for len(compressed.RecentMessages) > 1 && cc.EstimateTokenCount(compressed.RecentMessages) > budget {
+		compressed.RecentMessages = compressed.RecentMessages[1:]
+	}
+	return compressed
+}
+
+// NeedsSummaryUpdate 判断是否需要增量更新摘要
+// 会话超过 SummaryThreshold 条消息，且最近 MaxRecentMessages 条之前还有至少 SummaryBatch 条消息没有摘要
+func (cc *ContextCompressor) NeedsSummaryUpdate(summary *SessionSummary, messages []Message) bool {
+	if len(messages) <= cc.config.SummaryThreshold {
+		return false
+	}
+	return cc.summaryEnd(messages)-summarizedCount(summary, messages) >= max(1, cc.config.SummaryBatch)
+}
+
+// UpdateSummary 增量更新摘要：把上次摘要之后、最近 MaxRecentMessages 条之前的消息并入摘要
+// 没有需要并入的消息时原样返回 previous
+func (cc *ContextCompressor) UpdateSummary(ctx context.Context, previous *SessionSummary, messages []Message) (*SessionSummary, error) {
+	start := summarizedCount(previous, messages)
+	if start == 0 {
+		previous = nil
+	}
+	end := cc.summaryEnd(messages)
+	if end <= start {
+		return previous, nil
+	}
+	return cc.generateSummary(ctx, previous, messages[start:end])
+}
+
+// summaryEnd 摘要应覆盖到的位置（不含最近 MaxRecentMessages 条）
+func (cc *ContextCompressor) summaryEnd(messages []Message) int {
+	return max(0, len(messages)-cc.config.MaxRecentMessages)
+}
+
+// summarizedCount 摘要已覆盖的消息数，摘要与消息对不上时视为没有摘要
+func summarizedCount(summary *SessionSummary, messages []Message) int {
+	if summary == nil || summary.MessageCount > len(messages) {
+		return 0
+	}
+	return summary.MessageCount
+}
+
+// generateSummary 生成历史摘要，previous 不为空时把 messages 并入已有摘要
+func (cc *ContextCompressor) generateSummary(ctx context.Context, previous *SessionSummary, messages []Message) (*SessionSummary, error) {
 	if len(messages) == 0 {
 		return nil, fmt.Errorf("没有消息可摘要")
 	}
 
 	// 构建对话文本
 	var dialogText strings.Builder
-	dialogText.WriteString("请总结以下对话内容（包含用户和AI的交流）：\n\n")
+	if previous != nil {
+		dialogText.WriteString("以下是之前对话的摘要：\n")
+		dialogText.WriteString(previous.Content)
+		dialogText.WriteString("\n\n请结合之后的对话内容（包含用户和AI的交流）更新摘要：\n\n")
+	} else {
+		dialogText.WriteString("请总结以下对话内容（包含用户和AI的交流）：\n\n")
+	}
 
 	for i, msg := range messages {
 		role := "用户"
@@ -128,7 +195,7 @@ func (cc *ContextCompressor) generateSummary(messages []Message) (*SessionSummar
 	dialogText.WriteString("3. 涉及主题（标签）")
 
 	// 调用 GLM 生成摘要
-	response, err := cc.glmClient.SendMessage(ChatRequest{
+	response, err := cc.llm.SendMessage(ctx, ChatRequest{
 		Model:       "glm-4-flash", // 使用快速模型生成摘要
 		MaxTokens:   512,
 		Messages: []Message{
@@ -149,6 +216,10 @@ func (cc *ContextCompressor) generateSummary(messages []Message) (*SessionSummar
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
+	if previous != nil {
+		summary.MessageCount += previous.MessageCount
+		summary.CreatedAt = previous.CreatedAt
+	}
 
 	// 提取关键点和主题（简单实现）
 	cc.extractSummaryComponents(summary)
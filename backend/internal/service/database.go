@@ -3,6 +3,7 @@ package service
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -12,9 +13,17 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// ErrKnowledgeNotFound 知识不存在
+var ErrKnowledgeNotFound = errors.New("知识不存在")
+
+// ErrKnowledgeConflict 知识已被其他请求修改（版本号不一致）
+var ErrKnowledgeConflict = errors.New("知识已被修改，请刷新后重试")
+
 // Database 数据库
 type Database struct {
-	db *sql.DB
+	db               *sql.DB
+	ftsModule        string // 全文索引使用的模块: fts5 / fts4
+	messageFTSModule string // 消息全文索引使用的模块
 }
 
 // NewDatabase 创建数据库
@@ -26,7 +35,7 @@ func NewDatabase(dataDir string) (*Database, error) {
 
 	dbPath := filepath.Join(dataDir, "voice-memory.db")
 
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open(sqliteDriverName, dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("打开数据库失败: %w", err)
 	}
@@ -40,6 +49,15 @@ func NewDatabase(dataDir string) (*Database, error) {
 	if err := database.initTables(); err != nil {
 		return nil, fmt.Errorf("初始化表失败: %w", err)
 	}
+	if err := database.initFTS(); err != nil {
+		return nil, fmt.Errorf("初始化全文索引失败: %w", err)
+	}
+	if err := database.initMessageFTS(); err != nil {
+		return nil, fmt.Errorf("初始化消息全文索引失败: %w", err)
+	}
+	if err := database.migrateSessionMessages(); err != nil {
+		return nil, fmt.Errorf("迁移会话消息失败: %w", err)
+	}
 
 	fmt.Printf("数据库初始化完成: %s\n", dbPath)
 	return database, nil
@@ -74,6 +92,38 @@ func (d *Database) initTables() error {
 			FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE SET NULL
 		)`,
 
+		// 会话摘要（SessionSummary 的 JSON，ContextCompressor 增量生成）
+		`ALTER TABLE sessions ADD COLUMN summary TEXT`,
+
+		// 会话元数据：自动生成或用户编辑的标题、标签（JSON 数组）、置顶、归档
+		`ALTER TABLE sessions ADD COLUMN title TEXT`,
+		`ALTER TABLE sessions ADD COLUMN tags TEXT`,
+		`ALTER TABLE sessions ADD COLUMN pinned INTEGER DEFAULT 0`,
+		`ALTER TABLE sessions ADD COLUMN archived INTEGER DEFAULT 0`,
+
+		// 清空对话时的上下文起点：ID 不大于它的消息只保留在存档中，不再发给 LLM
+		`ALTER TABLE sessions ADD COLUMN context_start INTEGER DEFAULT 0`,
+
+		// 会话消息：每条消息一行，保留完整历史（sessions.messages 为旧版 JSON，启动时迁移到这里）
+		`CREATE TABLE IF NOT EXISTS messages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			session_id TEXT NOT NULL,
+			role TEXT NOT NULL,
+			content TEXT NOT NULL,
+			audio_url TEXT,
+			intent TEXT,
+			latency_ms INTEGER,
+			input_tokens INTEGER,
+			output_tokens INTEGER,
+			created_at INTEGER
+		)`,
+
+		// 删除会话时级联删除消息
+		`CREATE TRIGGER IF NOT EXISTS trg_session_messages_cascade AFTER DELETE ON sessions
+		BEGIN
+			DELETE FROM messages WHERE session_id = OLD.id;
+		END`,
+
 		// 添加 title 字段（如果表已存在但没有该字段）
 		`ALTER TABLE knowledge ADD COLUMN title TEXT`,
 
@@ -87,7 +137,104 @@ func (d *Database) initTables() error {
 		`ALTER TABLE knowledge ADD COLUMN observations TEXT`,
 		`ALTER TABLE knowledge ADD COLUMN action_items TEXT`,
 
+		// 乐观锁版本号（每次编辑 +1）
+		`ALTER TABLE knowledge ADD COLUMN version INTEGER DEFAULT 1`,
+
+		// 知识来源的会话消息 ID（JSON 数组，对应 messages.id）
+		`ALTER TABLE knowledge ADD COLUMN message_ids TEXT`,
+
+		// 知识向量表（SQLiteVectorStore 使用），与知识行同库同事务写入
+		`CREATE TABLE IF NOT EXISTS knowledge_vectors (
+			knowledge_id TEXT PRIMARY KEY,
+			embedding BLOB NOT NULL,
+			metadata TEXT,
+			updated_at INTEGER
+		)`,
+
+		// 删除知识时级联删除向量（未开启 foreign_keys，用触发器实现）
+		`CREATE TRIGGER IF NOT EXISTS trg_knowledge_vectors_cascade AFTER DELETE ON knowledge
+		BEGIN
+			DELETE FROM knowledge_vectors WHERE knowledge_id = OLD.id;
+		END`,
+
+		// 知识图谱（KnowledgeGraph 使用）：实体、别名、知识提及的实体、实体关系
+		`CREATE TABLE IF NOT EXISTS entities (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL,
+			created_at INTEGER,
+			updated_at INTEGER
+		)`,
+		`CREATE TABLE IF NOT EXISTS entity_aliases (
+			alias_key TEXT PRIMARY KEY,
+			alias TEXT NOT NULL,
+			entity_id INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS knowledge_entities (
+			knowledge_id TEXT NOT NULL,
+			entity_id INTEGER NOT NULL,
+			PRIMARY KEY (knowledge_id, entity_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS relations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			source_id INTEGER NOT NULL,
+			target_id INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			context TEXT,
+			knowledge_id TEXT NOT NULL,
+			created_at INTEGER
+		)`,
+
+		// 删除知识时级联删除它在图谱中的提及和关系
+		`CREATE TRIGGER IF NOT EXISTS trg_knowledge_graph_cascade AFTER DELETE ON knowledge
+		BEGIN
+			DELETE FROM knowledge_entities WHERE knowledge_id = OLD.id;
+			DELETE FROM relations WHERE knowledge_id = OLD.id;
+		END`,
+
+		// 待办事项（TaskStore 使用）：由知识的 ActionItems 生成，due_at 为空表示没有截止时间
+		`CREATE TABLE IF NOT EXISTS tasks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			due_at INTEGER,
+			all_day INTEGER NOT NULL DEFAULT 0,
+			knowledge_id TEXT,
+			session_id TEXT,
+			created_at INTEGER,
+			updated_at INTEGER,
+			completed_at INTEGER
+		)`,
+
+		// 删除知识时保留待办，只解除来源关联
+		`CREATE TRIGGER IF NOT EXISTS trg_knowledge_tasks_unlink AFTER DELETE ON knowledge
+		BEGIN
+			UPDATE tasks SET knowledge_id = NULL WHERE knowledge_id = OLD.id;
+		END`,
+
+		// 定时提醒（ReminderScheduler 使用）：status 为 pending / fired / cancelled
+		`CREATE TABLE IF NOT EXISTS reminders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id TEXT NOT NULL DEFAULT '',
+			session_id TEXT,
+			text TEXT NOT NULL,
+			remind_at INTEGER NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			created_at INTEGER,
+			fired_at INTEGER
+		)`,
+
 		// 索引
+		`CREATE INDEX IF NOT EXISTS idx_messages_session ON messages(session_id, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_sessions_updated_at ON sessions(updated_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_reminders_status_at ON reminders(status, remind_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_tasks_status_due ON tasks(status, due_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_tasks_knowledge ON tasks(knowledge_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_entity_aliases_entity ON entity_aliases(entity_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_knowledge_entities_entity ON knowledge_entities(entity_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_relations_source ON relations(source_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_relations_target ON relations(target_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_relations_knowledge ON relations(knowledge_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_knowledge_category ON knowledge(category)`,
 		`CREATE INDEX IF NOT EXISTS idx_knowledge_session_id ON knowledge(session_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_knowledge_created_at ON knowledge(created_at)`,
@@ -105,55 +252,69 @@ func (d *Database) initTables() error {
 	return nil
 }
 
-// SaveSession 保存会话
+// SaveSession 保存会话（元数据、摘要与时间），消息通过 AppendMessage 逐条写入 messages 表
 func (d *Database) SaveSession(session *Session) error {
-	messagesJSON, err := json.Marshal(session.Messages)
-	if err != nil {
-		return err
+	var summaryJSON interface{}
+	if session.Summary != nil {
+		data, err := json.Marshal(session.Summary)
+		if err != nil {
+			return err
+		}
+		summaryJSON = string(data)
+	}
+	var tagsJSON interface{}
+	if len(session.Tags) > 0 {
+		data, _ := json.Marshal(session.Tags)
+		tagsJSON = string(data)
 	}
 
-	query := `INSERT OR REPLACE INTO sessions (id, messages, created_at, updated_at)
-			  VALUES (?, ?, ?, ?)`
+	// 不能用 INSERT OR REPLACE：替换会先删除旧行并触发消息级联删除
+	query := `INSERT INTO sessions (id, messages, title, tags, pinned, archived, summary, context_start, created_at, updated_at)
+			  VALUES (?, '[]', ?, ?, ?, ?, ?, ?, ?, ?)
+			  ON CONFLICT(id) DO UPDATE SET title = excluded.title, tags = excluded.tags,
+			  pinned = excluded.pinned, archived = excluded.archived, summary = excluded.summary,
+			  context_start = excluded.context_start, updated_at = excluded.updated_at`
 
 	createdAt := session.CreatedAt.Unix()
 	updatedAt := session.UpdatedAt.Unix()
 
-	_, err = d.db.Exec(query, session.ID, string(messagesJSON), createdAt, updatedAt)
+	_, err := d.db.Exec(query, session.ID, nullString(session.Title), tagsJSON, session.Pinned, session.Archived,
+		summaryJSON, session.ContextStart, createdAt, updatedAt)
 	return err
 }
 
-// GetSession 获取会话
+// GetSession 获取会话（含全部消息）
 func (d *Database) GetSession(sessionID string) (*Session, error) {
-	query := `SELECT id, messages, created_at, updated_at FROM sessions WHERE id = ?`
+	session, err := d.GetSessionInfo(sessionID)
+	if err != nil || session == nil {
+		return nil, err
+	}
 
-	var id string
-	var messagesJSON string
-	var createdAt, updatedAt int64
+	messages, err := d.GetSessionMessages(sessionID, 0)
+	if err != nil {
+		return nil, err
+	}
+	session.Messages = toChatMessages(messages)
+	return session, nil
+}
+
+// GetSessionInfo 获取会话（不含消息，只有消息条数），不存在时返回 nil
+func (d *Database) GetSessionInfo(sessionID string) (*Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions s WHERE id = ?`
 
-	err := d.db.QueryRow(query, sessionID).Scan(&id, &messagesJSON, &createdAt, &updatedAt)
+	session, err := scanSession(d.db.QueryRow(query, sessionID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
-
-	var messages []Message
-	if err := json.Unmarshal([]byte(messagesJSON), &messages); err != nil {
-		return nil, err
-	}
-
-	return &Session{
-		ID:        id,
-		Messages:  messages,
-		CreatedAt: time.Unix(createdAt, 0),
-		UpdatedAt: time.Unix(updatedAt, 0),
-	}, nil
+	return session, nil
 }
 
-// GetAllSessions 获取所有会话
+// GetAllSessions 获取所有会话（不含消息，只有消息条数）
 func (d *Database) GetAllSessions() ([]Session, error) {
-	query := `SELECT id, messages, created_at, updated_at FROM sessions ORDER BY updated_at DESC`
+	query := `SELECT ` + sessionColumns + ` FROM sessions s ORDER BY updated_at DESC`
 
 	rows, err := d.db.Query(query)
 	if err != nil {
@@ -163,32 +324,87 @@ func (d *Database) GetAllSessions() ([]Session, error) {
 
 	var sessions []Session
 	for rows.Next() {
-		var id string
-		var messagesJSON string
-		var createdAt, updatedAt int64
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
 
-		if err := rows.Scan(&id, &messagesJSON, &createdAt, &updatedAt); err != nil {
+	return sessions, rows.Err()
+}
+
+// sessionColumns 会话表查询列（与 scanSession 的顺序一致），表别名须为 s
+const sessionColumns = `id, COALESCE(title, ''), COALESCE(tags, ''), COALESCE(pinned, 0), COALESCE(archived, 0),
+	summary, COALESCE(context_start, 0), created_at, updated_at,
+	(SELECT COUNT(*) FROM messages m WHERE m.session_id = s.id) AS message_count`
+
+// scanSession 扫描一行会话，不含消息
+func scanSession(row rowScanner) (*Session, error) {
+	var id, title, tagsJSON string
+	var pinned, archived bool
+	var summaryJSON sql.NullString
+	var contextStart, createdAt, updatedAt int64
+	var messageCount int
+
+	if err := row.Scan(&id, &title, &tagsJSON, &pinned, &archived, &summaryJSON, &contextStart, &createdAt, &updatedAt, &messageCount); err != nil {
+		return nil, err
+	}
+
+	tags := []string{}
+	if tagsJSON != "" {
+		if err := json.Unmarshal([]byte(tagsJSON), &tags); err != nil {
 			return nil, err
 		}
+	}
 
-		var messages []Message
-		if err := json.Unmarshal([]byte(messagesJSON), &messages); err != nil {
+	var summary *SessionSummary
+	if summaryJSON.Valid && summaryJSON.String != "" {
+		summary = &SessionSummary{}
+		if err := json.Unmarshal([]byte(summaryJSON.String), summary); err != nil {
 			return nil, err
 		}
+	}
+
+	return &Session{
+		ID:           id,
+		Title:        title,
+		Tags:         tags,
+		Pinned:       pinned,
+		Archived:     archived,
+		Summary:      summary,
+		ContextStart: contextStart,
+		MessageCount: messageCount,
+		CreatedAt:    time.Unix(createdAt, 0),
+		UpdatedAt:    time.Unix(updatedAt, 0),
+	}, nil
+}
+
+// execer 兼容 *sql.DB 和 *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
 
-		sessions = append(sessions, Session{
-			ID:        id,
-			Messages:  messages,
-			CreatedAt: time.Unix(createdAt, 0),
-			UpdatedAt: time.Unix(updatedAt, 0),
-		})
+// WithTx 在一个事务中执行 fn，fn 返回错误时回滚
+func (d *Database) WithTx(fn func(tx *sql.Tx) error) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	return sessions, nil
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // SaveKnowledge 保存知识
 func (d *Database) SaveKnowledge(knowledge *Knowledge) error {
+	return saveKnowledge(d.db, knowledge)
+}
+
+func saveKnowledge(ex execer, knowledge *Knowledge) error {
 	keyPointsJSON, _ := json.Marshal(knowledge.KeyPoints)
 	tagsJSON, _ := json.Marshal(knowledge.Tags)
 	entitiesJSON, _ := json.Marshal(knowledge.Entities)
@@ -196,15 +412,19 @@ func (d *Database) SaveKnowledge(knowledge *Knowledge) error {
 	observationsJSON, _ := json.Marshal(knowledge.Observations)
 	actionItemsJSON, _ := json.Marshal(knowledge.ActionItems)
 	metadataJSON, _ := json.Marshal(knowledge.Metadata)
+	messageIDsJSON, _ := json.Marshal(knowledge.MessageIDs)
 
 	query := `INSERT OR REPLACE INTO knowledge
-			  (id, title, content, summary, key_points, entities, relations, observations, action_items, category, tags, importance, sentiment, source, audio_url, session_id, created_at, updated_at, metadata)
-			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+			  (id, title, content, summary, key_points, entities, relations, observations, action_items, category, tags, importance, sentiment, source, audio_url, session_id, created_at, updated_at, metadata, version, message_ids)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	createdAt := knowledge.CreatedAt.Unix()
 	updatedAt := knowledge.UpdatedAt.Unix()
+	if knowledge.Version <= 0 {
+		knowledge.Version = 1
+	}
 
-	_, err := d.db.Exec(query,
+	_, err := ex.Exec(query,
 		knowledge.ID,
 		knowledge.Title,
 		knowledge.Content,
@@ -224,17 +444,72 @@ func (d *Database) SaveKnowledge(knowledge *Knowledge) error {
 		createdAt,
 		updatedAt,
 		string(metadataJSON),
+		knowledge.Version,
+		string(messageIDsJSON),
 	)
 
 	return err
 }
 
-// GetAllKnowledge 获取所有知识
-func (d *Database) GetAllKnowledge() ([]Knowledge, error) {
-	query := `SELECT id, COALESCE(title, '') as title, content, summary, key_points, COALESCE(entities, '{}') as entities, COALESCE(relations, '[]') as relations, COALESCE(observations, '[]') as observations, COALESCE(action_items, '[]') as action_items, category, tags, COALESCE(importance, 'medium') as importance, COALESCE(sentiment, 'neutral') as sentiment, source, audio_url, session_id, created_at, updated_at, metadata
-			  FROM knowledge ORDER BY created_at DESC`
+// knowledgeColumns 知识表查询列（与 scanKnowledge 的顺序一致）
+const knowledgeColumns = `id, COALESCE(title, '') as title, content, summary, key_points, COALESCE(entities, '{}') as entities, COALESCE(relations, '[]') as relations, COALESCE(observations, '[]') as observations, COALESCE(action_items, '[]') as action_items, category, tags, COALESCE(importance, 'medium') as importance, COALESCE(sentiment, 'neutral') as sentiment, source, audio_url, session_id, created_at, updated_at, metadata, COALESCE(version, 1) as version, COALESCE(message_ids, '[]') as message_ids`
 
-	rows, err := d.db.Query(query)
+// rowScanner 兼容 *sql.Row 和 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanKnowledge 扫描一行知识记录
+func scanKnowledge(row rowScanner) (*Knowledge, error) {
+	var k Knowledge
+	var keyPointsJSON, tagsJSON, entitiesJSON, relationsJSON, observationsJSON, actionItemsJSON, metadataJSON, messageIDsJSON string
+	var createdAt, updatedAt int64
+
+	err := row.Scan(
+		&k.ID,
+		&k.Title,
+		&k.Content,
+		&k.Summary,
+		&keyPointsJSON,
+		&entitiesJSON,
+		&relationsJSON,
+		&observationsJSON,
+		&actionItemsJSON,
+		&k.Category,
+		&tagsJSON,
+		&k.Importance,
+		&k.Sentiment,
+		&k.Source,
+		&k.AudioURL,
+		&k.SessionID,
+		&createdAt,
+		&updatedAt,
+		&metadataJSON,
+		&k.Version,
+		&messageIDsJSON,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal([]byte(keyPointsJSON), &k.KeyPoints)
+	json.Unmarshal([]byte(tagsJSON), &k.Tags)
+	json.Unmarshal([]byte(entitiesJSON), &k.Entities)
+	json.Unmarshal([]byte(relationsJSON), &k.Relations)
+	json.Unmarshal([]byte(observationsJSON), &k.Observations)
+	json.Unmarshal([]byte(actionItemsJSON), &k.ActionItems)
+	json.Unmarshal([]byte(metadataJSON), &k.Metadata)
+	json.Unmarshal([]byte(messageIDsJSON), &k.MessageIDs)
+
+	k.CreatedAt = time.Unix(createdAt, 0)
+	k.UpdatedAt = time.Unix(updatedAt, 0)
+
+	return &k, nil
+}
+
+// queryKnowledge 执行查询并扫描所有知识记录
+func (d *Database) queryKnowledge(query string, args ...interface{}) ([]Knowledge, error) {
+	rows, err := d.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -242,178 +517,157 @@ func (d *Database) GetAllKnowledge() ([]Knowledge, error) {
 
 	var knowledges []Knowledge
 	for rows.Next() {
-		var k Knowledge
-		var keyPointsJSON, tagsJSON, entitiesJSON, relationsJSON, observationsJSON, actionItemsJSON, metadataJSON string
-		var createdAt, updatedAt int64
-
-		err := rows.Scan(
-			&k.ID,
-			&k.Title,
-			&k.Content,
-			&k.Summary,
-			&keyPointsJSON,
-			&entitiesJSON,
-			&relationsJSON,
-			&observationsJSON,
-			&actionItemsJSON,
-			&k.Category,
-			&tagsJSON,
-			&k.Importance,
-			&k.Sentiment,
-			&k.Source,
-			&k.AudioURL,
-			&k.SessionID,
-			&createdAt,
-			&updatedAt,
-			&metadataJSON,
-		)
+		k, err := scanKnowledge(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		json.Unmarshal([]byte(keyPointsJSON), &k.KeyPoints)
-		json.Unmarshal([]byte(tagsJSON), &k.Tags)
-		json.Unmarshal([]byte(entitiesJSON), &k.Entities)
-		json.Unmarshal([]byte(relationsJSON), &k.Relations)
-		json.Unmarshal([]byte(observationsJSON), &k.Observations)
-		json.Unmarshal([]byte(actionItemsJSON), &k.ActionItems)
-		json.Unmarshal([]byte(metadataJSON), &k.Metadata)
-
-		k.CreatedAt = time.Unix(createdAt, 0)
-		k.UpdatedAt = time.Unix(updatedAt, 0)
-
-		knowledges = append(knowledges, k)
+		knowledges = append(knowledges, *k)
 	}
 
-	return knowledges, nil
+	return knowledges, rows.Err()
 }
 
-// GetKnowledgeByCategory 按分类获取知识
-func (d *Database) GetKnowledgeByCategory(category string) ([]Knowledge, error) {
-	query := `SELECT id, COALESCE(title, '') as title, content, summary, key_points, COALESCE(entities, '{}') as entities, COALESCE(relations, '[]') as relations, COALESCE(observations, '[]') as observations, COALESCE(action_items, '[]') as action_items, category, tags, COALESCE(importance, 'medium') as importance, COALESCE(sentiment, 'neutral') as sentiment, source, audio_url, session_id, created_at, updated_at, metadata
-			  FROM knowledge WHERE category = ? ORDER BY created_at DESC`
+// GetKnowledge 按 ID 获取知识，不存在时返回 nil
+func (d *Database) GetKnowledge(id string) (*Knowledge, error) {
+	query := `SELECT ` + knowledgeColumns + ` FROM knowledge WHERE id = ?`
 
-	rows, err := d.db.Query(query, category)
+	k, err := scanKnowledge(d.db.QueryRow(query, id))
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
 		return nil, err
 	}
-	defer rows.Close()
+	return k, nil
+}
 
-	var knowledges []Knowledge
-	for rows.Next() {
-		var k Knowledge
-		var keyPointsJSON, tagsJSON, entitiesJSON, relationsJSON, observationsJSON, actionItemsJSON, metadataJSON string
-		var createdAt, updatedAt int64
-
-		err := rows.Scan(
-			&k.ID,
-			&k.Title,
-			&k.Content,
-			&k.Summary,
-			&keyPointsJSON,
-			&entitiesJSON,
-			&relationsJSON,
-			&observationsJSON,
-			&actionItemsJSON,
-			&k.Category,
-			&tagsJSON,
-			&k.Importance,
-			&k.Sentiment,
-			&k.Source,
-			&k.AudioURL,
-			&k.SessionID,
-			&createdAt,
-			&updatedAt,
-			&metadataJSON,
-		)
+// knowledgeBatchSize 按 ID 批量查询时每条 SQL 的参数个数上限（SQLite 默认最多 999 个参数）
+const knowledgeBatchSize = 500
+
+// GetKnowledgeByIDs 按 ID 批量获取知识，返回 ID → 知识；不存在的 ID 不出现在结果中
+func (d *Database) GetKnowledgeByIDs(ids []string) (map[string]*Knowledge, error) {
+	result := make(map[string]*Knowledge, len(ids))
+	for start := 0; start < len(ids); start += knowledgeBatchSize {
+		batch := ids[start:min(start+knowledgeBatchSize, len(ids))]
+		args := make([]interface{}, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		query := `SELECT ` + knowledgeColumns + ` FROM knowledge
+			WHERE id IN (?` + strings.Repeat(", ?", len(batch)-1) + `)`
+		knowledges, err := d.queryKnowledge(query, args...)
 		if err != nil {
 			return nil, err
 		}
+		for i := range knowledges {
+			result[knowledges[i].ID] = &knowledges[i]
+		}
+	}
+	return result, nil
+}
 
-		json.Unmarshal([]byte(keyPointsJSON), &k.KeyPoints)
-		json.Unmarshal([]byte(tagsJSON), &k.Tags)
-		json.Unmarshal([]byte(entitiesJSON), &k.Entities)
-		json.Unmarshal([]byte(relationsJSON), &k.Relations)
-		json.Unmarshal([]byte(observationsJSON), &k.Observations)
-		json.Unmarshal([]byte(actionItemsJSON), &k.ActionItems)
-		json.Unmarshal([]byte(metadataJSON), &k.Metadata)
+// GetAllKnowledge 获取所有知识
+func (d *Database) GetAllKnowledge() ([]Knowledge, error) {
+	query := `SELECT ` + knowledgeColumns + `
+			  FROM knowledge ORDER BY created_at DESC`
 
-		k.CreatedAt = time.Unix(createdAt, 0)
-		k.UpdatedAt = time.Unix(updatedAt, 0)
+	return d.queryKnowledge(query)
+}
 
-		knowledges = append(knowledges, k)
-	}
+// GetKnowledgeByCategory 按分类获取知识
+func (d *Database) GetKnowledgeByCategory(category string) ([]Knowledge, error) {
+	query := `SELECT ` + knowledgeColumns + `
+			  FROM knowledge WHERE category = ? ORDER BY created_at DESC`
 
-	return knowledges, nil
+	return d.queryKnowledge(query, category)
 }
 
-// SearchKnowledge 搜索知识
+// SearchKnowledge 全文搜索知识，按 BM25 相关度排序
 func (d *Database) SearchKnowledge(searchQuery string) ([]Knowledge, error) {
-	query := `SELECT id, COALESCE(title, '') as title, content, summary, key_points, COALESCE(entities, '{}') as entities, COALESCE(relations, '[]') as relations, COALESCE(observations, '[]') as observations, COALESCE(action_items, '[]') as action_items, category, tags, COALESCE(importance, 'medium') as importance, COALESCE(sentiment, 'neutral') as sentiment, source, audio_url, session_id, created_at, updated_at, metadata
-			  FROM knowledge
-			  WHERE content LIKE ? OR summary LIKE ? OR category LIKE ? OR title LIKE ?
-			  ORDER BY created_at DESC`
-
-	pattern := "%" + searchQuery + "%"
+	hits, err := d.SearchKnowledgeFTS(searchQuery, 0, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := d.db.Query(query, pattern, pattern, pattern, pattern)
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	loaded, err := d.GetKnowledgeByIDs(ids)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var knowledges []Knowledge
-	for rows.Next() {
-		var k Knowledge
-		var keyPointsJSON, tagsJSON, entitiesJSON, relationsJSON, observationsJSON, actionItemsJSON, metadataJSON string
-		var createdAt, updatedAt int64
-
-		err := rows.Scan(
-			&k.ID,
-			&k.Title,
-			&k.Content,
-			&k.Summary,
-			&keyPointsJSON,
-			&entitiesJSON,
-			&relationsJSON,
-			&observationsJSON,
-			&actionItemsJSON,
-			&k.Category,
-			&tagsJSON,
-			&k.Importance,
-			&k.Sentiment,
-			&k.Source,
-			&k.AudioURL,
-			&k.SessionID,
-			&createdAt,
-			&updatedAt,
-			&metadataJSON,
-		)
-		if err != nil {
-			return nil, err
+	knowledges := make([]Knowledge, 0, len(hits))
+	for _, hit := range hits {
+		if k := loaded[hit.ID]; k != nil {
+			knowledges = append(knowledges, *k)
 		}
+	}
+	return knowledges, nil
+}
+
+// UpdateKnowledge 更新知识的可编辑字段（标题、正文、摘要、分类、标签、重要性、行动项）
+// expectedVersion > 0 时启用乐观锁：与库中版本不一致返回 ErrKnowledgeConflict。
+// 成功后 knowledge 的 Version 和 UpdatedAt 被更新为新值。
+func (d *Database) UpdateKnowledge(knowledge *Knowledge, expectedVersion int) error {
+	return d.WithTx(func(tx *sql.Tx) error {
+		return updateKnowledge(tx, knowledge, expectedVersion)
+	})
+}
 
-		json.Unmarshal([]byte(keyPointsJSON), &k.KeyPoints)
-		json.Unmarshal([]byte(tagsJSON), &k.Tags)
-		json.Unmarshal([]byte(entitiesJSON), &k.Entities)
-		json.Unmarshal([]byte(relationsJSON), &k.Relations)
-		json.Unmarshal([]byte(observationsJSON), &k.Observations)
-		json.Unmarshal([]byte(actionItemsJSON), &k.ActionItems)
-		json.Unmarshal([]byte(metadataJSON), &k.Metadata)
+func updateKnowledge(tx *sql.Tx, knowledge *Knowledge, expectedVersion int) error {
+	var version int
+	err := tx.QueryRow(`SELECT COALESCE(version, 1) FROM knowledge WHERE id = ?`, knowledge.ID).Scan(&version)
+	if err == sql.ErrNoRows {
+		return ErrKnowledgeNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if expectedVersion > 0 && expectedVersion != version {
+		return ErrKnowledgeConflict
+	}
 
-		k.CreatedAt = time.Unix(createdAt, 0)
-		k.UpdatedAt = time.Unix(updatedAt, 0)
+	tagsJSON, _ := json.Marshal(knowledge.Tags)
+	actionItemsJSON, _ := json.Marshal(knowledge.ActionItems)
+	updatedAt := time.Now()
 
-		knowledges = append(knowledges, k)
+	query := `UPDATE knowledge
+			  SET title = ?, content = ?, summary = ?, category = ?, tags = ?, importance = ?, action_items = ?, updated_at = ?, version = ?
+			  WHERE id = ?`
+
+	_, err = tx.Exec(query,
+		knowledge.Title,
+		knowledge.Content,
+		knowledge.Summary,
+		knowledge.Category,
+		string(tagsJSON),
+		knowledge.Importance,
+		string(actionItemsJSON),
+		updatedAt.Unix(),
+		version+1,
+		knowledge.ID,
+	)
+	if err != nil {
+		return err
 	}
 
-	return knowledges, nil
+	knowledge.Version = version + 1
+	knowledge.UpdatedAt = time.Unix(updatedAt.Unix(), 0)
+	return nil
 }
 
-// DeleteKnowledge 删除知识
+// DeleteKnowledge 删除知识，不存在时返回 ErrKnowledgeNotFound
 func (d *Database) DeleteKnowledge(id string) error {
-	_, err := d.db.Exec(`DELETE FROM knowledge WHERE id = ?`, id)
-	return err
+	result, err := d.db.Exec(`DELETE FROM knowledge WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrKnowledgeNotFound
+	}
+	return nil
 }
 
 // DeleteSession 删除会话
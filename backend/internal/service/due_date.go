@@ -0,0 +1,215 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DueDate 从中文描述中解析出的时间
+type DueDate struct {
+	Time   time.Time
+	AllDay bool // 只说了日期没说时刻，Time 为当天 0 点
+}
+
+var (
+	// "3天后"、"两个小时以后"、"半小时后"
+	dueAfterPattern = regexp.MustCompile(`(\d+|[一二两三四五六七八九十]+|半)(天|周|个星期|个月|个小时|小时|分钟)(?:之|以)?后`)
+	// "下周三"、"下下个星期五"、"这周日"、"礼拜天"
+	dueWeekdayPattern = regexp.MustCompile(`(下下|下|这|本)?个?(?:周|星期|礼拜)([一二三四五六日天1-7])`)
+	// "3月15日"、"十二月一号"
+	dueMonthDayPattern = regexp.MustCompile(`(\d{1,2}|[一二三四五六七八九十]{1,3})月(\d{1,2}|[一二三四五六七八九十]{1,3})[日号]`)
+	// "15号"
+	dueDayPattern = regexp.MustCompile(`(\d{1,2}|[一二三四五六七八九十]{1,3})[日号]`)
+	// "下午3点半"、"九点一刻"、"15:30"、"晚上8点20分"
+	dueClockPattern = regexp.MustCompile(`(凌晨|早上|早晨|上午|中午|下午|傍晚|晚上|今晚|明晚)?(\d{1,2}|[一二两三四五六七八九十]{1,3})(?:点|:|：|时)(半|一刻|三刻|(\d{1,2}|[一二三四五六七八九十]{1,3})分?)?`)
+)
+
+// dueWeekdays 星期几相对周一的偏移
+var dueWeekdays = map[string]int{
+	"一": 0, "二": 1, "三": 2, "四": 3, "五": 4, "六": 5, "日": 6, "天": 6,
+	"1": 0, "2": 1, "3": 2, "4": 3, "5": 4, "6": 5, "7": 6,
+}
+
+// ParseDueDate 解析 "明天下午三点"、"下周三"、"3月15日"、"两小时后" 这类中文时间表达，没有时间信息时返回 nil
+// 只有时刻没有日期时取 now 之后最近的那个时刻；只有星期几时取本周的那天，已经过去则顺延到下周
+func ParseDueDate(text string, now time.Time) *DueDate {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	if m := dueAfterPattern.FindStringSubmatch(text); m != nil {
+		n, ok := chineseNumber(m[1])
+		half := m[1] == "半"
+		if ok || half {
+			switch m[2] {
+			case "天":
+				return &DueDate{Time: today.AddDate(0, 0, n), AllDay: true}
+			case "周", "个星期":
+				return &DueDate{Time: today.AddDate(0, 0, 7*n), AllDay: true}
+			case "个月":
+				return &DueDate{Time: today.AddDate(0, n, 0), AllDay: true}
+			case "个小时", "小时":
+				if half {
+					return &DueDate{Time: now.Add(30 * time.Minute)}
+				}
+				return &DueDate{Time: now.Add(time.Duration(n) * time.Hour)}
+			case "分钟":
+				return &DueDate{Time: now.Add(time.Duration(n) * time.Minute)}
+			}
+		}
+	}
+
+	day, hasDay := parseDueDay(text, today)
+	hour, minute, hasClock := parseDueClock(text)
+
+	switch {
+	case hasDay && hasClock:
+		return &DueDate{Time: day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)}
+	case hasDay:
+		return &DueDate{Time: day, AllDay: true}
+	case hasClock:
+		t := today.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+		if !t.After(now) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return &DueDate{Time: t}
+	}
+	return nil
+}
+
+// parseDueDay 解析日期部分，返回当天 0 点
+func parseDueDay(text string, today time.Time) (time.Time, bool) {
+	switch {
+	case strings.Contains(text, "大后天"):
+		return today.AddDate(0, 0, 3), true
+	case strings.Contains(text, "后天"):
+		return today.AddDate(0, 0, 2), true
+	case containsAnyOf(text, "明天", "明早", "明晚", "明儿"):
+		return today.AddDate(0, 0, 1), true
+	case containsAnyOf(text, "今天", "今晚", "今早"):
+		return today, true
+	}
+
+	if m := dueWeekdayPattern.FindStringSubmatch(text); m != nil {
+		// 一周从周一开始
+		weekStart := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		day := weekStart.AddDate(0, 0, dueWeekdays[m[2]])
+		switch m[1] {
+		case "下":
+			day = day.AddDate(0, 0, 7)
+		case "下下":
+			day = day.AddDate(0, 0, 14)
+		case "":
+			if day.Before(today) {
+				day = day.AddDate(0, 0, 7)
+			}
+		}
+		return day, true
+	}
+
+	if m := dueMonthDayPattern.FindStringSubmatch(text); m != nil {
+		month, ok1 := chineseNumber(m[1])
+		dayOfMonth, ok2 := chineseNumber(m[2])
+		if ok1 && ok2 {
+			if day, ok := validDate(today.Year(), month, dayOfMonth, today.Location()); ok {
+				if day.Before(today) {
+					day, ok = validDate(today.Year()+1, month, dayOfMonth, today.Location())
+				}
+				return day, ok
+			}
+		}
+	}
+
+	if m := dueDayPattern.FindStringSubmatch(text); m != nil {
+		if dayOfMonth, ok := chineseNumber(m[1]); ok {
+			if day, ok := validDate(today.Year(), int(today.Month()), dayOfMonth, today.Location()); ok {
+				if day.Before(today) {
+					next := today.AddDate(0, 1, 1-today.Day())
+					day, ok = validDate(next.Year(), int(next.Month()), dayOfMonth, today.Location())
+				}
+				return day, ok
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// parseDueClock 解析时刻部分
+// "快一点"、"一点点"、"一点儿" 这类说法不算时刻：不带上午/下午的 "一点" 只在后面跟着分钟时才当作 1 点
+func parseDueClock(text string) (hour, minute int, ok bool) {
+	for _, loc := range dueClockPattern.FindAllStringSubmatchIndex(text, -1) {
+		if rest := text[loc[1]:]; strings.HasPrefix(rest, "点") || strings.HasPrefix(rest, "儿") {
+			continue
+		}
+		m := make([]string, len(loc)/2)
+		for i := range m {
+			if loc[2*i] >= 0 {
+				m[i] = text[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		if m[1] == "" && m[2] == "一" && m[3] == "" {
+			continue
+		}
+
+		minute = 0
+		h, valid := chineseNumber(m[2])
+		if !valid {
+			continue
+		}
+		switch m[3] {
+		case "":
+		case "半":
+			minute = 30
+		case "一刻":
+			minute = 15
+		case "三刻":
+			minute = 45
+		default:
+			if minute, valid = chineseNumber(m[4]); !valid {
+				continue
+			}
+		}
+
+		period := m[1]
+		if period == "" && containsAnyOf(text, "今晚", "明晚") {
+			period = "晚上"
+		}
+		switch period {
+		case "下午", "傍晚", "晚上", "今晚", "明晚":
+			if h < 12 {
+				h += 12
+			}
+		case "中午":
+			if h < 11 {
+				h += 12
+			}
+		}
+
+		if h > 23 || minute > 59 {
+			continue
+		}
+		return h, minute, true
+	}
+	return 0, 0, false
+}
+
+// dueDayWords 日期和时段用语，stripDueDate 时一并去掉
+var dueDayWords = []string{"大后天", "后天", "明天", "明早", "明晚", "明儿", "今天", "今晚", "今早",
+	"凌晨", "早上", "早晨", "上午", "中午", "下午", "傍晚", "晚上"}
+
+// stripDueDate 去掉文本中的时间表达，如 "明天九点开会" → "开会"
+func stripDueDate(text string) string {
+	for _, pattern := range []*regexp.Regexp{dueAfterPattern, dueWeekdayPattern, dueMonthDayPattern, dueClockPattern, dueDayPattern} {
+		text = pattern.ReplaceAllString(text, "")
+	}
+	for _, word := range dueDayWords {
+		text = strings.ReplaceAll(text, word, "")
+	}
+	return strings.TrimSpace(text)
+}
+
+// validDate 构造日期，月日不合法（如 2 月 30 日）时返回 false
+func validDate(year, month, day int, loc *time.Location) (time.Time, bool) {
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, loc)
+	return t, int(t.Month()) == month && t.Day() == day
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDueDate(t *testing.T) {
+	// 2026-03-11 是周三
+	now := time.Date(2026, 3, 11, 10, 0, 0, 0, time.Local)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		text   string
+		want   time.Time
+		allDay bool
+	}{
+		{"明天下午三点交报告", at(3, 12, 15, 0), false},
+		{"后天上午十点半面试", at(3, 13, 10, 30), false},
+		{"下周三开会", at(3, 18, 0, 0), true},
+		{"周五前提交", at(3, 13, 0, 0), true},
+		{"周一例会", at(3, 16, 0, 0), true}, // 本周一已过，顺延到下周
+		{"这周日去爬山", at(3, 15, 0, 0), true},
+		{"3月15日之前完成", at(3, 15, 0, 0), true},
+		{"1月2日续费", time.Date(2027, 1, 2, 0, 0, 0, 0, time.Local), true},
+		{"5号交房租", at(4, 5, 0, 0), true},
+		{"20号交房租", at(3, 20, 0, 0), true},
+		{"3天后复查", at(3, 14, 0, 0), true},
+		{"两小时后提醒", at(3, 11, 12, 0), false},
+		{"半小时后出发", at(3, 11, 10, 30), false},
+		{"晚上8点20分打电话", at(3, 11, 20, 20), false},
+		{"今晚八点看球", at(3, 11, 20, 0), false},
+		{"15:30 开会", at(3, 11, 15, 30), false},
+		{"九点一刻", at(3, 12, 9, 15), false}, // 今天的 9:15 已过
+		{"中午12点吃饭", at(3, 11, 12, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got := ParseDueDate(tt.text, now)
+			if got == nil {
+				t.Fatalf("未识别出时间")
+			}
+			if !got.Time.Equal(tt.want) || got.AllDay != tt.allDay {
+				t.Errorf("期望 %v (全天: %v), 实际 %v (全天: %v)", tt.want, tt.allDay, got.Time, got.AllDay)
+			}
+		})
+	}
+
+	t.Run("没有时间信息", func(t *testing.T) {
+		for _, text := range []string{"整理读书笔记", "快一点把代码写完", "一点点优化", ""} {
+			if got := ParseDueDate(text, now); got != nil {
+				t.Errorf("%q 不应识别出时间, 实际 %v", text, got.Time)
+			}
+		}
+	})
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,10 +10,17 @@ import (
 	"time"
 )
 
+// EmbeddingModel 默认使用的智谱 Embedding 模型
+const EmbeddingModel = "embedding-2"
+
+// EmbedBatchSize 单次批量请求的最大文本数
+const EmbedBatchSize = 16
+
 // EmbeddingClient Embedding 客户端
 type EmbeddingClient struct {
 	apiKey  string
 	baseURL string
+	model   string
 	client  *http.Client
 }
 
@@ -21,6 +29,7 @@ func NewEmbeddingClient(apiKey string) *EmbeddingClient {
 	return &EmbeddingClient{
 		apiKey:  apiKey,
 		baseURL: "https://open.bigmodel.cn/api/paas/v4/embeddings",
+		model:   EmbeddingModel,
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
@@ -54,12 +63,17 @@ type EmbeddingResult struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// Model 返回向量模型名称（随向量一起持久化，换模型后旧向量需重建）
+func (c *EmbeddingClient) Model() string {
+	return c.model
+}
+
 // Embed 生成单个文本的向量
-func (c *EmbeddingClient) Embed(text string) (*EmbeddingResult, error) {
+func (c *EmbeddingClient) Embed(ctx context.Context, text string) (*EmbeddingResult, error) {
 	start := time.Now()
 
 	req := EmbeddingRequest{
-		Model: "embedding-2", // 智谱 Embedding 模型
+		Model: c.model,
 		Input: []string{text},
 	}
 
@@ -69,7 +83,7 @@ func (c *EmbeddingClient) Embed(text string) (*EmbeddingResult, error) {
 	}
 
 	// 构建请求
-	httpReq, err := http.NewRequest("POST", c.baseURL, bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
@@ -114,49 +128,80 @@ func (c *EmbeddingClient) Embed(text string) (*EmbeddingResult, error) {
 }
 
 // GetEmbedding 实现 EmbeddingService 接口
-func (c *EmbeddingClient) GetEmbedding(text string) ([]float32, error) {
-	result, err := c.Embed(text)
+func (c *EmbeddingClient) GetEmbedding(ctx context.Context, text string) ([]float32, error) {
+	result, err := c.Embed(ctx, text)
 	if err != nil {
 		return nil, err
 	}
 	return result.Vector, nil
 }
 
-// EmbedBatch 批量生成向量（优化性能）
-func (c *EmbeddingClient) EmbedBatch(texts []string) ([]*EmbeddingResult, error) {
+// EmbedBatch 批量生成向量（优化性能），结果与 texts 一一对应
+func (c *EmbeddingClient) EmbedBatch(ctx context.Context, texts []string) ([]*EmbeddingResult, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	start := time.Now()
+
 	req := EmbeddingRequest{
-		Model: "embedding-2",
+		Model: c.model,
 		Input: texts,
 	}
 
-	jsonData, _ := json.Marshal(req)
-	httpReq, _ := http.NewRequest("POST", c.baseURL, bytes.NewBuffer(jsonData))
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("构建请求失败: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
 
 	resp, err := c.client.Do(httpReq)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("API 调用失败: %w", err)
 	}
 	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
 	if resp.StatusCode != 200 {
 		return nil, fmt.Errorf("API 错误 [%d]: %s", resp.StatusCode, string(body))
 	}
 
 	var result EmbeddingResponse
-	json.Unmarshal(body, &result)
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("向量数量不匹配: 请求 %d 条, 返回 %d 条", len(texts), len(result.Data))
+	}
 
-	results := make([]*EmbeddingResult, len(result.Data))
+	// 按 index 对齐，服务端不保证返回顺序
+	results := make([]*EmbeddingResult, len(texts))
 	now := time.Now()
-	for i, data := range result.Data {
-		results[i] = &EmbeddingResult{
+	duration := time.Since(start).Milliseconds()
+	for _, data := range result.Data {
+		if data.Index < 0 || data.Index >= len(texts) {
+			return nil, fmt.Errorf("向量序号越界: %d", data.Index)
+		}
+		results[data.Index] = &EmbeddingResult{
 			Vector:    data.Embedding,
 			Tokens:    result.Usage.TotalTokens / len(result.Data), // 估算
+			Duration:  duration,
 			CreatedAt: now,
 		}
 	}
+	for i, r := range results {
+		if r == nil {
+			return nil, fmt.Errorf("缺少第 %d 条向量", i)
+		}
+	}
 
 	return results, nil
 }
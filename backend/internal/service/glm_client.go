@@ -3,6 +3,7 @@ package service
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -108,7 +109,7 @@ type ChatResponse struct {
 }
 
 // SendMessage 发送消息（非流式）
-func (g *GLMClient) SendMessage(req ChatRequest) (*ChatResponse, error) {
+func (g *GLMClient) SendMessage(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
 	req.Stream = false
 
 	jsonData, err := json.Marshal(req)
@@ -118,7 +119,7 @@ func (g *GLMClient) SendMessage(req ChatRequest) (*ChatResponse, error) {
 
 	fmt.Printf("GLM API 请求: %s\n", string(jsonData))
 
-	httpReq, err := http.NewRequest("POST", g.baseURL+"/v1/messages", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", g.baseURL+"/v1/messages", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
@@ -173,7 +174,7 @@ func (r *ChatResponse) GetReplyText() string {
 }
 
 // SendMessageWithAudio 发送带音频的消息（GLM-4 Audio）
-func (g *GLMClient) SendMessageWithAudio(audioData []byte, messages []Message) (*ChatResponse, error) {
+func (g *GLMClient) SendMessageWithAudio(ctx context.Context, audioData []byte, messages []Message) (*ChatResponse, error) {
 	// 将音频编码为 base64
 	audioBase64 := base64.StdEncoding.EncodeToString(audioData)
 	audioURL := fmt.Sprintf("data:audio/wav;base64,%s", audioBase64)
@@ -230,7 +231,7 @@ func (g *GLMClient) SendMessageWithAudio(audioData []byte, messages []Message) (
 
 	fmt.Printf("GLM Audio API 请求 (音频大小: %d bytes): %s\n", len(audioData), string(jsonData))
 
-	httpReq, err := http.NewRequest("POST", g.baseURL+"/v1/messages", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", g.baseURL+"/v1/messages", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
@@ -266,16 +267,19 @@ func (g *GLMClient) SendMessageWithAudio(audioData []byte, messages []Message) (
 
 // StreamChunk 流式响应数据块
 type StreamChunk struct {
-	Delta    string `json:"delta"`
-	Done     bool   `json:"done"`
-	Error    string `json:"error,omitempty"`
+	Delta string `json:"delta"`
+	Done  bool   `json:"done"`
+	Error string `json:"error,omitempty"`
+	Usage *Usage `json:"usage,omitempty"` // 结束块携带的 token 用量（接口返回了用量时）
 }
 
 // GLMStreamEvent GLM 流式事件
 type GLMStreamEvent struct {
-	Type  string      `json:"type"`
-	Index int         `json:"index,omitempty"`
-	Delta *GLMDelta   `json:"delta,omitempty"`
+	Type    string        `json:"type"`
+	Index   int           `json:"index,omitempty"`
+	Delta   *GLMDelta     `json:"delta,omitempty"`
+	Message *ChatResponse `json:"message,omitempty"` // message_start 事件携带输入 token 数
+	Usage   *Usage        `json:"usage,omitempty"`   // message_delta 事件携带输出 token 数
 }
 
 // GLMDelta GLM 增量内容
@@ -286,7 +290,8 @@ type GLMDelta struct {
 }
 
 // SendMessageStream 发送消息（流式）
-func (g *GLMClient) SendMessageStream(req ChatRequest, callback func(StreamChunk)) error {
+// ctx 被取消时底层连接随之关闭，函数返回 ctx.Err()，不会再回调后续增量
+func (g *GLMClient) SendMessageStream(ctx context.Context, req ChatRequest, callback func(StreamChunk)) error {
 	req.Stream = true
 
 	jsonData, err := json.Marshal(req)
@@ -294,7 +299,7 @@ func (g *GLMClient) SendMessageStream(req ChatRequest, callback func(StreamChunk
 		return fmt.Errorf("构建请求失败: %w", err)
 	}
 
-	httpReq, err := http.NewRequest("POST", g.baseURL+"/v1/messages", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", g.baseURL+"/v1/messages", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("创建请求失败: %w", err)
 	}
@@ -308,6 +313,9 @@ func (g *GLMClient) SendMessageStream(req ChatRequest, callback func(StreamChunk
 
 	resp, err := g.client.Do(httpReq)
 	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		return fmt.Errorf("请求失败: %w", err)
 	}
 	defer resp.Body.Close()
@@ -320,7 +328,13 @@ func (g *GLMClient) SendMessageStream(req ChatRequest, callback func(StreamChunk
 	// 读取流式响应
 	scanner := bufio.NewScanner(resp.Body)
 	lineCount := 0
+	var usage *Usage
 	for scanner.Scan() {
+		// 已被打断：扫描器中可能还缓存着若干行，直接丢弃
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
 		line := strings.TrimSpace(scanner.Text())
 		lineCount++
 
@@ -340,7 +354,7 @@ func (g *GLMClient) SendMessageStream(req ChatRequest, callback func(StreamChunk
 
 		// 结束标记
 		if data == "[DONE]" {
-			callback(StreamChunk{Done: true})
+			callback(StreamChunk{Done: true, Usage: usage})
 			break
 		}
 
@@ -350,6 +364,20 @@ func (g *GLMClient) SendMessageStream(req ChatRequest, callback func(StreamChunk
 			continue
 		}
 
+		// 累计 token 用量
+		if event.Type == "message_start" && event.Message != nil {
+			usage = &Usage{InputTokens: event.Message.Usage.InputTokens}
+		}
+		if event.Usage != nil {
+			if usage == nil {
+				usage = &Usage{}
+			}
+			if event.Usage.InputTokens > 0 {
+				usage.InputTokens = event.Usage.InputTokens
+			}
+			usage.OutputTokens = event.Usage.OutputTokens
+		}
+
 		// 处理不同类型的事件
 		if event.Type == "content_block_delta" && event.Delta != nil && event.Delta.Type == "text_delta" {
 			// 文本增量
@@ -358,16 +386,19 @@ func (g *GLMClient) SendMessageStream(req ChatRequest, callback func(StreamChunk
 			}
 		} else if event.Type == "message_delta" && event.Delta != nil && event.Delta.StopReason != "" {
 			// 消息结束
-			callback(StreamChunk{Done: true})
+			callback(StreamChunk{Done: true, Usage: usage})
 			break
 		} else if event.Type == "message_stop" {
 			// 消息停止
-			callback(StreamChunk{Done: true})
+			callback(StreamChunk{Done: true, Usage: usage})
 			break
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		return fmt.Errorf("读取流失败: %w", err)
 	}
 
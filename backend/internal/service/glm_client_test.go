@@ -1,6 +1,11 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -335,6 +340,73 @@ func TestMessageRole(t *testing.T) {
 	}
 }
 
+// TestSendMessageStreamCancel 测试取消 context 会中断流式请求
+func TestSendMessageStreamCancel(t *testing.T) {
+	serverDone := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(serverDone)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"你好"}}`+"\n\n")
+		w.(http.Flusher).Flush()
+		// 模拟还在生成：直到客户端断开才返回
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := NewGLMClient("test_api_key")
+	client.baseURL = server.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var deltas []string
+	err := client.SendMessageStream(ctx, ChatRequest{Model: "glm-4.7"}, func(chunk StreamChunk) {
+		if chunk.Delta != "" {
+			deltas = append(deltas, chunk.Delta)
+			cancel()
+		}
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("期望 context.Canceled, 得到 %v", err)
+	}
+	if len(deltas) != 1 || deltas[0] != "你好" {
+		t.Errorf("取消后不应再收到增量: %v", deltas)
+	}
+	// 服务端应感知到连接断开
+	<-serverDone
+}
+
+// TestSendMessageStreamUsage 结束块携带 token 用量
+func TestSendMessageStreamUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"type":"message_start","message":{"usage":{"input_tokens":120,"output_tokens":1}}}`+"\n\n")
+		fmt.Fprint(w, `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"你好"}}`+"\n\n")
+		fmt.Fprint(w, `data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":8}}`+"\n\n")
+	}))
+	defer server.Close()
+
+	client := NewGLMClient("test_api_key")
+	client.baseURL = server.URL
+
+	var done *StreamChunk
+	err := client.SendMessageStream(context.Background(), ChatRequest{Model: "glm-4.7"}, func(chunk StreamChunk) {
+		if chunk.Done {
+			done = &chunk
+		}
+	})
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if done == nil || done.Usage == nil {
+		t.Fatalf("结束块应携带用量: %+v", done)
+	}
+	if done.Usage.InputTokens != 120 || done.Usage.OutputTokens != 8 {
+		t.Errorf("用量错误: %+v", *done.Usage)
+	}
+}
+
 // BenchmarkNewGLMClient 性能测试
 func BenchmarkNewGLMClient(b *testing.B) {
 	apiKey := "test_api_key"
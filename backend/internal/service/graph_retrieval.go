@@ -0,0 +1,170 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+// 图谱增强检索
+//
+// 向量检索只能找到和问题字面/语义相近的知识，"部署 X 之前需要准备什么" 需要的往往是
+// X 依赖的东西。Retrieve 在向量命中的基础上沿知识图谱扩展一跳：
+//   出发知识提及的实体 --关系--> 另一端实体 <--提及-- 关联知识
+//   出发知识提及的实体 <--提及-- 关联知识（提及同一实体）
+// 关联知识的得分 = max(自身与问题的相似度, 出发知识得分 × 关联权重)，与向量命中一起重新排序。
+
+// GraphExpansionLimit 每次检索最多追加的图谱关联知识条数
+const GraphExpansionLimit = 2
+
+// 关联权重：requires / part_of 最能回答 "需要什么"、"由什么组成"，权重最高；
+// 入边（其他实体依赖出发实体）和只是提及同一实体的关联较弱
+var graphRelationWeights = map[string]float64{
+	"requires": 0.9,
+	"part_of":  0.85,
+}
+
+const (
+	graphOutWeight    = 0.75
+	graphInWeight     = 0.65
+	graphSharedWeight = 0.6
+)
+
+// relationLabels 关系类型的中文说法（用于入选原因）
+var relationLabels = map[string]string{
+	"relates_to":     "关联",
+	"requires":       "依赖",
+	"implements":     "实现",
+	"improves":       "改进",
+	"contrasts_with": "对比",
+	"part_of":        "包含",
+	"alternative_to": "可替代",
+}
+
+// reasonVectorMatch 向量命中的入选原因
+const reasonVectorMatch = "与问题语义相似"
+
+// SetKnowledgeGraph 设置知识图谱，设置后检索结果会沿实体关系扩展一跳
+func (rag *RAGService) SetKnowledgeGraph(graph *KnowledgeGraph) {
+	rag.graph = graph
+}
+
+// expandWithGraph 用图谱关联扩展检索命中并重新排序；图谱查询失败时原样返回检索结果
+// query 为 nil 时关联知识只按出发知识得分 × 关联权重计分（混合检索的得分不是余弦相似度）
+func (rag *RAGService) expandWithGraph(query []float32, results []*RetrievalResult, filter *VectorFilter) []*RetrievalResult {
+	if rag.graph == nil || len(results) == 0 {
+		return results
+	}
+
+	seeds := make(map[string]*RetrievalResult, len(results))
+	ids := make([]string, len(results))
+	for i, r := range results {
+		seeds[r.ID] = r
+		ids[i] = r.ID
+	}
+
+	links, err := rag.graph.RelatedKnowledge(ids)
+	if err != nil {
+		log.Printf("[RAG] 查询知识图谱失败，只使用向量结果: %v", err)
+		return results
+	}
+
+	// 每条关联知识只保留得分最高的一条路径
+	type candidate struct {
+		score  float64
+		reason string
+	}
+	best := make(map[string]candidate)
+	var order []string
+	for _, link := range links {
+		from := seeds[link.FromID]
+		c := candidate{
+			score:  from.Score * graphLinkWeight(link),
+			reason: graphLinkReason(link, resultTitle(from)),
+		}
+		prev, ok := best[link.ToID]
+		if !ok {
+			order = append(order, link.ToID)
+		}
+		if !ok || c.score > prev.score {
+			best[link.ToID] = c
+		}
+	}
+
+	var expanded []*RetrievalResult
+	for _, id := range order {
+		c := best[id]
+		// 向量命中之间的关联只提升得分，不重复加入
+		if seed, ok := seeds[id]; ok {
+			if c.score > seed.Score {
+				seed.Score, seed.Reason = c.score, c.reason
+			}
+			continue
+		}
+
+		item, err := rag.vectorStore.Get(id)
+		if err != nil || item == nil || !filter.Match(item.Metadata) {
+			continue
+		}
+		content, _ := item.Metadata["content"].(string)
+		expanded = append(expanded, &RetrievalResult{
+			ID:       id,
+			Content:  content,
+			Score:    max(float64(cosineSimilarity(query, item.Embedding)), c.score),
+			Metadata: item.Metadata,
+			Reason:   c.reason,
+		})
+	}
+
+	sort.SliceStable(expanded, func(i, j int) bool { return expanded[i].Score > expanded[j].Score })
+	if len(expanded) > GraphExpansionLimit {
+		expanded = expanded[:GraphExpansionLimit]
+	}
+
+	combined := append(results, expanded...)
+	sort.SliceStable(combined, func(i, j int) bool { return combined[i].Score > combined[j].Score })
+	return combined
+}
+
+// graphLinkWeight 关联路径的权重
+func graphLinkWeight(link KnowledgeLink) float64 {
+	switch {
+	case link.Relation == "":
+		return graphSharedWeight
+	case link.Direction == "in":
+		return graphInWeight
+	}
+	if w, ok := graphRelationWeights[link.Relation]; ok {
+		return w
+	}
+	return graphOutWeight
+}
+
+// graphLinkReason 说明关联知识为什么被检索到，如 "「部署 Helm」提到的 Helm 依赖 Kubernetes"
+func graphLinkReason(link KnowledgeLink, fromTitle string) string {
+	label, ok := relationLabels[link.Relation]
+	if !ok {
+		label = link.Relation
+	}
+
+	switch {
+	case link.Relation == "":
+		return fmt.Sprintf("与「%s」都提到 %s", fromTitle, link.Entity)
+	case link.Direction == "in":
+		return fmt.Sprintf("%s %s「%s」提到的 %s", link.Target, label, fromTitle, link.Entity)
+	default:
+		return fmt.Sprintf("「%s」提到的 %s %s %s", fromTitle, link.Entity, label, link.Target)
+	}
+}
+
+// resultTitle 检索结果的标题，没有标题时截取正文
+func resultTitle(r *RetrievalResult) string {
+	if title, ok := r.Metadata["title"].(string); ok && title != "" {
+		return title
+	}
+	runes := []rune(r.Content)
+	if len(runes) > 20 {
+		return string(runes[:20]) + "…"
+	}
+	return r.Content
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestRAGService_GraphExpansion(t *testing.T) {
+	ctx := context.Background()
+
+	// 假 Embedding 把文本映射为 [len, 1]，查询 "x" 的向量是 [1, 1]
+	setup := func(t *testing.T) *RAGService {
+		rag, _ := newTestRAGService(t)
+		graph, repo := newTestKnowledgeGraph(t)
+		rag.SetKnowledgeGraph(graph)
+
+		knowledges := []struct {
+			k         Knowledge
+			embedding []float32
+		}{
+			// 向量最相似：部署 Helm Chart，Helm 依赖 Kubernetes
+			{Knowledge{ID: "kb_deploy", Title: "部署 Helm Chart", Category: "工作",
+				Entities:  Entities{Products: []string{"Helm"}, Concepts: []string{"发布"}},
+				Relations: []Relation{{Source: "Helm", Type: "requires", Target: "Kubernetes"}}}, []float32{1, 1}},
+			// 与问题无关，但是 Helm 依赖的东西
+			{Knowledge{ID: "kb_cluster", Title: "搭建 Kubernetes 集群", Category: "学习",
+				Entities: Entities{Products: []string{"Kubernetes"}}}, []float32{1, -1}},
+			// 只是同样提到 "发布"
+			{Knowledge{ID: "kb_chart", Title: "发布检查清单", Category: "工作",
+				Entities: Entities{Concepts: []string{"发布"}}}, []float32{-1, 1}},
+			// 向量次相似，与图谱无关
+			{Knowledge{ID: "kb_other", Title: "周末计划", Category: "工作"}, []float32{1, 0}},
+		}
+		for _, item := range knowledges {
+			k := item.k
+			k.Content = k.Title
+			if err := repo.Save(ctx, &k); err != nil {
+				t.Fatalf("保存失败: %v", err)
+			}
+			metadata := KnowledgeVectorMetadata(&k)
+			metadata["content"] = k.Content
+			if err := rag.vectorStore.Add(k.ID, item.embedding, metadata); err != nil {
+				t.Fatalf("写入向量失败: %v", err)
+			}
+		}
+		return rag
+	}
+
+	ids := func(results []*RetrievalResult) []string {
+		out := make([]string, len(results))
+		for i, r := range results {
+			out[i] = r.ID
+		}
+		return out
+	}
+
+	t.Run("沿依赖关系扩展并重新排序", func(t *testing.T) {
+		rag := setup(t)
+		results, err := rag.Retrieve(ctx, "x", 2, nil)
+		if err != nil {
+			t.Fatalf("检索失败: %v", err)
+		}
+
+		got := ids(results)
+		want := []string{"kb_deploy", "kb_cluster", "kb_other", "kb_chart"}
+		if len(got) != len(want) {
+			t.Fatalf("期望 %v, 实际 %v", want, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("期望 %v, 实际 %v", want, got)
+			}
+		}
+
+		if results[0].Reason != reasonVectorMatch || results[2].Reason != reasonVectorMatch {
+			t.Errorf("向量命中的原因错误: %q %q", results[0].Reason, results[2].Reason)
+		}
+		if results[1].Reason != "「部署 Helm Chart」提到的 Helm 依赖 Kubernetes" {
+			t.Errorf("依赖关联的原因错误: %q", results[1].Reason)
+		}
+		if math.Abs(results[1].Score-0.9) > 1e-6 {
+			t.Errorf("关联得分应为 1 × 0.9, 实际 %.3f", results[1].Score)
+		}
+		if results[3].Reason != "与「部署 Helm Chart」都提到 发布" {
+			t.Errorf("同实体关联的原因错误: %q", results[3].Reason)
+		}
+	})
+
+	t.Run("扩展结果同样满足过滤条件", func(t *testing.T) {
+		rag := setup(t)
+		results, _ := rag.Retrieve(ctx, "x", 1, &VectorFilter{Category: "工作"})
+		got := ids(results)
+		if len(got) != 2 || got[0] != "kb_deploy" || got[1] != "kb_chart" {
+			t.Errorf("不满足分类的关联知识应被过滤: %v", got)
+		}
+	})
+
+	t.Run("向量命中之间的关联只提升得分", func(t *testing.T) {
+		rag := setup(t)
+		results, _ := rag.Retrieve(ctx, "x", 4, nil)
+		if len(results) != 4 {
+			t.Fatalf("不应重复加入已命中的知识: %v", ids(results))
+		}
+		for _, r := range results {
+			if r.ID == "kb_cluster" && r.Reason == reasonVectorMatch {
+				t.Errorf("得分被图谱提升时应说明关联原因: %+v", r)
+			}
+		}
+	})
+
+	t.Run("未设置图谱时只做向量检索", func(t *testing.T) {
+		rag := setup(t)
+		rag.SetKnowledgeGraph(nil)
+		results, _ := rag.Retrieve(ctx, "x", 2, nil)
+		if got := ids(results); len(got) != 2 || got[1] != "kb_other" {
+			t.Errorf("结果错误: %v", got)
+		}
+	})
+
+	t.Run("上下文说明入选原因", func(t *testing.T) {
+		rag := setup(t)
+		context, err := rag.BuildContextWithRAG(ctx, "x", 1)
+		if err != nil {
+			t.Fatalf("构建失败: %v", err)
+		}
+		if !containsAnyOf(context, "入选原因: 「部署 Helm Chart」提到的 Helm 依赖 Kubernetes") {
+			t.Errorf("上下文缺少关联原因: %s", context)
+		}
+	})
+}
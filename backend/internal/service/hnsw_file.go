@@ -0,0 +1,389 @@
+package service
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"math"
+	"os"
+)
+
+// HNSW 向量存储的磁盘格式（小端序）
+//
+// 日志记录:  [uint32 payload 长度][uint32 payload CRC32][payload]
+//   payload: [op 1B][id][dim uvarint][dim × float32][metadata JSON]
+//   （字符串和 JSON 均为 uvarint 长度 + 字节，删除记录只有 op 和 id）
+//
+// 快照:      [magic 8B][dim][节点数][入口+1][最高层] 每个节点 [id][level][vector][metadata]
+//            [每层邻居数 + 邻居下标...]，最后是 uint32 CRC32（覆盖前面全部内容）
+
+const hnswSnapshotMagic = "VMHNSW01"
+
+// vectorOp 日志操作类型
+type vectorOp byte
+
+const (
+	vectorOpAdd    vectorOp = 1
+	vectorOpDelete vectorOp = 2
+)
+
+// vectorRecord 一条日志记录
+type vectorRecord struct {
+	op       vectorOp
+	id       string
+	vector   []float32
+	metadata map[string]interface{}
+}
+
+// vectorLog 追加写的二进制日志
+type vectorLog struct {
+	path    string
+	file    *os.File
+	records int // 自上次压缩以来的记录数
+}
+
+// openVectorLog 打开日志并逐条重放；末尾不完整或校验失败的记录（写入中途崩溃）会被截掉
+func openVectorLog(path string, replay func(vectorRecord) error) (*vectorLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &vectorLog{path: path, file: file}
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		record, size, err := readVectorRecord(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("[Vector] 日志在偏移 %d 处损坏，截断: %v", offset, err)
+			if err := file.Truncate(offset); err != nil {
+				file.Close()
+				return nil, err
+			}
+			break
+		}
+		if err := replay(record); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("重放日志失败 (偏移 %d): %w", offset, err)
+		}
+		offset += size
+		l.records++
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// append 追加记录（一次写入）
+func (l *vectorLog) append(records ...vectorRecord) error {
+	var buf []byte
+	for _, r := range records {
+		payload, err := encodeVectorRecord(r)
+		if err != nil {
+			return err
+		}
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(payload)))
+		buf = binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(payload))
+		buf = append(buf, payload...)
+	}
+
+	if _, err := l.file.Write(buf); err != nil {
+		return fmt.Errorf("写入向量日志失败: %w", err)
+	}
+	l.records += len(records)
+	return nil
+}
+
+// truncate 清空日志（快照已包含全部数据）
+func (l *vectorLog) truncate() error {
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	l.records = 0
+	return nil
+}
+
+func (l *vectorLog) close() error {
+	return l.file.Close()
+}
+
+func encodeVectorRecord(r vectorRecord) ([]byte, error) {
+	buf := []byte{byte(r.op)}
+	buf = appendString(buf, r.id)
+	if r.op == vectorOpAdd {
+		buf = appendVector(buf, r.vector)
+		meta, err := json.Marshal(r.metadata)
+		if err != nil {
+			return nil, fmt.Errorf("序列化元数据失败: %w", err)
+		}
+		buf = appendBytes(buf, meta)
+	}
+	return buf, nil
+}
+
+// readVectorRecord 读取一条记录，返回记录和占用的字节数
+func readVectorRecord(reader *bufio.Reader) (vectorRecord, int64, error) {
+	var header [8]byte
+	if n, err := io.ReadFull(reader, header[:]); err != nil {
+		if n == 0 && err == io.EOF {
+			return vectorRecord{}, 0, io.EOF
+		}
+		return vectorRecord{}, 0, errors.New("记录头不完整")
+	}
+
+	size := binary.LittleEndian.Uint32(header[:4])
+	if size > 1<<30 {
+		return vectorRecord{}, 0, errors.New("记录长度异常")
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return vectorRecord{}, 0, errors.New("记录内容不完整")
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:]) {
+		return vectorRecord{}, 0, errors.New("记录校验失败")
+	}
+
+	r, err := decodeVectorRecord(payload)
+	if err != nil {
+		return vectorRecord{}, 0, err
+	}
+	return r, int64(len(header)) + int64(size), nil
+}
+
+func decodeVectorRecord(payload []byte) (vectorRecord, error) {
+	d := &byteDecoder{buf: payload}
+	r := vectorRecord{op: vectorOp(d.byte())}
+	r.id = d.string()
+	switch r.op {
+	case vectorOpAdd:
+		r.vector = d.vector()
+		if meta := d.bytes(); d.err == nil {
+			if err := json.Unmarshal(meta, &r.metadata); err != nil {
+				return r, fmt.Errorf("解析元数据失败: %w", err)
+			}
+		}
+	case vectorOpDelete:
+	default:
+		return r, fmt.Errorf("未知操作类型: %d", r.op)
+	}
+	return r, d.err
+}
+
+// writeSnapshot 写入快照（先写临时文件再原子替换）
+func (s *HNSWVectorStore) writeSnapshot() error {
+	tmp := s.snapshotPath + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	crc := crc32.NewIEEE()
+	w := bufio.NewWriterSize(io.MultiWriter(file, crc), 1<<20)
+
+	var buf []byte
+	buf = append(buf, hnswSnapshotMagic...)
+	buf = binary.AppendUvarint(buf, uint64(s.dim))
+	buf = binary.AppendUvarint(buf, uint64(len(s.nodes)))
+	buf = binary.AppendUvarint(buf, uint64(s.entry+1))
+	buf = binary.AppendUvarint(buf, uint64(s.maxLevel))
+	w.Write(buf)
+
+	for _, n := range s.nodes {
+		meta, err := json.Marshal(n.metadata)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("序列化元数据失败: %w", err)
+		}
+
+		buf = buf[:0]
+		buf = appendString(buf, n.id)
+		buf = binary.AppendUvarint(buf, uint64(n.level))
+		buf = appendVector(buf, n.vector)
+		buf = appendBytes(buf, meta)
+		for _, neighbors := range n.neighbors {
+			buf = binary.AppendUvarint(buf, uint64(len(neighbors)))
+			for _, nb := range neighbors {
+				buf = binary.AppendUvarint(buf, uint64(nb))
+			}
+		}
+		w.Write(buf)
+	}
+
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Write(binary.LittleEndian.AppendUint32(nil, crc.Sum32())); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.snapshotPath)
+}
+
+// loadSnapshot 加载快照（包含完整的图结构，无需重建索引）
+func (s *HNSWVectorStore) loadSnapshot() error {
+	data, err := os.ReadFile(s.snapshotPath)
+	if err != nil {
+		return err
+	}
+	if len(data) < len(hnswSnapshotMagic)+4 || string(data[:len(hnswSnapshotMagic)]) != hnswSnapshotMagic {
+		return errors.New("快照格式无效")
+	}
+	body := data[:len(data)-4]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(data[len(data)-4:]) {
+		return errors.New("快照校验失败")
+	}
+
+	d := &byteDecoder{buf: body[len(hnswSnapshotMagic):]}
+	dim := int(d.uvarint())
+	count := int(d.uvarint())
+	entry := int(d.uvarint()) - 1
+	maxLevel := int(d.uvarint())
+	if d.err != nil || count > len(body) {
+		return errors.New("快照头无效")
+	}
+
+	nodes := make([]*hnswNode, count)
+	for i := range nodes {
+		n := &hnswNode{id: d.string(), level: int(d.uvarint())}
+		n.vector = d.vector()
+		meta := d.bytes()
+		if d.err != nil || n.level > hnswMaxLevel || len(n.vector) != dim {
+			return fmt.Errorf("快照节点 %d 无效", i)
+		}
+		if err := json.Unmarshal(meta, &n.metadata); err != nil {
+			return fmt.Errorf("解析元数据失败: %w", err)
+		}
+
+		n.neighbors = make([][]uint32, n.level+1)
+		for l := range n.neighbors {
+			size := int(d.uvarint())
+			if d.err != nil || size > count {
+				return fmt.Errorf("快照节点 %d 的邻居无效", i)
+			}
+			n.neighbors[l] = make([]uint32, size)
+			for j := range n.neighbors[l] {
+				nb := d.uvarint()
+				if nb >= uint64(count) {
+					return fmt.Errorf("快照节点 %d 的邻居越界", i)
+				}
+				n.neighbors[l][j] = uint32(nb)
+			}
+		}
+		nodes[i] = n
+	}
+	if d.err != nil || entry >= count {
+		return errors.New("快照内容无效")
+	}
+
+	s.nodes = nodes
+	s.dim = dim
+	s.entry = entry
+	s.maxLevel = maxLevel
+	for idx, n := range nodes {
+		s.ids[n.id] = uint32(idx)
+	}
+	return nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendBytes(buf []byte, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+func appendVector(buf []byte, v []float32) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(v)))
+	for _, x := range v {
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(x))
+	}
+	return buf
+}
+
+// byteDecoder 顺序解码，出错后后续读取均返回零值，最后统一检查 err
+type byteDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *byteDecoder) fail() {
+	if d.err == nil {
+		d.err = errors.New("数据不完整")
+	}
+	d.buf = nil
+}
+
+func (d *byteDecoder) byte() byte {
+	if len(d.buf) < 1 {
+		d.fail()
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *byteDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *byteDecoder) bytes() []byte {
+	size := d.uvarint()
+	if size > uint64(len(d.buf)) {
+		d.fail()
+		return nil
+	}
+	b := d.buf[:size]
+	d.buf = d.buf[size:]
+	return b
+}
+
+func (d *byteDecoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *byteDecoder) vector() []float32 {
+	size := d.uvarint()
+	if size*4 > uint64(len(d.buf)) {
+		d.fail()
+		return nil
+	}
+	v := make([]float32, size)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(d.buf[i*4:]))
+	}
+	d.buf = d.buf[size*4:]
+	return v
+}
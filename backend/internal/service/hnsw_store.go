@@ -0,0 +1,698 @@
+package service
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// HNSWConfig HNSW 索引参数
+type HNSWConfig struct {
+	M              int // 每个节点每层的最大邻居数（第 0 层为 2M）
+	EfConstruction int // 建图时的候选集大小，越大召回越高、写入越慢
+	EfSearch       int // 查询时的候选集大小（至少为 limit）
+	CompactEvery   int // 日志累计多少条记录后压缩为快照
+}
+
+// DefaultHNSWConfig 默认 HNSW 参数
+func DefaultHNSWConfig() HNSWConfig {
+	return HNSWConfig{
+		M:              16,
+		EfConstruction: 200,
+		EfSearch:       64,
+		CompactEvery:   1000,
+	}
+}
+
+// hnswMaxLevel 层数上限，防止极端随机值
+const hnswMaxLevel = 16
+
+// hnswNode 图中的一个节点
+type hnswNode struct {
+	id        string
+	vector    []float32 // 已归一化，内积即余弦相似度
+	metadata  map[string]interface{}
+	level     int
+	neighbors [][]uint32 // 每层的邻居节点下标
+	deleted   bool       // 墓碑：仍参与图导航，不出现在结果中，压缩时移除
+}
+
+// HNSWVectorStore 基于 HNSW 图的近似最近邻向量存储（纯 Go 实现）
+//
+// 磁盘上由两部分组成：
+//   - vectors.hnsw     快照：压缩时整体写入（包含图结构，启动时无需重建）
+//   - vectors.hnsw.log 追加日志：快照之后的每次 Add/Delete 追加一条带 CRC 的二进制记录
+//
+// 启动时加载快照再重放日志；日志超过 CompactEvery 条或墓碑超过 1/4 时压缩。
+// 向量在写入时归一化，Get 返回的是归一化后的向量。
+type HNSWVectorStore struct {
+	cfg       HNSWConfig
+	levelMult float64
+	rng       *rand.Rand
+
+	mu       sync.RWMutex
+	nodes    []*hnswNode
+	ids      map[string]uint32 // id -> 存活节点下标
+	entry    int               // 入口节点下标，-1 表示空图
+	maxLevel int
+	dim      int
+	deleted  int // 墓碑数
+
+	visited sync.Pool
+
+	snapshotPath string
+	log          *vectorLog
+}
+
+// NewHNSWVectorStore 创建（或从 dataDir 加载）HNSW 向量存储，未设置的参数使用默认值
+func NewHNSWVectorStore(dataDir string, cfg HNSWConfig) (*HNSWVectorStore, error) {
+	def := DefaultHNSWConfig()
+	if cfg.M <= 1 {
+		cfg.M = def.M
+	}
+	if cfg.EfConstruction <= 0 {
+		cfg.EfConstruction = def.EfConstruction
+	}
+	if cfg.EfSearch <= 0 {
+		cfg.EfSearch = def.EfSearch
+	}
+	if cfg.CompactEvery <= 0 {
+		cfg.CompactEvery = def.CompactEvery
+	}
+
+	s := &HNSWVectorStore{
+		cfg:          cfg,
+		levelMult:    1 / math.Log(float64(cfg.M)),
+		rng:          rand.New(rand.NewSource(42)),
+		ids:          make(map[string]uint32),
+		entry:        -1,
+		snapshotPath: filepath.Join(dataDir, "vectors.hnsw"),
+	}
+
+	if err := s.loadSnapshot(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("加载向量快照失败: %w", err)
+	}
+
+	log, err := openVectorLog(filepath.Join(dataDir, "vectors.hnsw.log"), s.replay)
+	if err != nil {
+		return nil, fmt.Errorf("加载向量日志失败: %w", err)
+	}
+	s.log = log
+
+	return s, nil
+}
+
+// Add 添加向量（已存在的 ID 会被替换）
+func (s *HNSWVectorStore) Add(id string, embedding []float32, metadata map[string]interface{}) error {
+	return s.AddBatch([]VectorItem{{ID: id, Embedding: embedding, Metadata: metadata}})
+}
+
+// AddBatch 批量添加向量，日志一次写入
+func (s *HNSWVectorStore) AddBatch(items []VectorItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]vectorRecord, 0, len(items))
+	for _, item := range items {
+		if err := s.checkDim(len(item.Embedding)); err != nil {
+			return err
+		}
+		records = append(records, vectorRecord{op: vectorOpAdd, id: item.ID, vector: normalize(item.Embedding), metadata: item.Metadata})
+	}
+
+	if err := s.log.append(records...); err != nil {
+		return err
+	}
+	for _, r := range records {
+		s.apply(r)
+	}
+
+	return s.maybeCompact()
+}
+
+// Delete 删除向量
+func (s *HNSWVectorStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.ids[id]; !ok {
+		return nil
+	}
+
+	r := vectorRecord{op: vectorOpDelete, id: id}
+	if err := s.log.append(r); err != nil {
+		return err
+	}
+	s.apply(r)
+
+	return s.maybeCompact()
+}
+
+// Get 获取向量条目
+func (s *HNSWVectorStore) Get(id string) (*VectorItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	idx, ok := s.ids[id]
+	if !ok {
+		return nil, nil
+	}
+	n := s.nodes[idx]
+	return &VectorItem{ID: n.id, Embedding: n.vector, Metadata: n.metadata}, nil
+}
+
+// IDs 列出所有向量 ID
+func (s *HNSWVectorStore) IDs() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.ids))
+	for id := range s.ids {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Search 近似搜索最相似的向量，Score 为余弦相似度
+func (s *HNSWVectorStore) Search(queryVector []float32, limit int, filter *VectorFilter) ([]VectorResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.entry < 0 || limit <= 0 {
+		return []VectorResult{}, nil
+	}
+	if len(queryVector) != s.dim {
+		return nil, fmt.Errorf("向量维度不匹配: 期望 %d, 实际 %d", s.dim, len(queryVector))
+	}
+
+	q := normalize(queryVector)
+	ep := hnswCandidate{id: uint32(s.entry), dist: s.distance(q, uint32(s.entry))}
+	for l := s.maxLevel; l > 0; l-- {
+		ep = s.greedy(q, ep, l)
+	}
+
+	ef := s.cfg.EfSearch
+	if limit > ef {
+		ef = limit
+	}
+	if filter.IsEmpty() {
+		return s.collect(s.searchLayer(q, ep, ef, 0), limit, nil), nil
+	}
+
+	// 有过滤条件时逐步放大 ef，直到候选中满足条件的条目够数；
+	// 条件很严格（ef 已覆盖全部节点）时退化为对满足条件的节点精确计算
+	for ef < len(s.ids) {
+		results := s.collect(s.searchLayer(q, ep, ef, 0), limit, filter)
+		if len(results) == limit {
+			return results, nil
+		}
+		ef *= 4
+	}
+	return s.bruteForce(q, limit, filter), nil
+}
+
+// collect 从候选中按距离取前 limit 个存活且满足过滤条件的节点
+func (s *HNSWVectorStore) collect(candidates []hnswCandidate, limit int, filter *VectorFilter) []VectorResult {
+	results := make([]VectorResult, 0, limit)
+	for _, c := range candidates {
+		n := s.nodes[c.id]
+		if n.deleted || !filter.Match(n.metadata) {
+			continue
+		}
+		results = append(results, VectorResult{ID: n.id, Score: 1 - c.dist, Metadata: n.metadata})
+		if len(results) == limit {
+			break
+		}
+	}
+	return results
+}
+
+// bruteForce 遍历全部满足条件的节点精确计算
+func (s *HNSWVectorStore) bruteForce(q []float32, limit int, filter *VectorFilter) []VectorResult {
+	top := &hnswMaxHeap{}
+	for idx, n := range s.nodes {
+		if n.deleted || !filter.Match(n.metadata) {
+			continue
+		}
+		d := s.distance(q, uint32(idx))
+		if top.Len() < limit {
+			heap.Push(top, hnswCandidate{id: uint32(idx), dist: d})
+		} else if d < (*top)[0].dist {
+			(*top)[0] = hnswCandidate{id: uint32(idx), dist: d}
+			heap.Fix(top, 0)
+		}
+	}
+
+	sorted := make([]hnswCandidate, top.Len())
+	for i := len(sorted) - 1; i >= 0; i-- {
+		sorted[i] = heap.Pop(top).(hnswCandidate)
+	}
+	return s.collect(sorted, limit, nil)
+}
+
+// Len 存活向量数
+func (s *HNSWVectorStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.ids)
+}
+
+// Compact 立即压缩：移除墓碑、写入快照并清空日志
+func (s *HNSWVectorStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compact()
+}
+
+// Close 关闭日志文件
+func (s *HNSWVectorStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.close()
+}
+
+// apply 在内存图上执行一条记录（写入日志后或重放时调用）
+func (s *HNSWVectorStore) apply(r vectorRecord) {
+	if idx, ok := s.ids[r.id]; ok {
+		s.nodes[idx].deleted = true
+		s.nodes[idx].metadata = nil
+		delete(s.ids, r.id)
+		s.deleted++
+	}
+	if r.op == vectorOpAdd {
+		if s.dim == 0 {
+			s.dim = len(r.vector)
+		}
+		s.insert(r.id, r.vector, r.metadata)
+	}
+}
+
+// replay 重放日志记录
+func (s *HNSWVectorStore) replay(r vectorRecord) error {
+	if r.op == vectorOpAdd {
+		if err := s.checkDim(len(r.vector)); err != nil {
+			return err
+		}
+	}
+	s.apply(r)
+	return nil
+}
+
+func (s *HNSWVectorStore) checkDim(dim int) error {
+	if dim == 0 {
+		return fmt.Errorf("向量不能为空")
+	}
+	if s.dim != 0 && dim != s.dim {
+		return fmt.Errorf("向量维度不匹配: 期望 %d, 实际 %d", s.dim, dim)
+	}
+	return nil
+}
+
+func (s *HNSWVectorStore) maybeCompact() error {
+	if s.log.records >= s.cfg.CompactEvery || (s.deleted > 0 && s.deleted*4 > len(s.nodes)) {
+		return s.compact()
+	}
+	return nil
+}
+
+// insert 把向量插入图中
+func (s *HNSWVectorStore) insert(id string, vector []float32, metadata map[string]interface{}) {
+	level := s.randomLevel()
+	idx := uint32(len(s.nodes))
+	node := &hnswNode{
+		id:        id,
+		vector:    vector,
+		metadata:  metadata,
+		level:     level,
+		neighbors: make([][]uint32, level+1),
+	}
+	s.nodes = append(s.nodes, node)
+	s.ids[id] = idx
+
+	if s.entry < 0 {
+		s.entry = int(idx)
+		s.maxLevel = level
+		return
+	}
+
+	ep := hnswCandidate{id: uint32(s.entry), dist: s.distance(vector, uint32(s.entry))}
+	for l := s.maxLevel; l > level; l-- {
+		ep = s.greedy(vector, ep, l)
+	}
+
+	for l := min(level, s.maxLevel); l >= 0; l-- {
+		candidates := s.searchLayer(vector, ep, s.cfg.EfConstruction, l)
+		selected := s.selectNeighbors(candidates, s.cfg.M)
+		node.neighbors[l] = make([]uint32, len(selected))
+		for i, c := range selected {
+			node.neighbors[l][i] = c.id
+			s.connect(c.id, idx, l)
+		}
+		ep = candidates[0]
+	}
+
+	if level > s.maxLevel {
+		s.maxLevel = level
+		s.entry = int(idx)
+	}
+}
+
+// connect 添加反向边，超出上限时按启发式裁剪
+func (s *HNSWVectorStore) connect(from, to uint32, level int) {
+	node := s.nodes[from]
+	node.neighbors[level] = append(node.neighbors[level], to)
+
+	maxConn := s.maxConn(level)
+	if len(node.neighbors[level]) <= maxConn {
+		return
+	}
+
+	candidates := make([]hnswCandidate, len(node.neighbors[level]))
+	for i, nb := range node.neighbors[level] {
+		candidates[i] = hnswCandidate{id: nb, dist: s.distance(node.vector, nb)}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].dist < candidates[j].dist })
+
+	selected := s.selectNeighbors(candidates, maxConn)
+	node.neighbors[level] = node.neighbors[level][:0]
+	for _, c := range selected {
+		node.neighbors[level] = append(node.neighbors[level], c.id)
+	}
+}
+
+// selectNeighbors 启发式选邻居：优先保留彼此不太相近的候选，让图覆盖更多方向
+// candidates 需按距离升序，不足 m 个时用被跳过的候选补齐
+func (s *HNSWVectorStore) selectNeighbors(candidates []hnswCandidate, m int) []hnswCandidate {
+	if len(candidates) <= m {
+		return candidates
+	}
+
+	selected := make([]hnswCandidate, 0, m)
+	var skipped []hnswCandidate
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+		good := true
+		for _, r := range selected {
+			if s.distanceBetween(c.id, r.id) < c.dist {
+				good = false
+				break
+			}
+		}
+		if good {
+			selected = append(selected, c)
+		} else {
+			skipped = append(skipped, c)
+		}
+	}
+	for _, c := range skipped {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, c)
+	}
+	return selected
+}
+
+// greedy 在某一层贪心地走向更近的节点
+func (s *HNSWVectorStore) greedy(q []float32, ep hnswCandidate, level int) hnswCandidate {
+	for changed := true; changed; {
+		changed = false
+		for _, nb := range s.nodes[ep.id].neighbors[level] {
+			if d := s.distance(q, nb); d < ep.dist {
+				ep = hnswCandidate{id: nb, dist: d}
+				changed = true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer 在某一层做 beam search，返回按距离升序的至多 ef 个候选
+func (s *HNSWVectorStore) searchLayer(q []float32, ep hnswCandidate, ef int, level int) []hnswCandidate {
+	visited := s.getVisited()
+	defer s.visited.Put(visited)
+	visited.visit(ep.id)
+
+	candidates := &hnswMinHeap{ep}
+	results := &hnswMaxHeap{ep}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(hnswCandidate)
+		if c.dist > (*results)[0].dist && results.Len() >= ef {
+			break
+		}
+
+		for _, nb := range s.nodes[c.id].neighbors[level] {
+			if !visited.visit(nb) {
+				continue
+			}
+			d := s.distance(q, nb)
+			if results.Len() < ef || d < (*results)[0].dist {
+				heap.Push(candidates, hnswCandidate{id: nb, dist: d})
+				heap.Push(results, hnswCandidate{id: nb, dist: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	sorted := make([]hnswCandidate, results.Len())
+	for i := len(sorted) - 1; i >= 0; i-- {
+		sorted[i] = heap.Pop(results).(hnswCandidate)
+	}
+	return sorted
+}
+
+func (s *HNSWVectorStore) getVisited() *visitedSet {
+	v, _ := s.visited.Get().(*visitedSet)
+	if v == nil {
+		v = &visitedSet{}
+	}
+	v.reset(len(s.nodes))
+	return v
+}
+
+func (s *HNSWVectorStore) maxConn(level int) int {
+	if level == 0 {
+		return s.cfg.M * 2
+	}
+	return s.cfg.M
+}
+
+func (s *HNSWVectorStore) randomLevel() int {
+	level := int(-math.Log(1-s.rng.Float64()) * s.levelMult)
+	if level > hnswMaxLevel {
+		level = hnswMaxLevel
+	}
+	return level
+}
+
+// distance 余弦距离（向量已归一化）
+func (s *HNSWVectorStore) distance(q []float32, idx uint32) float32 {
+	return 1 - dot(q, s.nodes[idx].vector)
+}
+
+func (s *HNSWVectorStore) distanceBetween(a, b uint32) float32 {
+	return 1 - dot(s.nodes[a].vector, s.nodes[b].vector)
+}
+
+// compact 移除墓碑节点并修复断开的边，然后写入快照、清空日志
+func (s *HNSWVectorStore) compact() error {
+	if s.deleted > 0 {
+		s.removeTombstones()
+	}
+
+	if err := s.writeSnapshot(); err != nil {
+		return fmt.Errorf("写入向量快照失败: %w", err)
+	}
+	return s.log.truncate()
+}
+
+// removeTombstones 删除墓碑节点
+// 指向墓碑的边用墓碑自身的邻居补位（局部修复），再按启发式裁剪
+func (s *HNSWVectorStore) removeTombstones() {
+	for idx, n := range s.nodes {
+		if n.deleted {
+			continue
+		}
+		for l := range n.neighbors {
+			if !s.hasDeleted(n.neighbors[l]) {
+				continue
+			}
+
+			seen := map[uint32]bool{uint32(idx): true}
+			var candidates []hnswCandidate
+			add := func(nb uint32) {
+				if seen[nb] || s.nodes[nb].deleted || len(s.nodes[nb].neighbors) <= l {
+					return
+				}
+				seen[nb] = true
+				candidates = append(candidates, hnswCandidate{id: nb, dist: s.distanceBetween(uint32(idx), nb)})
+			}
+			for _, nb := range n.neighbors[l] {
+				if !s.nodes[nb].deleted {
+					add(nb)
+					continue
+				}
+				for _, nb2 := range s.nodes[nb].neighbors[l] {
+					add(nb2)
+				}
+			}
+
+			sort.Slice(candidates, func(i, j int) bool { return candidates[i].dist < candidates[j].dist })
+			selected := s.selectNeighbors(candidates, s.maxConn(l))
+			n.neighbors[l] = make([]uint32, len(selected))
+			for i, c := range selected {
+				n.neighbors[l][i] = c.id
+			}
+		}
+	}
+
+	// 重新编号
+	remap := make([]int, len(s.nodes))
+	live := make([]*hnswNode, 0, len(s.ids))
+	for idx, n := range s.nodes {
+		remap[idx] = -1
+		if !n.deleted {
+			remap[idx] = len(live)
+			live = append(live, n)
+		}
+	}
+	for _, n := range live {
+		for l := range n.neighbors {
+			for i, nb := range n.neighbors[l] {
+				n.neighbors[l][i] = uint32(remap[nb])
+			}
+		}
+	}
+
+	s.nodes = live
+	s.deleted = 0
+	s.ids = make(map[string]uint32, len(live))
+	s.entry = -1
+	s.maxLevel = 0
+	for idx, n := range live {
+		s.ids[n.id] = uint32(idx)
+		if s.entry < 0 || n.level > s.maxLevel {
+			s.entry = idx
+			s.maxLevel = n.level
+		}
+	}
+	if len(live) == 0 {
+		s.dim = 0
+	}
+}
+
+func (s *HNSWVectorStore) hasDeleted(neighbors []uint32) bool {
+	for _, nb := range neighbors {
+		if s.nodes[nb].deleted {
+			return true
+		}
+	}
+	return false
+}
+
+// hnswCandidate 候选节点及其与查询的距离
+type hnswCandidate struct {
+	id   uint32
+	dist float32
+}
+
+// hnswMinHeap 按距离升序（待扩展的候选）
+type hnswMinHeap []hnswCandidate
+
+func (h hnswMinHeap) Len() int            { return len(h) }
+func (h hnswMinHeap) Less(i, j int) bool  { return h[i].dist < h[j].dist }
+func (h hnswMinHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hnswMinHeap) Push(x interface{}) { *h = append(*h, x.(hnswCandidate)) }
+func (h *hnswMinHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// hnswMaxHeap 按距离降序（当前最好的 ef 个结果，堆顶是最远的）
+type hnswMaxHeap []hnswCandidate
+
+func (h hnswMaxHeap) Len() int            { return len(h) }
+func (h hnswMaxHeap) Less(i, j int) bool  { return h[i].dist > h[j].dist }
+func (h hnswMaxHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hnswMaxHeap) Push(x interface{}) { *h = append(*h, x.(hnswCandidate)) }
+func (h *hnswMaxHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// visitedSet 用代数标记的访问集合，复用时无需清零
+type visitedSet struct {
+	marks []uint32
+	gen   uint32
+}
+
+func (v *visitedSet) reset(n int) {
+	if len(v.marks) < n {
+		v.marks = make([]uint32, n+n/4)
+		v.gen = 0
+	}
+	v.gen++
+	if v.gen == 0 {
+		for i := range v.marks {
+			v.marks[i] = 0
+		}
+		v.gen = 1
+	}
+}
+
+// visit 标记已访问，首次访问返回 true
+func (v *visitedSet) visit(id uint32) bool {
+	if v.marks[id] == v.gen {
+		return false
+	}
+	v.marks[id] = v.gen
+	return true
+}
+
+// normalize 返回归一化后的向量副本
+func normalize(v []float32) []float32 {
+	var sum float32
+	for _, x := range v {
+		sum += x * x
+	}
+	out := make([]float32, len(v))
+	if sum == 0 {
+		return out
+	}
+	inv := float32(1 / math.Sqrt(float64(sum)))
+	for i, x := range v {
+		out[i] = x * inv
+	}
+	return out
+}
+
+// dot 内积（4 路展开，热点函数）
+func dot(a, b []float32) float32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return s0 + s1 + s2 + s3
+}
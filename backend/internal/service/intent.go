@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -22,6 +23,12 @@ const (
 	IntentDelete Intent = "delete"
 	// IntentClear 清空会话
 	IntentClear Intent = "clear"
+	// IntentTodo 查询待办
+	IntentTodo Intent = "todo"
+	// IntentRemind 设置提醒
+	IntentRemind Intent = "remind"
+	// IntentHistory 查找以前的对话
+	IntentHistory Intent = "history"
 	// IntentUnknown 未知意图
 	IntentUnknown Intent = "unknown"
 )
@@ -61,40 +68,66 @@ func NewIntentRecognizer() *IntentRecognizer {
 			IntentClear: {
 				"清空", "重置", "重新开始", "新对话", "忘掉",
 			},
+			IntentTodo: {
+				"待办", "代办", "要做什么", "要做的事", "有什么任务", "todo",
+			},
+			IntentRemind: {
+				"提醒我", "提醒一下", "remind me",
+			},
+			IntentHistory: {
+				"之前聊过", "以前聊过", "聊过什么", "我们聊过", "上次聊", "之前说过", "以前说过", "历史对话",
+			},
 		},
 	}
 }
 
-// Recognize 识别用户意图
-func (ir *IntentRecognizer) Recognize(text string) IntentResult {
+// Recognize 识别用户意图，实现 IntentService 接口（关键词匹配不会阻塞，不使用 ctx）
+func (ir *IntentRecognizer) Recognize(ctx context.Context, text string) IntentResult {
+	return ir.recognize(text)
+}
+
+// recognize 关键词识别
+func (ir *IntentRecognizer) recognize(text string) IntentResult {
+	if candidates := ir.Candidates(text); len(candidates) > 0 {
+		return candidates[0]
+	}
+
+	// 默认为普通聊天
+	return IntentResult{
+		Intent:     IntentChat,
+		Confidence: 0.5,
+		Entities:   make(map[string]string),
+	}
+}
+
+// Candidates 按优先级返回所有命中关键词的意图（第一个即 Recognize 的结果），没有命中时返回空
+func (ir *IntentRecognizer) Candidates(text string) []IntentResult {
 	text = strings.ToLower(strings.TrimSpace(text))
 
 	// 按优先级检查意图
 	intentOrder := []Intent{
+		IntentRemind, // 最先判断："提醒我明天删除旧分支" 不是删除指令
 		IntentClear,
 		IntentDelete,
 		IntentRecord,
+		IntentTodo,    // 在搜索之前："今天有什么待办" 也包含搜索关键词 "有什么"
+		IntentHistory, // 在搜索之前："我之前聊过什么关于 Redis 的" 也包含搜索关键词 "关于.*的"
 		IntentSearch,
 		IntentQuestion,
 	}
 
+	var candidates []IntentResult
 	for _, intent := range intentOrder {
 		if confidence, entities := ir.matchIntent(text, intent); confidence > 0.10 {
 			// 降低阈值到 0.10，允许单个关键词匹配
-			return IntentResult{
+			candidates = append(candidates, IntentResult{
 				Intent:     intent,
 				Confidence: confidence,
 				Entities:   entities,
-			}
+			})
 		}
 	}
-
-	// 默认为普通聊天
-	return IntentResult{
-		Intent:     IntentChat,
-		Confidence: 0.5,
-		Entities:   make(map[string]string),
-	}
+	return candidates
 }
 
 // matchIntent 匹配特定意图
@@ -141,21 +174,21 @@ func (ir *IntentRecognizer) matchIntent(text string, intent Intent) (float64, ma
 
 // ShouldSaveToKnowledge 是否应该保存到知识库
 func (ir *IntentRecognizer) ShouldSaveToKnowledge(text string) bool {
-	result := ir.Recognize(text)
+	result := ir.recognize(text)
 	// 使用较低的阈值，因为关键词匹配本身就说明意图
 	return result.Intent == IntentRecord && result.Confidence > 0.1
 }
 
 // ShouldSearchKnowledge 是否应该搜索知识库
 func (ir *IntentRecognizer) ShouldSearchKnowledge(text string) bool {
-	result := ir.Recognize(text)
+	result := ir.recognize(text)
 	// 使用较低的阈值，因为关键词匹配本身就说明意图
 	return result.Intent == IntentSearch && result.Confidence > 0.1
 }
 
 // ExtractSearchQuery 提取搜索查询
 func (ir *IntentRecognizer) ExtractSearchQuery(text string) string {
-	result := ir.Recognize(text)
+	result := ir.recognize(text)
 
 	// 如果有提取的实体
 	if entity, exists := result.Entities["extracted"]; exists {
@@ -186,6 +219,12 @@ func (i Intent) GetIntentDescription() string {
 		return "删除操作"
 	case IntentClear:
 		return "清空会话"
+	case IntentTodo:
+		return "待办查询"
+	case IntentRemind:
+		return "设置提醒"
+	case IntentHistory:
+		return "历史对话"
 	default:
 		return "未知意图"
 	}
@@ -199,7 +238,7 @@ func (i Intent) String() string {
 // DebugIntent 调试意图识别
 func DebugIntent(text string) {
 	ir := NewIntentRecognizer()
-	result := ir.Recognize(text)
+	result := ir.recognize(text)
 	fmt.Printf("意图识别: \"%s\"\n", text)
 	fmt.Printf("  → 意图: %s (%.2f)\n", result.Intent.GetIntentDescription(), result.Confidence)
 	if len(result.Entities) > 0 {
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 意图槽位（IntentResult.Entities 中的键）
+const (
+	SlotQuery  = "query"  // 搜索/问答的检索词
+	SlotTarget = "target" // 删除等操作针对的知识
+	SlotTime   = "time"   // 时间描述，如 "明天九点"、"上周"
+)
+
+const (
+	// IntentClassifierModel 意图分类使用的快速模型
+	IntentClassifierModel = "glm-4-flash"
+	// DefaultIntentTimeout LLM 意图分类的超时时间，超时后使用关键词识别结果
+	DefaultIntentTimeout = 1500 * time.Millisecond
+	// intentCacheSize 意图缓存条数
+	intentCacheSize = 256
+	// defaultLLMIntentConfidence LLM 未给出置信度时使用的默认值
+	defaultLLMIntentConfidence = 0.8
+)
+
+// llmIntents LLM 可以返回的意图
+var llmIntents = map[Intent]bool{
+	IntentChat: true, IntentQuestion: true, IntentRecord: true, IntentSearch: true,
+	IntentDelete: true, IntentClear: true, IntentTodo: true, IntentRemind: true, IntentHistory: true,
+}
+
+const intentPrompt = `你是 Voice Memory 语音助手的意图分类器。判断用户这句话的意图，并提取槽位。
+
+【意图】
+- chat: 闲聊、表达感受或偏好（如 "我不要吃辣"、"今天好累"）
+- question: 提问，需要回答
+- record: 要求记住/保存/记录某些内容
+- search: 查找之前记录的知识
+- delete: 明确要求删除某条已记录的知识
+- clear: 清空或重新开始当前对话
+- todo: 询问待办事项
+- remind: 要求在某个时间提醒自己
+- history: 询问以前和你聊过的内容（如 "我之前聊过什么关于 Redis"、"上次我们聊的那个方案是什么"）
+
+【槽位】（没有就省略）
+- query: search / question / history 的检索关键词
+- target: delete 要删除的知识（用用户的原话描述）
+- time: 句中的时间描述，保持原文
+
+【输出】只输出一行 JSON，不要解释，例如：
+{"intent": "delete", "confidence": 0.9, "slots": {"target": "昨天记的那条会议纪要"}}
+
+用户：`
+
+// llmIntentOutput LLM 返回的意图 JSON
+type llmIntentOutput struct {
+	Intent     string            `json:"intent"`
+	Confidence *float64          `json:"confidence"`
+	Slots      map[string]string `json:"slots"`
+}
+
+// LLMIntentClassifier 基于 LLM 的意图识别
+// 使用快速模型输出 JSON（意图 + 槽位），结果按文本缓存；LLM 超时、出错或输出无法解析时退回关键词识别
+type LLMIntentClassifier struct {
+	llm      LLMService
+	fallback IntentService
+	timeout  time.Duration
+
+	mu    sync.Mutex
+	cache map[string]*list.Element
+	order *list.List // 最近使用的在前
+}
+
+type intentCacheEntry struct {
+	key    string
+	result IntentResult
+}
+
+// NewLLMIntentClassifier 创建 LLM 意图识别，fallback 通常为 NewIntentRecognizer()
+func NewLLMIntentClassifier(llm LLMService, fallback IntentService) *LLMIntentClassifier {
+	return &LLMIntentClassifier{
+		llm:      llm,
+		fallback: fallback,
+		timeout:  DefaultIntentTimeout,
+		cache:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// SetTimeout 设置 LLM 调用超时时间
+func (c *LLMIntentClassifier) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// Recognize 识别用户意图，实现 IntentService 接口
+// LLM 调用受 ctx 控制：用户插话取消本轮时立即返回关键词识别结果
+func (c *LLMIntentClassifier) Recognize(ctx context.Context, text string) IntentResult {
+	key := strings.ToLower(strings.TrimSpace(text))
+	if result, ok := c.cached(key); ok {
+		return result
+	}
+
+	llmCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	result, err := c.classify(llmCtx, text)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("[Intent] LLM 意图识别失败，使用关键词识别: %v", err)
+		}
+		return c.fallbackResult(ctx, text)
+	}
+
+	c.store(key, result)
+	return result
+}
+
+// classify 调用 LLM 并解析输出
+func (c *LLMIntentClassifier) classify(ctx context.Context, text string) (IntentResult, error) {
+	resp, err := c.llm.SendMessage(ctx, ChatRequest{
+		Model:       IntentClassifierModel,
+		MaxTokens:   200,
+		Temperature: 0.1,
+		Messages: []Message{
+			{Role: "user", Content: intentPrompt + text},
+		},
+	})
+	if err != nil {
+		return IntentResult{}, err
+	}
+
+	reply := strings.TrimSpace(cleanMarkdownCode(strings.TrimSpace(resp.GetReplyText())))
+	var output llmIntentOutput
+	if err := json.Unmarshal([]byte(reply), &output); err != nil {
+		return IntentResult{}, fmt.Errorf("解析意图 JSON 失败: %w (输出: %s)", err, reply)
+	}
+
+	intent := Intent(strings.ToLower(strings.TrimSpace(output.Intent)))
+	if !llmIntents[intent] {
+		return IntentResult{}, fmt.Errorf("未知意图: %q", output.Intent)
+	}
+
+	confidence := defaultLLMIntentConfidence
+	if output.Confidence != nil {
+		confidence = clampConfidence(*output.Confidence, 0, 1)
+	}
+
+	entities := make(map[string]string)
+	for _, slot := range []string{SlotQuery, SlotTarget, SlotTime} {
+		if value := strings.TrimSpace(output.Slots[slot]); value != "" {
+			entities[slot] = value
+		}
+	}
+
+	return IntentResult{
+		Intent:     intent,
+		Confidence: confidence,
+		Entities:   entities,
+	}, nil
+}
+
+// fallbackResult 关键词识别结果（未设置 fallback 时按普通聊天处理）
+func (c *LLMIntentClassifier) fallbackResult(ctx context.Context, text string) IntentResult {
+	if c.fallback == nil {
+		return IntentResult{Intent: IntentChat, Confidence: 0.5, Entities: make(map[string]string)}
+	}
+	return c.fallback.Recognize(ctx, text)
+}
+
+func (c *LLMIntentClassifier) cached(key string) (IntentResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.cache[key]
+	if !ok {
+		return IntentResult{}, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*intentCacheEntry).result, true
+}
+
+func (c *LLMIntentClassifier) store(key string, result IntentResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.cache[key]; ok {
+		elem.Value.(*intentCacheEntry).result = result
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.cache[key] = c.order.PushFront(&intentCacheEntry{key: key, result: result})
+	if c.order.Len() > intentCacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.cache, oldest.Value.(*intentCacheEntry).key)
+	}
+}
+
+// clampConfidence 把置信度限制在 [lo, hi]
+func clampConfidence(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
+
+// DefaultAmbiguousConfidence 删除/清空意图的关键词置信度低于该值时视为不确定
+// 删除有 4 个关键词，只命中一个（如 "我不要吃辣" 中的 "不要"）时置信度为 0.25
+const DefaultAmbiguousConfidence = 0.5
+
+// CompositeIntentRecognizer 组合意图识别：先用关键词识别，只有结果不确定时才交给 LLM
+// 不确定指命中了多个意图，或者只凭单个关键词判断为会短路对话的删除/清空指令
+type CompositeIntentRecognizer struct {
+	rules     *IntentRecognizer
+	llm       IntentService
+	threshold float64
+}
+
+// NewCompositeIntentRecognizer 创建组合意图识别，llm 为 nil 时等同于关键词识别
+func NewCompositeIntentRecognizer(rules *IntentRecognizer, llm IntentService) *CompositeIntentRecognizer {
+	return &CompositeIntentRecognizer{
+		rules:     rules,
+		llm:       llm,
+		threshold: DefaultAmbiguousConfidence,
+	}
+}
+
+// SetAmbiguousConfidence 设置删除/清空意图交给 LLM 复核的置信度阈值
+func (c *CompositeIntentRecognizer) SetAmbiguousConfidence(threshold float64) {
+	c.threshold = threshold
+}
+
+// Recognize 识别用户意图，实现 IntentService 接口
+func (c *CompositeIntentRecognizer) Recognize(ctx context.Context, text string) IntentResult {
+	if c.llm == nil || !c.ambiguous(c.rules.Candidates(text)) {
+		return c.rules.recognize(text)
+	}
+	return c.llm.Recognize(ctx, text)
+}
+
+func (c *CompositeIntentRecognizer) ambiguous(candidates []IntentResult) bool {
+	switch {
+	case len(candidates) == 0:
+		return false // 没有命中关键词：普通聊天
+	case len(candidates) > 1:
+		return true
+	}
+	top := candidates[0]
+	if top.Intent == IntentDelete || top.Intent == IntentClear {
+		return top.Confidence < c.threshold
+	}
+	return false
+}
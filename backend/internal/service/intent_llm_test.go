@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeIntentLLM 按固定回复（或错误 / 延迟）响应的 LLM，记录调用次数
+type fakeIntentLLM struct {
+	reply string
+	err   error
+	delay time.Duration
+	calls int32
+	model string
+}
+
+func (f *fakeIntentLLM) SendMessage(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	atomic.AddInt32(&f.calls, 1)
+	f.model = req.Model
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &ChatResponse{Type: "message", Content: []Content{{Type: "text", Text: f.reply}}}, nil
+}
+
+func (f *fakeIntentLLM) SendMessageStream(ctx context.Context, req ChatRequest, callback func(StreamChunk)) error {
+	return errors.New("not implemented")
+}
+
+func TestLLMIntentClassifier(t *testing.T) {
+	t.Run("解析意图和槽位", func(t *testing.T) {
+		llm := &fakeIntentLLM{reply: "```json\n" + `{"intent": "delete", "confidence": 0.92, "slots": {"target": "会议纪要", "time": "昨天", "extra": "x"}}` + "\n```"}
+		c := NewLLMIntentClassifier(llm, NewIntentRecognizer())
+
+		result := c.Recognize(context.Background(), "把昨天那条会议纪要删掉")
+		if result.Intent != IntentDelete || result.Confidence != 0.92 {
+			t.Errorf("意图错误: %+v", result)
+		}
+		if result.Entities[SlotTarget] != "会议纪要" || result.Entities[SlotTime] != "昨天" || len(result.Entities) != 2 {
+			t.Errorf("槽位错误: %v", result.Entities)
+		}
+		if llm.model != IntentClassifierModel {
+			t.Errorf("应使用快速模型, 实际 %s", llm.model)
+		}
+	})
+
+	t.Run("相同文本命中缓存", func(t *testing.T) {
+		llm := &fakeIntentLLM{reply: `{"intent": "chat"}`}
+		c := NewLLMIntentClassifier(llm, NewIntentRecognizer())
+
+		first := c.Recognize(context.Background(), "我不要吃辣")
+		second := c.Recognize(context.Background(), "  我不要吃辣 ")
+		if first.Intent != IntentChat || second.Intent != IntentChat {
+			t.Errorf("意图错误: %+v %+v", first, second)
+		}
+		if first.Confidence != defaultLLMIntentConfidence {
+			t.Errorf("未给出置信度时应使用默认值, 实际 %.2f", first.Confidence)
+		}
+		if llm.calls != 1 {
+			t.Errorf("期望只调用 1 次 LLM, 实际 %d", llm.calls)
+		}
+	})
+
+	t.Run("缓存按最近使用淘汰", func(t *testing.T) {
+		llm := &fakeIntentLLM{reply: `{"intent": "chat"}`}
+		c := NewLLMIntentClassifier(llm, nil)
+		c.Recognize(context.Background(), "第一句")
+		for i := 0; i < intentCacheSize; i++ {
+			c.Recognize(context.Background(), fmt.Sprintf("句子 %d", i))
+		}
+		before := atomic.LoadInt32(&llm.calls)
+		c.Recognize(context.Background(), "第一句")
+		if llm.calls != before+1 {
+			t.Errorf("最久未使用的条目应被淘汰")
+		}
+		if len(c.cache) != intentCacheSize {
+			t.Errorf("缓存条数应保持为 %d, 实际 %d", intentCacheSize, len(c.cache))
+		}
+	})
+
+	fallbackCases := []struct {
+		name string
+		llm  *fakeIntentLLM
+	}{
+		{"LLM 出错", &fakeIntentLLM{err: errors.New("服务不可用")}},
+		{"LLM 超时", &fakeIntentLLM{reply: `{"intent": "chat"}`, delay: time.Second}},
+		{"输出不是 JSON", &fakeIntentLLM{reply: "这句话是闲聊"}},
+		{"未知意图", &fakeIntentLLM{reply: `{"intent": "order_food"}`}},
+	}
+	for _, tt := range fallbackCases {
+		t.Run(tt.name+"时退回关键词识别", func(t *testing.T) {
+			c := NewLLMIntentClassifier(tt.llm, NewIntentRecognizer())
+			c.SetTimeout(20 * time.Millisecond)
+
+			if result := c.Recognize(context.Background(), "清空对话"); result.Intent != IntentClear {
+				t.Errorf("期望关键词识别结果 clear, 实际 %s", result.Intent)
+			}
+			c.Recognize(context.Background(), "清空对话")
+			if tt.llm.calls != 2 {
+				t.Errorf("失败结果不应缓存, 调用次数 %d", tt.llm.calls)
+			}
+		})
+	}
+}
+
+func TestLLMIntentClassifier_Cancel(t *testing.T) {
+	llm := &fakeIntentLLM{reply: `{"intent": "chat"}`, delay: time.Minute}
+	c := NewLLMIntentClassifier(llm, NewIntentRecognizer())
+
+	// 打断本轮对话时不必等到分类超时
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	if result := c.Recognize(ctx, "清空对话"); result.Intent != IntentClear {
+		t.Errorf("取消后应返回关键词识别结果, 实际 %s", result.Intent)
+	}
+	if elapsed := time.Since(start); elapsed >= DefaultIntentTimeout {
+		t.Errorf("取消后应立即返回, 耗时 %v", elapsed)
+	}
+}
+
+func TestCompositeIntentRecognizer(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		escalate bool
+		want     Intent
+	}{
+		{"单个关键词的删除交给 LLM", "我不要吃辣", true, IntentChat},
+		{"命中多个意图交给 LLM", "提醒我明天删除旧分支", true, IntentChat},
+		{"明确的删除指令不调用 LLM", "删除不要的那条", false, IntentDelete},
+		{"没有命中关键词不调用 LLM", "今天天气不错", false, IntentChat},
+		{"单一非指令意图不调用 LLM", "帮我记一下明天开会", false, IntentRecord},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := &fakeIntentLLM{reply: `{"intent": "chat", "confidence": 0.9}`}
+			rules := NewIntentRecognizer()
+			c := NewCompositeIntentRecognizer(rules, NewLLMIntentClassifier(llm, rules))
+
+			result := c.Recognize(context.Background(), tt.text)
+			if result.Intent != tt.want {
+				t.Errorf("期望 %s, 实际 %s", tt.want, result.Intent)
+			}
+			if escalated := llm.calls > 0; escalated != tt.escalate {
+				t.Errorf("是否调用 LLM: 期望 %v, 实际 %v", tt.escalate, escalated)
+			}
+		})
+	}
+
+	t.Run("未设置 LLM 时等同于关键词识别", func(t *testing.T) {
+		c := NewCompositeIntentRecognizer(NewIntentRecognizer(), nil)
+		if result := c.Recognize(context.Background(), "我不要吃辣"); result.Intent != IntentDelete {
+			t.Errorf("期望关键词识别结果 delete, 实际 %s", result.Intent)
+		}
+	})
+}
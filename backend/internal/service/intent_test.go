@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"testing"
 )
 
@@ -43,7 +44,7 @@ func TestRecognizeChatIntent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := ir.Recognize(tt.text)
+			result := ir.Recognize(context.Background(), tt.text)
 			if result.Intent != tt.expected {
 				t.Errorf("期望 %s, 得到 %s", tt.expected, result.Intent)
 			}
@@ -89,7 +90,7 @@ func TestRecognizeRecordIntent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := ir.Recognize(tt.text)
+			result := ir.Recognize(context.Background(), tt.text)
 			if result.Intent != tt.expectIntent {
 				t.Errorf("期望 %s, 得到 %s", tt.expectIntent, result.Intent)
 			}
@@ -132,7 +133,7 @@ func TestRecognizeSearchIntent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := ir.Recognize(tt.text)
+			result := ir.Recognize(context.Background(), tt.text)
 			if result.Intent != tt.expectIntent {
 				t.Errorf("期望 %s, 得到 %s", tt.expectIntent, result.Intent)
 			}
@@ -181,7 +182,7 @@ func TestRecognizeQuestionIntent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := ir.Recognize(tt.text)
+			result := ir.Recognize(context.Background(), tt.text)
 			if result.Intent != tt.expectIntent {
 				t.Errorf("期望 %s, 得到 %s", tt.expectIntent, result.Intent)
 			}
@@ -224,7 +225,80 @@ func TestRecognizeClearIntent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := ir.Recognize(tt.text)
+			result := ir.Recognize(context.Background(), tt.text)
+			if result.Intent != tt.expectIntent {
+				t.Errorf("期望 %s, 得到 %s", tt.expectIntent, result.Intent)
+			}
+		})
+	}
+}
+
+// TestRecognizeTodoIntent 测试识别待办查询意图
+func TestRecognizeTodoIntent(t *testing.T) {
+	ir := NewIntentRecognizer()
+
+	tests := []struct {
+		name         string
+		text         string
+		expectIntent Intent
+	}{
+		{name: "今天的待办", text: "我今天有什么待办", expectIntent: IntentTodo},
+		{name: "同音识别结果", text: "明天有哪些代办事项", expectIntent: IntentTodo},
+		{name: "要做的事", text: "这周还有什么要做的事", expectIntent: IntentTodo},
+		{name: "记录优先", text: "帮我记一个待办：周五交报告", expectIntent: IntentRecord},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ir.Recognize(context.Background(), tt.text)
+			if result.Intent != tt.expectIntent {
+				t.Errorf("期望 %s, 得到 %s", tt.expectIntent, result.Intent)
+			}
+		})
+	}
+}
+
+// TestRecognizeRemindIntent 测试识别设置提醒意图
+func TestRecognizeRemindIntent(t *testing.T) {
+	ir := NewIntentRecognizer()
+
+	tests := []struct {
+		name         string
+		text         string
+		expectIntent Intent
+	}{
+		{name: "提醒我", text: "提醒我明天九点开会", expectIntent: IntentRemind},
+		{name: "时间在前", text: "两小时后提醒我给妈妈打电话", expectIntent: IntentRemind},
+		{name: "优先于删除", text: "提醒我周五删除旧分支", expectIntent: IntentRemind},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ir.Recognize(context.Background(), tt.text)
+			if result.Intent != tt.expectIntent {
+				t.Errorf("期望 %s, 得到 %s", tt.expectIntent, result.Intent)
+			}
+		})
+	}
+}
+
+// TestRecognizeHistoryIntent 测试识别查找历史对话意图
+func TestRecognizeHistoryIntent(t *testing.T) {
+	ir := NewIntentRecognizer()
+
+	tests := []struct {
+		name         string
+		text         string
+		expectIntent Intent
+	}{
+		{name: "之前聊过", text: "我之前聊过什么关于Redis的", expectIntent: IntentHistory},
+		{name: "上次聊", text: "上次聊的那个部署方案是什么", expectIntent: IntentHistory},
+		{name: "知识搜索不受影响", text: "搜索关于Redis的笔记", expectIntent: IntentSearch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ir.Recognize(context.Background(), tt.text)
 			if result.Intent != tt.expectIntent {
 				t.Errorf("期望 %s, 得到 %s", tt.expectIntent, result.Intent)
 			}
@@ -357,6 +431,9 @@ func TestGetIntentDescription(t *testing.T) {
 		{IntentSearch, "知识搜索"},
 		{IntentDelete, "删除操作"},
 		{IntentClear, "清空会话"},
+		{IntentTodo, "待办查询"},
+		{IntentRemind, "设置提醒"},
+		{IntentHistory, "历史对话"},
 		{IntentUnknown, "未知意图"},
 	}
 
@@ -400,6 +477,6 @@ func BenchmarkRecognize(b *testing.B) {
 	text := "记住，我今天下午3点有个会议"
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		ir.Recognize(text)
+		ir.Recognize(context.Background(), text)
 	}
 }
@@ -1,37 +1,76 @@
 package service
 
+import "context"
+
+// 所有访问外部服务的接口都接收 context.Context：
+// WebSocket 打断会取消 context，从而中止正在进行的 HTTP/WebSocket 请求。
+
 // STTService 语音转文字服务接口
 type STTService interface {
-	Recognize(req *RecognizeRequest) ([]string, error)
+	Recognize(ctx context.Context, req *RecognizeRequest) ([]string, error)
+}
+
+// StreamingSTTService 流式语音识别服务接口
+// 音频边采集边推送，识别过程中持续返回部分结果，而不必等整段语音结束
+type StreamingSTTService interface {
+	// NewStream 打开一个识别流，ctx 取消时流随之关闭
+	NewStream(ctx context.Context) (STTStream, error)
+}
+
+// STTStream 一次流式识别会话
+type STTStream interface {
+	// Write 推送一段 16kHz 16bit 单声道 PCM 音频
+	Write(pcm []byte) error
+	// Results 识别结果通道（包含部分结果和最终结果），识别结束后关闭
+	Results() <-chan STTResult
+	// Close 通知服务端音频已发送完毕，剩余结果仍会从 Results 返回
+	Close() error
+}
+
+// STTResult 流式识别结果
+type STTResult struct {
+	Text    string `json:"text"`
+	Segment int    `json:"segment"`  // 分段序号，同一分段的部分结果会被后续结果覆盖
+	IsFinal bool   `json:"is_final"` // 是否为该分段的最终结果
 }
 
 // LLMService 大语言模型服务接口
 type LLMService interface {
 	// SendMessage 发送消息（非流式）
-	SendMessage(req ChatRequest) (*ChatResponse, error)
+	SendMessage(ctx context.Context, req ChatRequest) (*ChatResponse, error)
 	// SendMessageStream 流式发送消息
 	// callback: 每收到一个 chunk 就回调一次
-	SendMessageStream(req ChatRequest, callback func(StreamChunk)) error
+	// ctx 被取消时立即中断 SSE 连接并返回 ctx.Err()
+	SendMessageStream(ctx context.Context, req ChatRequest, callback func(StreamChunk)) error
 }
 
 // TTSService 文字转语音服务接口
 type TTSService interface {
 	// Synthesize 合成语音
-	Synthesize(options TTSOptions) ([]byte, error)
+	Synthesize(ctx context.Context, options TTSOptions) ([]byte, error)
 	// SynthesizeToFile 合成语音并保存到文件
-	SynthesizeToFile(options TTSOptions) (string, error)
+	SynthesizeToFile(ctx context.Context, options TTSOptions) (string, error)
 	// ServeAudio 提供音频文件
 	ServeAudio(filename string) ([]byte, string, error)
 }
 
 // IntentService 意图识别服务接口
 type IntentService interface {
-	Recognize(text string) IntentResult
+	// Recognize 识别意图；ctx 取消（打断）时应尽快返回，可退回关键词识别结果
+	Recognize(ctx context.Context, text string) IntentResult
 }
 
 // EmbeddingService 文本向量化服务接口
 type EmbeddingService interface {
-	GetEmbedding(text string) ([]float32, error)
+	GetEmbedding(ctx context.Context, text string) ([]float32, error)
+}
+
+// RetrievalService 知识检索服务接口
+type RetrievalService interface {
+	// ShouldUseRAG 根据意图判断是否需要检索知识库
+	ShouldUseRAG(intent Intent, confidence float64) bool
+	// Retrieve 检索与查询最相关的 topK 条知识，filter 为 nil 时不过滤
+	Retrieve(ctx context.Context, query string, topK int, filter *VectorFilter) ([]*RetrievalResult, error)
 }
 
 // VectorResult 向量搜索结果
@@ -45,8 +84,14 @@ type VectorResult struct {
 type VectorStore interface {
 	// Add 添加向量
 	Add(id string, embedding []float32, metadata map[string]interface{}) error
-	// Search 搜索相似向量
-	Search(embedding []float32, limit int) ([]VectorResult, error)
+	// AddBatch 批量添加向量（一次持久化）
+	AddBatch(items []VectorItem) error
+	// Search 搜索相似向量，filter 非空时只返回元数据满足条件的条目
+	Search(embedding []float32, limit int, filter *VectorFilter) ([]VectorResult, error)
 	// Delete 删除向量
 	Delete(id string) error
+	// Get 获取指定向量条目，不存在时返回 nil
+	Get(id string) (*VectorItem, error)
+	// IDs 列出所有向量 ID（用于与数据库对账）
+	IDs() ([]string, error)
 }
\ No newline at end of file
@@ -0,0 +1,545 @@
+package service
+
+import (
+	"container/heap"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// 知识全文索引
+//
+// knowledge_fts 是 FTS 虚拟表（rowid 与 knowledge.rowid 一致），由触发器与 knowledge 表保持同步。
+// SQLite 内置分词器不会切分中文，所以写入前用 Go 注册的 fts_tokens() 预先分词：
+// 中文按单字 + 相邻二字（bigram）切分，英文/数字按单词切分并转小写，词之间用空格分隔。
+// 驱动编译了 FTS5（-tags sqlite_fts5）时使用 FTS5 的 bm25()，否则使用 FTS4 并根据 matchinfo 在 Go 中计算 BM25。
+//
+// 注意：触发器依赖 fts_tokens()，只能通过 NewDatabase 打开的连接写入 knowledge 表。
+
+// sqliteDriverName 注册了自定义函数的 SQLite 驱动
+const sqliteDriverName = "sqlite3_voice_memory"
+
+func init() {
+	sql.Register(sqliteDriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("fts_tokens", ftsTokens, true)
+		},
+	})
+}
+
+// ftsColumns 索引的列及 BM25 权重（标题和标签命中比正文更重要）
+var ftsColumns = []struct {
+	name   string
+	weight float64
+}{
+	{"title", 3.0},
+	{"summary", 2.0},
+	{"content", 1.0},
+	{"tags", 2.0},
+	{"key_points", 1.5},
+}
+
+// BM25 参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// KeywordHit 全文检索命中
+type KeywordHit struct {
+	ID    string
+	Score float64 // BM25 分数，越大越相关
+}
+
+// createFTSTable 创建全文索引虚拟表（驱动不支持 FTS5 时使用 FTS4），返回使用的模块；表已存在时返回其原有模块
+func (d *Database) createFTSTable(name string, columns []string) (string, error) {
+	var existing string
+	err := d.db.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&existing)
+	switch {
+	case err == sql.ErrNoRows:
+		module := "fts5"
+		_, err = d.db.Exec(`CREATE VIRTUAL TABLE ` + name + ` USING fts5(` + strings.Join(columns, ", ") + `)`)
+		if err != nil && strings.Contains(err.Error(), "no such module") {
+			log.Printf("[FTS] SQLite 驱动未编译 FTS5（需 go build -tags sqlite_fts5），%s 改用 FTS4，BM25 在 Go 中计算", name)
+			module = "fts4"
+			_, err = d.db.Exec(`CREATE VIRTUAL TABLE ` + name + ` USING fts4(` + strings.Join(columns, ", ") + `)`)
+		}
+		if err != nil {
+			return "", fmt.Errorf("创建全文索引失败: %w", err)
+		}
+		return module, nil
+	case err != nil:
+		return "", err
+	case strings.Contains(strings.ToLower(existing), "fts5"):
+		return "fts5", nil
+	default:
+		log.Printf("[FTS] %s 是 FTS4 表，BM25 在 Go 中计算（删除该表后以 -tags sqlite_fts5 启动可改用 FTS5）", name)
+		return "fts4", nil
+	}
+}
+
+// initFTS 创建全文索引表和同步触发器，索引与知识表条数不一致时重建
+func (d *Database) initFTS() error {
+	columns := make([]string, len(ftsColumns))
+	for i, c := range ftsColumns {
+		columns[i] = c.name
+	}
+	module, err := d.createFTSTable("knowledge_fts", columns)
+	if err != nil {
+		return err
+	}
+	d.ftsModule = module
+
+	tokenized := make([]string, len(ftsColumns))
+	for i, c := range ftsColumns {
+		tokenized[i] = "fts_tokens(COALESCE(NEW." + c.name + ", ''))"
+	}
+	insert := `INSERT INTO knowledge_fts (rowid, title, summary, content, tags, key_points)
+			VALUES (NEW.rowid, ` + strings.Join(tokenized, ", ") + `);`
+
+	triggers := []string{
+		// INSERT OR REPLACE 不会触发 DELETE 触发器，插入前先删除同 ID 旧行的索引
+		`CREATE TRIGGER IF NOT EXISTS trg_knowledge_fts_replace BEFORE INSERT ON knowledge
+		BEGIN
+			DELETE FROM knowledge_fts WHERE rowid IN (SELECT rowid FROM knowledge WHERE id = NEW.id);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS trg_knowledge_fts_insert AFTER INSERT ON knowledge
+		BEGIN
+			` + insert + `
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS trg_knowledge_fts_update AFTER UPDATE OF title, summary, content, tags, key_points ON knowledge
+		BEGIN
+			DELETE FROM knowledge_fts WHERE rowid = OLD.rowid;
+			` + insert + `
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS trg_knowledge_fts_delete AFTER DELETE ON knowledge
+		BEGIN
+			DELETE FROM knowledge_fts WHERE rowid = OLD.rowid;
+		END`,
+	}
+	for _, trigger := range triggers {
+		if _, err := d.db.Exec(trigger); err != nil {
+			return fmt.Errorf("创建全文索引触发器失败: %w", err)
+		}
+	}
+
+	var indexed, total int
+	d.db.QueryRow(`SELECT COUNT(*) FROM knowledge_fts`).Scan(&indexed)
+	d.db.QueryRow(`SELECT COUNT(*) FROM knowledge`).Scan(&total)
+	if indexed != total {
+		return d.RebuildFTS()
+	}
+	return nil
+}
+
+// RebuildFTS 重建全文索引（首次创建索引或分词规则变化时使用）
+func (d *Database) RebuildFTS() error {
+	return d.WithTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM knowledge_fts`); err != nil {
+			return fmt.Errorf("清空全文索引失败: %w", err)
+		}
+		_, err := tx.Exec(`INSERT INTO knowledge_fts (rowid, title, summary, content, tags, key_points)
+			SELECT rowid, fts_tokens(COALESCE(title, '')), fts_tokens(COALESCE(summary, '')), fts_tokens(COALESCE(content, '')),
+			       fts_tokens(COALESCE(tags, '')), fts_tokens(COALESCE(key_points, ''))
+			FROM knowledge`)
+		if err != nil {
+			return fmt.Errorf("重建全文索引失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// SearchKnowledgeFTS 全文检索，按 BM25 从高到低返回最多 limit 条（limit <= 0 不限制）
+// filter 在 SQL 中过滤（nil 不过滤）；FTS5 由 SQLite 排序取前 limit 条，FTS4 边读边保留前 limit 条
+func (d *Database) SearchKnowledgeFTS(query string, limit int, filter *VectorFilter) ([]KeywordHit, error) {
+	tokens := SearchTokens(query)
+	if len(tokens) == 0 {
+		return []KeywordHit{}, nil
+	}
+	match := ftsMatchExpr(tokens)
+	where, args := knowledgeFilterSQL(filter)
+
+	var hits []KeywordHit
+	var err error
+	if d.ftsModule == "fts5" {
+		hits, err = d.searchFTS5(match, where, args, limit)
+	} else {
+		hits, err = d.searchFTS4(match, where, args, limit)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("全文检索失败: %w", err)
+	}
+	return hits, nil
+}
+
+func (d *Database) searchFTS5(match, where string, args []interface{}, limit int) ([]KeywordHit, error) {
+	weights := make([]string, len(ftsColumns))
+	for i, c := range ftsColumns {
+		weights[i] = fmt.Sprintf("%g", c.weight)
+	}
+	// FTS5 的 bm25() 越小越相关，取负数
+	query := `SELECT k.id, -bm25(knowledge_fts, ` + strings.Join(weights, ", ") + `) AS score
+		FROM knowledge_fts JOIN knowledge k ON k.rowid = knowledge_fts.rowid
+		WHERE knowledge_fts MATCH ?` + where + ` ORDER BY score DESC, k.id`
+	args = append([]interface{}{match}, args...)
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []KeywordHit{}
+	for rows.Next() {
+		var hit KeywordHit
+		if err := rows.Scan(&hit.ID, &hit.Score); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+func (d *Database) searchFTS4(match, where string, args []interface{}, limit int) ([]KeywordHit, error) {
+	rows, err := d.db.Query(`SELECT k.id, matchinfo(knowledge_fts, 'pcnalx')
+		FROM knowledge_fts JOIN knowledge k ON k.rowid = knowledge_fts.rowid
+		WHERE knowledge_fts MATCH ?`+where, append([]interface{}{match}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	top := newTopN(limit, keywordHitBefore)
+	weights := ftsWeights()
+	for rows.Next() {
+		var id string
+		var info []byte
+		if err := rows.Scan(&id, &info); err != nil {
+			return nil, err
+		}
+		top.add(KeywordHit{ID: id, Score: bm25FromMatchinfo(info, weights)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return top.sorted(), nil
+}
+
+// knowledgeFilterSQL 把过滤条件转成知识表（别名 k）上的 SQL 条件，以 " AND ..." 开头，没有条件时为空
+// 与 VectorFilter.Match 的规则一致：标签不区分大小写且须全部包含，时间为 [Since, Until)
+func knowledgeFilterSQL(f *VectorFilter) (string, []interface{}) {
+	if f.IsEmpty() {
+		return "", nil
+	}
+
+	var where strings.Builder
+	var args []interface{}
+	if f.Category != "" {
+		where.WriteString(` AND k.category = ?`)
+		args = append(args, f.Category)
+	}
+	if f.Importance != "" {
+		where.WriteString(` AND COALESCE(k.importance, 'medium') = ?`)
+		args = append(args, f.Importance)
+	}
+	if f.SessionID != "" {
+		where.WriteString(` AND k.session_id = ?`)
+		args = append(args, f.SessionID)
+	}
+	for _, tag := range f.Tags {
+		where.WriteString(` AND EXISTS (SELECT 1 FROM json_each(CASE WHEN json_valid(k.tags) THEN k.tags ELSE '[]' END) t
+			WHERE lower(t.value) = lower(?))`)
+		args = append(args, tag)
+	}
+	// created_at 精确到秒，带小数秒的边界向上取整后比较
+	if f.Since != nil {
+		where.WriteString(` AND k.created_at >= ?`)
+		args = append(args, ceilUnix(*f.Since))
+	}
+	if f.Until != nil {
+		where.WriteString(` AND k.created_at < ?`)
+		args = append(args, ceilUnix(*f.Until))
+	}
+	return where.String(), args
+}
+
+// ceilUnix 向上取整的 Unix 秒
+func ceilUnix(t time.Time) int64 {
+	if t.Nanosecond() > 0 {
+		return t.Unix() + 1
+	}
+	return t.Unix()
+}
+
+// topN 边读边保留排序最靠前的 n 项（堆顶为保留项中最靠后的一项），n <= 0 时全部保留
+type topN[T any] struct {
+	n      int
+	before func(a, b T) bool // a 排在 b 前面
+	items  []T
+}
+
+func newTopN[T any](n int, before func(a, b T) bool) *topN[T] {
+	return &topN[T]{n: n, before: before}
+}
+
+func (t *topN[T]) Len() int           { return len(t.items) }
+func (t *topN[T]) Swap(i, j int)      { t.items[i], t.items[j] = t.items[j], t.items[i] }
+func (t *topN[T]) Less(i, j int) bool { return t.before(t.items[j], t.items[i]) }
+func (t *topN[T]) Push(x interface{}) { t.items = append(t.items, x.(T)) }
+func (t *topN[T]) Pop() interface{} {
+	last := t.items[len(t.items)-1]
+	t.items = t.items[:len(t.items)-1]
+	return last
+}
+
+func (t *topN[T]) add(item T) {
+	switch {
+	case t.n <= 0 || len(t.items) < t.n:
+		heap.Push(t, item)
+	case t.before(item, t.items[0]):
+		t.items[0] = item
+		heap.Fix(t, 0)
+	}
+}
+
+// sorted 按排序返回保留的项
+func (t *topN[T]) sorted() []T {
+	items := append([]T{}, t.items...)
+	sort.Slice(items, func(i, j int) bool { return t.before(items[i], items[j]) })
+	return items
+}
+
+// keywordHitBefore a 排在 b 前面：分数更高，同分时 ID 更小（与 FTS5 的 ORDER BY score DESC, id 一致）
+func keywordHitBefore(a, b KeywordHit) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.ID < b.ID
+}
+
+// ftsWeights 知识索引各列的 BM25 权重
+func ftsWeights() []float64 {
+	weights := make([]float64, len(ftsColumns))
+	for i, c := range ftsColumns {
+		weights[i] = c.weight
+	}
+	return weights
+}
+
+// bm25FromMatchinfo 根据 FTS4 matchinfo('pcnalx') 计算按列加权的 BM25，weights 为各列权重
+// 布局（uint32，本机字节序）: p 短语数, c 列数, n 总行数, a[c] 各列平均词数, l[c] 本行各列词数,
+// x[p*c*3] 每个短语在每列的 (本行命中数, 全表命中数, 命中行数)
+func bm25FromMatchinfo(blob []byte, weights []float64) float64 {
+	info := make([]uint32, len(blob)/4)
+	for i := range info {
+		info[i] = binary.NativeEndian.Uint32(blob[i*4:])
+	}
+	if len(info) < 3 {
+		return 0
+	}
+	p, c := int(info[0]), int(info[1])
+	if len(info) < 3+2*c+3*p*c {
+		return 0
+	}
+	n := float64(info[2])
+	avg := info[3 : 3+c]
+	lens := info[3+c : 3+2*c]
+	x := info[3+2*c:]
+
+	var score float64
+	for i := 0; i < p; i++ {
+		for j := 0; j < c && j < len(weights); j++ {
+			base := 3 * (i*c + j)
+			tf, df := float64(x[base]), float64(x[base+2])
+			if tf == 0 {
+				continue
+			}
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			norm := 1 - bm25B
+			if avg[j] > 0 {
+				norm += bm25B * float64(lens[j]) / float64(avg[j])
+			}
+			score += weights[j] * idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+	return score
+}
+
+// ftsMatchExpr 构造 MATCH 表达式：任一词命中即可，排序交给 BM25
+func ftsMatchExpr(tokens []string) string {
+	quoted := make([]string, len(tokens))
+	for i, t := range tokens {
+		quoted[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"`
+	}
+	return strings.Join(quoted, " OR ")
+}
+
+// isCJK 中日韩文字（按字切分）
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// splitTerms 把文本切成中文连续片段和英文/数字单词（小写）
+func splitTerms(text string, onCJK func([]rune), onWord func(string)) {
+	var cjk, word []rune
+	flush := func() {
+		if len(cjk) > 0 {
+			onCJK(cjk)
+			cjk = nil
+		}
+		if len(word) > 0 {
+			onWord(strings.ToLower(string(word)))
+			word = nil
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			if len(word) > 0 {
+				flush()
+			}
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if len(cjk) > 0 {
+				flush()
+			}
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+}
+
+// ftsTokens 索引分词：中文输出单字和 bigram（单字保证一个字的查询也能命中），英文输出单词
+func ftsTokens(text string) string {
+	var tokens []string
+	splitTerms(text, func(run []rune) {
+		for i := range run {
+			tokens = append(tokens, string(run[i]))
+			if i+1 < len(run) {
+				tokens = append(tokens, string(run[i:i+2]))
+			}
+		}
+	}, func(word string) {
+		tokens = append(tokens, word)
+	})
+	return strings.Join(tokens, " ")
+}
+
+// SearchTokens 查询分词：中文片段切成 bigram（只有一个字时用单字），英文为单词，去重
+func SearchTokens(query string) []string {
+	var tokens []string
+	seen := make(map[string]bool)
+	add := func(t string) {
+		if !seen[t] {
+			seen[t] = true
+			tokens = append(tokens, t)
+		}
+	}
+
+	splitTerms(query, func(run []rune) {
+		if len(run) == 1 {
+			add(string(run))
+			return
+		}
+		for i := 0; i+1 < len(run); i++ {
+			add(string(run[i : i+2]))
+		}
+	}, add)
+	return tokens
+}
+
+// HighlightSnippet 截取 text 中命中查询词最多的片段（约 width 个字），命中处用 <mark></mark> 包裹
+// 输出已做 HTML 转义，可直接插入页面；没有命中时返回开头部分
+func HighlightSnippet(text string, tokens []string, width int) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		lower = runes
+	}
+
+	marked := make([]bool, len(runes))
+	for _, token := range tokens {
+		t := []rune(token)
+		if len(t) == 0 {
+			continue
+		}
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if runesEqual(lower[i:i+len(t)], t) {
+				for j := i; j < i+len(t); j++ {
+					marked[j] = true
+				}
+			}
+		}
+	}
+
+	// 滑动窗口找命中字数最多的位置
+	start, best, count := 0, 0, 0
+	for i := range runes {
+		if marked[i] {
+			count++
+		}
+		if i >= width && marked[i-width] {
+			count--
+		}
+		if count > best {
+			best = count
+			start = max(0, i-width+1)
+		}
+	}
+	// 从窗口内第一个命中处开始，左侧留少量上下文
+	if best > 0 {
+		for !marked[start] {
+			start++
+		}
+		start = max(0, start-width/5)
+	}
+	end := min(len(runes), start+width)
+	start = max(0, min(start, end-width))
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && marked[j] == marked[i] {
+			j++
+		}
+		segment := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			sb.WriteString("<mark>" + segment + "</mark>")
+		} else {
+			sb.WriteString(segment)
+		}
+		i = j
+	}
+	if end < len(runes) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
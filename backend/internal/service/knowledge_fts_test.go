@@ -0,0 +1,156 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFTSTokenize(t *testing.T) {
+	t.Run("索引分词输出单字和 bigram", func(t *testing.T) {
+		if got := ftsTokens("部署Go服务, v2"); got != "部 部署 署 go 服 服务 务 v2" {
+			t.Errorf("分词结果错误: %q", got)
+		}
+	})
+
+	t.Run("查询分词输出 bigram 并去重", func(t *testing.T) {
+		got := SearchTokens("周会 周会纪要 K8s")
+		want := []string{"周会", "会纪", "纪要", "k8s"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("期望 %v, 实际 %v", want, got)
+		}
+		if got := SearchTokens("会"); !reflect.DeepEqual(got, []string{"会"}) {
+			t.Errorf("单字查询应保留单字: %v", got)
+		}
+	})
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	t.Run("高亮命中词并转义 HTML", func(t *testing.T) {
+		got := HighlightSnippet("<b>周会</b>纪要", []string{"周会"}, 80)
+		if got != "&lt;b&gt;<mark>周会</mark>&lt;/b&gt;纪要" {
+			t.Errorf("结果错误: %q", got)
+		}
+	})
+
+	t.Run("长文本截取命中附近的片段", func(t *testing.T) {
+		text := "一二三四五六七八九十一二三四五六七八九十关键词在这里一二三四五六七八九十一二三四五六七八九十"
+		got := HighlightSnippet(text, []string{"关键"}, 10)
+		if got != "…九十<mark>关键</mark>词在这里一二…" {
+			t.Errorf("结果错误: %q", got)
+		}
+	})
+
+	t.Run("没有命中时返回开头", func(t *testing.T) {
+		if got := HighlightSnippet("今天天气不错", []string{"下雨"}, 4); got != "今天天气…" {
+			t.Errorf("结果错误: %q", got)
+		}
+	})
+}
+
+func TestDatabase_SearchKnowledgeFTS(t *testing.T) {
+	db, err := NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	save := func(k Knowledge) {
+		k.CreatedAt, k.UpdatedAt = time.Now(), time.Now()
+		if err := db.SaveKnowledge(&k); err != nil {
+			t.Fatalf("保存失败: %v", err)
+		}
+	}
+	ids := func(query string) []string {
+		hits, err := db.SearchKnowledgeFTS(query, 0, nil)
+		if err != nil {
+			t.Fatalf("检索失败: %v", err)
+		}
+		result := make([]string, len(hits))
+		for i, h := range hits {
+			result[i] = h.ID
+		}
+		return result
+	}
+
+	save(Knowledge{ID: "kb_body", Title: "周报", Content: "本周完成了服务部署和压测"})
+	save(Knowledge{ID: "kb_title", Title: "服务部署手册", Content: "步骤见附件"})
+	save(Knowledge{ID: "kb_tag", Title: "杂记", Content: "随手记", Tags: []string{"Kubernetes"}})
+
+	t.Run("标题命中排在正文命中前面", func(t *testing.T) {
+		if got := ids("服务部署"); !reflect.DeepEqual(got, []string{"kb_title", "kb_body"}) {
+			t.Errorf("排序错误: %v", got)
+		}
+	})
+
+	t.Run("标签和英文不区分大小写", func(t *testing.T) {
+		if got := ids("kubernetes"); !reflect.DeepEqual(got, []string{"kb_tag"}) {
+			t.Errorf("结果错误: %v", got)
+		}
+	})
+
+	t.Run("只返回前 limit 条并在 SQL 中过滤", func(t *testing.T) {
+		save(Knowledge{ID: "kb_ops", Title: "部署记录", Content: "灰度发布", Category: "work", Tags: []string{"Ops"}})
+		save(Knowledge{ID: "kb_life", Title: "部署心得", Content: "周末整理", Category: "life"})
+
+		all := ids("部署")
+		top, err := db.SearchKnowledgeFTS("部署", 1, nil)
+		if err != nil || len(all) < 2 || len(top) != 1 || top[0].ID != all[0] {
+			t.Errorf("limit 应取分数最高的一条: %v, 全部 %v, %v", top, all, err)
+		}
+
+		hits, err := db.SearchKnowledgeFTS("部署", 0, &VectorFilter{Category: "work", Tags: []string{"ops"}})
+		if err != nil || len(hits) != 1 || hits[0].ID != "kb_ops" {
+			t.Errorf("过滤结果错误: %+v, %v", hits, err)
+		}
+		future := time.Now().Add(time.Hour)
+		if hits, _ := db.SearchKnowledgeFTS("部署", 0, &VectorFilter{Since: &future}); len(hits) != 0 {
+			t.Errorf("时间过滤后不应命中: %+v", hits)
+		}
+	})
+
+	t.Run("触发器同步覆盖写入、编辑和删除", func(t *testing.T) {
+		save(Knowledge{ID: "kb_body", Title: "周报", Content: "本周主要在写文档"})
+		if got := ids("压测"); len(got) != 0 {
+			t.Errorf("覆盖写入后旧内容不应命中: %v", got)
+		}
+
+		k, _ := db.GetKnowledge("kb_title")
+		k.Content = "补充了压测步骤"
+		if err := db.UpdateKnowledge(k, 0); err != nil {
+			t.Fatalf("更新失败: %v", err)
+		}
+		if got := ids("压测"); !reflect.DeepEqual(got, []string{"kb_title"}) {
+			t.Errorf("编辑后应命中新内容: %v", got)
+		}
+
+		db.DeleteKnowledge("kb_title")
+		if got := ids("压测"); len(got) != 0 {
+			t.Errorf("删除后不应命中: %v", got)
+		}
+	})
+
+	t.Run("按 ID 批量获取命中的知识", func(t *testing.T) {
+		got, err := db.GetKnowledgeByIDs([]string{"kb_body", "kb_tag", "kb_missing"})
+		if err != nil {
+			t.Fatalf("批量获取失败: %v", err)
+		}
+		if len(got) != 2 || got["kb_body"] == nil || got["kb_tag"].Tags[0] != "Kubernetes" {
+			t.Errorf("结果错误: %+v", got)
+		}
+	})
+
+	t.Run("索引缺失时打开数据库自动重建", func(t *testing.T) {
+		dir := t.TempDir()
+		db1, _ := NewDatabase(dir)
+		db1.SaveKnowledge(&Knowledge{ID: "kb_1", Content: "向量检索", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+		db1.db.Exec(`DELETE FROM knowledge_fts`)
+		db1.Close()
+
+		db2, _ := NewDatabase(dir)
+		defer db2.Close()
+		if hits, _ := db2.SearchKnowledgeFTS("检索", 0, nil); len(hits) != 1 {
+			t.Errorf("重建后应命中 1 条, 实际 %d", len(hits))
+		}
+	})
+}
@@ -0,0 +1,649 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 知识图谱
+//
+// 把 KnowledgeOrganizer 提取的实体和关系从 knowledge 行上的 JSON 规范化为四张表：
+//   entities           实体（名称为首次出现时的写法）
+//   entity_aliases     归一化名称 → 实体，"Go"、"go "、"Ｇｏ" 指向同一实体，也可手动添加别名或合并实体
+//   knowledge_entities 知识提及的实体（含关系两端）
+//   relations          实体之间的有向关系，记录来源知识，删除知识时由触发器级联删除
+
+// 实体类型
+const (
+	EntityPerson   = "person"
+	EntityProduct  = "product"
+	EntityCompany  = "company"
+	EntityLocation = "location"
+	EntityConcept  = "concept"
+)
+
+// 图谱遍历的限制，防止热门实体把整张图拉出来
+const (
+	MaxGraphHops     = 3
+	DefaultGraphHops = 2
+	MaxGraphNodes    = 200
+)
+
+var (
+	// ErrEntityNotFound 实体不存在
+	ErrEntityNotFound = errors.New("实体不存在")
+	// ErrAliasConflict 别名已属于其他实体
+	ErrAliasConflict = errors.New("别名已属于其他实体")
+)
+
+// Entity 图谱实体
+type Entity struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Aliases   []string  `json:"aliases,omitempty"` // 除名称外的其他写法
+	Mentions  int       `json:"mentions"`          // 提及该实体的知识条数
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GraphEdge 实体关系
+type GraphEdge struct {
+	ID          int64  `json:"id"`
+	SourceID    int64  `json:"source_id"`
+	TargetID    int64  `json:"target_id"`
+	Type        string `json:"type"`
+	Context     string `json:"context,omitempty"`
+	KnowledgeID string `json:"knowledge_id"` // 关系来源的知识
+}
+
+// Neighbor 相邻实体
+type Neighbor struct {
+	Entity      Entity `json:"entity"`
+	Relation    string `json:"relation"`
+	Direction   string `json:"direction"` // out: 本实体 → 邻居, in: 邻居 → 本实体
+	Context     string `json:"context,omitempty"`
+	KnowledgeID string `json:"knowledge_id"`
+}
+
+// GraphNode 子图中的实体及其到起点的跳数
+type GraphNode struct {
+	Entity
+	Depth int `json:"depth"`
+}
+
+// Subgraph 从某个实体出发 N 跳内的子图
+type Subgraph struct {
+	Nodes     []GraphNode `json:"nodes"`
+	Edges     []GraphEdge `json:"edges"`
+	Truncated bool        `json:"truncated"` // 节点数达到上限，未完整展开
+}
+
+// KnowledgeGraph 知识图谱（与知识共用数据库，表结构由 Database 初始化）
+type KnowledgeGraph struct {
+	db *Database
+}
+
+// NewKnowledgeGraph 创建知识图谱
+func NewKnowledgeGraph(db *Database) *KnowledgeGraph {
+	return &KnowledgeGraph{db: db}
+}
+
+// queryer 同时满足 *sql.DB 和 *sql.Tx（事务内必须用 tx 查询，连接池只有一个连接）
+type queryer interface {
+	execer
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// IndexKnowledge 把知识的实体和关系写入图谱（覆盖该知识之前的图谱数据）
+func (g *KnowledgeGraph) IndexKnowledge(k *Knowledge) error {
+	return g.db.WithTx(func(tx *sql.Tx) error {
+		return g.indexKnowledge(tx, k)
+	})
+}
+
+func (g *KnowledgeGraph) indexKnowledge(q queryer, k *Knowledge) error {
+	if _, err := q.Exec(`DELETE FROM knowledge_entities WHERE knowledge_id = ?`, k.ID); err != nil {
+		return err
+	}
+	if _, err := q.Exec(`DELETE FROM relations WHERE knowledge_id = ?`, k.ID); err != nil {
+		return err
+	}
+
+	names := knowledgeEntityNames(k)
+	mention := func(name, entityType string) (int64, error) {
+		id, err := g.ensureEntity(q, name, entityType)
+		if err != nil {
+			return 0, err
+		}
+		_, err = q.Exec(`INSERT OR IGNORE INTO knowledge_entities (knowledge_id, entity_id) VALUES (?, ?)`, k.ID, id)
+		return id, err
+	}
+
+	for _, n := range names {
+		if _, err := mention(n.name, n.entityType); err != nil {
+			return fmt.Errorf("写入实体失败: %w", err)
+		}
+	}
+
+	now := time.Now().Unix()
+	for _, rel := range k.Relations {
+		source := relationSource(rel, names)
+		target := strings.TrimSpace(rel.Target)
+		if source == "" || target == "" || normalizeEntityName(source) == normalizeEntityName(target) {
+			continue
+		}
+
+		sourceID, err := mention(source, entityTypeOf(source, names))
+		if err != nil {
+			return fmt.Errorf("写入实体失败: %w", err)
+		}
+		targetID, err := mention(target, entityTypeOf(target, names))
+		if err != nil {
+			return fmt.Errorf("写入实体失败: %w", err)
+		}
+
+		relType := strings.TrimSpace(rel.Type)
+		if relType == "" {
+			relType = "relates_to"
+		}
+		_, err = q.Exec(`INSERT INTO relations (source_id, target_id, type, context, knowledge_id, created_at)
+				  VALUES (?, ?, ?, ?, ?, ?)`, sourceID, targetID, relType, rel.Context, k.ID, now)
+		if err != nil {
+			return fmt.Errorf("写入关系失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// ensureEntity 按归一化名称查找实体，不存在时创建
+func (g *KnowledgeGraph) ensureEntity(q queryer, name, entityType string) (int64, error) {
+	key := normalizeEntityName(name)
+	var id int64
+	err := q.QueryRow(`SELECT entity_id FROM entity_aliases WHERE alias_key = ?`, key).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	now := time.Now().Unix()
+	result, err := q.Exec(`INSERT INTO entities (name, type, created_at, updated_at) VALUES (?, ?, ?, ?)`,
+		strings.TrimSpace(name), entityType, now, now)
+	if err != nil {
+		return 0, err
+	}
+	if id, err = result.LastInsertId(); err != nil {
+		return 0, err
+	}
+	_, err = q.Exec(`INSERT INTO entity_aliases (alias_key, alias, entity_id) VALUES (?, ?, ?)`, key, strings.TrimSpace(name), id)
+	return id, err
+}
+
+// Backfill 为尚未进入图谱的知识建立图谱（旧数据或绕过 KnowledgeRepository 写入的知识），返回处理的条数
+func (g *KnowledgeGraph) Backfill() (int, error) {
+	knowledges, err := g.db.queryKnowledge(`SELECT ` + knowledgeColumns + ` FROM knowledge
+		WHERE id NOT IN (SELECT DISTINCT knowledge_id FROM knowledge_entities)`)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := range knowledges {
+		k := &knowledges[i]
+		if len(knowledgeEntityNames(k)) == 0 && len(k.Relations) == 0 {
+			continue
+		}
+		if err := g.IndexKnowledge(k); err != nil {
+			return count, fmt.Errorf("建立图谱失败 (ID: %s): %w", k.ID, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// entityColumns 实体查询列（含提及次数）
+const entityColumns = `e.id, e.name, e.type, e.created_at,
+	(SELECT COUNT(*) FROM knowledge_entities ke WHERE ke.entity_id = e.id) AS mentions`
+
+func scanEntity(row rowScanner) (*Entity, error) {
+	var e Entity
+	var createdAt int64
+	if err := row.Scan(&e.ID, &e.Name, &e.Type, &createdAt, &e.Mentions); err != nil {
+		return nil, err
+	}
+	e.CreatedAt = time.Unix(createdAt, 0)
+	return &e, nil
+}
+
+// GetEntity 获取实体（含别名），不存在时返回 ErrEntityNotFound
+func (g *KnowledgeGraph) GetEntity(id int64) (*Entity, error) {
+	e, err := scanEntity(g.db.db.QueryRow(`SELECT `+entityColumns+` FROM entities e WHERE e.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrEntityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := g.db.db.Query(`SELECT alias FROM entity_aliases WHERE entity_id = ? ORDER BY alias`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return nil, err
+		}
+		if alias != e.Name {
+			e.Aliases = append(e.Aliases, alias)
+		}
+	}
+	return e, rows.Err()
+}
+
+// FindEntity 按名称或别名查找实体，不存在时返回 ErrEntityNotFound
+func (g *KnowledgeGraph) FindEntity(name string) (*Entity, error) {
+	var id int64
+	err := g.db.db.QueryRow(`SELECT entity_id FROM entity_aliases WHERE alias_key = ?`, normalizeEntityName(name)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, ErrEntityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g.GetEntity(id)
+}
+
+// ListEntities 列出被知识提及的实体，按提及次数排序；query 匹配名称或别名，entityType 为空时不限类型
+func (g *KnowledgeGraph) ListEntities(query, entityType string, limit int) ([]Entity, error) {
+	sqlQuery := `SELECT ` + entityColumns + ` FROM entities e WHERE 1 = 1`
+	var args []interface{}
+	if query != "" {
+		sqlQuery += ` AND e.id IN (SELECT entity_id FROM entity_aliases WHERE alias_key LIKE ?)`
+		args = append(args, "%"+normalizeEntityName(query)+"%")
+	}
+	if entityType != "" {
+		sqlQuery += ` AND e.type = ?`
+		args = append(args, entityType)
+	}
+	sqlQuery += ` AND mentions > 0 ORDER BY mentions DESC, e.id LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := g.db.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entities := []Entity{}
+	for rows.Next() {
+		e, err := scanEntity(rows)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, *e)
+	}
+	return entities, rows.Err()
+}
+
+// Neighbors 列出与实体直接相连的实体（出边和入边，同一邻居的同类关系只保留一条）
+func (g *KnowledgeGraph) Neighbors(id int64) ([]Neighbor, error) {
+	if _, err := g.GetEntity(id); err != nil {
+		return nil, err
+	}
+
+	edges, err := g.edgesOf([]int64{id})
+	if err != nil {
+		return nil, err
+	}
+
+	neighbors := []Neighbor{}
+	seen := make(map[string]bool)
+	for _, edge := range edges {
+		n := Neighbor{Relation: edge.Type, Context: edge.Context, KnowledgeID: edge.KnowledgeID}
+		otherID := edge.TargetID
+		n.Direction = "out"
+		if edge.TargetID == id {
+			otherID = edge.SourceID
+			n.Direction = "in"
+		}
+
+		key := fmt.Sprintf("%d|%s|%s", otherID, edge.Type, n.Direction)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		other, err := g.GetEntity(otherID)
+		if err != nil {
+			return nil, err
+		}
+		n.Entity = *other
+		neighbors = append(neighbors, n)
+	}
+	return neighbors, nil
+}
+
+// Traverse 从实体出发沿关系（不分方向）展开 hops 跳，节点数超过 maxNodes 时截断
+func (g *KnowledgeGraph) Traverse(id int64, hops, maxNodes int) (*Subgraph, error) {
+	start, err := g.GetEntity(id)
+	if err != nil {
+		return nil, err
+	}
+	hops = max(1, min(hops, MaxGraphHops))
+	if maxNodes <= 0 || maxNodes > MaxGraphNodes {
+		maxNodes = MaxGraphNodes
+	}
+
+	graph := &Subgraph{Nodes: []GraphNode{{Entity: *start}}, Edges: []GraphEdge{}}
+	depth := map[int64]int{id: 0}
+	seenEdges := make(map[int64]bool)
+	frontier := []int64{id}
+
+	for level := 1; level <= hops && len(frontier) > 0; level++ {
+		edges, err := g.edgesOf(frontier)
+		if err != nil {
+			return nil, err
+		}
+
+		var next []int64
+		for _, edge := range edges {
+			if seenEdges[edge.ID] {
+				continue
+			}
+			for _, nodeID := range []int64{edge.SourceID, edge.TargetID} {
+				if _, ok := depth[nodeID]; ok {
+					continue
+				}
+				if len(depth) >= maxNodes {
+					graph.Truncated = true
+					continue
+				}
+				entity, err := g.GetEntity(nodeID)
+				if err != nil {
+					return nil, err
+				}
+				depth[nodeID] = level
+				graph.Nodes = append(graph.Nodes, GraphNode{Entity: *entity, Depth: level})
+				next = append(next, nodeID)
+			}
+			// 只保留两端都在子图中的边
+			_, sourceIn := depth[edge.SourceID]
+			_, targetIn := depth[edge.TargetID]
+			if sourceIn && targetIn {
+				seenEdges[edge.ID] = true
+				graph.Edges = append(graph.Edges, edge)
+			}
+		}
+		frontier = next
+	}
+	return graph, nil
+}
+
+// MentionedIn 提及实体的知识 ID，按知识创建时间倒序
+func (g *KnowledgeGraph) MentionedIn(id int64) ([]string, error) {
+	if _, err := g.GetEntity(id); err != nil {
+		return nil, err
+	}
+
+	rows, err := g.db.db.Query(`SELECT ke.knowledge_id FROM knowledge_entities ke
+		JOIN knowledge k ON k.id = ke.knowledge_id
+		WHERE ke.entity_id = ? ORDER BY k.created_at DESC`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var knowledgeID string
+		if err := rows.Scan(&knowledgeID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, knowledgeID)
+	}
+	return ids, rows.Err()
+}
+
+// KnowledgeLink 两条知识之间经由实体或关系的一跳关联
+type KnowledgeLink struct {
+	FromID    string // 出发的知识
+	ToID      string // 关联到的知识
+	Entity    string // 出发知识提及的实体
+	Relation  string // 关系类型，为空表示两条知识提及同一实体
+	Direction string // out: Entity → Target, in: Target → Entity
+	Target    string // 关系另一端的实体（关联知识提及）
+}
+
+// maxKnowledgeLinks 单次查询返回的关联上限，防止热门实体拖慢检索
+const maxKnowledgeLinks = 500
+
+// RelatedKnowledge 列出与给定知识一跳相连的知识：
+// 沿出边/入边找到提及关系另一端实体的知识，以及提及同一实体的知识
+func (g *KnowledgeGraph) RelatedKnowledge(ids []string) ([]KnowledgeLink, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	var args []interface{}
+	for i := 0; i < 3; i++ {
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	args = append(args, maxKnowledgeLinks)
+
+	rows, err := g.db.db.Query(`
+		SELECT ke.knowledge_id, ke2.knowledge_id, e.name, r.type, 'out', t.name
+		FROM knowledge_entities ke
+		JOIN relations r ON r.source_id = ke.entity_id
+		JOIN knowledge_entities ke2 ON ke2.entity_id = r.target_id AND ke2.knowledge_id != ke.knowledge_id
+		JOIN entities e ON e.id = r.source_id
+		JOIN entities t ON t.id = r.target_id
+		WHERE ke.knowledge_id IN (`+placeholders+`)
+		UNION ALL
+		SELECT ke.knowledge_id, ke2.knowledge_id, e.name, r.type, 'in', t.name
+		FROM knowledge_entities ke
+		JOIN relations r ON r.target_id = ke.entity_id
+		JOIN knowledge_entities ke2 ON ke2.entity_id = r.source_id AND ke2.knowledge_id != ke.knowledge_id
+		JOIN entities e ON e.id = r.target_id
+		JOIN entities t ON t.id = r.source_id
+		WHERE ke.knowledge_id IN (`+placeholders+`)
+		UNION ALL
+		SELECT ke.knowledge_id, ke2.knowledge_id, e.name, '', '', ''
+		FROM knowledge_entities ke
+		JOIN knowledge_entities ke2 ON ke2.entity_id = ke.entity_id AND ke2.knowledge_id != ke.knowledge_id
+		JOIN entities e ON e.id = ke.entity_id
+		WHERE ke.knowledge_id IN (`+placeholders+`)
+		LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []KnowledgeLink
+	for rows.Next() {
+		var l KnowledgeLink
+		if err := rows.Scan(&l.FromID, &l.ToID, &l.Entity, &l.Relation, &l.Direction, &l.Target); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// AddAlias 为实体添加别名；别名已属于其他实体时返回 ErrAliasConflict（需要时用 MergeEntities 合并）
+func (g *KnowledgeGraph) AddAlias(id int64, alias string) error {
+	alias = strings.TrimSpace(alias)
+	key := normalizeEntityName(alias)
+	if key == "" {
+		return fmt.Errorf("别名不能为空")
+	}
+	if _, err := g.GetEntity(id); err != nil {
+		return err
+	}
+
+	var owner int64
+	err := g.db.db.QueryRow(`SELECT entity_id FROM entity_aliases WHERE alias_key = ?`, key).Scan(&owner)
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = g.db.db.Exec(`INSERT INTO entity_aliases (alias_key, alias, entity_id) VALUES (?, ?, ?)`, key, alias, id)
+		return err
+	case err != nil:
+		return err
+	case owner != id:
+		return ErrAliasConflict
+	}
+	return nil
+}
+
+// MergeEntities 把 sourceID 合并到 targetID：别名、提及和关系全部转移，删除 sourceID
+func (g *KnowledgeGraph) MergeEntities(targetID, sourceID int64) error {
+	if targetID == sourceID {
+		return fmt.Errorf("不能与自身合并")
+	}
+	for _, id := range []int64{targetID, sourceID} {
+		if _, err := g.GetEntity(id); err != nil {
+			return err
+		}
+	}
+
+	return g.db.WithTx(func(tx *sql.Tx) error {
+		statements := []string{
+			`UPDATE entity_aliases SET entity_id = ?1 WHERE entity_id = ?2`,
+			`INSERT OR IGNORE INTO knowledge_entities (knowledge_id, entity_id)
+				SELECT knowledge_id, ?1 FROM knowledge_entities WHERE entity_id = ?2`,
+			`DELETE FROM knowledge_entities WHERE entity_id = ?2`,
+			`UPDATE relations SET source_id = ?1 WHERE source_id = ?2`,
+			`UPDATE relations SET target_id = ?1 WHERE target_id = ?2`,
+			`DELETE FROM relations WHERE source_id = target_id`,
+			`DELETE FROM entities WHERE id = ?2`,
+		}
+		for _, stmt := range statements {
+			var args []interface{}
+			if strings.Contains(stmt, "?") {
+				args = []interface{}{targetID, sourceID}
+			}
+			if _, err := tx.Exec(stmt, args...); err != nil {
+				return fmt.Errorf("合并实体失败: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// edgesOf 与任一实体相连的全部关系
+func (g *KnowledgeGraph) edgesOf(ids []int64) ([]GraphEdge, error) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]interface{}, 0, len(ids)*2)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, args...)
+
+	rows, err := g.db.db.Query(`SELECT id, source_id, target_id, type, COALESCE(context, ''), knowledge_id FROM relations
+		WHERE source_id IN (`+placeholders+`) OR target_id IN (`+placeholders+`)
+		ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var edges []GraphEdge
+	for rows.Next() {
+		var e GraphEdge
+		if err := rows.Scan(&e.ID, &e.SourceID, &e.TargetID, &e.Type, &e.Context, &e.KnowledgeID); err != nil {
+			return nil, err
+		}
+		edges = append(edges, e)
+	}
+	return edges, rows.Err()
+}
+
+// entityName 知识中提到的实体名称及类型
+type entityName struct {
+	name       string
+	entityType string
+}
+
+// knowledgeEntityNames 按 "概念、产品、公司、人物、地点" 顺序列出知识的实体（第一个作为关系缺省的源实体）
+func knowledgeEntityNames(k *Knowledge) []entityName {
+	var names []entityName
+	seen := make(map[string]bool)
+	groups := []struct {
+		entityType string
+		names      []string
+	}{
+		{EntityConcept, k.Entities.Concepts},
+		{EntityProduct, k.Entities.Products},
+		{EntityCompany, k.Entities.Companies},
+		{EntityPerson, k.Entities.People},
+		{EntityLocation, k.Entities.Locations},
+	}
+	for _, group := range groups {
+		for _, name := range group.names {
+			key := normalizeEntityName(name)
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			names = append(names, entityName{name: strings.TrimSpace(name), entityType: group.entityType})
+		}
+	}
+	return names
+}
+
+// relationSource 关系的源实体：优先使用 Source，其次是关系说明中提到的本条知识的实体，最后取知识的第一个实体
+func relationSource(rel Relation, names []entityName) string {
+	if source := strings.TrimSpace(rel.Source); source != "" {
+		return source
+	}
+
+	target := normalizeEntityName(rel.Target)
+	context := normalizeEntityName(rel.Context)
+	for _, n := range names {
+		key := normalizeEntityName(n.name)
+		if key != target && context != "" && strings.Contains(context, key) {
+			return n.name
+		}
+	}
+	for _, n := range names {
+		if normalizeEntityName(n.name) != target {
+			return n.name
+		}
+	}
+	return ""
+}
+
+// entityTypeOf 实体在知识中的类型，关系中新出现的实体（前向引用）视为概念
+func entityTypeOf(name string, names []entityName) string {
+	key := normalizeEntityName(name)
+	for _, n := range names {
+		if normalizeEntityName(n.name) == key {
+			return n.entityType
+		}
+	}
+	return EntityConcept
+}
+
+// normalizeEntityName 实体名称归一化：全角转半角、转小写、去掉空白和连接符
+func normalizeEntityName(name string) string {
+	var sb strings.Builder
+	for _, r := range strings.TrimSpace(name) {
+		if r >= '！' && r <= '～' {
+			r -= '！' - '!'
+		}
+		if unicode.IsSpace(r) || strings.ContainsRune("-_.·・", r) {
+			continue
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// newTestKnowledgeGraph 创建使用临时数据库的知识图谱和只写数据库的知识仓库
+func newTestKnowledgeGraph(t *testing.T) (*KnowledgeGraph, *KnowledgeRepository) {
+	db, err := NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewKnowledgeGraph(db), NewKnowledgeRepository(db, nil)
+}
+
+func mustFindEntity(t *testing.T, g *KnowledgeGraph, name string) *Entity {
+	t.Helper()
+	e, err := g.FindEntity(name)
+	if err != nil {
+		t.Fatalf("查找实体 %s 失败: %v", name, err)
+	}
+	return e
+}
+
+func neighborNames(neighbors []Neighbor) []string {
+	names := make([]string, len(neighbors))
+	for i, n := range neighbors {
+		names[i] = n.Direction + ":" + n.Relation + ":" + n.Entity.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestNormalizeEntityName(t *testing.T) {
+	for _, name := range []string{"Kubernetes", " kubernetes ", "Ｋｕｂｅｒｎｅｔｅｓ", "kuber-netes"} {
+		if got := normalizeEntityName(name); got != "kubernetes" {
+			t.Errorf("%q 归一化结果错误: %q", name, got)
+		}
+	}
+}
+
+func TestRelationSource(t *testing.T) {
+	names := []entityName{{"Docker", EntityProduct}, {"Kubernetes", EntityProduct}, {"Helm", EntityProduct}}
+
+	tests := []struct {
+		name string
+		rel  Relation
+		want string
+	}{
+		{"显式指定源实体", Relation{Source: "Helm", Type: "requires", Target: "Kubernetes"}, "Helm"},
+		{"从关系说明中推断", Relation{Type: "requires", Target: "Kubernetes", Context: "helm 依赖集群"}, "Helm"},
+		{"缺省取第一个实体", Relation{Type: "part_of", Target: "CNCF"}, "Docker"},
+		{"跳过目标自身", Relation{Type: "alternative_to", Target: "docker"}, "Kubernetes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := relationSource(tt.rel, names); got != tt.want {
+				t.Errorf("期望 %q, 实际 %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestKnowledgeGraph(t *testing.T) {
+	ctx := context.Background()
+	g, repo := newTestKnowledgeGraph(t)
+
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)
+	save := func(k *Knowledge, offset int) {
+		k.CreatedAt = base.Add(time.Duration(offset) * time.Hour)
+		if err := repo.Save(ctx, k); err != nil {
+			t.Fatalf("保存失败: %v", err)
+		}
+	}
+
+	// Helm → Kubernetes → Docker → Linux 组成一条链
+	save(&Knowledge{
+		ID:        "kb_helm",
+		Content:   "Helm 部署",
+		Entities:  Entities{Products: []string{"Helm", "Kubernetes"}},
+		Relations: []Relation{{Type: "requires", Target: "Kubernetes", Context: "Helm 依赖 K8s 集群"}},
+	}, 0)
+	save(&Knowledge{
+		ID:        "kb_k8s",
+		Content:   "K8s 运行容器",
+		Entities:  Entities{Products: []string{"kubernetes", "Docker"}, Companies: []string{"Google"}},
+		Relations: []Relation{{Source: "Kubernetes", Type: "requires", Target: "docker"}},
+	}, 1)
+	save(&Knowledge{
+		ID:        "kb_docker",
+		Content:   "Docker 基于 Linux",
+		Entities:  Entities{Products: []string{"Ｄｏｃｋｅｒ"}, Concepts: []string{"Linux"}},
+		Relations: []Relation{{Source: "Docker", Type: "requires", Target: "Linux"}},
+	}, 2)
+
+	t.Run("同名实体去重并记录提及次数", func(t *testing.T) {
+		k8s := mustFindEntity(t, g, "KUBERNETES")
+		if k8s.Name != "Kubernetes" || k8s.Type != EntityProduct || k8s.Mentions != 2 {
+			t.Errorf("实体错误: %+v", k8s)
+		}
+		if docker := mustFindEntity(t, g, "docker"); docker.Mentions != 2 {
+			t.Errorf("全角写法应归并到同一实体: %+v", docker)
+		}
+	})
+
+	t.Run("列出实体按提及次数排序并支持过滤", func(t *testing.T) {
+		entities, err := g.ListEntities("", "", 10)
+		if err != nil {
+			t.Fatalf("列出失败: %v", err)
+		}
+		if len(entities) != 5 || entities[0].Mentions != 2 {
+			t.Errorf("结果错误: %+v", entities)
+		}
+
+		entities, _ = g.ListEntities("goo", "", 10)
+		if len(entities) != 1 || entities[0].Name != "Google" {
+			t.Errorf("名称过滤错误: %+v", entities)
+		}
+		entities, _ = g.ListEntities("", EntityConcept, 10)
+		if len(entities) != 1 || entities[0].Name != "Linux" {
+			t.Errorf("类型过滤错误: %+v", entities)
+		}
+	})
+
+	t.Run("相邻实体区分方向", func(t *testing.T) {
+		neighbors, err := g.Neighbors(mustFindEntity(t, g, "Kubernetes").ID)
+		if err != nil {
+			t.Fatalf("查询失败: %v", err)
+		}
+		want := []string{"in:requires:Helm", "out:requires:Docker"}
+		if got := neighborNames(neighbors); !reflect.DeepEqual(got, want) {
+			t.Errorf("期望 %v, 实际 %v", want, got)
+		}
+		if neighbors[0].KnowledgeID == "" {
+			t.Errorf("应记录关系来源的知识")
+		}
+	})
+
+	t.Run("N 跳遍历", func(t *testing.T) {
+		helm := mustFindEntity(t, g, "Helm")
+		sub, err := g.Traverse(helm.ID, 1, 0)
+		if err != nil {
+			t.Fatalf("遍历失败: %v", err)
+		}
+		if len(sub.Nodes) != 2 || len(sub.Edges) != 1 {
+			t.Errorf("1 跳应包含 2 个节点 1 条边: %+v", sub)
+		}
+
+		sub, _ = g.Traverse(helm.ID, 3, 0)
+		depth := make(map[string]int)
+		for _, n := range sub.Nodes {
+			depth[n.Name] = n.Depth
+		}
+		if !reflect.DeepEqual(depth, map[string]int{"Helm": 0, "Kubernetes": 1, "Docker": 2, "Linux": 3}) {
+			t.Errorf("跳数错误: %v", depth)
+		}
+		if len(sub.Edges) != 3 || sub.Truncated {
+			t.Errorf("边数错误: %+v", sub)
+		}
+
+		sub, _ = g.Traverse(helm.ID, 3, 2)
+		if len(sub.Nodes) != 2 || !sub.Truncated {
+			t.Errorf("超过节点上限应截断: %+v", sub)
+		}
+	})
+
+	t.Run("查找提及实体的知识", func(t *testing.T) {
+		ids, err := g.MentionedIn(mustFindEntity(t, g, "docker").ID)
+		if err != nil {
+			t.Fatalf("查询失败: %v", err)
+		}
+		if !reflect.DeepEqual(ids, []string{"kb_docker", "kb_k8s"}) {
+			t.Errorf("结果错误: %v", ids)
+		}
+	})
+
+	t.Run("别名", func(t *testing.T) {
+		k8s := mustFindEntity(t, g, "Kubernetes")
+		if err := g.AddAlias(k8s.ID, "K8s"); err != nil {
+			t.Fatalf("添加别名失败: %v", err)
+		}
+		if e := mustFindEntity(t, g, "k8s"); e.ID != k8s.ID || !reflect.DeepEqual(e.Aliases, []string{"K8s"}) {
+			t.Errorf("别名未生效: %+v", e)
+		}
+		if err := g.AddAlias(k8s.ID, "docker"); !errors.Is(err, ErrAliasConflict) {
+			t.Errorf("别名属于其他实体应返回 ErrAliasConflict, 实际 %v", err)
+		}
+
+		// 之后保存的知识用别名提及时归到同一实体
+		save(&Knowledge{ID: "kb_alias", Content: "k8s 升级", Entities: Entities{Products: []string{"K8s"}}}, 3)
+		if e := mustFindEntity(t, g, "Kubernetes"); e.Mentions != 3 {
+			t.Errorf("期望 3 次提及, 实际 %d", e.Mentions)
+		}
+	})
+
+	t.Run("删除知识级联删除提及和关系", func(t *testing.T) {
+		if err := repo.Delete(ctx, "kb_docker"); err != nil {
+			t.Fatalf("删除失败: %v", err)
+		}
+		linux := mustFindEntity(t, g, "Linux")
+		if linux.Mentions != 0 {
+			t.Errorf("提及应被删除: %+v", linux)
+		}
+		if neighbors, _ := g.Neighbors(linux.ID); len(neighbors) != 0 {
+			t.Errorf("关系应被删除: %+v", neighbors)
+		}
+		if entities, _ := g.ListEntities("linux", "", 10); len(entities) != 0 {
+			t.Errorf("无提及的实体不应出现在列表中: %+v", entities)
+		}
+	})
+
+	t.Run("合并实体", func(t *testing.T) {
+		save(&Knowledge{
+			ID:        "kb_moby",
+			Content:   "Moby 是 Docker 的开源项目",
+			Entities:  Entities{Products: []string{"Moby", "Kubernetes"}},
+			Relations: []Relation{{Source: "Kubernetes", Type: "requires", Target: "Moby"}},
+		}, 4)
+		docker := mustFindEntity(t, g, "Docker")
+		moby := mustFindEntity(t, g, "Moby")
+		if err := g.MergeEntities(docker.ID, moby.ID); err != nil {
+			t.Fatalf("合并失败: %v", err)
+		}
+
+		if _, err := g.GetEntity(moby.ID); !errors.Is(err, ErrEntityNotFound) {
+			t.Errorf("被合并的实体应删除, 实际 %v", err)
+		}
+		if e := mustFindEntity(t, g, "moby"); e.ID != docker.ID {
+			t.Errorf("原名称应成为别名: %+v", e)
+		}
+		ids, _ := g.MentionedIn(docker.ID)
+		if !reflect.DeepEqual(ids, []string{"kb_moby", "kb_k8s"}) {
+			t.Errorf("提及应转移: %v", ids)
+		}
+		neighbors, _ := g.Neighbors(docker.ID)
+		if got := neighborNames(neighbors); !reflect.DeepEqual(got, []string{"in:requires:Kubernetes"}) {
+			t.Errorf("关系应转移并去重: %v", got)
+		}
+	})
+
+	t.Run("重新保存覆盖该知识的图谱", func(t *testing.T) {
+		save(&Knowledge{ID: "kb_helm", Content: "Helm 部署", Entities: Entities{Products: []string{"Helm"}}}, 0)
+		if neighbors, _ := g.Neighbors(mustFindEntity(t, g, "Helm").ID); len(neighbors) != 0 {
+			t.Errorf("旧关系应被覆盖: %+v", neighbors)
+		}
+	})
+}
+
+func TestKnowledgeGraph_Backfill(t *testing.T) {
+	g, _ := newTestKnowledgeGraph(t)
+
+	// 绕过 KnowledgeRepository 直接写库，模拟升级前的数据
+	err := g.db.SaveKnowledge(&Knowledge{
+		ID:        "kb_old",
+		Content:   "旧知识",
+		Entities:  Entities{Products: []string{"Redis"}},
+		Relations: []Relation{{Type: "alternative_to", Target: "Memcached"}},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	g.db.SaveKnowledge(&Knowledge{ID: "kb_plain", Content: "没有实体", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+
+	count, err := g.Backfill()
+	if err != nil || count != 1 {
+		t.Fatalf("期望补建 1 条, 实际 %d: %v", count, err)
+	}
+	neighbors, _ := g.Neighbors(mustFindEntity(t, g, "Redis").ID)
+	if got := neighborNames(neighbors); !reflect.DeepEqual(got, []string{"out:alternative_to:Memcached"}) {
+		t.Errorf("关系错误: %v", got)
+	}
+
+	if count, _ := g.Backfill(); count != 0 {
+		t.Errorf("已建立图谱的知识不应重复处理, 实际 %d", count)
+	}
+}
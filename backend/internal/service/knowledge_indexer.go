@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// IndexProgress 后台索引任务进度
+type IndexProgress struct {
+	Running    bool             `json:"running"`
+	Total      int              `json:"total"`  // 需要处理的条目数（补建 + 重建 + 清理）
+	Done       int              `json:"done"`   // 已处理的条目数（含失败）
+	Failed     int              `json:"failed"` // 失败的条目数
+	StartedAt  *time.Time       `json:"started_at,omitempty"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+	Report     *ReconcileReport `json:"report,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// KnowledgeIndexer 后台知识索引任务
+// 启动时在后台对账数据库与向量库，只向量化新增或正文变化的知识，不阻塞服务启动
+type KnowledgeIndexer struct {
+	repo *KnowledgeRepository
+
+	mu       sync.Mutex
+	progress IndexProgress
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewKnowledgeIndexer 创建后台索引任务
+func NewKnowledgeIndexer(repo *KnowledgeRepository) *KnowledgeIndexer {
+	return &KnowledgeIndexer{repo: repo}
+}
+
+// Start 在后台启动一次索引，已有任务在运行时返回 false
+func (ix *KnowledgeIndexer) Start() bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if ix.progress.Running {
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	ix.progress = IndexProgress{Running: true, StartedAt: &now}
+	ix.cancel = cancel
+	ix.done = make(chan struct{})
+
+	go ix.run(ctx, ix.done)
+	return true
+}
+
+// Progress 返回当前（或最近一次）索引任务的进度
+func (ix *KnowledgeIndexer) Progress() IndexProgress {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.progress
+}
+
+// Wait 等待当前任务结束
+func (ix *KnowledgeIndexer) Wait() {
+	ix.mu.Lock()
+	done := ix.done
+	ix.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+// Stop 取消正在运行的任务并等待其退出
+func (ix *KnowledgeIndexer) Stop() {
+	ix.mu.Lock()
+	cancel := ix.cancel
+	ix.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	ix.Wait()
+}
+
+func (ix *KnowledgeIndexer) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	report, err := ix.repo.reconcile(ctx, true, func(n, total int) {
+		ix.mu.Lock()
+		ix.progress.Done = n
+		ix.progress.Total = total
+		ix.mu.Unlock()
+	})
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	now := time.Now()
+	ix.progress.Running = false
+	ix.progress.FinishedAt = &now
+	ix.progress.Report = report
+	if report != nil {
+		ix.progress.Failed = len(report.Failed)
+	}
+	if err != nil {
+		ix.progress.Error = err.Error()
+		log.Printf("[Knowledge] 后台索引失败: %v", err)
+		return
+	}
+	if report.Pending() > 0 {
+		log.Printf("[Knowledge] 后台索引完成: 向量化 %d 条, 清理 %d 条, 失败 %d 条", report.Indexed, report.Removed, len(report.Failed))
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -18,7 +19,7 @@ func NewKnowledgeOrganizer(llmService LLMService) *KnowledgeOrganizer {
 }
 
 // Organize 自动整理知识内容 (v2.0 - 关系增强)
-func (o *KnowledgeOrganizer) Organize(content string) (*KnowledgeOrganizeResult, error) {
+func (o *KnowledgeOrganizer) Organize(ctx context.Context, content string) (*KnowledgeOrganizeResult, error) {
 	systemPrompt := `你是 Voice Memory 的知识整理助手，负责创建结构化的语义知识图谱。
 
 【核心任务】将对话转化为知识条目，提取实体、关系和上下文，构建可连接的知识网络。
@@ -38,6 +39,7 @@ func (o *KnowledgeOrganizer) Organize(content string) (*KnowledgeOrganizeResult,
   "tags": ["标签1", "标签2"],
   "relations": [
     {
+      "source": "源实体（本条内容中的实体）",
       "type": "relates_to|requires|implements|improves|contrasts_with",
       "target": "相关实体或概念",
       "context": "关系说明"
@@ -108,7 +110,7 @@ func (o *KnowledgeOrganizer) Organize(content string) (*KnowledgeOrganizeResult,
 		Temperature: 0.2, // 更低的温度，确保结构化输出的稳定性
 	}
 
-	resp, err := o.llmService.SendMessage(req)
+	resp, err := o.llmService.SendMessage(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("AI 整理失败: %w", err)
 	}
@@ -156,7 +158,7 @@ func min(a, b int) int {
 }
 
 // GenerateTitleFromSession 根据会话内容生成标题 (v1.0 improved)
-func (o *KnowledgeOrganizer) GenerateTitleFromSession(session *Session) (string, error) {
+func (o *KnowledgeOrganizer) GenerateTitleFromSession(ctx context.Context, session *Session) (string, error) {
 	// 构建会话摘要提示
 	var conversationText string
 	for i, msg := range session.Messages {
@@ -267,7 +269,7 @@ func (o *KnowledgeOrganizer) GenerateTitleFromSession(session *Session) (string,
 		Temperature: 0.3,
 	}
 
-	resp, err := o.llmService.SendMessage(req)
+	resp, err := o.llmService.SendMessage(ctx, req)
 	if err != nil {
 		return "", fmt.Errorf("AI 生成标题失败: %w", err)
 	}
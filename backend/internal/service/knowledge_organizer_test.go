@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -12,14 +13,14 @@ type MockLLMService struct {
 	MockSendMessage func(req ChatRequest) (*ChatResponse, error)
 }
 
-func (m *MockLLMService) SendMessage(req ChatRequest) (*ChatResponse, error) {
+func (m *MockLLMService) SendMessage(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
 	if m.MockSendMessage != nil {
 		return m.MockSendMessage(req)
 	}
 	return nil, fmt.Errorf("MockSendMessage not implemented")
 }
 
-func (m *MockLLMService) SendMessageStream(req ChatRequest, callback func(StreamChunk)) error {
+func (m *MockLLMService) SendMessageStream(ctx context.Context, req ChatRequest, callback func(StreamChunk)) error {
 	// Dummy implementation for interface satisfaction
 	return nil
 }
@@ -85,7 +86,7 @@ func TestOrganizeV2(t *testing.T) {
 	organizer := NewKnowledgeOrganizer(mockLLM)
 
 	// 4. Call the Organize function
-	result, err := organizer.Organize("some test content")
+	result, err := organizer.Organize(context.Background(), "some test content")
 
 	// 5. Assert the results
 	assert.NoError(t, err, "Organize function should not return an error")
@@ -143,7 +144,7 @@ func TestOrganizeJSONErrorHandling(t *testing.T) {
 
 	// 4. Call the function
 	testContent := "this is the original content"
-	result, err := organizer.Organize(testContent)
+	result, err := organizer.Organize(context.Background(), testContent)
 
 	// 5. Assert the fallback behavior
 	assert.NoError(t, err, "Error should be nil on JSON parsing failure as it has a fallback")
@@ -185,7 +186,7 @@ func TestGenerateTitleFromSession_Mock(t *testing.T) {
 	}
 
 	// 5. Generate title
-	title, err := organizer.GenerateTitleFromSession(session)
+	title, err := organizer.GenerateTitleFromSession(context.Background(), session)
 
 	// 6. Assert
 	assert.NoError(t, err)
@@ -0,0 +1,342 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// KnowledgeRepository 知识写入的唯一入口
+// SQLite 是事实来源：先写数据库，再同步向量库。向量化失败会按退避重试，
+// 仍然失败时只记录日志，由 Reconcile 在之后补齐，保证两边最终一致。
+// 向量库是同一数据库中的 SQLiteVectorStore 时，先生成向量，再把知识行和向量在一个事务中写入。
+// 知识图谱（实体、关系）和由行动项生成的待办与知识行总是在同一个事务中写入。
+type KnowledgeRepository struct {
+	db         *Database
+	rag        *RAGService
+	graph      *KnowledgeGraph
+	tasks      *TaskStore
+	retries    int
+	retryDelay time.Duration
+}
+
+// NewKnowledgeRepository 创建知识仓库，rag 为 nil 时只写数据库
+func NewKnowledgeRepository(db *Database, rag *RAGService) *KnowledgeRepository {
+	return &KnowledgeRepository{
+		db:         db,
+		rag:        rag,
+		graph:      NewKnowledgeGraph(db),
+		tasks:      NewTaskStore(db),
+		retries:    3,
+		retryDelay: 500 * time.Millisecond,
+	}
+}
+
+// Get 按 ID 获取知识，不存在时返回 nil
+func (r *KnowledgeRepository) Get(id string) (*Knowledge, error) {
+	return r.db.GetKnowledge(id)
+}
+
+// Save 保存新知识，同时写入知识图谱、生成待办并建立向量索引
+// 只有写数据库失败才返回错误；向量化失败留给 Reconcile 修复
+func (r *KnowledgeRepository) Save(ctx context.Context, knowledge *Knowledge) error {
+	now := time.Now()
+	if knowledge.CreatedAt.IsZero() {
+		knowledge.CreatedAt = now
+	}
+	if knowledge.UpdatedAt.IsZero() {
+		knowledge.UpdatedAt = now
+	}
+
+	store := r.txStore()
+	var item *VectorItem
+	if store != nil {
+		item = r.buildVector(ctx, knowledge)
+	}
+
+	err := r.db.WithTx(func(tx *sql.Tx) error {
+		if err := saveKnowledge(tx, knowledge); err != nil {
+			return err
+		}
+		if err := r.graph.indexKnowledge(tx, knowledge); err != nil {
+			return err
+		}
+		if err := r.tasks.syncKnowledge(tx, knowledge); err != nil {
+			return err
+		}
+		if item == nil {
+			return nil
+		}
+		return store.addTx(tx, *item)
+	})
+	if err != nil {
+		return fmt.Errorf("保存知识失败: %w", err)
+	}
+
+	if store == nil {
+		r.index(ctx, knowledge)
+	}
+	return nil
+}
+
+// Update 更新知识的可编辑字段，同步待办和向量库（正文变化时重新向量化）
+// 错误语义同 Database.UpdateKnowledge（ErrKnowledgeNotFound / ErrKnowledgeConflict）
+func (r *KnowledgeRepository) Update(ctx context.Context, knowledge *Knowledge, expectedVersion int) error {
+	store := r.txStore()
+	var item *VectorItem
+	if store != nil {
+		item = r.buildVector(ctx, knowledge)
+	}
+
+	err := r.db.WithTx(func(tx *sql.Tx) error {
+		if err := updateKnowledge(tx, knowledge, expectedVersion); err != nil {
+			return err
+		}
+		if err := r.tasks.syncKnowledge(tx, knowledge); err != nil {
+			return err
+		}
+		if item == nil {
+			return nil
+		}
+		return store.addTx(tx, *item)
+	})
+	if err != nil {
+		return err
+	}
+
+	if store == nil {
+		r.index(ctx, knowledge)
+	}
+	return nil
+}
+
+// Delete 删除知识及其向量，不存在时返回 ErrKnowledgeNotFound
+func (r *KnowledgeRepository) Delete(ctx context.Context, id string) error {
+	if err := r.db.DeleteKnowledge(id); err != nil {
+		return err
+	}
+
+	if r.ragEnabled() {
+		err := r.retry(ctx, func() error { return r.rag.vectorStore.Delete(id) })
+		if err != nil {
+			log.Printf("[Knowledge] 删除向量失败，等待对账清理 (ID: %s): %v", id, err)
+		}
+	}
+	return nil
+}
+
+// ReconcileReport 数据库与向量库对账结果
+type ReconcileReport struct {
+	MissingVectors []string `json:"missing_vectors"` // 数据库中有、向量库中缺失的知识
+	StaleVectors   []string `json:"stale_vectors"`   // 正文或向量模型已变化、需要重建的向量
+	OrphanVectors  []string `json:"orphan_vectors"`  // 向量库中有、数据库中已不存在的知识
+	Indexed        int      `json:"indexed"`         // 已补建/重建的向量数
+	Removed        int      `json:"removed"`         // 已清理的孤立向量数
+	Failed         []string `json:"failed"`          // 修复失败的知识 ID
+}
+
+// Pending 需要修复的条目总数
+func (r *ReconcileReport) Pending() int {
+	return len(r.MissingVectors) + len(r.StaleVectors) + len(r.OrphanVectors)
+}
+
+// ReconcileProgress 对账进度回调，done 为已处理（含失败）的条目数
+type ReconcileProgress func(done, total int)
+
+// Reconcile 对比数据库与向量库，fix 为 true 时批量补建缺失/过期向量、删除孤立向量
+// 只有正文哈希或向量模型变化的知识才会重新向量化
+func (r *KnowledgeRepository) Reconcile(ctx context.Context, fix bool) (*ReconcileReport, error) {
+	return r.reconcile(ctx, fix, nil)
+}
+
+func (r *KnowledgeRepository) reconcile(ctx context.Context, fix bool, progress ReconcileProgress) (*ReconcileReport, error) {
+	if !r.ragEnabled() {
+		return nil, fmt.Errorf("RAG 服务未启用")
+	}
+
+	// 先取向量 ID 再读知识：对账期间新写入的知识与向量不在这份 ID 列表里，不会被误判为孤儿删掉
+	vectorIDs, err := r.rag.vectorStore.IDs()
+	if err != nil {
+		return nil, fmt.Errorf("读取向量失败: %w", err)
+	}
+	knowledges, err := r.db.GetAllKnowledge()
+	if err != nil {
+		return nil, fmt.Errorf("读取知识失败: %w", err)
+	}
+
+	known := make(map[string]bool, len(knowledges))
+	report := &ReconcileReport{}
+	var docs []KnowledgeDocument
+	for i := range knowledges {
+		k := &knowledges[i]
+		known[k.ID] = true
+		if k.Content == "" {
+			continue
+		}
+
+		item, err := r.rag.vectorStore.Get(k.ID)
+		if err != nil {
+			return nil, fmt.Errorf("读取向量失败: %w", err)
+		}
+		if !r.rag.NeedsEmbedding(item, k.Content) {
+			continue
+		}
+		if item == nil {
+			report.MissingVectors = append(report.MissingVectors, k.ID)
+		} else {
+			report.StaleVectors = append(report.StaleVectors, k.ID)
+		}
+		docs = append(docs, KnowledgeDocument{ID: k.ID, Content: k.Content, Metadata: KnowledgeVectorMetadata(k)})
+	}
+	for _, id := range vectorIDs {
+		if !known[id] {
+			report.OrphanVectors = append(report.OrphanVectors, id)
+		}
+	}
+
+	if !fix {
+		return report, nil
+	}
+
+	total := report.Pending()
+	done := 0
+	if progress != nil {
+		progress(done, total)
+	}
+
+	for start := 0; start < len(docs); start += EmbedBatchSize {
+		end := start + EmbedBatchSize
+		if end > len(docs) {
+			end = len(docs)
+		}
+		batch := docs[start:end]
+
+		err := r.retry(ctx, func() error { return r.rag.AddKnowledgeBatch(ctx, batch) })
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		if err != nil {
+			log.Printf("[Knowledge] 对账批量向量化失败 (%d 条): %v", len(batch), err)
+			for _, doc := range batch {
+				report.Failed = append(report.Failed, doc.ID)
+			}
+		} else {
+			report.Indexed += len(batch)
+		}
+
+		done += len(batch)
+		if progress != nil {
+			progress(done, total)
+		}
+	}
+
+	for _, id := range report.OrphanVectors {
+		// 向量化可能耗时很久，删除前再确认一次知识确实不存在
+		k, err := r.db.GetKnowledge(id)
+		if err == nil && k == nil {
+			if err = r.rag.vectorStore.Delete(id); err == nil {
+				report.Removed++
+			}
+		}
+		if err != nil {
+			log.Printf("[Knowledge] 对账删除孤立向量失败 (ID: %s): %v", id, err)
+			report.Failed = append(report.Failed, id)
+		}
+
+		done++
+		if progress != nil {
+			progress(done, total)
+		}
+	}
+
+	return report, nil
+}
+
+// index 同步知识到向量库（失败重试）
+func (r *KnowledgeRepository) index(ctx context.Context, knowledge *Knowledge) {
+	if !r.ragEnabled() || knowledge.Content == "" {
+		return
+	}
+
+	err := r.retry(ctx, func() error {
+		return r.rag.UpdateKnowledge(ctx, knowledge.ID, knowledge.Content, KnowledgeVectorMetadata(knowledge))
+	})
+	if err != nil {
+		log.Printf("[Knowledge] 向量化失败，等待对账补建 (ID: %s): %v", knowledge.ID, err)
+		return
+	}
+	log.Printf("[Knowledge] 知识 %s 已同步到向量库", knowledge.ID)
+}
+
+// buildVector 生成向量（失败重试），仍失败时返回 nil，由 Reconcile 补建
+func (r *KnowledgeRepository) buildVector(ctx context.Context, knowledge *Knowledge) *VectorItem {
+	if !r.ragEnabled() || knowledge.Content == "" {
+		return nil
+	}
+
+	var item *VectorItem
+	err := r.retry(ctx, func() error {
+		var err error
+		item, err = r.rag.BuildVector(ctx, knowledge.ID, knowledge.Content, KnowledgeVectorMetadata(knowledge))
+		return err
+	})
+	if err != nil {
+		log.Printf("[Knowledge] 向量化失败，等待对账补建 (ID: %s): %v", knowledge.ID, err)
+		return nil
+	}
+	return item
+}
+
+// txStore 向量库与知识共用同一个数据库时返回它，此时两者可在一个事务中写入
+func (r *KnowledgeRepository) txStore() *SQLiteVectorStore {
+	if !r.ragEnabled() {
+		return nil
+	}
+	store, ok := r.rag.vectorStore.(*SQLiteVectorStore)
+	if !ok || store.db != r.db {
+		return nil
+	}
+	return store
+}
+
+// retry 按指数退避重试，ctx 取消时立即返回
+func (r *KnowledgeRepository) retry(ctx context.Context, fn func() error) error {
+	delay := r.retryDelay
+	var err error
+	for attempt := 0; attempt < r.retries; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt == r.retries-1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	return err
+}
+
+func (r *KnowledgeRepository) ragEnabled() bool {
+	return r.rag != nil && r.rag.IsEnabled()
+}
+
+// KnowledgeVectorMetadata 构建同步到向量库的知识元数据
+func KnowledgeVectorMetadata(k *Knowledge) map[string]interface{} {
+	return map[string]interface{}{
+		"title":      k.Title,
+		"category":   k.Category,
+		"tags":       k.Tags,
+		"summary":    k.Summary,
+		"key_points": k.KeyPoints,
+		"importance": k.Importance,
+		"source":     k.Source,
+		"session_id": k.SessionID,
+		"created_at": k.CreatedAt,
+	}
+}
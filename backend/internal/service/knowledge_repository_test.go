@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestKnowledgeRepository 创建使用临时数据库和假 Embedding 服务的知识仓库
+func newTestKnowledgeRepository(t *testing.T) (*KnowledgeRepository, *RAGService, *int32) {
+	db, err := NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	rag, calls := newTestRAGService(t)
+	repo := NewKnowledgeRepository(db, rag)
+	repo.retryDelay = time.Millisecond
+	return repo, rag, calls
+}
+
+func TestKnowledgeRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("保存同时写入数据库和向量库", func(t *testing.T) {
+		repo, rag, _ := newTestKnowledgeRepository(t)
+
+		k := &Knowledge{ID: "kb_1", Title: "标题", Content: "内容", Category: "工作"}
+		if err := repo.Save(ctx, k); err != nil {
+			t.Fatalf("保存失败: %v", err)
+		}
+		if k.CreatedAt.IsZero() {
+			t.Errorf("应填充创建时间")
+		}
+		if got, _ := repo.Get("kb_1"); got == nil {
+			t.Errorf("数据库中应存在")
+		}
+		item, _ := rag.vectorStore.Get("kb_1")
+		if item == nil || item.Metadata["category"] != "工作" {
+			t.Errorf("向量库中应存在且带元数据: %+v", item)
+		}
+	})
+
+	t.Run("更新同步向量元数据", func(t *testing.T) {
+		repo, rag, _ := newTestKnowledgeRepository(t)
+		k := &Knowledge{ID: "kb_1", Title: "旧标题", Content: "内容"}
+		repo.Save(ctx, k)
+
+		k.Title = "新标题"
+		if err := repo.Update(ctx, k, k.Version); err != nil {
+			t.Fatalf("更新失败: %v", err)
+		}
+		item, _ := rag.vectorStore.Get("kb_1")
+		if item.Metadata["title"] != "新标题" {
+			t.Errorf("向量元数据未更新: %v", item.Metadata)
+		}
+		if err := repo.Update(ctx, k, 1); !errors.Is(err, ErrKnowledgeConflict) {
+			t.Errorf("过期版本应返回冲突, 实际 %v", err)
+		}
+	})
+
+	t.Run("删除同时删除向量", func(t *testing.T) {
+		repo, rag, _ := newTestKnowledgeRepository(t)
+		repo.Save(ctx, &Knowledge{ID: "kb_1", Content: "内容"})
+
+		if err := repo.Delete(ctx, "kb_1"); err != nil {
+			t.Fatalf("删除失败: %v", err)
+		}
+		if item, _ := rag.vectorStore.Get("kb_1"); item != nil {
+			t.Errorf("向量应已删除")
+		}
+		if err := repo.Delete(ctx, "kb_1"); !errors.Is(err, ErrKnowledgeNotFound) {
+			t.Errorf("重复删除应返回 ErrKnowledgeNotFound, 实际 %v", err)
+		}
+	})
+
+	t.Run("向量化失败不影响入库，对账后补齐", func(t *testing.T) {
+		repo, rag, _ := newTestKnowledgeRepository(t)
+
+		// Embedding 服务不可用
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer failing.Close()
+		baseURL := rag.embeddingClient.baseURL
+		rag.embeddingClient.baseURL = failing.URL
+
+		if err := repo.Save(ctx, &Knowledge{ID: "kb_1", Content: "内容"}); err != nil {
+			t.Fatalf("向量化失败不应导致保存失败: %v", err)
+		}
+		if item, _ := rag.vectorStore.Get("kb_1"); item != nil {
+			t.Fatalf("Embedding 不可用时不应有向量")
+		}
+
+		rag.embeddingClient.baseURL = baseURL
+		report, err := repo.Reconcile(ctx, true)
+		if err != nil {
+			t.Fatalf("对账失败: %v", err)
+		}
+		if len(report.MissingVectors) != 1 || report.Indexed != 1 {
+			t.Errorf("应补建 1 条向量: %+v", report)
+		}
+		if item, _ := rag.vectorStore.Get("kb_1"); item == nil {
+			t.Errorf("对账后应存在向量")
+		}
+	})
+
+	t.Run("对账检测双向差异", func(t *testing.T) {
+		repo, rag, _ := newTestKnowledgeRepository(t)
+		repo.db.SaveKnowledge(&Knowledge{ID: "kb_db_only", Content: "只在数据库"})
+		repo.db.SaveKnowledge(&Knowledge{ID: "kb_empty"}) // 无正文不需要向量
+		rag.vectorStore.Add("kb_vec_only", []float32{1, 0}, map[string]interface{}{})
+
+		report, err := repo.Reconcile(ctx, false)
+		if err != nil {
+			t.Fatalf("对账失败: %v", err)
+		}
+		if len(report.MissingVectors) != 1 || report.MissingVectors[0] != "kb_db_only" {
+			t.Errorf("缺失向量检测错误: %v", report.MissingVectors)
+		}
+		if len(report.OrphanVectors) != 1 || report.OrphanVectors[0] != "kb_vec_only" {
+			t.Errorf("孤立向量检测错误: %v", report.OrphanVectors)
+		}
+		if report.Indexed != 0 || report.Removed != 0 {
+			t.Errorf("dry run 不应修改: %+v", report)
+		}
+
+		if _, err := repo.Reconcile(ctx, true); err != nil {
+			t.Fatalf("对账修复失败: %v", err)
+		}
+		ids, _ := rag.vectorStore.IDs()
+		sort.Strings(ids)
+		if len(ids) != 1 || ids[0] != "kb_db_only" {
+			t.Errorf("修复后向量库应只含 kb_db_only, 实际 %v", ids)
+		}
+	})
+
+	t.Run("对账期间写入的知识不删除其向量", func(t *testing.T) {
+		repo, rag, _ := newTestKnowledgeRepository(t)
+		repo.db.SaveKnowledge(&Knowledge{ID: "kb_db_only", Content: "只在数据库"})
+		rag.vectorStore.Add("kb_new", []float32{1, 0}, map[string]interface{}{})
+
+		// 向量已写入、知识行在补建向量期间才落库（与 Save 并发）
+		report, err := repo.reconcile(ctx, true, func(done, total int) {
+			if done == 1 {
+				repo.db.SaveKnowledge(&Knowledge{ID: "kb_new", Content: "新知识"})
+			}
+		})
+		if err != nil {
+			t.Fatalf("对账失败: %v", err)
+		}
+		if report.Removed != 0 {
+			t.Errorf("不应删除新知识的向量: %+v", report)
+		}
+		if item, _ := rag.vectorStore.Get("kb_new"); item == nil {
+			t.Errorf("新知识的向量被误删")
+		}
+	})
+
+	t.Run("只重建正文或模型变化的向量并批量调用", func(t *testing.T) {
+		repo, rag, calls := newTestKnowledgeRepository(t)
+		for _, id := range []string{"kb_1", "kb_2", "kb_3"} {
+			repo.db.SaveKnowledge(&Knowledge{ID: id, Content: "内容 " + id})
+		}
+
+		report, err := repo.Reconcile(ctx, true)
+		if err != nil {
+			t.Fatalf("对账失败: %v", err)
+		}
+		if report.Indexed != 3 || atomic.LoadInt32(calls) != 1 {
+			t.Errorf("3 条缺失向量应一次批量请求完成: indexed=%d calls=%d", report.Indexed, atomic.LoadInt32(calls))
+		}
+
+		// 没有变化时不调用 Embedding
+		report, _ = repo.Reconcile(ctx, true)
+		if report.Pending() != 0 || atomic.LoadInt32(calls) != 1 {
+			t.Errorf("无变化时不应重新向量化: %+v, calls=%d", report, atomic.LoadInt32(calls))
+		}
+
+		// 正文变化（绕过仓库直接改库）
+		k, _ := repo.Get("kb_2")
+		k.Content = "修改后的内容"
+		repo.db.UpdateKnowledge(k, 0)
+		report, _ = repo.Reconcile(ctx, true)
+		if len(report.StaleVectors) != 1 || report.StaleVectors[0] != "kb_2" || report.Indexed != 1 {
+			t.Errorf("应只重建 kb_2: %+v", report)
+		}
+		item, _ := rag.vectorStore.Get("kb_2")
+		if item.Metadata["content_hash"] != ContentHash("修改后的内容") {
+			t.Errorf("向量应记录新正文哈希: %v", item.Metadata["content_hash"])
+		}
+
+		// 更换向量模型后全部重建
+		rag.embeddingClient.model = "embedding-3"
+		report, _ = repo.Reconcile(ctx, false)
+		if len(report.StaleVectors) != 3 {
+			t.Errorf("更换模型后应全部过期: %+v", report)
+		}
+	})
+
+	t.Run("兼容没有哈希的旧向量", func(t *testing.T) {
+		repo, rag, calls := newTestKnowledgeRepository(t)
+		repo.db.SaveKnowledge(&Knowledge{ID: "kb_1", Content: "内容"})
+		rag.vectorStore.Add("kb_1", []float32{1, 0}, map[string]interface{}{"content": "内容"})
+
+		report, _ := repo.Reconcile(ctx, true)
+		if report.Pending() != 0 || atomic.LoadInt32(calls) != 0 {
+			t.Errorf("正文未变的旧向量不应重建: %+v", report)
+		}
+	})
+}
+
+func TestKnowledgeIndexer(t *testing.T) {
+	repo, rag, _ := newTestKnowledgeRepository(t)
+	for _, id := range []string{"kb_1", "kb_2"} {
+		repo.db.SaveKnowledge(&Knowledge{ID: id, Content: "内容 " + id})
+	}
+	rag.vectorStore.Add("kb_deleted", []float32{1, 0}, map[string]interface{}{})
+
+	indexer := NewKnowledgeIndexer(repo)
+	if !indexer.Start() {
+		t.Fatalf("启动索引失败")
+	}
+	indexer.Wait()
+
+	progress := indexer.Progress()
+	if progress.Running || progress.Error != "" {
+		t.Fatalf("索引应已成功结束: %+v", progress)
+	}
+	if progress.Total != 3 || progress.Done != 3 || progress.Failed != 0 {
+		t.Errorf("进度错误: %+v", progress)
+	}
+	if progress.Report.Indexed != 2 || progress.Report.Removed != 1 {
+		t.Errorf("报告错误: %+v", progress.Report)
+	}
+	if progress.StartedAt == nil || progress.FinishedAt == nil {
+		t.Errorf("应记录开始和结束时间")
+	}
+
+	// 结束后可以再次启动
+	if !indexer.Start() {
+		t.Errorf("任务结束后应可再次启动")
+	}
+	indexer.Stop()
+}
+
+func TestKnowledgeRepository_Search(t *testing.T) {
+	ctx := context.Background()
+	repo, rag, _ := newTestKnowledgeRepository(t)
+	repo.Save(ctx, &Knowledge{ID: "kb_work", Content: "周会纪要", Category: "工作"})
+	repo.Save(ctx, &Knowledge{ID: "kb_life", Content: "周末爬山", Category: "生活"})
+	rag.vectorStore.Add("kb_orphan", []float32{4, 1}, map[string]interface{}{"category": "工作"})
+
+	t.Run("向量检索按元数据过滤并读取完整知识", func(t *testing.T) {
+		results, err := repo.Search(ctx, "周会", 10, &VectorFilter{Category: "工作"})
+		if err != nil {
+			t.Fatalf("搜索失败: %v", err)
+		}
+		if len(results) != 1 || results[0].ID != "kb_work" || results[0].Knowledge.Content != "周会纪要" {
+			t.Errorf("应只返回工作类知识且跳过孤立向量: %+v", results)
+		}
+	})
+
+	t.Run("关键词和向量都命中的排在前面", func(t *testing.T) {
+		results, err := repo.Search(ctx, "周会", 10, nil)
+		if err != nil || len(results) == 0 {
+			t.Fatalf("搜索失败: %+v, %v", results, err)
+		}
+		top := results[0]
+		if top.ID != "kb_work" || top.KeywordRank != 1 || top.VectorRank == 0 {
+			t.Errorf("两路都命中的应排第一: %+v", top)
+		}
+		if top.Snippet != "<mark>周会</mark>纪要" {
+			t.Errorf("摘录应高亮命中词: %q", top.Snippet)
+		}
+	})
+
+	t.Run("对话检索走混合检索", func(t *testing.T) {
+		results, err := repo.Retrieve(ctx, "周会", 1, nil)
+		if err != nil || len(results) != 1 {
+			t.Fatalf("检索失败: %+v, %v", results, err)
+		}
+		r := results[0]
+		if r.ID != "kb_work" || r.Content != "周会纪要" || r.Reason != reasonHybridMatch || r.Score <= 0.5 || r.Score > 1 {
+			t.Errorf("两路都命中的结果错误: %+v", r)
+		}
+	})
+
+	t.Run("向量检索失败时只用关键词结果", func(t *testing.T) {
+		rag.embeddingClient.baseURL = "http://127.0.0.1:1"
+		results, err := repo.Search(ctx, "爬山", 10, nil)
+		if err != nil || len(results) != 1 || results[0].ID != "kb_life" || results[0].VectorRank != 0 {
+			t.Errorf("应只返回关键词结果: %+v, %v", results, err)
+		}
+
+		retrieved, err := repo.Retrieve(ctx, "爬山", 3, nil)
+		if err != nil || len(retrieved) != 1 || retrieved[0].Reason != reasonKeywordMatch || retrieved[0].Score != 1 {
+			t.Errorf("对话检索应退回关键词结果: %+v, %v", retrieved, err)
+		}
+	})
+}
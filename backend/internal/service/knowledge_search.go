@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+)
+
+// rrfK 倒数排名融合的平滑常数（常用取值 60），名次靠后的结果贡献趋于平缓
+const rrfK = 60
+
+// searchSnippetWidth 搜索结果摘录的长度（字）
+const searchSnippetWidth = 80
+
+// 混合检索结果的入选原因
+const (
+	reasonKeywordMatch = "包含问题中的关键词"
+	reasonHybridMatch  = "与问题的关键词和语义都相近"
+)
+
+// SearchHit 混合检索的一条结果
+type SearchHit struct {
+	Knowledge    Knowledge `json:"-"`
+	ID           string    `json:"id"`
+	Score        float64   `json:"score"`                   // RRF 融合分数
+	KeywordRank  int       `json:"keyword_rank,omitempty"`  // 关键词检索名次（从 1 开始，0 表示未命中）
+	KeywordScore float64   `json:"keyword_score,omitempty"` // BM25 分数
+	VectorRank   int       `json:"vector_rank,omitempty"`   // 向量检索名次
+	VectorScore  float64   `json:"vector_score,omitempty"`  // 余弦相似度
+	Snippet      string    `json:"snippet"`                 // 高亮摘录（HTML，命中词用 <mark> 包裹）
+}
+
+// Search 混合检索：FTS 关键词检索（BM25）与向量检索分别取候选，用倒数排名融合（RRF）合并排序
+// filter 为 nil 时不过滤。RAG 未启用或向量检索失败时只用关键词结果。
+// 两路各取 pool 条候选（关键词的过滤在 SQL 中完成），只为融合后的前 limit 条读取完整知识
+func (r *KnowledgeRepository) Search(ctx context.Context, query string, limit int, filter *VectorFilter) ([]SearchHit, error) {
+	results, _, err := r.search(ctx, query, limit, filter)
+	return results, err
+}
+
+// ShouldUseRAG 搜索和问答意图检索知识库，实现 RetrievalService 接口（未启用 RAG 时只用关键词检索）
+func (r *KnowledgeRepository) ShouldUseRAG(intent Intent, confidence float64) bool {
+	return shouldRetrieve(intent, confidence)
+}
+
+// Retrieve 用混合检索取 topK 条知识供对话引用，实现 RetrievalService 接口
+// 得分为归一化的 RRF 分数（两路都排第一时为 1）；设置了知识图谱时再沿实体关系扩展
+func (r *KnowledgeRepository) Retrieve(ctx context.Context, query string, topK int, filter *VectorFilter) ([]*RetrievalResult, error) {
+	hits, channels, err := r.search(ctx, query, topK, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*RetrievalResult, len(hits))
+	for i, h := range hits {
+		reason := reasonHybridMatch
+		switch {
+		case h.VectorRank == 0:
+			reason = reasonKeywordMatch
+		case h.KeywordRank == 0:
+			reason = reasonVectorMatch
+		}
+		results[i] = &RetrievalResult{
+			ID:       h.ID,
+			Content:  h.Knowledge.Content,
+			Score:    h.Score * (rrfK + 1) / float64(channels),
+			Metadata: KnowledgeVectorMetadata(&h.Knowledge),
+			Reason:   reason,
+		}
+	}
+	if r.rag == nil {
+		return results, nil
+	}
+	return r.rag.expandWithGraph(nil, results, filter), nil
+}
+
+// search 执行混合检索，同时返回参与融合的检索路数（只有关键词时为 1）
+func (r *KnowledgeRepository) search(ctx context.Context, query string, limit int, filter *VectorFilter) ([]SearchHit, int, error) {
+	pool := max(limit*3, 30)
+	channels := 1
+
+	hits := make(map[string]*SearchHit)
+	hit := func(id string) *SearchHit {
+		h, ok := hits[id]
+		if !ok {
+			h = &SearchHit{ID: id}
+			hits[id] = h
+		}
+		return h
+	}
+
+	keywordHits, err := r.db.SearchKnowledgeFTS(query, pool, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i, kh := range keywordHits {
+		h := hit(kh.ID)
+		h.KeywordRank, h.KeywordScore = i+1, kh.Score
+		h.Score += 1.0 / float64(rrfK+i+1)
+	}
+
+	if r.ragEnabled() {
+		// 只取向量排名参与融合，图谱扩展用于对话检索
+		_, results, err := r.rag.vectorSearch(ctx, query, pool, filter)
+		if err != nil {
+			log.Printf("[Knowledge] 向量检索失败，只使用关键词结果: %v", err)
+		} else {
+			channels = 2
+		}
+		for i, result := range results {
+			h := hit(result.ID)
+			h.VectorRank, h.VectorScore = i+1, result.Score
+			h.Score += 1.0 / float64(rrfK+i+1)
+		}
+	}
+
+	ranked := make([]*SearchHit, 0, len(hits))
+	for _, h := range hits {
+		ranked = append(ranked, h)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+
+	// 按名次分批读取知识，向量库中残留的孤立条目跳过（等待对账清理），由后面的结果补足
+	tokens := SearchTokens(query)
+	results := make([]SearchHit, 0, min(limit, len(ranked)))
+	for start := 0; start < len(ranked) && len(results) < limit; {
+		batch := ranked[start:min(start+limit-len(results), len(ranked))]
+		start += len(batch)
+
+		ids := make([]string, len(batch))
+		for i, h := range batch {
+			ids[i] = h.ID
+		}
+		loaded, err := r.db.GetKnowledgeByIDs(ids)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, h := range batch {
+			k := loaded[h.ID]
+			if k == nil {
+				continue
+			}
+			h.Knowledge = *k
+			h.Snippet = searchSnippet(k, tokens)
+			results = append(results, *h)
+		}
+	}
+	return results, channels, nil
+}
+
+// searchSnippet 依次在正文、摘要、标题中找命中查询词的字段生成摘录，都没命中时摘录正文开头
+func searchSnippet(k *Knowledge, tokens []string) string {
+	for _, field := range []string{k.Content, k.Summary, k.Title} {
+		lower := strings.ToLower(field)
+		for _, t := range tokens {
+			if strings.Contains(lower, t) {
+				return HighlightSnippet(field, tokens, searchSnippetWidth)
+			}
+		}
+	}
+	return HighlightSnippet(k.Content, tokens, searchSnippetWidth)
+}
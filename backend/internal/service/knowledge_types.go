@@ -23,6 +23,8 @@ type Knowledge struct {
 	CreatedAt    time.Time         `json:"created_at"`
 	UpdatedAt    time.Time         `json:"updated_at"`
 	Metadata     map[string]string `json:"metadata"`
+	Version      int               `json:"version"`               // 乐观锁版本号，编辑时校验
+	MessageIDs   []int64           `json:"message_ids,omitempty"` // 来源会话消息（messages.id）
 }
 
 // KnowledgeStoreData 知识库存储数据
@@ -42,9 +44,10 @@ type Entities struct {
 
 // Relation 实体关系
 type Relation struct {
-	Type    string `json:"type"`    // 关系类型
-	Target  string `json:"target"`  // 目标实体
-	Context string `json:"context"` // 关系说明
+	Source  string `json:"source,omitempty"` // 源实体（为空时由知识图谱推断）
+	Type    string `json:"type"`             // 关系类型
+	Target  string `json:"target"`           // 目标实体
+	Context string `json:"context"`          // 关系说明
 }
 
 // Observation 观察点
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrMessageNotFound 消息不存在
+var ErrMessageNotFound = errors.New("消息不存在")
+
+// ErrSessionNotFound 会话不存在
+var ErrSessionNotFound = errors.New("会话不存在")
+
+const (
+	// DefaultMessagePageSize 分页获取消息的默认条数
+	DefaultMessagePageSize = 50
+	// MaxMessagePageSize 分页获取消息的最大条数
+	MaxMessagePageSize = 200
+)
+
+// SessionMessage 会话中的一条消息（messages 表）
+// 完整历史都保留在这里，发给 LLM 的上下文由 SessionManager / ContextCompressor 另行裁剪
+type SessionMessage struct {
+	ID           int64     `json:"id"`
+	SessionID    string    `json:"session_id"`
+	Role         string    `json:"role"`
+	Content      string    `json:"content"`
+	AudioURL     string    `json:"audio_url,omitempty"`     // 对应的录音文件
+	Intent       string    `json:"intent,omitempty"`        // 用户消息的识别意图
+	LatencyMs    int64     `json:"latency_ms,omitempty"`    // 助手回复的生成耗时
+	InputTokens  int       `json:"input_tokens,omitempty"`  // 生成该回复的输入 token 数
+	OutputTokens int       `json:"output_tokens,omitempty"` // 生成该回复的输出 token 数
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// MessagePage 一页消息（按时间正序）
+type MessagePage struct {
+	Messages   []SessionMessage `json:"messages"`
+	Total      int              `json:"total"`                 // 会话的消息总数
+	HasMore    bool             `json:"has_more"`              // 是否还有更早的消息
+	NextBefore int64            `json:"next_before,omitempty"` // 获取更早一页时传入的 before
+}
+
+const messageColumns = `id, session_id, role, content, COALESCE(audio_url, ''), COALESCE(intent, ''),
+	COALESCE(latency_ms, 0), COALESCE(input_tokens, 0), COALESCE(output_tokens, 0), created_at`
+
+func scanMessage(row rowScanner) (*SessionMessage, error) {
+	var m SessionMessage
+	var createdAt int64
+	err := row.Scan(&m.ID, &m.SessionID, &m.Role, &m.Content, &m.AudioURL, &m.Intent,
+		&m.LatencyMs, &m.InputTokens, &m.OutputTokens, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	m.CreatedAt = time.Unix(createdAt, 0)
+	return &m, nil
+}
+
+// AppendMessage 追加一条消息并刷新会话更新时间，写入后设置 msg.ID
+// 会话不存在时返回 ErrSessionNotFound
+func (d *Database) AppendMessage(msg *SessionMessage) error {
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+
+	return d.WithTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(`UPDATE sessions SET updated_at = ? WHERE id = ?`, msg.CreatedAt.Unix(), msg.SessionID)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return ErrSessionNotFound
+		}
+		return insertMessage(tx, msg)
+	})
+}
+
+func insertMessage(ex execer, msg *SessionMessage) error {
+	result, err := ex.Exec(`INSERT INTO messages
+		(session_id, role, content, audio_url, intent, latency_ms, input_tokens, output_tokens, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		msg.SessionID, msg.Role, msg.Content, nullString(msg.AudioURL), nullString(msg.Intent),
+		msg.LatencyMs, msg.InputTokens, msg.OutputTokens, msg.CreatedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("保存消息失败: %w", err)
+	}
+	msg.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetMessage 按 ID 获取消息，不存在时返回 ErrMessageNotFound
+func (d *Database) GetMessage(id int64) (*SessionMessage, error) {
+	m, err := scanMessage(d.db.QueryRow(`SELECT `+messageColumns+` FROM messages WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMessageNotFound
+	}
+	return m, err
+}
+
+// GetMessagesByIDs 按 ID 批量获取消息（按时间正序），不存在的 ID 忽略
+func (d *Database) GetMessagesByIDs(ids []int64) ([]SessionMessage, error) {
+	if len(ids) == 0 {
+		return []SessionMessage{}, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	return d.queryMessages(`SELECT `+messageColumns+` FROM messages WHERE id IN (`+placeholders+`) ORDER BY id`, args...)
+}
+
+// GetSessionMessages 获取会话中 ID 大于 after 的全部消息（按时间正序），after 为 0 时返回全部
+func (d *Database) GetSessionMessages(sessionID string, after int64) ([]SessionMessage, error) {
+	return d.queryMessages(`SELECT `+messageColumns+` FROM messages WHERE session_id = ? AND id > ? ORDER BY id`, sessionID, after)
+}
+
+// GetRecentMessages 获取会话中 ID 大于 after 的最近 limit 条消息（按时间正序）
+// limit 规则与 ListMessages 一致
+func (d *Database) GetRecentMessages(sessionID string, after int64, limit int) ([]SessionMessage, error) {
+	if limit <= 0 {
+		limit = DefaultMessagePageSize
+	}
+	limit = min(limit, MaxMessagePageSize)
+
+	messages, err := d.queryMessages(`SELECT `+messageColumns+` FROM messages
+		WHERE session_id = ? AND id > ? ORDER BY id DESC LIMIT ?`, sessionID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// ListMessages 分页获取会话消息：返回 ID 小于 before 的最近 limit 条（before 为 0 时从最新一条开始）
+// limit 不合法时使用 DefaultMessagePageSize，最多 MaxMessagePageSize
+func (d *Database) ListMessages(sessionID string, before int64, limit int) (*MessagePage, error) {
+	if limit <= 0 {
+		limit = DefaultMessagePageSize
+	}
+	limit = min(limit, MaxMessagePageSize)
+
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE session_id = ?`
+	args := []interface{}{sessionID}
+	if before > 0 {
+		query += ` AND id < ?`
+		args = append(args, before)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit+1)
+
+	messages, err := d.queryMessages(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &MessagePage{}
+	if len(messages) > limit {
+		messages = messages[:limit]
+		page.HasMore = true
+		page.NextBefore = messages[limit-1].ID
+	}
+	// 倒序取出，按时间正序返回
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	page.Messages = messages
+	return page, nil
+}
+
+// CountMessages 会话的消息条数
+func (d *Database) CountMessages(sessionID string) (int, error) {
+	var n int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM messages WHERE session_id = ?`, sessionID).Scan(&n)
+	return n, err
+}
+
+// LastMessageID 会话最后一条消息的 ID，没有消息时为 0
+func (d *Database) LastMessageID(sessionID string) (int64, error) {
+	var id int64
+	err := d.db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM messages WHERE session_id = ?`, sessionID).Scan(&id)
+	return id, err
+}
+
+func (d *Database) queryMessages(query string, args ...interface{}) ([]SessionMessage, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []SessionMessage{}
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, *m)
+	}
+	return messages, rows.Err()
+}
+
+// migrateSessionMessages 把旧版 sessions.messages 中的 JSON 历史迁移到 messages 表
+// 迁移后该列置为 "[]"，重复执行不会重复导入
+func (d *Database) migrateSessionMessages() error {
+	rows, err := d.db.Query(`SELECT id, messages, COALESCE(updated_at, 0) FROM sessions
+		WHERE messages IS NOT NULL AND messages NOT IN ('', '[]', 'null')`)
+	if err != nil {
+		return err
+	}
+	type legacySession struct {
+		id        string
+		messages  string
+		updatedAt int64
+	}
+	var legacy []legacySession
+	for rows.Next() {
+		var s legacySession
+		if err := rows.Scan(&s.id, &s.messages, &s.updatedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		legacy = append(legacy, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, s := range legacy {
+		var messages []Message
+		if err := json.Unmarshal([]byte(s.messages), &messages); err != nil {
+			return fmt.Errorf("解析会话 %s 的历史消息失败: %w", s.id, err)
+		}
+		err := d.WithTx(func(tx *sql.Tx) error {
+			for _, m := range messages {
+				// 旧数据没有逐条时间，统一记为会话最后更新时间
+				msg := &SessionMessage{
+					SessionID: s.id,
+					Role:      m.Role,
+					Content:   messageText(m.Content),
+					CreatedAt: time.Unix(s.updatedAt, 0),
+				}
+				if err := insertMessage(tx, msg); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec(`UPDATE sessions SET messages = '[]' WHERE id = ?`, s.id)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("迁移会话 %s 的历史消息失败: %w", s.id, err)
+		}
+	}
+	if len(legacy) > 0 {
+		fmt.Printf("已将 %d 个会话的历史消息迁移到 messages 表\n", len(legacy))
+	}
+	return nil
+}
+
+// messageText 消息的文本内容（非文本内容按 JSON 保存）
+func messageText(content interface{}) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case nil:
+		return ""
+	default:
+		data, _ := json.Marshal(c)
+		return string(data)
+	}
+}
+
+// nullString 空字符串写入 NULL
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// 会话消息全文索引
+//
+// message_fts 的 rowid 与 messages.id 一致，由触发器在写入/删除消息时同步，分词方式与知识全文索引相同（见 knowledge_fts.go）。
+
+const (
+	// DefaultMessageSearchLimit 搜索历史消息默认返回的条数
+	DefaultMessageSearchLimit = 10
+	// MaxMessageSearchLimit 搜索历史消息最多返回的条数
+	MaxMessageSearchLimit = 50
+	// DefaultMessageSearchContext 命中消息前后各带的消息条数
+	DefaultMessageSearchContext = 2
+	// MaxMessageSearchContext 命中消息前后各带的最大消息条数
+	MaxMessageSearchContext = 5
+)
+
+// MessageSearchOptions 历史消息搜索条件
+type MessageSearchOptions struct {
+	SessionID      string   // 只搜索该会话，为空时搜索全部会话
+	ExcludeIntents []string // 排除这些意图的问答（用户消息及紧随其后的助手回复），如历史检索本身
+	Context        int      // 命中消息前后各带几条消息，默认 DefaultMessageSearchContext；小于 0 时不带
+	Limit          int      // 默认 DefaultMessageSearchLimit，最多 MaxMessageSearchLimit
+}
+
+// MessageSearchHit 一条命中的历史消息及其上下文
+type MessageSearchHit struct {
+	Message      SessionMessage   `json:"message"`
+	Score        float64          `json:"score"`         // BM25 分数，越大越相关
+	Snippet      string           `json:"snippet"`       // 命中片段，<mark> 高亮，已做 HTML 转义
+	Before       []SessionMessage `json:"before"`        // 之前的消息（按时间正序）
+	After        []SessionMessage `json:"after"`         // 之后的消息（按时间正序）
+	SessionTitle string           `json:"session_title"` // 所在会话的标题
+	Link         string           `json:"link"`          // 以该消息结尾的一页会话消息
+}
+
+// initMessageFTS 创建消息全文索引和同步触发器，索引与消息表条数不一致时重建
+func (d *Database) initMessageFTS() error {
+	module, err := d.createFTSTable("message_fts", []string{"content"})
+	if err != nil {
+		return err
+	}
+	d.messageFTSModule = module
+
+	triggers := []string{
+		`CREATE TRIGGER IF NOT EXISTS trg_message_fts_insert AFTER INSERT ON messages
+		BEGIN
+			INSERT INTO message_fts (rowid, content) VALUES (NEW.id, fts_tokens(NEW.content));
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS trg_message_fts_delete AFTER DELETE ON messages
+		BEGIN
+			DELETE FROM message_fts WHERE rowid = OLD.id;
+		END`,
+	}
+	for _, trigger := range triggers {
+		if _, err := d.db.Exec(trigger); err != nil {
+			return fmt.Errorf("创建消息全文索引触发器失败: %w", err)
+		}
+	}
+
+	var indexed, total int
+	d.db.QueryRow(`SELECT COUNT(*) FROM message_fts`).Scan(&indexed)
+	d.db.QueryRow(`SELECT COUNT(*) FROM messages`).Scan(&total)
+	if indexed != total {
+		return d.RebuildMessageFTS()
+	}
+	return nil
+}
+
+// RebuildMessageFTS 重建消息全文索引
+func (d *Database) RebuildMessageFTS() error {
+	return d.WithTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM message_fts`); err != nil {
+			return fmt.Errorf("清空消息全文索引失败: %w", err)
+		}
+		_, err := tx.Exec(`INSERT INTO message_fts (rowid, content) SELECT id, fts_tokens(content) FROM messages`)
+		if err != nil {
+			return fmt.Errorf("重建消息全文索引失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// messageHit 消息全文检索命中
+type messageHit struct {
+	id           int64
+	sessionID    string
+	sessionTitle string
+	score        float64
+}
+
+// messageHitBefore a 排在 b 前面：分数更高，同分时较新的在前
+func messageHitBefore(a, b messageHit) bool {
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	return a.id > b.id
+}
+
+// SearchMessages 全文检索历史消息，按 BM25 从高到低（同分时较新的在前）返回命中消息及其上下文
+// 排除条件和条数限制在检索时完成，之后每条命中只用一次查询读取它和前后的消息
+func (d *Database) SearchMessages(query string, opts MessageSearchOptions) ([]MessageSearchHit, error) {
+	results := []MessageSearchHit{}
+	tokens := SearchTokens(query)
+	if len(tokens) == 0 {
+		return results, nil
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultMessageSearchLimit
+	}
+	limit = min(limit, MaxMessageSearchLimit)
+	around := opts.Context
+	if around == 0 {
+		around = DefaultMessageSearchContext
+	}
+	around = max(min(around, MaxMessageSearchContext), 0)
+
+	hits, err := d.searchMessageFTS(ftsMatchExpr(tokens), opts, limit)
+	if err != nil {
+		return nil, fmt.Errorf("检索历史消息失败: %w", err)
+	}
+
+	for _, hit := range hits {
+		window, err := d.queryMessages(`SELECT * FROM (
+				SELECT `+messageColumns+` FROM messages WHERE session_id = ? AND id <= ? ORDER BY id DESC LIMIT ?
+			) UNION ALL SELECT * FROM (
+				SELECT `+messageColumns+` FROM messages WHERE session_id = ? AND id > ? ORDER BY id LIMIT ?
+			) ORDER BY 1`,
+			hit.sessionID, hit.id, around+1, hit.sessionID, hit.id, around)
+		if err != nil {
+			return nil, err
+		}
+
+		pos := -1
+		for i := range window {
+			if window[i].ID == hit.id {
+				pos = i
+				break
+			}
+		}
+		// 检索之后消息已被删除
+		if pos < 0 {
+			continue
+		}
+		msg := window[pos]
+		results = append(results, MessageSearchHit{
+			Message:      msg,
+			Score:        hit.score,
+			Snippet:      HighlightSnippet(msg.Content, tokens, 80),
+			Before:       window[:pos],
+			After:        window[pos+1:],
+			SessionTitle: hit.sessionTitle,
+			Link:         fmt.Sprintf("/api/sessions/%s/messages?before=%d", msg.SessionID, msg.ID+1),
+		})
+	}
+	return results, nil
+}
+
+// searchMessageFTS 检索命中的消息，按 messageHitBefore 排序返回最多 limit 条
+// FTS5 由 SQLite 排序截断，FTS4 边读边保留前 limit 条
+func (d *Database) searchMessageFTS(match string, opts MessageSearchOptions, limit int) ([]messageHit, error) {
+	fts5 := d.messageFTSModule == "fts5"
+	score := `-bm25(message_fts)` // FTS5 的 bm25() 越小越相关，取负数
+	if !fts5 {
+		score = `matchinfo(message_fts, 'pcnalx')`
+	}
+	query := `SELECT m.id, m.session_id, COALESCE(s.title, ''), ` + score + ` AS score
+		FROM message_fts JOIN messages m ON m.id = message_fts.rowid
+		LEFT JOIN sessions s ON s.id = m.session_id
+		WHERE message_fts MATCH ?`
+	args := []interface{}{match}
+	if opts.SessionID != "" {
+		query += ` AND m.session_id = ?`
+		args = append(args, opts.SessionID)
+	}
+	if where, excluded := excludedTurnSQL(opts.ExcludeIntents); where != "" {
+		query += where
+		args = append(args, excluded...)
+	}
+	if fts5 {
+		query += ` ORDER BY score DESC, m.id DESC LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	top := newTopN(limit, messageHitBefore)
+	for rows.Next() {
+		var hit messageHit
+		if fts5 {
+			err = rows.Scan(&hit.id, &hit.sessionID, &hit.sessionTitle, &hit.score)
+		} else {
+			var info []byte
+			err = rows.Scan(&hit.id, &hit.sessionID, &hit.sessionTitle, &info)
+			hit.score = bm25FromMatchinfo(info, []float64{1})
+		}
+		if err != nil {
+			return nil, err
+		}
+		top.add(hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return top.sorted(), nil
+}
+
+// excludedTurnSQL 排除指定意图的问答（消息表别名须为 m），以 " AND ..." 开头，没有要排除的意图时为空
+// 用户消息看自身意图，紧跟在用户消息之后的助手回复看那条用户消息的意图
+func excludedTurnSQL(intents []string) (string, []interface{}) {
+	var args []interface{}
+	for _, intent := range intents {
+		if intent != "" {
+			args = append(args, intent)
+		}
+	}
+	if len(args) == 0 {
+		return "", nil
+	}
+
+	previous := `(SELECT p.%s FROM messages p WHERE p.session_id = m.session_id AND p.id < m.id ORDER BY p.id DESC LIMIT 1)`
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(args)), ",")
+	return ` AND COALESCE(CASE WHEN m.role = 'assistant' AND ` + fmt.Sprintf(previous, "role") + ` = 'user'
+		THEN ` + fmt.Sprintf(previous, "intent") + ` ELSE m.intent END, '') NOT IN (` + placeholders + `)`, args
+}
+
+// SearchMessages 全文检索历史消息（见 Database.SearchMessages）
+func (sm *SessionManager) SearchMessages(query string, opts MessageSearchOptions) ([]MessageSearchHit, error) {
+	if sm.db == nil {
+		return []MessageSearchHit{}, nil
+	}
+	return sm.db.SearchMessages(query, opts)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSearchMessages(t *testing.T) {
+	db, sm := setupTestDB(t)
+	sm.GetOrCreateSession("s1")
+	title := "缓存方案"
+	sm.UpdateSession("s1", SessionUpdate{Title: &title})
+	for _, m := range testDialog(2) {
+		sm.AddMessage("s1", m.Role, m.Content.(string))
+	}
+	sm.AddMessage("s1", "user", "Redis 做缓存要注意什么")
+	sm.AddMessage("s1", "assistant", "注意过期时间和缓存击穿")
+	sm.AddMessage("s1", "user", "好的谢谢")
+
+	sm.GetOrCreateSession("s2")
+	sm.AddMessage("s2", "user", "周末去爬山")
+	sm.AppendMessage(&SessionMessage{SessionID: "s2", Role: "user", Content: "我之前聊过什么关于Redis的", Intent: string(IntentHistory)})
+	sm.AddMessage("s2", "assistant", "你之前问过 Redis 缓存的注意事项")
+
+	t.Run("命中消息带上下文和会话链接", func(t *testing.T) {
+		hits, err := sm.SearchMessages("redis缓存", MessageSearchOptions{ExcludeIntents: []string{string(IntentHistory)}})
+		if err != nil {
+			t.Fatalf("搜索失败: %v", err)
+		}
+		if len(hits) == 0 || hits[0].Message.Content != "Redis 做缓存要注意什么" {
+			t.Fatalf("最相关的应为提问消息: %+v", hits)
+		}
+		hit := hits[0]
+		if hit.SessionTitle != "缓存方案" || len(hit.Before) != 2 || len(hit.After) != 2 || hit.Before[1].Content != "消息1" {
+			t.Errorf("上下文错误: %+v", hit)
+		}
+		if !strings.Contains(hit.Snippet, "<mark>") || !strings.HasPrefix(hit.Link, "/api/sessions/s1/messages?before=") {
+			t.Errorf("片段或链接错误: %s %s", hit.Snippet, hit.Link)
+		}
+		for _, h := range hits {
+			if h.Message.SessionID == "s2" {
+				t.Errorf("历史检索问答应被排除: %+v", h.Message)
+			}
+		}
+	})
+
+	t.Run("不排除时包含全部会话", func(t *testing.T) {
+		hits, _ := sm.SearchMessages("Redis", MessageSearchOptions{Context: -1})
+		if len(hits) != 3 || len(hits[0].Before) != 0 {
+			t.Errorf("期望 3 条不带上下文的结果: %+v", hits)
+		}
+		top, err := db.searchMessageFTS(ftsMatchExpr(SearchTokens("Redis")), MessageSearchOptions{}, 1)
+		if err != nil || len(top) != 1 || top[0].id != hits[0].Message.ID {
+			t.Errorf("候选应只保留分数最高的一条: %+v, %v", top, err)
+		}
+		if hits, _ := sm.SearchMessages("Redis", MessageSearchOptions{ExcludeIntents: []string{string(IntentHistory)}, Limit: 2}); len(hits) != 1 {
+			t.Errorf("排除的问答不应占用条数: %+v", hits)
+		}
+		hits, _ = sm.SearchMessages("Redis", MessageSearchOptions{SessionID: "s2", Limit: 1})
+		if len(hits) != 1 || hits[0].Message.SessionID != "s2" {
+			t.Errorf("会话过滤错误: %+v", hits)
+		}
+	})
+
+	t.Run("删除会话后不再命中", func(t *testing.T) {
+		sm.DeleteSession("s1")
+		hits, _ := sm.SearchMessages("击穿", MessageSearchOptions{})
+		if len(hits) != 0 {
+			t.Errorf("已删除的消息仍被搜到: %+v", hits)
+		}
+		var indexed int
+		db.db.QueryRow(`SELECT COUNT(*) FROM message_fts`).Scan(&indexed)
+		if n, _ := db.CountMessages("s2"); indexed != n {
+			t.Errorf("索引条数 %d 与消息条数 %d 不一致", indexed, n)
+		}
+	})
+}
+
+func TestMessageFTSRebuild(t *testing.T) {
+	tempDir := t.TempDir()
+	db, _ := NewDatabase(tempDir)
+	sm := NewSessionManagerWithDB(db)
+	sm.GetOrCreateSession("s1")
+	sm.AddMessage("s1", "user", "Kubernetes 部署")
+	db.db.Exec(`DELETE FROM message_fts`)
+	db.Close()
+
+	// 重新打开时发现索引缺失并重建
+	db, _ = NewDatabase(tempDir)
+	defer db.Close()
+	hits, err := db.SearchMessages("kubernetes", MessageSearchOptions{})
+	if err != nil || len(hits) != 1 {
+		t.Errorf("重建后应能搜到: %v %+v", err, hits)
+	}
+}
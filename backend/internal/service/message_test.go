@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestListMessages(t *testing.T) {
+	db, sm := setupTestDB(t)
+	sm.GetOrCreateSession("s1")
+	for i := 0; i < 5; i++ {
+		sm.AddMessage("s1", "user", fmt.Sprintf("消息%d", i))
+	}
+
+	t.Run("从最新一页向前翻页", func(t *testing.T) {
+		page, err := sm.ListMessages("s1", 0, 2)
+		if err != nil {
+			t.Fatalf("获取消息失败: %v", err)
+		}
+		if page.Total != 5 || !page.HasMore || len(page.Messages) != 2 ||
+			page.Messages[0].Content != "消息3" || page.Messages[1].Content != "消息4" {
+			t.Fatalf("第一页错误: %+v", page)
+		}
+
+		var contents []string
+		for before := page.NextBefore; before > 0; {
+			older, err := sm.ListMessages("s1", before, 2)
+			if err != nil {
+				t.Fatalf("获取消息失败: %v", err)
+			}
+			for _, m := range older.Messages {
+				contents = append(contents, m.Content)
+			}
+			before = older.NextBefore
+		}
+		if fmt.Sprint(contents) != "[消息1 消息2 消息0]" {
+			t.Errorf("翻页结果错误: %v", contents)
+		}
+	})
+
+	t.Run("会话不存在", func(t *testing.T) {
+		if _, err := sm.ListMessages("missing", 0, 10); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("期望 ErrSessionNotFound, 得到 %v", err)
+		}
+		err := db.AppendMessage(&SessionMessage{SessionID: "missing", Role: "user", Content: "x"})
+		if !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("向不存在的会话追加消息应失败, 得到 %v", err)
+		}
+	})
+
+	t.Run("保存消息元数据", func(t *testing.T) {
+		msg := &SessionMessage{SessionID: "s1", Role: "assistant", Content: "好的", Intent: "chat",
+			LatencyMs: 1200, InputTokens: 30, OutputTokens: 5}
+		if err := sm.AppendMessage(msg); err != nil || msg.ID == 0 {
+			t.Fatalf("追加消息失败: %v, id=%d", err, msg.ID)
+		}
+		got, err := db.GetMessage(msg.ID)
+		if err != nil {
+			t.Fatalf("读取消息失败: %v", err)
+		}
+		if got.Intent != "chat" || got.LatencyMs != 1200 || got.InputTokens != 30 || got.OutputTokens != 5 {
+			t.Errorf("元数据不一致: %+v", got)
+		}
+	})
+
+	t.Run("删除会话同时删除消息", func(t *testing.T) {
+		sm.DeleteSession("s1")
+		if n, _ := db.CountMessages("s1"); n != 0 {
+			t.Errorf("删除会话后仍有 %d 条消息", n)
+		}
+	})
+}
+
+func TestMigrateSessionMessages(t *testing.T) {
+	tempDir := t.TempDir()
+	db, _ := NewDatabase(tempDir)
+	_, err := db.db.Exec(`INSERT INTO sessions (id, messages, created_at, updated_at)
+		VALUES ('old', '[{"role":"user","content":"旧消息"},{"role":"assistant","content":"旧回复"}]', 1, 2)`)
+	if err != nil {
+		t.Fatalf("写入旧数据失败: %v", err)
+	}
+	db.Close()
+
+	// 重新打开时迁移，再次打开不应重复导入
+	for i := 0; i < 2; i++ {
+		db, err = NewDatabase(tempDir)
+		if err != nil {
+			t.Fatalf("打开数据库失败: %v", err)
+		}
+		messages, _ := db.GetSessionMessages("old", 0)
+		if len(messages) != 2 || messages[0].Content != "旧消息" || messages[1].Role != "assistant" {
+			t.Errorf("第 %d 次打开后消息错误: %+v", i+1, messages)
+		}
+		db.Close()
+	}
+}
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"strings"
 	"sync"
@@ -10,6 +13,7 @@ import (
 type RAGService struct {
 	embeddingClient *EmbeddingClient
 	vectorStore     VectorStore
+	graph           *KnowledgeGraph // 为 nil 时只做向量检索
 	mu              sync.RWMutex
 	enabled         bool
 }
@@ -24,48 +28,189 @@ func NewRAGService(apiKey string, vectorStore VectorStore) *RAGService {
 }
 
 // AddKnowledge 添加知识到向量库
-func (rag *RAGService) AddKnowledge(id, content string, metadata map[string]interface{}) error {
+func (rag *RAGService) AddKnowledge(ctx context.Context, id, content string, metadata map[string]interface{}) error {
 	if !rag.enabled {
 		return fmt.Errorf("RAG 服务未启用")
 	}
 
 	// 1. 生成向量
-	result, err := rag.embeddingClient.Embed(content)
+	result, err := rag.embeddingClient.Embed(ctx, content)
 	if err != nil {
 		return fmt.Errorf("生成向量失败: %w", err)
 	}
 
 	// 2. 存储到向量库
 	// Store content in metadata so we can retrieve it
+	metadata = rag.vectorMetadata(content, metadata, result.CreatedAt)
+
+	if err := rag.vectorStore.Add(id, result.Vector, metadata); err != nil {
+		return fmt.Errorf("存储向量失败: %w", err)
+	}
+
+	return nil
+}
+
+// KnowledgeDocument 待向量化的知识
+type KnowledgeDocument struct {
+	ID       string
+	Content  string
+	Metadata map[string]interface{}
+}
+
+// AddKnowledgeBatch 批量向量化并写入向量库
+// 按 EmbedBatchSize 分批调用 EmbedBatch，每批只持久化一次
+func (rag *RAGService) AddKnowledgeBatch(ctx context.Context, docs []KnowledgeDocument) error {
+	if !rag.enabled {
+		return fmt.Errorf("RAG 服务未启用")
+	}
+
+	for start := 0; start < len(docs); start += EmbedBatchSize {
+		end := start + EmbedBatchSize
+		if end > len(docs) {
+			end = len(docs)
+		}
+		batch := docs[start:end]
+
+		texts := make([]string, len(batch))
+		for i, doc := range batch {
+			texts[i] = doc.Content
+		}
+		results, err := rag.embeddingClient.EmbedBatch(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("批量生成向量失败: %w", err)
+		}
+
+		items := make([]VectorItem, len(batch))
+		for i, doc := range batch {
+			items[i] = VectorItem{
+				ID:        doc.ID,
+				Embedding: results[i].Vector,
+				Metadata:  rag.vectorMetadata(doc.Content, doc.Metadata, results[i].CreatedAt),
+			}
+		}
+		if err := rag.vectorStore.AddBatch(items); err != nil {
+			return fmt.Errorf("存储向量失败: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// NeedsEmbedding 判断向量条目是否需要（重新）生成：不存在、正文哈希变化或向量模型变化
+// 旧版本写入的条目没有哈希和模型字段，按正文和默认模型比较
+func (rag *RAGService) NeedsEmbedding(item *VectorItem, content string) bool {
+	if item == nil {
+		return true
+	}
+
+	hash, _ := item.Metadata["content_hash"].(string)
+	if hash == "" {
+		old, _ := item.Metadata["content"].(string)
+		hash = ContentHash(old)
+	}
+	model, _ := item.Metadata["embedding_model"].(string)
+	if model == "" {
+		model = EmbeddingModel
+	}
+
+	return hash != ContentHash(content) || model != rag.embeddingClient.Model()
+}
+
+// ContentHash 计算知识正文的哈希（判断是否需要重新向量化）
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// vectorMetadata 补充向量库元数据：正文、正文哈希、向量模型和创建时间
+func (rag *RAGService) vectorMetadata(content string, metadata map[string]interface{}, createdAt interface{}) map[string]interface{} {
 	if metadata == nil {
 		metadata = make(map[string]interface{})
 	}
 	metadata["content"] = content
-	metadata["created_at"] = result.CreatedAt
+	metadata["content_hash"] = ContentHash(content)
+	metadata["embedding_model"] = rag.embeddingClient.Model()
+	if _, ok := metadata["created_at"]; !ok {
+		metadata["created_at"] = createdAt
+	}
+	return metadata
+}
 
-	if err := rag.vectorStore.Add(id, result.Vector, metadata); err != nil {
+// BuildVector 生成向量条目但不写入向量库（正文哈希和模型未变时复用原向量）
+// 供需要和知识行在同一事务中写入的向量存储使用
+func (rag *RAGService) BuildVector(ctx context.Context, id, content string, metadata map[string]interface{}) (*VectorItem, error) {
+	if !rag.enabled {
+		return nil, fmt.Errorf("RAG 服务未启用")
+	}
+
+	item, err := rag.vectorStore.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("读取向量失败: %w", err)
+	}
+	if !rag.NeedsEmbedding(item, content) {
+		return &VectorItem{
+			ID:        id,
+			Embedding: item.Embedding,
+			Metadata:  rag.vectorMetadata(content, metadata, item.Metadata["created_at"]),
+		}, nil
+	}
+
+	result, err := rag.embeddingClient.Embed(ctx, content)
+	if err != nil {
+		return nil, fmt.Errorf("生成向量失败: %w", err)
+	}
+	return &VectorItem{
+		ID:        id,
+		Embedding: result.Vector,
+		Metadata:  rag.vectorMetadata(content, metadata, result.CreatedAt),
+	}, nil
+}
+
+// UpdateKnowledge 同步编辑后的知识到向量库
+// 正文哈希或向量模型变化（或向量库中不存在该条目）时重新生成向量，否则沿用原向量只更新元数据
+func (rag *RAGService) UpdateKnowledge(ctx context.Context, id, content string, metadata map[string]interface{}) error {
+	item, err := rag.BuildVector(ctx, id, content, metadata)
+	if err != nil {
+		return err
+	}
+
+	if err := rag.vectorStore.Add(id, item.Embedding, item.Metadata); err != nil {
 		return fmt.Errorf("存储向量失败: %w", err)
 	}
 
 	return nil
 }
 
-// Retrieve 检索相关知识
-func (rag *RAGService) Retrieve(query string, topK int) ([]*RetrievalResult, error) {
+// Retrieve 检索相关知识，filter 非空时只在满足元数据条件的知识中检索
+// 设置了知识图谱时，在 topK 条向量命中之外最多追加 GraphExpansionLimit 条图谱关联知识，
+// 每条结果的 Reason 说明入选原因
+func (rag *RAGService) Retrieve(ctx context.Context, query string, topK int, filter *VectorFilter) ([]*RetrievalResult, error) {
 	if !rag.enabled {
 		return []*RetrievalResult{}, nil
 	}
 
+	vector, results, err := rag.vectorSearch(ctx, query, topK, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		r.Reason = reasonVectorMatch
+	}
+	return rag.expandWithGraph(vector, results, filter), nil
+}
+
+// vectorSearch 纯向量检索，同时返回查询向量
+func (rag *RAGService) vectorSearch(ctx context.Context, query string, topK int, filter *VectorFilter) ([]float32, []*RetrievalResult, error) {
 	// 1. 生成查询向量
-	result, err := rag.embeddingClient.Embed(query)
+	result, err := rag.embeddingClient.Embed(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("生成查询向量失败: %w", err)
+		return nil, nil, fmt.Errorf("生成查询向量失败: %w", err)
 	}
 
 	// 2. 向量搜索
-	searchResults, err := rag.vectorStore.Search(result.Vector, topK)
+	searchResults, err := rag.vectorStore.Search(result.Vector, topK, filter)
 	if err != nil {
-		return nil, fmt.Errorf("向量搜索失败: %w", err)
+		return nil, nil, fmt.Errorf("向量搜索失败: %w", err)
 	}
 
 	// 3. 转换为检索结果
@@ -81,13 +226,13 @@ func (rag *RAGService) Retrieve(query string, topK int) ([]*RetrievalResult, err
 		}
 	}
 
-	return retrievalResults, nil
+	return result.Vector, retrievalResults, nil
 }
 
 // BuildContextWithRAG 构建 RAG 增强的上下文
-func (rag *RAGService) BuildContextWithRAG(query string, topK int) (string, error) {
+func (rag *RAGService) BuildContextWithRAG(ctx context.Context, query string, topK int) (string, error) {
 	// 检索相关知识
-	results, err := rag.Retrieve(query, topK)
+	results, err := rag.Retrieve(ctx, query, topK, nil)
 	if err != nil {
 		return "", err
 	}
@@ -103,6 +248,9 @@ func (rag *RAGService) BuildContextWithRAG(query string, topK int) (string, erro
 
 	for i, r := range results {
 		context.WriteString(fmt.Sprintf("[知识 %d] (相关度: %.2f)\n", i+1, r.Score))
+		if r.Reason != "" {
+			context.WriteString(fmt.Sprintf("入选原因: %s\n", r.Reason))
+		}
 		context.WriteString(r.Content)
 		context.WriteString("\n\n")
 	}
@@ -142,6 +290,7 @@ type RetrievalResult struct {
 	Content  string                 `json:"content"`
 	Score    float64                `json:"score"`
 	Metadata map[string]interface{} `json:"metadata"`
+	Reason   string                 `json:"reason,omitempty"` // 入选原因：语义相似或经由哪条实体关系关联
 }
 
 // ShouldUseRAG 判断是否应该使用 RAG（基于意图识别）
@@ -149,7 +298,10 @@ func (rag *RAGService) ShouldUseRAG(intent Intent, confidence float64) bool {
 	if !rag.enabled {
 		return false
 	}
+	return shouldRetrieve(intent, confidence)
+}
 
-	// 搜索意图和问答意图可以使用 RAG
+// shouldRetrieve 搜索意图和问答意图需要检索知识库
+func shouldRetrieve(intent Intent, confidence float64) bool {
 	return (intent == IntentSearch || intent == IntentQuestion) && confidence > 0.1
 }
\ No newline at end of file
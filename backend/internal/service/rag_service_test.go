@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestRAGService 创建使用假 Embedding 服务的 RAG 服务，返回 Embedding 调用次数
+func newTestRAGService(t *testing.T) (*RAGService, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		var req EmbeddingRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		var resp EmbeddingResponse
+		for i, text := range req.Input {
+			resp.Data = append(resp.Data, struct {
+				Object    string    `json:"object"`
+				Embedding []float32 `json:"embedding"`
+				Index     int       `json:"index"`
+			}{Object: "embedding", Embedding: []float32{float32(len(text)), 1}, Index: i})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	store, err := NewSimpleVectorStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建向量存储失败: %v", err)
+	}
+	rag := NewRAGService("test-key", store)
+	rag.embeddingClient.baseURL = server.URL
+	return rag, &calls
+}
+
+func TestRAGService_UpdateKnowledge(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("只改元数据不重新向量化", func(t *testing.T) {
+		rag, calls := newTestRAGService(t)
+		if err := rag.AddKnowledge(ctx, "kb_1", "原始内容", map[string]interface{}{"title": "旧"}); err != nil {
+			t.Fatalf("添加失败: %v", err)
+		}
+
+		if err := rag.UpdateKnowledge(ctx, "kb_1", "原始内容", map[string]interface{}{"title": "新"}); err != nil {
+			t.Fatalf("更新失败: %v", err)
+		}
+		if n := atomic.LoadInt32(calls); n != 1 {
+			t.Errorf("元数据变化不应调用 Embedding, 实际调用 %d 次", n)
+		}
+		item, _ := rag.vectorStore.Get("kb_1")
+		if item.Metadata["title"] != "新" || item.Metadata["content"] != "原始内容" {
+			t.Errorf("元数据未更新: %v", item.Metadata)
+		}
+	})
+
+	t.Run("正文变化重新向量化", func(t *testing.T) {
+		rag, calls := newTestRAGService(t)
+		rag.AddKnowledge(ctx, "kb_1", "原始内容", nil)
+
+		if err := rag.UpdateKnowledge(ctx, "kb_1", "修改后的内容", nil); err != nil {
+			t.Fatalf("更新失败: %v", err)
+		}
+		if n := atomic.LoadInt32(calls); n != 2 {
+			t.Errorf("正文变化应重新调用 Embedding, 实际调用 %d 次", n)
+		}
+		item, _ := rag.vectorStore.Get("kb_1")
+		if item.Metadata["content"] != "修改后的内容" {
+			t.Errorf("向量库内容未更新: %v", item.Metadata)
+		}
+	})
+
+	t.Run("向量库中不存在则新建", func(t *testing.T) {
+		rag, calls := newTestRAGService(t)
+
+		if err := rag.UpdateKnowledge(ctx, "kb_new", "内容", nil); err != nil {
+			t.Fatalf("更新失败: %v", err)
+		}
+		if item, _ := rag.vectorStore.Get("kb_new"); item == nil || atomic.LoadInt32(calls) != 1 {
+			t.Errorf("应重新向量化并写入")
+		}
+	})
+
+	t.Run("删除知识", func(t *testing.T) {
+		rag, _ := newTestRAGService(t)
+		rag.AddKnowledge(ctx, "kb_1", "内容", nil)
+
+		if !rag.DeleteKnowledge("kb_1") {
+			t.Fatalf("删除失败")
+		}
+		if item, _ := rag.vectorStore.Get("kb_1"); item != nil {
+			t.Errorf("向量应已删除")
+		}
+	})
+}
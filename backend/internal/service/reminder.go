@@ -0,0 +1,482 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 提醒状态
+const (
+	ReminderPending    = "pending"
+	ReminderDelivering = "delivering" // 已到时间，还没有任何渠道投递成功
+	ReminderFired      = "fired"
+	ReminderCancelled  = "cancelled"
+)
+
+// DefaultReminderUser 没有指定用户时的默认用户（当前为单用户部署）
+const DefaultReminderUser = "default"
+
+// reminderPollInterval 调度循环的最长休眠时间，防止系统时间跳变或休眠后错过提醒
+const reminderPollInterval = time.Minute
+
+// defaultReminderHour 只说了日期没说时刻的提醒在当天几点触发
+const defaultReminderHour = 9
+
+var (
+	// ErrReminderNotFound 提醒不存在
+	ErrReminderNotFound = errors.New("提醒不存在")
+	// ErrReminderFired 提醒已触发（不能再取消）
+	ErrReminderFired = errors.New("提醒已触发")
+	// ErrReminderNoRecipient 投递渠道当前没有接收方（如用户不在线），提醒保留等待重新投递
+	ErrReminderNoRecipient = errors.New("提醒暂无接收方")
+)
+
+// Reminder 定时提醒
+type Reminder struct {
+	ID        int64      `json:"id"`
+	UserID    string     `json:"user_id"`
+	SessionID string     `json:"session_id,omitempty"`
+	Text      string     `json:"text"`
+	RemindAt  time.Time  `json:"remind_at"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	FiredAt   *time.Time `json:"fired_at,omitempty"`
+}
+
+// ReminderFilter 提醒查询条件
+type ReminderFilter struct {
+	UserID string // 为空时不限用户
+	Status string // 为空时不限状态
+	Limit  int
+}
+
+// ReminderDeliverer 提醒投递渠道（WebSocket 推送、Webhook 等）
+type ReminderDeliverer interface {
+	DeliverReminder(ctx context.Context, r *Reminder) error
+}
+
+// ReminderDelivererFunc 函数适配器
+type ReminderDelivererFunc func(ctx context.Context, r *Reminder) error
+
+// DeliverReminder 实现 ReminderDeliverer 接口
+func (f ReminderDelivererFunc) DeliverReminder(ctx context.Context, r *Reminder) error {
+	return f(ctx, r)
+}
+
+// ReminderScheduler 定时提醒调度器
+// 提醒持久化在 reminders 表中，调度循环总是等待最近的一条；服务重启后，停机期间错过的提醒在启动时立即触发。
+// 到期的提醒先标记为 delivering（并发取消或重复触发时只有一方成功），至少一个渠道投递成功后才改为 fired。
+// 所有渠道都失败（如用户不在线又没有配置 Webhook）时保持 delivering：
+// 用户上线时通过 Redeliver 重新投递，调度器启动时也会重试一次。
+type ReminderScheduler struct {
+	db         *Database
+	deliverers []ReminderDeliverer
+	now        func() time.Time
+	wake       chan struct{}
+
+	mu         sync.Mutex
+	ctx        context.Context // 调度循环的 context，Stop 时取消进行中的投递
+	cancel     context.CancelFunc
+	done       chan struct{}
+	inflight   map[int64]bool // 正在投递的提醒，避免同一条被重复投递
+	deliveries sync.WaitGroup
+}
+
+// NewReminderScheduler 创建提醒调度器（需调用 Start 启动）
+func NewReminderScheduler(db *Database) *ReminderScheduler {
+	return &ReminderScheduler{
+		db:       db,
+		now:      time.Now,
+		wake:     make(chan struct{}, 1),
+		inflight: make(map[int64]bool),
+	}
+}
+
+// AddDeliverer 添加投递渠道（在 Start 之前调用）
+func (s *ReminderScheduler) AddDeliverer(d ReminderDeliverer) {
+	s.deliverers = append(s.deliverers, d)
+}
+
+// Start 在后台启动调度循环，已启动时不做任何事
+func (s *ReminderScheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.ctx, s.cancel = ctx, cancel
+	s.done = make(chan struct{})
+	go s.run(ctx, s.done)
+}
+
+// Stop 停止调度循环，并等待正在进行的投递结束
+func (s *ReminderScheduler) Stop() {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.ctx, s.cancel, s.done = nil, nil, nil
+	s.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	s.deliveries.Wait()
+}
+
+// Create 创建提醒，时间已过的提醒会立即触发
+func (s *ReminderScheduler) Create(userID, sessionID, text string, at time.Time) (*Reminder, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("提醒内容不能为空")
+	}
+	if userID == "" {
+		userID = DefaultReminderUser
+	}
+
+	result, err := s.db.db.Exec(`INSERT INTO reminders (user_id, session_id, text, remind_at, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, userID, sessionID, text, at.Unix(), ReminderPending, s.now().Unix())
+	if err != nil {
+		return nil, fmt.Errorf("保存提醒失败: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("保存提醒失败: %w", err)
+	}
+
+	s.notify()
+	return s.Get(id)
+}
+
+// Cancel 取消未触发的提醒（已取消的直接返回）；已触发（包括投递中）的提醒返回 ErrReminderFired
+func (s *ReminderScheduler) Cancel(id int64) (*Reminder, error) {
+	_, err := s.db.db.Exec(`UPDATE reminders SET status = ? WHERE id = ? AND status = ?`,
+		ReminderCancelled, id, ReminderPending)
+	if err != nil {
+		return nil, fmt.Errorf("取消提醒失败: %w", err)
+	}
+
+	r, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status == ReminderFired || r.Status == ReminderDelivering {
+		return nil, ErrReminderFired
+	}
+	s.notify()
+	return r, nil
+}
+
+// reminderColumns 提醒查询列
+const reminderColumns = `id, user_id, COALESCE(session_id, ''), text, remind_at, status, created_at, fired_at`
+
+func scanReminder(row rowScanner) (*Reminder, error) {
+	var r Reminder
+	var remindAt, createdAt int64
+	var firedAt sql.NullInt64
+	if err := row.Scan(&r.ID, &r.UserID, &r.SessionID, &r.Text, &remindAt, &r.Status, &createdAt, &firedAt); err != nil {
+		return nil, err
+	}
+	r.RemindAt = time.Unix(remindAt, 0)
+	r.CreatedAt = time.Unix(createdAt, 0)
+	if firedAt.Valid {
+		fired := time.Unix(firedAt.Int64, 0)
+		r.FiredAt = &fired
+	}
+	return &r, nil
+}
+
+// Get 获取提醒，不存在时返回 ErrReminderNotFound
+func (s *ReminderScheduler) Get(id int64) (*Reminder, error) {
+	r, err := scanReminder(s.db.db.QueryRow(`SELECT `+reminderColumns+` FROM reminders WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrReminderNotFound
+	}
+	return r, err
+}
+
+// List 按触发时间列出提醒
+func (s *ReminderScheduler) List(filter ReminderFilter) ([]Reminder, error) {
+	query := `SELECT ` + reminderColumns + ` FROM reminders WHERE 1 = 1`
+	var args []interface{}
+	if filter.UserID != "" {
+		query += ` AND user_id = ?`
+		args = append(args, filter.UserID)
+	}
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+	query += ` ORDER BY remind_at, id`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reminders := []Reminder{}
+	for rows.Next() {
+		r, err := scanReminder(rows)
+		if err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, *r)
+	}
+	return reminders, rows.Err()
+}
+
+// notify 唤醒调度循环重新计算下一次触发时间
+func (s *ReminderScheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *ReminderScheduler) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	// 上次运行时没有投递成功的提醒（Webhook 当时不可用等）先重试一次
+	if err := s.redeliver(ctx, ""); err != nil {
+		log.Printf("[Reminder] 重新投递提醒失败: %v", err)
+	}
+
+	for {
+		next, err := s.fireDue(ctx)
+		if err != nil {
+			log.Printf("[Reminder] 触发提醒失败: %v", err)
+		}
+
+		wait := reminderPollInterval
+		if !next.IsZero() {
+			if d := next.Sub(s.now()); d < wait {
+				wait = d
+			}
+		}
+		if wait < 0 {
+			wait = 0
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// fireDue 触发所有到期的提醒，返回下一条待触发提醒的时间（没有时为零值）
+func (s *ReminderScheduler) fireDue(ctx context.Context) (time.Time, error) {
+	now := s.now()
+	due, err := s.List(ReminderFilter{Status: ReminderPending})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for i := range due {
+		r := due[i]
+		if r.RemindAt.After(now) {
+			return r.RemindAt, nil
+		}
+
+		// 先标记再投递：并发取消或重复触发时只有一方能更新成功
+		result, err := s.db.db.Exec(`UPDATE reminders SET status = ?, fired_at = ? WHERE id = ? AND status = ?`,
+			ReminderDelivering, now.Unix(), r.ID, ReminderPending)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+		r.FiredAt = &now
+
+		log.Printf("[Reminder] 触发提醒 #%d (用户: %s): %s", r.ID, r.UserID, r.Text)
+		s.startDelivery(ctx, &r)
+	}
+	return time.Time{}, nil
+}
+
+// Redeliver 重新投递该用户已到时间但还没送达的提醒（用户上线时调用）
+// 调度器未启动时不做任何事
+func (s *ReminderScheduler) Redeliver(userID string) {
+	s.mu.Lock()
+	ctx := s.ctx
+	s.mu.Unlock()
+	if ctx == nil {
+		return
+	}
+	if err := s.redeliver(ctx, userID); err != nil {
+		log.Printf("[Reminder] 重新投递提醒失败: %v", err)
+	}
+}
+
+// redeliver 重新投递处于 delivering 状态的提醒，userID 为空时不限用户
+func (s *ReminderScheduler) redeliver(ctx context.Context, userID string) error {
+	undelivered, err := s.List(ReminderFilter{UserID: userID, Status: ReminderDelivering})
+	if err != nil {
+		return err
+	}
+	for i := range undelivered {
+		log.Printf("[Reminder] 重新投递提醒 #%d (用户: %s)", undelivered[i].ID, undelivered[i].UserID)
+		s.startDelivery(ctx, &undelivered[i])
+	}
+	return nil
+}
+
+// startDelivery 在后台投递提醒，同一条提醒同时只有一个投递
+func (s *ReminderScheduler) startDelivery(ctx context.Context, r *Reminder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inflight[r.ID] {
+		return
+	}
+	s.inflight[r.ID] = true
+	s.deliveries.Add(1)
+	go s.deliver(ctx, r)
+}
+
+// deliver 依次投递到所有渠道，单个渠道失败不影响其他渠道
+// 至少一个渠道成功时标记为 fired，否则保持 delivering 等待重新投递
+func (s *ReminderScheduler) deliver(ctx context.Context, r *Reminder) {
+	defer s.deliveries.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.inflight, r.ID)
+		s.mu.Unlock()
+	}()
+
+	// 投递内容中的状态是送达后的状态
+	r.Status = ReminderFired
+	delivered := false
+	for _, d := range s.deliverers {
+		err := d.DeliverReminder(ctx, r)
+		switch {
+		case err == nil:
+			delivered = true
+		case !errors.Is(err, ErrReminderNoRecipient):
+			log.Printf("[Reminder] 投递提醒 #%d 失败: %v", r.ID, err)
+		}
+	}
+	if !delivered {
+		log.Printf("[Reminder] 提醒 #%d 暂未送达，等待用户上线后重新投递", r.ID)
+		return
+	}
+
+	if _, err := s.db.db.Exec(`UPDATE reminders SET status = ? WHERE id = ? AND status = ?`,
+		ReminderFired, r.ID, ReminderDelivering); err != nil {
+		log.Printf("[Reminder] 更新提醒 #%d 状态失败: %v", r.ID, err)
+	}
+}
+
+// WebhookDeliverer 把触发的提醒 POST 到外部 URL
+// 请求体为 {"type": "reminder", "reminder": {...}}，非 2xx 响应会重试
+type WebhookDeliverer struct {
+	url      string
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+}
+
+// NewWebhookDeliverer 创建 Webhook 投递渠道
+func NewWebhookDeliverer(url string) *WebhookDeliverer {
+	return &WebhookDeliverer{
+		url: url,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		attempts: 3,
+		backoff:  2 * time.Second,
+	}
+}
+
+// DeliverReminder 实现 ReminderDeliverer 接口
+func (w *WebhookDeliverer) DeliverReminder(ctx context.Context, r *Reminder) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"type":     "reminder",
+		"reminder": r,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化提醒失败: %w", err)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < w.attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(w.backoff * time.Duration(attempt)):
+			}
+		}
+		if lastErr = w.post(ctx, body); lastErr == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("Webhook 投递失败: %w", lastErr)
+}
+
+func (w *WebhookDeliverer) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// reminderFillers 提醒内容中要去掉的口语词
+var reminderFillers = []string{"记得提醒我", "提醒一下我", "提醒我一下", "提醒一下", "提醒我", "请", "帮我", "到时候"}
+
+// ParseReminder 解析 "提醒我明天九点开会"、"两小时后提醒我给妈妈打电话" 这类语音指令，返回提醒内容和触发时间
+// 没说时刻的按当天 9 点提醒；没有时间信息或时间已过时 ok 为 false（text 仍返回解析出的内容）
+func ParseReminder(text string, now time.Time) (content string, at time.Time, ok bool) {
+	content = text
+	for _, filler := range reminderFillers {
+		content = strings.ReplaceAll(content, filler, "")
+	}
+	content = strings.Trim(stripDueDate(content), " ，,。.！!、：:的在")
+	if content == "" {
+		content = strings.TrimSpace(text)
+	}
+
+	due := ParseDueDate(text, now)
+	if due == nil {
+		return content, time.Time{}, false
+	}
+	at = due.Time
+	if due.AllDay {
+		at = at.Add(defaultReminderHour * time.Hour)
+	}
+	if !at.After(now) {
+		return content, time.Time{}, false
+	}
+	return content, at, true
+}
@@ -0,0 +1,365 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testClock 可在调度循环运行时安全拨动的时钟
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// reminderRecorder 记录投递的提醒
+type reminderRecorder struct {
+	ch chan Reminder
+}
+
+func newReminderRecorder() *reminderRecorder {
+	return &reminderRecorder{ch: make(chan Reminder, 10)}
+}
+
+func (r *reminderRecorder) DeliverReminder(ctx context.Context, reminder *Reminder) error {
+	r.ch <- *reminder
+	return nil
+}
+
+func (r *reminderRecorder) expect(t *testing.T, text string) Reminder {
+	t.Helper()
+	select {
+	case got := <-r.ch:
+		if got.Text != text {
+			t.Fatalf("期望提醒 %q, 实际 %q", text, got.Text)
+		}
+		return got
+	case <-time.After(2 * time.Second):
+		t.Fatalf("超时未收到提醒 %q", text)
+	}
+	return Reminder{}
+}
+
+func (r *reminderRecorder) expectNone(t *testing.T) {
+	t.Helper()
+	select {
+	case got := <-r.ch:
+		t.Fatalf("不应投递提醒, 实际收到 %+v", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// newTestReminderScheduler 创建使用临时数据库和可拨动时钟的调度器
+func newTestReminderScheduler(t *testing.T, db *Database, clock *testClock) (*ReminderScheduler, *reminderRecorder) {
+	s := NewReminderScheduler(db)
+	s.now = clock.Now
+	recorder := newReminderRecorder()
+	s.AddDeliverer(recorder)
+	t.Cleanup(s.Stop)
+	return s, recorder
+}
+
+func newTestReminderDB(t *testing.T) *Database {
+	db, err := NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestReminderScheduler(t *testing.T) {
+	start := time.Date(2026, 3, 11, 10, 0, 0, 0, time.Local)
+
+	t.Run("到时间触发并只触发一次", func(t *testing.T) {
+		clock := &testClock{now: start}
+		s, recorder := newTestReminderScheduler(t, newTestReminderDB(t), clock)
+		s.Start()
+
+		r, err := s.Create("alice", "sess_1", "开会", start.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("创建失败: %v", err)
+		}
+		if r.Status != ReminderPending || r.UserID != "alice" || r.SessionID != "sess_1" {
+			t.Errorf("创建结果错误: %+v", r)
+		}
+		recorder.expectNone(t)
+
+		clock.Advance(time.Hour)
+		s.notify()
+		fired := recorder.expect(t, "开会")
+		if fired.Status != ReminderFired || fired.FiredAt == nil {
+			t.Errorf("投递的提醒状态错误: %+v", fired)
+		}
+
+		s.notify()
+		recorder.expectNone(t)
+		if got, _ := s.Get(r.ID); got.Status != ReminderFired {
+			t.Errorf("状态应持久化为已触发: %+v", got)
+		}
+	})
+
+	t.Run("重启后补发停机期间错过的提醒", func(t *testing.T) {
+		db := newTestReminderDB(t)
+		clock := &testClock{now: start}
+		first, _ := newTestReminderScheduler(t, db, clock)
+		first.Start()
+		if _, err := first.Create("", "", "交房租", start.Add(30*time.Minute)); err != nil {
+			t.Fatalf("创建失败: %v", err)
+		}
+		if _, err := first.Create("", "", "明天的事", start.Add(24*time.Hour)); err != nil {
+			t.Fatalf("创建失败: %v", err)
+		}
+		first.Stop()
+
+		clock.Advance(time.Hour)
+		second, recorder := newTestReminderScheduler(t, db, clock)
+		second.Start()
+		got := recorder.expect(t, "交房租")
+		if got.UserID != DefaultReminderUser {
+			t.Errorf("未指定用户时应使用默认用户, 实际 %q", got.UserID)
+		}
+		recorder.expectNone(t)
+
+		pending, _ := second.List(ReminderFilter{Status: ReminderPending})
+		if len(pending) != 1 || pending[0].Text != "明天的事" {
+			t.Errorf("未到期的提醒应保持待触发: %+v", pending)
+		}
+	})
+
+	t.Run("取消提醒", func(t *testing.T) {
+		clock := &testClock{now: start}
+		s, recorder := newTestReminderScheduler(t, newTestReminderDB(t), clock)
+		s.Start()
+
+		r, _ := s.Create("alice", "", "开会", start.Add(time.Hour))
+		cancelled, err := s.Cancel(r.ID)
+		if err != nil || cancelled.Status != ReminderCancelled {
+			t.Fatalf("取消失败: %+v (err: %v)", cancelled, err)
+		}
+		if again, err := s.Cancel(r.ID); err != nil || again.Status != ReminderCancelled {
+			t.Errorf("重复取消应直接返回: %+v (err: %v)", again, err)
+		}
+
+		clock.Advance(2 * time.Hour)
+		s.notify()
+		recorder.expectNone(t)
+
+		fired, _ := s.Create("alice", "", "已过期", start)
+		recorder.expect(t, "已过期")
+		if _, err := s.Cancel(fired.ID); !errors.Is(err, ErrReminderFired) {
+			t.Errorf("取消已触发的提醒应返回 ErrReminderFired, 实际 %v", err)
+		}
+		if _, err := s.Cancel(999); !errors.Is(err, ErrReminderNotFound) {
+			t.Errorf("期望 ErrReminderNotFound, 实际 %v", err)
+		}
+	})
+
+	t.Run("没有渠道送达时保留并在重新投递后完成", func(t *testing.T) {
+		clock := &testClock{now: start}
+		s := NewReminderScheduler(newTestReminderDB(t))
+		s.now = clock.Now
+		var online atomic.Bool
+		delivered := make(chan Reminder, 10)
+		s.AddDeliverer(ReminderDelivererFunc(func(ctx context.Context, r *Reminder) error {
+			if !online.Load() {
+				return ErrReminderNoRecipient
+			}
+			delivered <- *r
+			return nil
+		}))
+		t.Cleanup(s.Stop)
+		s.Start()
+
+		r, _ := s.Create("alice", "", "吃药", start)
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			got, _ := s.Get(r.ID)
+			if got.Status == ReminderDelivering {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("未送达的提醒应保持 delivering, 实际 %s", got.Status)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if _, err := s.Cancel(r.ID); !errors.Is(err, ErrReminderFired) {
+			t.Errorf("已到期的提醒不能取消, 实际 %v", err)
+		}
+
+		online.Store(true)
+		s.Redeliver("alice")
+		select {
+		case got := <-delivered:
+			if got.Text != "吃药" || got.Status != ReminderFired {
+				t.Errorf("重新投递内容错误: %+v", got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("用户上线后应重新投递")
+		}
+
+		deadline = time.Now().Add(2 * time.Second)
+		for {
+			if got, _ := s.Get(r.ID); got.Status == ReminderFired {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("送达后应标记为已触发")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	t.Run("按用户和状态列出", func(t *testing.T) {
+		clock := &testClock{now: start}
+		s, _ := newTestReminderScheduler(t, newTestReminderDB(t), clock)
+		s.Create("alice", "", "晚的", start.Add(2*time.Hour))
+		s.Create("alice", "", "早的", start.Add(time.Hour))
+		s.Create("bob", "", "别人的", start.Add(time.Hour))
+
+		list, err := s.List(ReminderFilter{UserID: "alice", Status: ReminderPending})
+		if err != nil {
+			t.Fatalf("查询失败: %v", err)
+		}
+		if len(list) != 2 || list[0].Text != "早的" || list[1].Text != "晚的" {
+			t.Errorf("结果错误: %+v", list)
+		}
+		if _, err := s.Create("alice", "", "  ", start); err == nil {
+			t.Errorf("内容为空应返回错误")
+		}
+	})
+}
+
+func TestWebhookDeliverer(t *testing.T) {
+	var calls int32
+	var payload struct {
+		Type     string   `json:"type"`
+		Reminder Reminder `json:"reminder"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 第一次返回 500，验证重试
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type 错误: %s", r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("解析请求失败: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	t.Run("失败后重试直到成功", func(t *testing.T) {
+		webhook := NewWebhookDeliverer(server.URL)
+		webhook.backoff = time.Millisecond
+
+		err := webhook.DeliverReminder(context.Background(), &Reminder{ID: 7, UserID: "alice", Text: "开会"})
+		if err != nil {
+			t.Fatalf("投递失败: %v", err)
+		}
+		if calls != 2 {
+			t.Errorf("期望请求 2 次, 实际 %d", calls)
+		}
+		if payload.Type != "reminder" || payload.Reminder.ID != 7 || payload.Reminder.Text != "开会" {
+			t.Errorf("请求体错误: %+v", payload)
+		}
+	})
+
+	t.Run("重试次数用尽返回错误", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer failing.Close()
+
+		webhook := NewWebhookDeliverer(failing.URL)
+		webhook.backoff = time.Millisecond
+		if err := webhook.DeliverReminder(context.Background(), &Reminder{Text: "开会"}); err == nil {
+			t.Errorf("期望返回错误")
+		}
+	})
+
+	t.Run("调度器触发后投递到 Webhook", func(t *testing.T) {
+		received := make(chan Reminder, 1)
+		hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				Reminder Reminder `json:"reminder"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			received <- body.Reminder
+		}))
+		defer hook.Close()
+
+		s := NewReminderScheduler(newTestReminderDB(t))
+		s.AddDeliverer(NewWebhookDeliverer(hook.URL))
+		s.Start()
+		defer s.Stop()
+
+		s.Create("alice", "", "喝水", time.Now().Add(-time.Second))
+		select {
+		case got := <-received:
+			if got.Text != "喝水" || got.Status != ReminderFired {
+				t.Errorf("投递内容错误: %+v", got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("超时未收到 Webhook")
+		}
+	})
+}
+
+func TestParseReminder(t *testing.T) {
+	// 2026-03-11 是周三
+	now := time.Date(2026, 3, 11, 10, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		text    string
+		content string
+		at      time.Time
+	}{
+		{"提醒我明天九点开会", "开会", time.Date(2026, 3, 12, 9, 0, 0, 0, time.Local)},
+		{"两小时后提醒我给妈妈打电话", "给妈妈打电话", now.Add(2 * time.Hour)},
+		{"明天下午三点提醒我去接孩子", "去接孩子", time.Date(2026, 3, 12, 15, 0, 0, 0, time.Local)},
+		{"提醒我下周三上午10点半和客户开会", "和客户开会", time.Date(2026, 3, 18, 10, 30, 0, 0, time.Local)},
+		{"周五提醒我交周报", "交周报", time.Date(2026, 3, 13, 9, 0, 0, 0, time.Local)}, // 没说时刻按 9 点
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			content, at, ok := ParseReminder(tt.text, now)
+			if !ok {
+				t.Fatalf("未识别出提醒时间")
+			}
+			if content != tt.content || !at.Equal(tt.at) {
+				t.Errorf("期望 %q @ %v, 实际 %q @ %v", tt.content, tt.at, content, at)
+			}
+		})
+	}
+
+	t.Run("没有时间或时间已过", func(t *testing.T) {
+		if content, _, ok := ParseReminder("提醒我买牛奶", now); ok || content != "买牛奶" {
+			t.Errorf("没有时间时 ok 应为 false 并返回内容, 实际 %q %v", content, ok)
+		}
+		if _, _, ok := ParseReminder("今天提醒我交房租", now); ok {
+			t.Errorf("今天 9 点已过，ok 应为 false")
+		}
+	})
+}
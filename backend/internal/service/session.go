@@ -1,128 +1,283 @@
 package service
 
 import (
+	"errors"
 	"fmt"
-	"sync"
 	"time"
 )
 
 // Session 对话会话
 type Session struct {
-	ID        string         `json:"id"`
-	Messages  []Message      `json:"messages"`
-	Summary   *SessionSummary `json:"summary,omitempty"`   // 会话摘要
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
+	ID           string          `json:"id"`
+	Title        string          `json:"title"` // 前几轮对话后自动生成，可由用户修改
+	Tags         []string        `json:"tags"`
+	Pinned       bool            `json:"pinned"`
+	Archived     bool            `json:"archived"`
+	Messages     []Message       `json:"messages,omitempty"`      // 当前上下文中的消息（会话列表中不返回）
+	MessageCount int             `json:"message_count"`           // 消息总数，含清空对话前的存档
+	Summary      *SessionSummary `json:"summary,omitempty"`       // 会话摘要
+	ContextStart int64           `json:"context_start,omitempty"` // 清空对话时最后一条消息的 ID，不大于它的消息不再进入上下文
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
 }
 
+// DefaultHistoryMessages 未启用上下文压缩时发给 LLM 的最近消息条数
+// 消息本身全部保留在 messages 表中，这里只限制上下文窗口
+const DefaultHistoryMessages = 20
+
 // SessionManager 会话管理器
+// 活跃会话缓存在内存 LRU 中（写穿到数据库），每个会话一把读写锁，不同会话之间互不阻塞
 type SessionManager struct {
-	db *Database
-	mu sync.RWMutex
+	db    *Database
+	cache *sessionCache
 }
 
 // NewSessionManager 创建内存版会话管理器（仅用于测试，无持久化）
 func NewSessionManager() *SessionManager {
-	return &SessionManager{}
+	return &SessionManager{
+		cache: newSessionCache(DefaultSessionCacheSize),
+	}
 }
 
 // NewSessionManagerWithDB 创建带数据库持久化的会话管理器
 func NewSessionManagerWithDB(db *Database) *SessionManager {
 	return &SessionManager{
-		db: db,
+		db:    db,
+		cache: newSessionCache(DefaultSessionCacheSize),
 	}
 }
 
+// SetCacheSize 设置内存中缓存的会话数（0 表示不缓存，每次都从数据库读取）
+func (sm *SessionManager) SetCacheSize(size int) {
+	sm.cache.setCapacity(size)
+}
+
+// readSession 在会话读锁内执行 fn，needMessages 为 true 时保证已加载全部消息
+// 缓存未命中时升级为写锁从数据库加载，会话不存在返回 ErrSessionNotFound
+func (sm *SessionManager) readSession(sessionID string, needMessages bool, fn func(e *sessionEntry) error) error {
+	e := sm.cache.acquire(sessionID)
+	defer sm.cache.release(e)
+
+	e.mu.RLock()
+	if e.loaded && (!needMessages || e.messages != nil) {
+		defer e.mu.RUnlock()
+		return fn(e)
+	}
+	e.mu.RUnlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.load(sm.db); err != nil {
+		return err
+	}
+	if !e.loaded {
+		return ErrSessionNotFound
+	}
+	if needMessages {
+		if err := e.loadMessages(sm.db); err != nil {
+			return err
+		}
+	}
+	return fn(e)
+}
+
+// writeSession 在会话写锁内执行 fn，会话不存在返回 ErrSessionNotFound
+func (sm *SessionManager) writeSession(sessionID string, fn func(e *sessionEntry) error) error {
+	e := sm.cache.acquire(sessionID)
+	defer sm.cache.release(e)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.load(sm.db); err != nil {
+		return err
+	}
+	if !e.loaded {
+		return ErrSessionNotFound
+	}
+	return fn(e)
+}
+
 // GetOrCreateSession 获取或创建会话
-func (sm *SessionManager) GetOrCreateSession(sessionID string) *Session {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+// 只有确认会话不存在时才创建；读取失败时返回错误，不能用空会话覆盖已有的标题、标签和摘要
+func (sm *SessionManager) GetOrCreateSession(sessionID string) (*Session, error) {
+	if sm.db == nil {
+		return &Session{ID: sessionID, Messages: []Message{}, CreatedAt: time.Now(), UpdatedAt: time.Now()}, nil
+	}
 
-	if sm.db != nil {
-		session, err := sm.db.GetSession(sessionID)
-		if err == nil && session != nil {
-			return session
+	e := sm.cache.acquire(sessionID)
+	defer sm.cache.release(e)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.load(sm.db); err != nil {
+		return nil, fmt.Errorf("加载会话失败: %w", err)
+	}
+	if e.loaded {
+		if err := e.loadMessages(sm.db); err != nil {
+			return nil, fmt.Errorf("加载会话消息失败: %w", err)
 		}
+		messages, err := e.contextMessages(sm.db)
+		if err != nil {
+			return nil, fmt.Errorf("加载会话消息失败: %w", err)
+		}
+		session := e.snapshot()
+		session.Messages = toChatMessages(messages)
+		return session, nil
 	}
 
 	// 创建新会话
 	session := &Session{
 		ID:        sessionID,
+		Tags:      []string{},
 		Messages:  []Message{},
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
-
-	if sm.db != nil {
-		sm.db.SaveSession(session)
+	if err := sm.db.SaveSession(session); err != nil {
+		return nil, fmt.Errorf("创建会话失败: %w", err)
 	}
 
+	info := *session
+	info.Messages = nil
+	e.session = &info
+	e.messages = []SessionMessage{}
+	e.loaded = true
+
 	fmt.Printf("创建新会话: %s\n", sessionID)
-	return session
+	return session, nil
 }
 
-// AddMessage 添加消息到会话
+// AddMessage 添加消息到会话（会话不存在时忽略）
 func (sm *SessionManager) AddMessage(sessionID string, role, content string) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-
-	var session *Session
-	var err error
+	sm.AppendMessage(&SessionMessage{
+		SessionID: sessionID,
+		Role:      role,
+		Content:   content,
+	})
+}
 
-	if sm.db != nil {
-		session, err = sm.db.GetSession(sessionID)
+// AppendMessage 添加带元数据（意图、耗时、token 用量等）的消息，写入后设置 msg.ID
+// 会话不存在时返回 ErrSessionNotFound
+func (sm *SessionManager) AppendMessage(msg *SessionMessage) error {
+	if sm.db == nil {
+		return nil
 	}
 
-	if err == nil && session != nil {
-		session.Messages = append(session.Messages, Message{
-			Role:    role,
-			Content: content,
-		})
-		session.UpdatedAt = time.Now()
-
-		// 限制历史消息数量（保留最近 20 条）
-		if len(session.Messages) > 20 {
-			session.Messages = session.Messages[len(session.Messages)-20:]
+	return sm.writeSession(msg.SessionID, func(e *sessionEntry) error {
+		if err := sm.db.AppendMessage(msg); err != nil {
+			if errors.Is(err, ErrSessionNotFound) {
+				e.reset()
+			}
+			return err
 		}
 
-		if sm.db != nil {
-			sm.db.SaveSession(session)
+		e.session.UpdatedAt = msg.CreatedAt
+		e.session.MessageCount++
+		e.appendMessage(*msg)
+		fmt.Printf("会话 %s 新增消息 #%d (已保存)\n", msg.SessionID, msg.ID)
+		return nil
+	})
+}
+
+// GetMessages 获取会话当前上下文的全部消息（清空对话前的消息只保留在存档中，见 ListMessages）
+func (sm *SessionManager) GetMessages(sessionID string) []Message {
+	messages := []Message{}
+	if sm.db == nil {
+		return messages
+	}
+
+	sm.readSession(sessionID, true, func(e *sessionEntry) error {
+		current, err := e.contextMessages(sm.db)
+		if err != nil {
+			return err
 		}
-		fmt.Printf("会话 %s 消息数: %d (已保存)\n", sessionID, len(session.Messages))
+		messages = toChatMessages(current)
+		return nil
+	})
+	return messages
+}
+
+// GetRecentMessages 获取会话最近 limit 条消息，作为 LLM 的上下文窗口
+func (sm *SessionManager) GetRecentMessages(sessionID string, limit int) []Message {
+	messages := []Message{}
+	if sm.db == nil {
+		return messages
 	}
+
+	sm.readSession(sessionID, false, func(e *sessionEntry) error {
+		// 与 Database.ListMessages 的 limit 规则一致
+		if limit <= 0 {
+			limit = DefaultMessagePageSize
+		}
+		limit = min(limit, MaxMessagePageSize)
+
+		if e.messages == nil || (e.truncated && limit > len(e.messages)) {
+			recent, err := sm.db.GetRecentMessages(sessionID, e.session.ContextStart, limit)
+			if err != nil {
+				return err
+			}
+			messages = toChatMessages(recent)
+			return nil
+		}
+		messages = toChatMessages(e.messages[max(len(e.messages)-limit, 0):])
+		return nil
+	})
+	return messages
 }
 
-// GetMessages 获取会话消息历史
-func (sm *SessionManager) GetMessages(sessionID string) []Message {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
+// ListMessages 分页获取会话消息（见 Database.ListMessages），会话不存在时返回 ErrSessionNotFound
+func (sm *SessionManager) ListMessages(sessionID string, before int64, limit int) (*MessagePage, error) {
+	if sm.db == nil {
+		return &MessagePage{Messages: []SessionMessage{}}, nil
+	}
 
-	if sm.db != nil {
-		session, err := sm.db.GetSession(sessionID)
-		if err == nil && session != nil {
-			return session.Messages
+	var page *MessagePage
+	err := sm.readSession(sessionID, false, func(e *sessionEntry) error {
+		var err error
+		page, err = sm.db.ListMessages(sessionID, before, limit)
+		if err != nil {
+			return err
 		}
+		page.Total = e.session.MessageCount
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return []Message{}
+	return page, nil
 }
 
-// GetSession 获取完整会话
-func (sm *SessionManager) GetSession(sessionID string) *Session {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
+// toChatMessages 转换为 LLM 请求使用的消息
+func toChatMessages(messages []SessionMessage) []Message {
+	result := make([]Message, len(messages))
+	for i, m := range messages {
+		result[i] = Message{Role: m.Role, Content: m.Content}
+	}
+	return result
+}
 
-	if sm.db != nil {
-		session, _ := sm.db.GetSession(sessionID)
-		return session
+// GetSession 获取会话及其当前上下文中的消息
+func (sm *SessionManager) GetSession(sessionID string) *Session {
+	if sm.db == nil {
+		return nil
 	}
-	return nil
+
+	var session *Session
+	sm.readSession(sessionID, true, func(e *sessionEntry) error {
+		messages, err := e.contextMessages(sm.db)
+		if err != nil {
+			return err
+		}
+		session = e.snapshot()
+		session.Messages = toChatMessages(messages)
+		return nil
+	})
+	return session
 }
 
 // GetAllSessions 获取所有会话
 func (sm *SessionManager) GetAllSessions() []Session {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
 	if sm.db != nil {
 		sessions, _ := sm.db.GetAllSessions()
 		return sessions
@@ -132,9 +287,6 @@ func (sm *SessionManager) GetAllSessions() []Session {
 
 // GetSessionCount 获取会话总数
 func (sm *SessionManager) GetSessionCount() int {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
 	if sm.db != nil {
 		sessions, _ := sm.db.GetAllSessions()
 		return len(sessions)
@@ -142,60 +294,85 @@ func (sm *SessionManager) GetSessionCount() int {
 	return 0
 }
 
-// ClearSession 清空会话
+// ClearSession 清空对话上下文：之后的 LLM 上下文从下一条消息开始，摘要一并清空
+// 已有消息仍保留在 messages 表中，可通过 ListMessages 和消息搜索查看
 func (sm *SessionManager) ClearSession(sessionID string) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+	if sm.db == nil {
+		return
+	}
 
-	if sm.db != nil {
-		session, _ := sm.db.GetSession(sessionID)
-		if session != nil {
-			session.Messages = []Message{}
-			session.UpdatedAt = time.Now()
-			sm.db.SaveSession(session)
-			fmt.Printf("清空会话: %s\n", sessionID)
+	sm.writeSession(sessionID, func(e *sessionEntry) error {
+		lastID, err := sm.db.LastMessageID(sessionID)
+		if err != nil {
+			return err
 		}
-	}
+
+		session := e.snapshot()
+		session.Summary = nil
+		session.ContextStart = lastID
+		session.UpdatedAt = time.Now()
+		if err := sm.db.SaveSession(session); err != nil {
+			return err
+		}
+
+		e.session = session
+		e.messages = []SessionMessage{}
+		e.truncated = false
+		fmt.Printf("清空会话: %s\n", sessionID)
+		return nil
+	})
 }
 
 // DeleteSession 删除会话
 func (sm *SessionManager) DeleteSession(sessionID string) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-
-	if sm.db != nil {
-		sm.db.DeleteSession(sessionID)
-		fmt.Printf("删除会话: %s\n", sessionID)
+	if sm.db == nil {
+		return
 	}
-}
 
+	e := sm.cache.acquire(sessionID)
+	defer sm.cache.release(e)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	sm.db.DeleteSession(sessionID)
+	e.reset()
+	fmt.Printf("删除会话: %s\n", sessionID)
+}
 
 // UpdateSummary 更新会话摘要
 func (sm *SessionManager) UpdateSummary(sessionID string, summary *SessionSummary) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+	if sm.db == nil {
+		return
+	}
 
-	if sm.db != nil {
-		session, _ := sm.db.GetSession(sessionID)
-		if session != nil {
-			session.Summary = summary
-			session.UpdatedAt = time.Now()
-			sm.db.SaveSession(session)
-			fmt.Printf("更新摘要: %s\n", sessionID)
+	sm.writeSession(sessionID, func(e *sessionEntry) error {
+		session := e.snapshot()
+		session.Summary = summary
+		session.UpdatedAt = time.Now()
+		if err := sm.db.SaveSession(session); err != nil {
+			return err
 		}
-	}
+
+		e.session = session
+		fmt.Printf("更新摘要: %s\n", sessionID)
+		return nil
+	})
 }
 
 // GetSummary 获取会话摘要
 func (sm *SessionManager) GetSummary(sessionID string) *SessionSummary {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
+	if sm.db == nil {
+		return nil
+	}
 
-	if sm.db != nil {
-		session, _ := sm.db.GetSession(sessionID)
-		if session != nil {
-			return session.Summary
+	var summary *SessionSummary
+	sm.readSession(sessionID, false, func(e *sessionEntry) error {
+		if e.session.Summary != nil {
+			copied := *e.session.Summary
+			summary = &copied
 		}
-	}
-	return nil
-}
\ No newline at end of file
+		return nil
+	})
+	return summary
+}
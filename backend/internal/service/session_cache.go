@@ -0,0 +1,171 @@
+package service
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultSessionCacheSize 内存中缓存的活跃会话数
+const DefaultSessionCacheSize = 256
+
+// maxCachedMessages 每个会话在内存中最多缓存的上下文消息数（只保留最近的，足够覆盖 LLM 的上下文窗口）
+// 长会话更早的消息在需要完整上下文时再从数据库读取，缓存占用的内存不随会话长度增长
+const maxCachedMessages = MaxMessagePageSize
+
+// sessionEntry 一个会话的缓存项
+// mu 只保护本会话，不同会话的读写互不阻塞；写操作先写数据库，成功后再更新缓存
+type sessionEntry struct {
+	id string
+	mu sync.RWMutex
+
+	loaded    bool             // session 是否已从数据库加载
+	session   *Session         // 会话信息（不含消息），会话不存在时为 nil
+	messages  []SessionMessage // 当前上下文（ID 大于 session.ContextStart）最近的至多 maxCachedMessages 条消息，nil 表示尚未加载
+	truncated bool             // messages 之前还有未缓存的上下文消息
+
+	refs int // 正在使用该项的调用数，由 sessionCache.mu 保护，大于 0 时不会被淘汰
+}
+
+// sessionCache 活跃会话的 LRU 缓存
+type sessionCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // 最近使用的在前
+}
+
+func newSessionCache(capacity int) *sessionCache {
+	return &sessionCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// acquire 获取会话的缓存项（不存在时创建空项），用完必须调用 release
+func (c *sessionCache) acquire(id string) *sessionEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[id]; ok {
+		c.order.MoveToFront(elem)
+		entry := elem.Value.(*sessionEntry)
+		entry.refs++
+		return entry
+	}
+
+	entry := &sessionEntry{id: id, refs: 1}
+	c.entries[id] = c.order.PushFront(entry)
+	c.evict()
+	return entry
+}
+
+// release 释放 acquire 获取的缓存项
+func (c *sessionCache) release(entry *sessionEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.refs--
+	c.evict()
+}
+
+// evict 超出容量时从最久未使用的一端淘汰空闲项；正在使用的项保留，
+// 保证同一会话任何时刻只有一个缓存项（也就是一把锁）
+func (c *sessionCache) evict() {
+	for elem := c.order.Back(); elem != nil && c.order.Len() > c.capacity; {
+		prev := elem.Prev()
+		if entry := elem.Value.(*sessionEntry); entry.refs == 0 {
+			c.order.Remove(elem)
+			delete(c.entries, entry.id)
+		}
+		elem = prev
+	}
+}
+
+// setCapacity 修改容量并立即淘汰多出的项
+func (c *sessionCache) setCapacity(capacity int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.capacity = capacity
+	c.evict()
+}
+
+// len 当前缓存的会话数
+func (c *sessionCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// load 首次使用时从数据库加载会话信息，调用方需持有 e.mu 写锁
+func (e *sessionEntry) load(db *Database) error {
+	if e.loaded {
+		return nil
+	}
+	session, err := db.GetSessionInfo(e.id)
+	if err != nil {
+		return err
+	}
+	// 不缓存 "不存在"，会话可能随后被创建
+	if session != nil {
+		e.session = session
+		e.loaded = true
+	}
+	return nil
+}
+
+// loadMessages 首次读取上下文消息时从数据库加载，调用方需持有 e.mu 写锁且会话已加载
+func (e *sessionEntry) loadMessages(db *Database) error {
+	if e.messages != nil {
+		return nil
+	}
+	messages, err := db.GetRecentMessages(e.id, e.session.ContextStart, maxCachedMessages)
+	if err != nil {
+		return err
+	}
+	e.messages = messages
+	e.truncated = len(messages) == maxCachedMessages
+	return nil
+}
+
+// appendMessage 追加一条消息到已加载的缓存，超出 maxCachedMessages 时丢弃最早的一条，调用方需持有 e.mu 写锁
+func (e *sessionEntry) appendMessage(msg SessionMessage) {
+	if e.messages == nil {
+		return
+	}
+	if len(e.messages) >= maxCachedMessages {
+		n := copy(e.messages, e.messages[len(e.messages)-maxCachedMessages+1:])
+		e.messages = e.messages[:n]
+		e.truncated = true
+	}
+	e.messages = append(e.messages, msg)
+}
+
+// contextMessages 返回当前上下文的全部消息：缓存完整时直接使用，否则从数据库读取
+// 调用方需持有 e.mu 读锁且消息已加载
+func (e *sessionEntry) contextMessages(db *Database) ([]SessionMessage, error) {
+	if !e.truncated {
+		return e.messages, nil
+	}
+	return db.GetSessionMessages(e.id, e.session.ContextStart)
+}
+
+// reset 丢弃缓存的内容，下次使用时重新从数据库加载
+func (e *sessionEntry) reset() {
+	e.loaded = false
+	e.session = nil
+	e.messages = nil
+	e.truncated = false
+}
+
+// snapshot 返回会话信息的副本，调用方需持有 e.mu 读锁
+func (e *sessionEntry) snapshot() *Session {
+	session := *e.session
+	session.Tags = append([]string{}, e.session.Tags...)
+	if e.session.Summary != nil {
+		summary := *e.session.Summary
+		session.Summary = &summary
+	}
+	return &session
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultSessionPageSize 会话列表每页默认条数
+	DefaultSessionPageSize = 20
+	// MaxSessionPageSize 会话列表每页最大条数
+	MaxSessionPageSize = 100
+)
+
+// sessionSortColumns 会话列表支持的排序字段
+var sessionSortColumns = map[string]string{
+	"updated_at":    "s.updated_at",
+	"created_at":    "s.created_at",
+	"title":         "COALESCE(s.title, '')",
+	"message_count": "message_count",
+}
+
+// IsValidSessionSort 是否为支持的排序字段（updated_at / created_at / title / message_count）
+func IsValidSessionSort(sort string) bool {
+	_, ok := sessionSortColumns[sort]
+	return ok
+}
+
+// SessionFilter 会话列表的过滤、排序与分页条件，零值字段不参与过滤
+type SessionFilter struct {
+	Tags     []string   // 须包含全部标签（不区分大小写）
+	Pinned   *bool      // 只返回置顶 / 未置顶的会话
+	Archived *bool      // 只返回已归档 / 未归档的会话
+	Since    *time.Time // 最后活动时间 >= Since
+	Until    *time.Time // 最后活动时间 < Until
+	Sort     string     // 排序字段，默认 updated_at；置顶的会话总是排在前面
+	Asc      bool       // 默认倒序
+	Limit    int        // 默认 DefaultSessionPageSize，最多 MaxSessionPageSize
+	Offset   int
+}
+
+// SessionPage 一页会话（不含消息）
+type SessionPage struct {
+	Sessions []Session `json:"sessions"`
+	Total    int       `json:"total"` // 满足过滤条件的会话总数
+	Limit    int       `json:"limit"`
+	Offset   int       `json:"offset"`
+}
+
+// ListSessions 按条件分页列出会话，只返回元数据和消息条数，不含消息内容
+func (d *Database) ListSessions(filter SessionFilter) (*SessionPage, error) {
+	var where []string
+	var args []interface{}
+	for _, tag := range filter.Tags {
+		where = append(where, `EXISTS (SELECT 1 FROM json_each(COALESCE(s.tags, '[]')) WHERE LOWER(value) = LOWER(?))`)
+		args = append(args, tag)
+	}
+	if filter.Pinned != nil {
+		where = append(where, `COALESCE(s.pinned, 0) = ?`)
+		args = append(args, *filter.Pinned)
+	}
+	if filter.Archived != nil {
+		where = append(where, `COALESCE(s.archived, 0) = ?`)
+		args = append(args, *filter.Archived)
+	}
+	if filter.Since != nil {
+		where = append(where, `s.updated_at >= ?`)
+		args = append(args, filter.Since.Unix())
+	}
+	if filter.Until != nil {
+		where = append(where, `s.updated_at < ?`)
+		args = append(args, filter.Until.Unix())
+	}
+	whereSQL := ""
+	if len(where) > 0 {
+		whereSQL = ` WHERE ` + strings.Join(where, ` AND `)
+	}
+
+	page := &SessionPage{Sessions: []Session{}, Limit: filter.Limit, Offset: max(filter.Offset, 0)}
+	if page.Limit <= 0 {
+		page.Limit = DefaultSessionPageSize
+	}
+	page.Limit = min(page.Limit, MaxSessionPageSize)
+
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM sessions s`+whereSQL, args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("统计会话失败: %w", err)
+	}
+
+	sortColumn, ok := sessionSortColumns[filter.Sort]
+	if !ok {
+		sortColumn = sessionSortColumns["updated_at"]
+	}
+	order := "DESC"
+	if filter.Asc {
+		order = "ASC"
+	}
+	query := `SELECT ` + sessionColumns + ` FROM sessions s` + whereSQL +
+		` ORDER BY COALESCE(s.pinned, 0) DESC, ` + sortColumn + ` ` + order + `, s.id LIMIT ? OFFSET ?`
+
+	rows, err := d.db.Query(query, append(args, page.Limit, page.Offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("查询会话失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Sessions = append(page.Sessions, *session)
+	}
+	return page, rows.Err()
+}
+
+// SessionUpdate 会话元数据修改，nil 字段保持不变
+type SessionUpdate struct {
+	Title    *string   `json:"title"`
+	Tags     *[]string `json:"tags"`
+	Pinned   *bool     `json:"pinned"`
+	Archived *bool     `json:"archived"`
+}
+
+// ListSessions 按条件分页列出会话（见 Database.ListSessions）
+func (sm *SessionManager) ListSessions(filter SessionFilter) (*SessionPage, error) {
+	if sm.db == nil {
+		return &SessionPage{Sessions: []Session{}}, nil
+	}
+	return sm.db.ListSessions(filter)
+}
+
+// UpdateSession 修改会话的标题、标签、置顶和归档状态，不改变最后活动时间
+// 会话不存在时返回 ErrSessionNotFound
+func (sm *SessionManager) UpdateSession(sessionID string, update SessionUpdate) (*Session, error) {
+	if sm.db == nil {
+		return nil, ErrSessionNotFound
+	}
+
+	var updated *Session
+	err := sm.writeSession(sessionID, func(e *sessionEntry) error {
+		session := e.snapshot()
+		if update.Title != nil {
+			session.Title = strings.TrimSpace(*update.Title)
+		}
+		if update.Tags != nil {
+			session.Tags = normalizeTags(*update.Tags)
+		}
+		if update.Pinned != nil {
+			session.Pinned = *update.Pinned
+		}
+		if update.Archived != nil {
+			session.Archived = *update.Archived
+		}
+		if err := sm.db.SaveSession(session); err != nil {
+			return fmt.Errorf("保存会话失败: %w", err)
+		}
+
+		e.session = session
+		updated = e.snapshot()
+		return nil
+	})
+	return updated, err
+}
+
+// SetTitleIfEmpty 会话还没有标题时设置标题（不覆盖用户修改过的标题），返回是否已设置
+func (sm *SessionManager) SetTitleIfEmpty(sessionID, title string) bool {
+	title = strings.TrimSpace(title)
+	if sm.db == nil || title == "" {
+		return false
+	}
+
+	set := false
+	sm.writeSession(sessionID, func(e *sessionEntry) error {
+		if e.session.Title != "" {
+			return nil
+		}
+		session := e.snapshot()
+		session.Title = title
+		if err := sm.db.SaveSession(session); err != nil {
+			return err
+		}
+		e.session = session
+		set = true
+		return nil
+	})
+	return set
+}
+
+// normalizeTags 去掉首尾空白、空标签和重复标签（不区分大小写）
+func normalizeTags(tags []string) []string {
+	result := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !containsFold(result, tag) {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// seedSessions 创建会话，最后活动时间依次为 base、base+1 天…
+func seedSessions(t *testing.T, sm *SessionManager, base time.Time, ids ...string) {
+	for i, id := range ids {
+		sm.GetOrCreateSession(id)
+		err := sm.AppendMessage(&SessionMessage{SessionID: id, Role: "user", Content: "你好", CreatedAt: base.AddDate(0, 0, i)})
+		if err != nil {
+			t.Fatalf("写入消息失败: %v", err)
+		}
+	}
+}
+
+func sessionIDs(sessions []Session) []string {
+	ids := make([]string, len(sessions))
+	for i, s := range sessions {
+		ids[i] = s.ID
+	}
+	return ids
+}
+
+func TestListSessions(t *testing.T) {
+	_, sm := setupTestDB(t)
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+	seedSessions(t, sm, base, "a", "b", "c", "d")
+
+	pinned, archived := true, false
+	if _, err := sm.UpdateSession("b", SessionUpdate{Pinned: &pinned}); err != nil {
+		t.Fatalf("修改会话失败: %v", err)
+	}
+	archived = true
+	sm.UpdateSession("d", SessionUpdate{Archived: &archived})
+	sm.UpdateSession("a", SessionUpdate{Tags: &[]string{"工作", " Go ", "go", ""}})
+	sm.UpdateSession("c", SessionUpdate{Tags: &[]string{"工作"}})
+
+	notArchived := false
+	list := func(filter SessionFilter) *SessionPage {
+		t.Helper()
+		if filter.Archived == nil {
+			filter.Archived = &notArchived
+		}
+		page, err := sm.ListSessions(filter)
+		if err != nil {
+			t.Fatalf("列出会话失败: %v", err)
+		}
+		return page
+	}
+
+	t.Run("置顶在前，其余按最后活动时间倒序，默认不含归档", func(t *testing.T) {
+		page := list(SessionFilter{})
+		if got := sessionIDs(page.Sessions); page.Total != 3 || len(got) != 3 || got[0] != "b" || got[1] != "c" || got[2] != "a" {
+			t.Errorf("排序错误: %v (total %d)", got, page.Total)
+		}
+		if page.Sessions[0].MessageCount != 1 || page.Sessions[0].Messages != nil {
+			t.Errorf("列表应只有消息条数不含消息: %+v", page.Sessions[0])
+		}
+	})
+
+	t.Run("分页", func(t *testing.T) {
+		page := list(SessionFilter{Limit: 2, Offset: 2})
+		if got := sessionIDs(page.Sessions); page.Total != 3 || len(got) != 1 || got[0] != "a" {
+			t.Errorf("分页错误: %v", got)
+		}
+	})
+
+	t.Run("按标签和时间过滤", func(t *testing.T) {
+		if got := sessionIDs(list(SessionFilter{Tags: []string{"工作", "GO"}}).Sessions); len(got) != 1 || got[0] != "a" {
+			t.Errorf("标签过滤错误: %v", got)
+		}
+		since, until := base.AddDate(0, 0, 1), base.AddDate(0, 0, 2)
+		if got := sessionIDs(list(SessionFilter{Since: &since, Until: &until}).Sessions); len(got) != 1 || got[0] != "b" {
+			t.Errorf("时间过滤错误: %v", got)
+		}
+	})
+
+	t.Run("归档与排序字段", func(t *testing.T) {
+		if got := sessionIDs(list(SessionFilter{Archived: &archived}).Sessions); len(got) != 1 || got[0] != "d" {
+			t.Errorf("应只返回归档的会话: %v", got)
+		}
+		unpinned := false
+		page := list(SessionFilter{Pinned: &unpinned, Sort: "created_at", Asc: true})
+		if got := sessionIDs(page.Sessions); len(got) != 2 || got[0] != "a" || got[1] != "c" {
+			t.Errorf("按创建时间正序错误: %v", got)
+		}
+	})
+
+	t.Run("修改元数据不改变最后活动时间且标签已规范化", func(t *testing.T) {
+		session := sm.GetSession("a")
+		if !session.UpdatedAt.Equal(base) {
+			t.Errorf("最后活动时间被修改: %v", session.UpdatedAt)
+		}
+		if len(session.Tags) != 2 || session.Tags[0] != "工作" || session.Tags[1] != "Go" {
+			t.Errorf("标签未规范化: %v", session.Tags)
+		}
+		if _, err := sm.UpdateSession("missing", SessionUpdate{}); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("期望 ErrSessionNotFound, 得到 %v", err)
+		}
+	})
+}
+
+func TestSessionTitler(t *testing.T) {
+	_, sm := setupTestDB(t)
+	sm.GetOrCreateSession("s1")
+
+	calls := 0
+	llm := &MockLLMService{MockSendMessage: func(req ChatRequest) (*ChatResponse, error) {
+		calls++
+		return &ChatResponse{Type: "message", Content: []Content{{Type: "text", Text: "Go并发入门"}}}, nil
+	}}
+	titler := NewSessionTitler(NewKnowledgeOrganizer(llm), sm)
+
+	t.Run("消息不足时不生成", func(t *testing.T) {
+		sm.AddMessage("s1", "user", "聊聊 Go 并发")
+		sm.AddMessage("s1", "assistant", "好的")
+		titler.Schedule("s1")
+		titler.Wait()
+		if calls != 0 || sm.GetSession("s1").Title != "" {
+			t.Errorf("前两条消息后不应生成标题")
+		}
+	})
+
+	t.Run("前几轮对话后生成标题", func(t *testing.T) {
+		sm.AddMessage("s1", "user", "channel 怎么用")
+		sm.AddMessage("s1", "assistant", "用 make 创建")
+		titler.Schedule("s1")
+		titler.Wait()
+		if title := sm.GetSession("s1").Title; title != "Go并发入门" {
+			t.Errorf("标题错误: %q", title)
+		}
+
+		titler.Schedule("s1")
+		titler.Wait()
+		if calls != 1 {
+			t.Errorf("已有标题时不应再生成, 调用 %d 次", calls)
+		}
+	})
+
+	t.Run("不覆盖用户修改的标题", func(t *testing.T) {
+		title := "我的笔记"
+		sm.UpdateSession("s1", SessionUpdate{Title: &title})
+		if sm.SetTitleIfEmpty("s1", "自动标题") || sm.GetSession("s1").Title != "我的笔记" {
+			t.Errorf("用户标题被覆盖")
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultSummaryTimeout 后台生成一次摘要的超时时间
+const DefaultSummaryTimeout = 30 * time.Second
+
+// SessionSummarizer 会话摘要维护
+// 请求路径上只读取已有摘要拼装上下文（BuildMessages），摘要在回复完成后由 Schedule 在后台增量更新，
+// 同一会话同时只有一个更新任务，运行期间又有新消息时结束后再检查一次
+type SessionSummarizer struct {
+	compressor *ContextCompressor
+	sessions   *SessionManager
+	timeout    time.Duration
+
+	mu      sync.Mutex
+	running map[string]bool
+	dirty   map[string]bool
+	wg      sync.WaitGroup
+}
+
+// NewSessionSummarizer 创建会话摘要维护
+func NewSessionSummarizer(compressor *ContextCompressor, sessions *SessionManager) *SessionSummarizer {
+	return &SessionSummarizer{
+		compressor: compressor,
+		sessions:   sessions,
+		timeout:    DefaultSummaryTimeout,
+		running:    make(map[string]bool),
+		dirty:      make(map[string]bool),
+	}
+}
+
+// BuildMessages 构建发给 LLM 的消息：已摘要的历史用摘要代替，其余消息按 token 预算保留
+// history 为会话全部消息，最后一条是当前用户消息
+func (s *SessionSummarizer) BuildMessages(sessionID string, history []Message) []Message {
+	if len(history) == 0 {
+		return history
+	}
+
+	compressed := s.compressor.CompressWithSummary(s.sessions.GetSummary(sessionID), history)
+	recent := compressed.RecentMessages
+	current := recent[len(recent)-1]
+	compressed.RecentMessages = recent[:len(recent)-1]
+	return s.compressor.BuildMessagesForAPI(compressed, "", fmt.Sprintf("%v", current.Content))
+}
+
+// Schedule 在后台检查并增量更新会话摘要，不阻塞调用方
+func (s *SessionSummarizer) Schedule(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running[sessionID] {
+		s.dirty[sessionID] = true
+		return
+	}
+	s.running[sessionID] = true
+	s.wg.Add(1)
+	go s.run(sessionID)
+}
+
+// Wait 等待所有进行中的摘要任务结束
+func (s *SessionSummarizer) Wait() {
+	s.wg.Wait()
+}
+
+func (s *SessionSummarizer) run(sessionID string) {
+	defer s.wg.Done()
+
+	for {
+		s.summarize(sessionID)
+
+		s.mu.Lock()
+		if s.dirty[sessionID] {
+			delete(s.dirty, sessionID)
+			s.mu.Unlock()
+			continue
+		}
+		delete(s.running, sessionID)
+		s.mu.Unlock()
+		return
+	}
+}
+
+// summarize 需要时把新消息并入摘要并保存
+func (s *SessionSummarizer) summarize(sessionID string) {
+	messages := s.sessions.GetMessages(sessionID)
+	previous := s.sessions.GetSummary(sessionID)
+	if !s.compressor.NeedsSummaryUpdate(previous, messages) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	summary, err := s.compressor.UpdateSummary(ctx, previous, messages)
+	if err != nil {
+		log.Printf("[Summary] 更新会话摘要失败 (Session: %s): %v", sessionID, err)
+		return
+	}
+	if summary == nil || summary == previous {
+		return
+	}
+
+	// 生成期间会话被清空或裁剪时摘要已对不上，放弃这次结果
+	current := s.sessions.GetMessages(sessionID)
+	n := summary.MessageCount
+	if n > len(current) || n > len(messages) || fmt.Sprint(current[n-1].Content) != fmt.Sprint(messages[n-1].Content) {
+		log.Printf("[Summary] 会话在摘要期间已变化，放弃本次摘要 (Session: %s)", sessionID)
+		return
+	}
+
+	s.sessions.UpdateSummary(sessionID, summary)
+	log.Printf("[Summary] 已更新会话摘要 (Session: %s, 覆盖 %d 条消息)", sessionID, summary.MessageCount)
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSummaryLLM 记录收到的摘要请求，按调用次数返回 "摘要1"、"摘要2"…
+type fakeSummaryLLM struct {
+	mu      sync.Mutex
+	prompts []string
+	err     error
+}
+
+func (f *fakeSummaryLLM) SendMessage(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.prompts = append(f.prompts, req.Messages[0].Content.(string))
+	if f.err != nil {
+		return nil, f.err
+	}
+	text := fmt.Sprintf("摘要%d", len(f.prompts))
+	return &ChatResponse{Type: "message", Content: []Content{{Type: "text", Text: text}}}, nil
+}
+
+func (f *fakeSummaryLLM) SendMessageStream(ctx context.Context, req ChatRequest, callback func(StreamChunk)) error {
+	return errors.New("not implemented")
+}
+
+// testDialog 生成 n 条交替的用户/助手消息，内容为 "消息0"、"消息1"…
+func testDialog(n int) []Message {
+	messages := make([]Message, n)
+	for i := range messages {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		messages[i] = Message{Role: role, Content: fmt.Sprintf("消息%d", i)}
+	}
+	return messages
+}
+
+func testContextConfig() ContextConfig {
+	config := DefaultContextConfig()
+	config.MaxRecentMessages = 4
+	config.SummaryThreshold = 6
+	config.SummaryBatch = 2
+	return config
+}
+
+func TestContextCompressor_CompressWithSummary(t *testing.T) {
+	cc := NewContextCompressor(nil, testContextConfig())
+	messages := testDialog(10)
+
+	t.Run("摘要覆盖的消息用摘要代替", func(t *testing.T) {
+		compressed := cc.CompressWithSummary(&SessionSummary{Content: "前情", MessageCount: 6}, messages)
+		if compressed.Summary == nil || len(compressed.RecentMessages) != 4 || compressed.RecentMessages[0].Content != "消息6" {
+			t.Errorf("压缩结果错误: %+v", compressed)
+		}
+	})
+
+	t.Run("没有摘要时保留全部消息", func(t *testing.T) {
+		compressed := cc.CompressWithSummary(nil, messages)
+		if compressed.Summary != nil || len(compressed.RecentMessages) != 10 {
+			t.Errorf("压缩结果错误: %+v", compressed)
+		}
+	})
+
+	t.Run("摘要与消息对不上时忽略摘要", func(t *testing.T) {
+		compressed := cc.CompressWithSummary(&SessionSummary{Content: "前情", MessageCount: 20}, messages)
+		if compressed.Summary != nil || len(compressed.RecentMessages) != 10 {
+			t.Errorf("压缩结果错误: %+v", compressed)
+		}
+	})
+
+	t.Run("超出 token 预算时丢弃较早的消息", func(t *testing.T) {
+		config := testContextConfig()
+		// 每条消息 7 字节，估算 3 token
+		config.MaxTotalTokens = 10
+		compressed := NewContextCompressor(nil, config).CompressWithSummary(nil, messages)
+		if len(compressed.RecentMessages) != 3 || compressed.RecentMessages[0].Content != "消息7" {
+			t.Errorf("应只保留最近 3 条: %+v", compressed.RecentMessages)
+		}
+
+		config.MaxTotalTokens = 1
+		compressed = NewContextCompressor(nil, config).CompressWithSummary(nil, messages)
+		if len(compressed.RecentMessages) != 1 || compressed.RecentMessages[0].Content != "消息9" {
+			t.Errorf("最新一条消息总是保留: %+v", compressed.RecentMessages)
+		}
+	})
+}
+
+func TestContextCompressor_UpdateSummary(t *testing.T) {
+	llm := &fakeSummaryLLM{}
+	cc := NewContextCompressor(llm, testContextConfig())
+
+	if cc.NeedsSummaryUpdate(nil, testDialog(6)) {
+		t.Errorf("未超过阈值不应生成摘要")
+	}
+	if !cc.NeedsSummaryUpdate(nil, testDialog(8)) {
+		t.Errorf("超过阈值应生成摘要")
+	}
+	if cc.NeedsSummaryUpdate(&SessionSummary{MessageCount: 5}, testDialog(10)) {
+		t.Errorf("未摘要的消息不足一批时不应更新")
+	}
+
+	first, err := cc.UpdateSummary(context.Background(), nil, testDialog(8))
+	if err != nil {
+		t.Fatalf("生成摘要失败: %v", err)
+	}
+	if first.Content != "摘要1" || first.MessageCount != 4 {
+		t.Errorf("首次摘要错误: %+v", first)
+	}
+	if !strings.Contains(llm.prompts[0], "消息3") || strings.Contains(llm.prompts[0], "消息4") {
+		t.Errorf("首次摘要应只包含最近 4 条之前的消息: %s", llm.prompts[0])
+	}
+
+	second, err := cc.UpdateSummary(context.Background(), first, testDialog(12))
+	if err != nil {
+		t.Fatalf("更新摘要失败: %v", err)
+	}
+	if second.Content != "摘要2" || second.MessageCount != 8 {
+		t.Errorf("增量摘要错误: %+v", second)
+	}
+	prompt := llm.prompts[1]
+	if !strings.Contains(prompt, "摘要1") || !strings.Contains(prompt, "消息4") || strings.Contains(prompt, "消息3") {
+		t.Errorf("增量摘要应基于上次摘要并只包含新消息: %s", prompt)
+	}
+}
+
+func TestSessionSummarizer(t *testing.T) {
+	_, sm := setupTestDB(t)
+	sm.GetOrCreateSession("s1")
+
+	llm := &fakeSummaryLLM{}
+	summarizer := NewSessionSummarizer(NewContextCompressor(llm, testContextConfig()), sm)
+
+	for _, m := range testDialog(8) {
+		sm.AddMessage("s1", m.Role, m.Content.(string))
+	}
+
+	t.Run("后台生成并保存摘要", func(t *testing.T) {
+		summarizer.Schedule("s1")
+		summarizer.Wait()
+
+		summary := sm.GetSummary("s1")
+		if summary == nil || summary.Content != "摘要1" || summary.MessageCount != 4 {
+			t.Fatalf("摘要未保存: %+v", summary)
+		}
+
+		// 没有新消息时不重复生成
+		summarizer.Schedule("s1")
+		summarizer.Wait()
+		if len(llm.prompts) != 1 {
+			t.Errorf("没有新消息不应调用 LLM, 实际 %d 次", len(llm.prompts))
+		}
+	})
+
+	t.Run("构建消息时用摘要代替已摘要的历史", func(t *testing.T) {
+		sm.AddMessage("s1", "user", "现在的问题")
+		messages := summarizer.BuildMessages("s1", sm.GetMessages("s1"))
+
+		if len(messages) != 6 {
+			t.Fatalf("应为 摘要 + 4 条未摘要消息 + 当前消息, 实际 %d 条: %+v", len(messages), messages)
+		}
+		if !strings.Contains(messages[0].Content.(string), "摘要1") {
+			t.Errorf("第一条应为历史摘要: %v", messages[0].Content)
+		}
+		if messages[1].Content != "消息4" || messages[5].Content != "现在的问题" {
+			t.Errorf("消息顺序错误: %+v", messages)
+		}
+	})
+
+	t.Run("生成失败时保留原摘要", func(t *testing.T) {
+		sm.AddMessage("s1", "assistant", "回答")
+		llm.err = errors.New("glm down")
+		summarizer.Schedule("s1")
+		summarizer.Wait()
+
+		if summary := sm.GetSummary("s1"); summary.Content != "摘要1" {
+			t.Errorf("失败时不应覆盖摘要: %+v", summary)
+		}
+	})
+}
@@ -1,7 +1,12 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // setupTestDB 创建一个用于测试的临时数据库和 SessionManager
@@ -32,9 +37,9 @@ func TestGetOrCreateSession(t *testing.T) {
 
 	// 测试创建新会话
 	sessionID := "test_session_1"
-	session := sm.GetOrCreateSession(sessionID)
-	if session == nil {
-		t.Fatal("返回 nil")
+	session, err := sm.GetOrCreateSession(sessionID)
+	if err != nil {
+		t.Fatalf("创建会话失败: %v", err)
 	}
 	if session.ID != sessionID {
 		t.Errorf("期望 ID %s, 得到 %s", sessionID, session.ID)
@@ -44,7 +49,10 @@ func TestGetOrCreateSession(t *testing.T) {
 	}
 
 	// 测试获取已存在的会话
-	sameSession := sm.GetOrCreateSession(sessionID)
+	sameSession, err := sm.GetOrCreateSession(sessionID)
+	if err != nil {
+		t.Fatalf("获取会话失败: %v", err)
+	}
 	if sameSession.ID != sessionID {
 		t.Errorf("期望相同 ID %s, 得到 %s", sessionID, sameSession.ID)
 	}
@@ -53,6 +61,27 @@ func TestGetOrCreateSession(t *testing.T) {
 	}
 }
 
+// TestGetOrCreateSession_LoadError 读取失败时不创建会话，已有会话的元数据不被覆盖
+func TestGetOrCreateSession_LoadError(t *testing.T) {
+	db, sm := setupTestDB(t)
+	sm.GetOrCreateSession("sess_1")
+	if _, err := db.db.Exec(`UPDATE sessions SET title = '周报讨论', summary = '{损坏' WHERE id = 'sess_1'`); err != nil {
+		t.Fatalf("准备数据失败: %v", err)
+	}
+
+	// 新的管理器没有缓存，必须从数据库读取
+	fresh := NewSessionManagerWithDB(db)
+	if _, err := fresh.GetOrCreateSession("sess_1"); err == nil {
+		t.Fatal("读取失败时应返回错误")
+	}
+
+	var title string
+	db.db.QueryRow(`SELECT COALESCE(title, '') FROM sessions WHERE id = 'sess_1'`).Scan(&title)
+	if title != "周报讨论" {
+		t.Errorf("读取失败后会话被覆盖, 标题: %q", title)
+	}
+}
+
 // TestAddMessage 测试添加消息
 func TestAddMessage(t *testing.T) {
 	_, sm := setupTestDB(t)
@@ -115,4 +144,257 @@ func TestDeleteSession(t *testing.T) {
 	if sm.GetSessionCount() != 0 {
 		t.Errorf("删除后数量应为 0")
 	}
-}
\ No newline at end of file
+}
+
+// TestSessionSummaryPersistence 会话摘要持久化、清空与裁剪
+func TestSessionSummaryPersistence(t *testing.T) {
+	t.Run("摘要跨实例保留", func(t *testing.T) {
+		tempDir := t.TempDir()
+		db1, _ := NewDatabase(tempDir)
+		sm1 := NewSessionManagerWithDB(db1)
+		sm1.GetOrCreateSession("s1")
+		sm1.AddMessage("s1", "user", "你好")
+		sm1.UpdateSummary("s1", &SessionSummary{Content: "用户打了招呼", MessageCount: 1})
+		db1.Close()
+
+		db2, _ := NewDatabase(tempDir)
+		defer db2.Close()
+		summary := NewSessionManagerWithDB(db2).GetSummary("s1")
+		if summary == nil || summary.Content != "用户打了招呼" || summary.MessageCount != 1 {
+			t.Errorf("重新加载后摘要丢失: %+v", summary)
+		}
+	})
+
+	t.Run("清空会话同时清空摘要", func(t *testing.T) {
+		_, sm := setupTestDB(t)
+		sm.GetOrCreateSession("s1")
+		sm.AddMessage("s1", "user", "你好")
+		sm.UpdateSummary("s1", &SessionSummary{Content: "摘要", MessageCount: 1})
+
+		sm.ClearSession("s1")
+		if summary := sm.GetSummary("s1"); summary != nil {
+			t.Errorf("清空后摘要应为空: %+v", summary)
+		}
+	})
+
+	t.Run("清空会话只重置上下文，消息存档保留", func(t *testing.T) {
+		db, sm := setupTestDB(t)
+		sm.GetOrCreateSession("s1")
+		sm.AddMessage("s1", "user", "旧消息")
+		sm.AddMessage("s1", "assistant", "旧回复")
+
+		sm.ClearSession("s1")
+		sm.AddMessage("s1", "user", "新消息")
+
+		for name, m := range map[string]*SessionManager{"缓存": sm, "重新加载": NewSessionManagerWithDB(db)} {
+			if msgs := m.GetMessages("s1"); len(msgs) != 1 || msgs[0].Content != "新消息" {
+				t.Errorf("%s: 上下文应只有清空后的消息: %+v", name, msgs)
+			}
+			if recent := m.GetRecentMessages("s1", DefaultHistoryMessages); len(recent) != 1 || recent[0].Content != "新消息" {
+				t.Errorf("%s: 最近消息不应包含清空前的消息: %+v", name, recent)
+			}
+		}
+
+		page, err := sm.ListMessages("s1", 0, 0)
+		if err != nil || page.Total != 3 || len(page.Messages) != 3 || page.Messages[0].Content != "旧消息" {
+			t.Errorf("清空后消息存档应完整保留: %+v, %v", page, err)
+		}
+	})
+
+	t.Run("超过上下文窗口的消息全部保留", func(t *testing.T) {
+		_, sm := setupTestDB(t)
+		sm.GetOrCreateSession("s1")
+		for i := 0; i < 50; i++ {
+			sm.AddMessage("s1", "user", fmt.Sprintf("消息%d", i))
+		}
+
+		if n := len(sm.GetMessages("s1")); n != 50 {
+			t.Errorf("应保留全部 50 条消息, 实际 %d", n)
+		}
+		recent := sm.GetRecentMessages("s1", DefaultHistoryMessages)
+		if len(recent) != DefaultHistoryMessages || recent[0].Content != "消息30" || recent[19].Content != "消息49" {
+			t.Errorf("上下文窗口错误: %+v", recent)
+		}
+	})
+}
+
+// TestSessionCache 会话缓存：写穿、淘汰与按会话加锁
+func TestSessionCache(t *testing.T) {
+	t.Run("写入同时落库", func(t *testing.T) {
+		db, sm := setupTestDB(t)
+		sm.GetOrCreateSession("s1")
+		sm.AddMessage("s1", "user", "你好")
+		sm.UpdateSummary("s1", &SessionSummary{Content: "摘要", MessageCount: 1})
+
+		// 绕过缓存，用新的管理器直接读数据库
+		fresh := NewSessionManagerWithDB(db)
+		if msgs := fresh.GetMessages("s1"); len(msgs) != 1 || msgs[0].Content != "你好" {
+			t.Errorf("消息未写入数据库: %+v", msgs)
+		}
+		if summary := fresh.GetSummary("s1"); summary == nil || summary.Content != "摘要" {
+			t.Errorf("摘要未写入数据库: %+v", summary)
+		}
+		if session := sm.GetSession("s1"); session.MessageCount != 1 || len(session.Messages) != 1 {
+			t.Errorf("缓存的会话未更新: %+v", session)
+		}
+	})
+
+	t.Run("超出容量时淘汰最久未使用的会话", func(t *testing.T) {
+		_, sm := setupTestDB(t)
+		sm.SetCacheSize(2)
+		for _, id := range []string{"s1", "s2", "s3"} {
+			sm.GetOrCreateSession(id)
+		}
+		if n := sm.cache.len(); n != 2 {
+			t.Errorf("期望缓存 2 个会话, 实际 %d", n)
+		}
+		if _, ok := sm.cache.entries["s1"]; ok {
+			t.Errorf("最久未使用的 s1 应被淘汰")
+		}
+
+		// 淘汰后重新从数据库加载
+		sm.AddMessage("s1", "user", "回来了")
+		if msgs := sm.GetMessages("s1"); len(msgs) != 1 {
+			t.Errorf("淘汰后重新加载失败: %+v", msgs)
+		}
+	})
+
+	t.Run("删除和清空后缓存与数据库一致", func(t *testing.T) {
+		_, sm := setupTestDB(t)
+		sm.GetOrCreateSession("s1")
+		sm.AddMessage("s1", "user", "你好")
+
+		sm.ClearSession("s1")
+		if session := sm.GetSession("s1"); session == nil || session.MessageCount != 1 || len(session.Messages) != 0 {
+			t.Errorf("清空后缓存未更新: %+v", session)
+		}
+
+		sm.DeleteSession("s1")
+		if session := sm.GetSession("s1"); session != nil {
+			t.Errorf("删除后不应再返回会话: %+v", session)
+		}
+		if err := sm.AppendMessage(&SessionMessage{SessionID: "s1", Role: "user", Content: "x"}); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("向已删除的会话追加消息应返回 ErrSessionNotFound, 得到 %v", err)
+		}
+	})
+
+	t.Run("长会话只缓存最近的消息", func(t *testing.T) {
+		_, sm := setupTestDB(t)
+		sm.GetOrCreateSession("s1")
+		total := maxCachedMessages + 5
+		for i := 0; i < total; i++ {
+			sm.AddMessage("s1", "user", fmt.Sprintf("消息%d", i))
+		}
+
+		e := sm.cache.acquire("s1")
+		cached := len(e.messages)
+		sm.cache.release(e)
+		if cached != maxCachedMessages {
+			t.Errorf("期望缓存 %d 条消息, 实际 %d", maxCachedMessages, cached)
+		}
+
+		// 完整上下文从数据库补齐，最近的消息直接取缓存
+		if msgs := sm.GetMessages("s1"); len(msgs) != total || msgs[0].Content != "消息0" {
+			t.Errorf("完整上下文应有 %d 条消息, 实际 %d", total, len(msgs))
+		}
+		recent := sm.GetRecentMessages("s1", 3)
+		if len(recent) != 3 || recent[2].Content != fmt.Sprintf("消息%d", total-1) {
+			t.Errorf("最近消息错误: %+v", recent)
+		}
+	})
+
+	t.Run("一个会话加锁不阻塞其他会话", func(t *testing.T) {
+		_, sm := setupTestDB(t)
+		sm.GetOrCreateSession("busy")
+		sm.GetOrCreateSession("idle")
+
+		busy := sm.cache.acquire("busy")
+		busy.mu.Lock()
+		defer func() {
+			busy.mu.Unlock()
+			sm.cache.release(busy)
+		}()
+
+		done := make(chan struct{})
+		go func() {
+			sm.AddMessage("idle", "user", "你好")
+			sm.GetMessages("idle")
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("其他会话的读写被阻塞")
+		}
+	})
+
+	t.Run("并发读写", func(t *testing.T) {
+		_, sm := setupTestDB(t)
+		sm.SetCacheSize(2)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			id := fmt.Sprintf("s%d", i)
+			sm.GetOrCreateSession(id)
+			for j := 0; j < 2; j++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for k := 0; k < 10; k++ {
+						sm.AddMessage(id, "user", "消息")
+						sm.GetRecentMessages(id, 5)
+						sm.GetSummary(id)
+					}
+				}()
+			}
+		}
+		wg.Wait()
+
+		for i := 0; i < 4; i++ {
+			if n := len(sm.GetMessages(fmt.Sprintf("s%d", i))); n != 20 {
+				t.Errorf("s%d 期望 20 条消息, 实际 %d", i, n)
+			}
+		}
+	})
+}
+
+// BenchmarkSessionManager_ParallelSessions 多个连接同时读取各自会话的上下文
+// 缓存命中时只需要本会话的读锁；SetCacheSize(0) 时每次都查询数据库作为对照
+func BenchmarkSessionManager_ParallelSessions(b *testing.B) {
+	for _, bc := range []struct {
+		name      string
+		cacheSize int
+	}{
+		{"cached", DefaultSessionCacheSize},
+		{"uncached", 0},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			db, err := NewDatabase(b.TempDir())
+			if err != nil {
+				b.Fatalf("创建数据库失败: %v", err)
+			}
+			defer db.Close()
+			sm := NewSessionManagerWithDB(db)
+			sm.SetCacheSize(bc.cacheSize)
+
+			const sessions = 64
+			for i := 0; i < sessions; i++ {
+				id := fmt.Sprintf("s%d", i)
+				sm.GetOrCreateSession(id)
+				for j := 0; j < 30; j++ {
+					sm.AddMessage(id, "user", fmt.Sprintf("消息%d", j))
+				}
+			}
+
+			var next int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				id := fmt.Sprintf("s%d", atomic.AddInt64(&next, 1)%sessions)
+				for pb.Next() {
+					sm.GetRecentMessages(id, DefaultHistoryMessages)
+					sm.GetSummary(id)
+				}
+			})
+		})
+	}
+}
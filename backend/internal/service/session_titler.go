@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTitleAfterMessages 会话累计多少条消息（两轮对话）后生成标题
+	DefaultTitleAfterMessages = 4
+	// DefaultTitleTimeout 后台生成一次标题的超时时间
+	DefaultTitleTimeout = 30 * time.Second
+	// titleMaxMessages 生成标题时最多参考的开头消息数
+	titleMaxMessages = 10
+)
+
+// SessionTitler 会话标题自动生成
+// 会话还没有标题且已有前几轮对话时，由 Schedule 在后台用 KnowledgeOrganizer 生成标题；
+// 用户修改过的标题不会被覆盖，生成失败时下一轮回复后重试
+type SessionTitler struct {
+	organizer *KnowledgeOrganizer
+	sessions  *SessionManager
+	after     int
+	timeout   time.Duration
+
+	mu      sync.Mutex
+	running map[string]bool
+	wg      sync.WaitGroup
+}
+
+// NewSessionTitler 创建会话标题生成器
+func NewSessionTitler(organizer *KnowledgeOrganizer, sessions *SessionManager) *SessionTitler {
+	return &SessionTitler{
+		organizer: organizer,
+		sessions:  sessions,
+		after:     DefaultTitleAfterMessages,
+		timeout:   DefaultTitleTimeout,
+		running:   make(map[string]bool),
+	}
+}
+
+// SetTitleAfterMessages 设置累计多少条消息后生成标题
+func (t *SessionTitler) SetTitleAfterMessages(n int) {
+	t.after = n
+}
+
+// Schedule 会话需要标题时在后台生成，不阻塞调用方
+func (t *SessionTitler) Schedule(sessionID string) {
+	session := t.sessions.GetSession(sessionID)
+	if session == nil || session.Title != "" || session.MessageCount < t.after {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.running[sessionID] {
+		return
+	}
+	t.running[sessionID] = true
+	t.wg.Add(1)
+	go t.run(session)
+}
+
+// Wait 等待所有进行中的标题生成任务结束
+func (t *SessionTitler) Wait() {
+	t.wg.Wait()
+}
+
+func (t *SessionTitler) run(session *Session) {
+	defer t.wg.Done()
+	defer func() {
+		t.mu.Lock()
+		delete(t.running, session.ID)
+		t.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+
+	session.Messages = session.Messages[:min(len(session.Messages), titleMaxMessages)]
+	title, err := t.organizer.GenerateTitleFromSession(ctx, session)
+	if err != nil {
+		log.Printf("[Title] 生成会话标题失败 (Session: %s): %v", session.ID, err)
+		return
+	}
+
+	if t.sessions.SetTitleIfEmpty(session.ID, title) {
+		log.Printf("[Title] 已生成会话标题 (Session: %s): %s", session.ID, title)
+	}
+}
@@ -1,13 +1,16 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"math"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
+	"voice-memory/internal/audio"
 
 	"github.com/gorilla/websocket"
 )
@@ -36,101 +39,170 @@ type SherpaResponse struct {
 	IsFinal bool   `json:"is_final"`
 }
 
-// Recognize 语音识别
-func (s *SherpaSTT) Recognize(req *RecognizeRequest) ([]string, error) {
-	u := url.URL{Scheme: "ws", Host: s.addr, Path: "/"}
-	log.Printf("Connecting to Sherpa STT: %s", u.String())
+// Recognize 语音识别（整段音频）
+// 内部复用流式识别：分块推送音频，只收集最终结果
+func (s *SherpaSTT) Recognize(ctx context.Context, req *RecognizeRequest) ([]string, error) {
+	// 输入可能是 WAV 或 16k 16bit PCM，统一转换为 16k 单声道 PCM
+	audioData, err := audio.Normalize(req.AudioData, audio.PCM16kMono)
+	if err != nil {
+		return nil, err
+	}
 
-	c, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	stream, err := s.NewStream(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("connect sherpa error: %w", err)
+		return nil, err
 	}
-	defer c.Close()
-
-	// 转换音频数据为 float32 切片 (Assuming 16bit PCM)
-	// Sherpa onnx server usually expects raw samples (float32) sent as bytes
-	// But check specific server implementation. Many accept raw bytes of 16k 16bit PCM mono.
-	
-	// 这里假设输入已经是 16k 16bit PCM 或者 WAV
-	// 如果是 WAV，需要去掉头部。
-	audioData := req.AudioData
-	if len(audioData) > 44 && string(audioData[:4]) == "RIFF" {
-		audioData = audioData[44:]
-	}
-
-	// Converting []byte (int16) to []float32 usually required by some sherpa clients,
-	// but the server might accept binary payload as raw PCM samples.
-	// Let's try sending binary message directly first (most efficient).
-	// Standard sherpa-onnx-online-websocket-server accepts binary messages as chunks of samples.
-	// It expects 16kHz, 16-bit mono PCM if sending raw bytes? Or float32?
-	
-	// Reference python client sends: samples (np.float32) -> tobytes() -> send_bytes.
-	// So it expects bytes representing float32 usually.
-	
-	samples := int16ToFloat32(audioData)
-	
-	// Send audio in chunks to simulate streaming (or just one big chunk)
-	// Sending one big chunk might timeout or be too large.
-	chunkSize := 4096 // samples
-	
+
 	// Collect results
 	var results []string
-	
-	// Channel to receive text segments
-	textChan := make(chan string)
-	
+	collected := make(chan struct{})
 	go func() {
-		defer close(textChan)
-		defer c.Close()
-		
-		for {
-			_, message, err := c.ReadMessage()
-			if err != nil {
-				return
-			}
-			
-			var resp SherpaResponse
-			if err := json.Unmarshal(message, &resp); err != nil {
-				continue
-			}
-			
-			// If it's final, send it to channel
-			if resp.IsFinal {
-				textChan <- resp.Text
-			} else {
-				// Intermediate result
+		defer close(collected)
+		for r := range stream.Results() {
+			if r.IsFinal && r.Text != "" {
+				results = append(results, r.Text)
 			}
 		}
 	}()
 
-	// Send audio
-	floatBytes := float32ToBytes(samples)
-	bytesPerChunk := chunkSize * 4
-	for i := 0; i < len(floatBytes); i += bytesPerChunk {
+	// Send audio in chunks to simulate streaming (or just one big chunk)
+	// Sending one big chunk might timeout or be too large.
+	bytesPerChunk := sherpaChunkSamples * 2
+	for i := 0; i < len(audioData); i += bytesPerChunk {
 		end := i + bytesPerChunk
-		if end > len(floatBytes) {
-			end = len(floatBytes)
+		if end > len(audioData) {
+			end = len(audioData)
 		}
-		err := c.WriteMessage(websocket.BinaryMessage, floatBytes[i:end])
-		if err != nil {
-			return nil, fmt.Errorf("write audio error: %w", err)
+		if err := stream.Write(audioData[i:end]); err != nil {
+			stream.Close()
+			<-collected
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
 		}
 		time.Sleep(5 * time.Millisecond) // throttling
 	}
 
 	// Send "Done" signal
-	c.WriteMessage(websocket.TextMessage, []byte("Done"))
-	
+	stream.Close()
+
 	// Collect all texts
-	for text := range textChan {
-		if text != "" {
-			results = append(results, text)
-		}
+	<-collected
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
 	}
-	
+
 	return results, nil
 }
 
+// sherpaChunkSamples 整段识别时每次推送的采样数
+const sherpaChunkSamples = 4096
+
+// NewStream 打开一个流式识别会话（实现 StreamingSTTService）
+// 每个会话对应一条到 sherpa 服务端的 WebSocket 连接
+func (s *SherpaSTT) NewStream(ctx context.Context) (STTStream, error) {
+	u := url.URL{Scheme: "ws", Host: s.addr, Path: "/"}
+	log.Printf("Connecting to Sherpa STT: %s", u.String())
+
+	c, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("connect sherpa error: %w", err)
+	}
+
+	stream := &sherpaStream{
+		ctx:     ctx,
+		conn:    c,
+		results: make(chan STTResult, 16),
+	}
+	// 打断时关闭连接，读协程会随之退出
+	stream.stop = context.AfterFunc(ctx, func() { c.Close() })
+
+	go stream.readLoop()
+	return stream, nil
+}
+
+// sherpaStream sherpa 流式识别会话
+type sherpaStream struct {
+	ctx     context.Context
+	conn    *websocket.Conn
+	results chan STTResult
+	stop    func() bool
+	writeMu sync.Mutex
+	closed  bool
+	carry   []byte // 上一个分片末尾不成对的字节，拼到下一个分片前面
+}
+
+// Write 推送 16bit PCM，转换为服务端要求的 float32 样本
+// 客户端分片可能是奇数字节，剩下的半个样本留到下一次 Write，保证后续样本对齐
+// Reference python client sends: samples (np.float32) -> tobytes() -> send_bytes.
+func (st *sherpaStream) Write(pcm []byte) error {
+	st.writeMu.Lock()
+	defer st.writeMu.Unlock()
+
+	if st.closed {
+		return fmt.Errorf("sherpa stream already closed")
+	}
+	if len(st.carry) > 0 {
+		pcm = append(st.carry, pcm...)
+		st.carry = nil
+	}
+	if len(pcm)%2 == 1 {
+		st.carry = []byte{pcm[len(pcm)-1]}
+		pcm = pcm[:len(pcm)-1]
+	}
+	if len(pcm) == 0 {
+		return nil
+	}
+	if err := st.conn.WriteMessage(websocket.BinaryMessage, float32ToBytes(int16ToFloat32(pcm))); err != nil {
+		return fmt.Errorf("write audio error: %w", err)
+	}
+	return nil
+}
+
+// Results 返回识别结果通道
+func (st *sherpaStream) Results() <-chan STTResult {
+	return st.results
+}
+
+// Close 发送 "Done"，服务端返回最后的结果后关闭连接
+func (st *sherpaStream) Close() error {
+	st.writeMu.Lock()
+	defer st.writeMu.Unlock()
+
+	if st.closed {
+		return nil
+	}
+	st.closed = true
+	return st.conn.WriteMessage(websocket.TextMessage, []byte("Done"))
+}
+
+// readLoop 读取服务端的识别结果，连接关闭后关闭结果通道
+func (st *sherpaStream) readLoop() {
+	defer close(st.results)
+	defer st.stop()
+	defer st.conn.Close()
+
+	for {
+		_, message, err := st.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var resp SherpaResponse
+		if err := json.Unmarshal(message, &resp); err != nil {
+			continue
+		}
+
+		select {
+		case st.results <- STTResult{Text: resp.Text, Segment: resp.Segment, IsFinal: resp.IsFinal}:
+		case <-st.ctx.Done():
+			return
+		}
+	}
+}
+
 func int16ToFloat32(data []byte) []float32 {
 	samples := make([]float32, len(data)/2)
 	for i := 0; i < len(data)/2; i++ {
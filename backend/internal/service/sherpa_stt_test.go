@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newFakeSherpaServer 模拟 sherpa-onnx 在线识别服务：
+// 每收到一个音频分片返回一次部分结果，收到 "Done" 后返回最终结果并关闭连接
+func newFakeSherpaServer(t *testing.T) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade 失败: %v", err)
+			return
+		}
+		defer c.Close()
+
+		for {
+			msgType, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			if msgType == websocket.TextMessage && string(data) == "Done" {
+				final, _ := json.Marshal(SherpaResponse{Text: "你好世界", IsFinal: true})
+				c.WriteMessage(websocket.TextMessage, final)
+				c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			partial, _ := json.Marshal(SherpaResponse{Text: "你好", Segment: 0})
+			c.WriteMessage(websocket.TextMessage, partial)
+		}
+	}))
+}
+
+func TestSherpaSTT_NewStream(t *testing.T) {
+	server := newFakeSherpaServer(t)
+	defer server.Close()
+
+	stt := NewSherpaSTT(server.URL)
+	stream, err := stt.NewStream(context.Background())
+	if err != nil {
+		t.Fatalf("打开识别流失败: %v", err)
+	}
+
+	if err := stream.Write(make([]byte, 3200)); err != nil {
+		t.Fatalf("推送音频失败: %v", err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatalf("结束识别流失败: %v", err)
+	}
+
+	var results []STTResult
+	timeout := time.After(2 * time.Second)
+	for done := false; !done; {
+		select {
+		case r, ok := <-stream.Results():
+			if !ok {
+				done = true
+				break
+			}
+			results = append(results, r)
+		case <-timeout:
+			t.Fatal("等待识别结果超时")
+		}
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("期望 1 个部分结果 + 1 个最终结果, 实际 %+v", results)
+	}
+	if results[0].IsFinal || results[0].Text != "你好" {
+		t.Errorf("第一个结果应为部分结果: %+v", results[0])
+	}
+	if !results[1].IsFinal || results[1].Text != "你好世界" {
+		t.Errorf("第二个结果应为最终结果: %+v", results[1])
+	}
+}
+
+func TestSherpaSTT_Recognize(t *testing.T) {
+	server := newFakeSherpaServer(t)
+	defer server.Close()
+
+	stt := NewSherpaSTT(server.URL)
+	results, err := stt.Recognize(context.Background(), &RecognizeRequest{AudioData: make([]byte, 16000)})
+	if err != nil {
+		t.Fatalf("识别失败: %v", err)
+	}
+	// 整段识别只返回最终结果
+	if len(results) != 1 || results[0] != "你好世界" {
+		t.Errorf("识别结果错误: %v", results)
+	}
+}
+
+func TestSherpaSTT_StreamOddChunks(t *testing.T) {
+	// 记录服务端收到的 float32 样本
+	samples := make(chan []float32, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		var received []float32
+		for {
+			msgType, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			if msgType == websocket.TextMessage {
+				samples <- received
+				return
+			}
+			for i := 0; i+4 <= len(data); i += 4 {
+				received = append(received, math.Float32frombits(binary.LittleEndian.Uint32(data[i:])))
+			}
+		}
+	}))
+	defer server.Close()
+
+	stream, err := NewSherpaSTT(server.URL).NewStream(context.Background())
+	if err != nil {
+		t.Fatalf("打开识别流失败: %v", err)
+	}
+
+	// 0x4000 (0.5) 和 0xC000 (-0.5) 两个样本被拆成 1 + 3 字节两个分片
+	for _, chunk := range [][]byte{{0x00}, {0x40, 0x00, 0xC0}} {
+		if err := stream.Write(chunk); err != nil {
+			t.Fatalf("推送音频失败: %v", err)
+		}
+	}
+	stream.Close()
+
+	select {
+	case got := <-samples:
+		if len(got) != 2 || got[0] != 0.5 || got[1] != -0.5 {
+			t.Errorf("样本未对齐: %v", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待服务端收到音频超时")
+	}
+}
+
+func TestSherpaSTT_StreamCancel(t *testing.T) {
+	server := newFakeSherpaServer(t)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := NewSherpaSTT(server.URL).NewStream(ctx)
+	if err != nil {
+		t.Fatalf("打开识别流失败: %v", err)
+	}
+
+	cancel()
+
+	// 取消后结果通道应被关闭
+	select {
+	case <-drain(stream.Results()):
+	case <-time.After(2 * time.Second):
+		t.Fatal("取消后结果通道未关闭")
+	}
+}
+
+// drain 读完结果通道，通道关闭后返回
+func drain(results <-chan STTResult) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range results {
+		}
+	}()
+	return done
+}
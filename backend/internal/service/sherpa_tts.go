@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -43,7 +44,7 @@ func NewSherpaTTSWithDir(addr, audioDir string) *SherpaTTS {
 }
 
 // Synthesize 合成语音
-func (s *SherpaTTS) Synthesize(options TTSOptions) ([]byte, error) {
+func (s *SherpaTTS) Synthesize(ctx context.Context, options TTSOptions) ([]byte, error) {
 	// Construct URL: /generate
 	reqURL := s.addr + "/generate"
 	
@@ -55,7 +56,13 @@ func (s *SherpaTTS) Synthesize(options TTSOptions) ([]byte, error) {
 	// Let's assume options.Spd=5 maps to 1.0.
 	
 	// Send request
-	resp, err := http.PostForm(reqURL, params)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", reqURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("sherpa tts build request failed: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("sherpa tts request failed: %w", err)
 	}
@@ -75,8 +82,8 @@ func (s *SherpaTTS) Synthesize(options TTSOptions) ([]byte, error) {
 }
 
 // SynthesizeToFile 合成语音并保存到文件
-func (s *SherpaTTS) SynthesizeToFile(options TTSOptions) (string, error) {
-	audioData, err := s.Synthesize(options)
+func (s *SherpaTTS) SynthesizeToFile(ctx context.Context, options TTSOptions) (string, error) {
+	audioData, err := s.Synthesize(ctx, options)
 	if err != nil {
 		return "", err
 	}
@@ -93,8 +100,8 @@ func (s *SherpaTTS) SynthesizeToFile(options TTSOptions) (string, error) {
 }
 
 // SynthesizeBase64 合成语音并返回 Base64
-func (s *SherpaTTS) SynthesizeBase64(options TTSOptions) (string, error) {
-	audioData, err := s.Synthesize(options)
+func (s *SherpaTTS) SynthesizeBase64(ctx context.Context, options TTSOptions) (string, error) {
+	audioData, err := s.Synthesize(ctx, options)
 	if err != nil {
 		return "", err
 	}
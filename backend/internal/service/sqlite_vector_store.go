@@ -0,0 +1,224 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SQLiteVectorStore 把向量存入主数据库的 knowledge_vectors 表
+// 与知识行在同一个 SQLite 文件中：可以和知识一起在一个事务里写入，
+// 删除知识时由触发器级联删除向量，备份数据库文件即同时备份了向量。
+// 搜索为全表暴力计算，适合个人知识库规模；数据量大时使用 HNSWVectorStore。
+type SQLiteVectorStore struct {
+	db *Database
+}
+
+// NewSQLiteVectorStore 创建 SQLite 向量存储（表结构由 Database 初始化）
+func NewSQLiteVectorStore(db *Database) *SQLiteVectorStore {
+	return &SQLiteVectorStore{db: db}
+}
+
+// Add 添加向量
+func (s *SQLiteVectorStore) Add(id string, embedding []float32, metadata map[string]interface{}) error {
+	return s.addTx(s.db.db, VectorItem{ID: id, Embedding: embedding, Metadata: metadata})
+}
+
+// AddBatch 批量添加向量（一个事务）
+func (s *SQLiteVectorStore) AddBatch(items []VectorItem) error {
+	return s.db.WithTx(func(tx *sql.Tx) error {
+		for _, item := range items {
+			if err := s.addTx(tx, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// addTx 在给定的连接或事务中写入向量（供 KnowledgeRepository 与知识行同事务写入）
+func (s *SQLiteVectorStore) addTx(ex execer, item VectorItem) error {
+	metadataJSON, err := json.Marshal(item.Metadata)
+	if err != nil {
+		return fmt.Errorf("序列化元数据失败: %w", err)
+	}
+
+	_, err = ex.Exec(`INSERT OR REPLACE INTO knowledge_vectors (knowledge_id, embedding, metadata, updated_at)
+			  VALUES (?, ?, ?, ?)`,
+		item.ID, encodeVectorBlob(item.Embedding), string(metadataJSON), time.Now().Unix())
+	return err
+}
+
+// Delete 删除向量
+func (s *SQLiteVectorStore) Delete(id string) error {
+	_, err := s.db.db.Exec(`DELETE FROM knowledge_vectors WHERE knowledge_id = ?`, id)
+	return err
+}
+
+// Get 获取向量条目
+func (s *SQLiteVectorStore) Get(id string) (*VectorItem, error) {
+	var blob []byte
+	var metadataJSON sql.NullString
+	err := s.db.db.QueryRow(`SELECT embedding, metadata FROM knowledge_vectors WHERE knowledge_id = ?`, id).
+		Scan(&blob, &metadataJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	item := &VectorItem{ID: id, Embedding: decodeVectorBlob(blob)}
+	if metadataJSON.Valid {
+		json.Unmarshal([]byte(metadataJSON.String), &item.Metadata)
+	}
+	return item, nil
+}
+
+// IDs 列出所有向量 ID
+func (s *SQLiteVectorStore) IDs() ([]string, error) {
+	rows, err := s.db.db.Query(`SELECT knowledge_id FROM knowledge_vectors`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Search 搜索相似向量（全表扫描）
+// 无过滤条件时只为命中的结果解析元数据；有过滤条件时逐条解析元数据判断
+func (s *SQLiteVectorStore) Search(queryVector []float32, limit int, filter *VectorFilter) ([]VectorResult, error) {
+	if !filter.IsEmpty() {
+		return s.searchFiltered(queryVector, limit, filter)
+	}
+
+	rows, err := s.db.db.Query(`SELECT knowledge_id, embedding FROM knowledge_vectors`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []VectorResult
+	for rows.Next() {
+		var id string
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			return nil, err
+		}
+		results = append(results, VectorResult{ID: id, Score: cosineSimilarity(queryVector, decodeVectorBlob(blob))})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if limit < len(results) {
+		results = results[:limit]
+	}
+	if len(results) == 0 {
+		return []VectorResult{}, nil
+	}
+
+	return results, s.loadMetadata(results)
+}
+
+// searchFiltered 带过滤条件的搜索
+func (s *SQLiteVectorStore) searchFiltered(queryVector []float32, limit int, filter *VectorFilter) ([]VectorResult, error) {
+	rows, err := s.db.db.Query(`SELECT knowledge_id, embedding, metadata FROM knowledge_vectors`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []VectorResult{}
+	for rows.Next() {
+		var id string
+		var blob []byte
+		var metadataJSON sql.NullString
+		if err := rows.Scan(&id, &blob, &metadataJSON); err != nil {
+			return nil, err
+		}
+
+		var metadata map[string]interface{}
+		if metadataJSON.Valid {
+			json.Unmarshal([]byte(metadataJSON.String), &metadata)
+		}
+		if !filter.Match(metadata) {
+			continue
+		}
+		results = append(results, VectorResult{
+			ID:       id,
+			Score:    cosineSimilarity(queryVector, decodeVectorBlob(blob)),
+			Metadata: metadata,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if limit < len(results) {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// loadMetadata 批量读取结果的元数据
+func (s *SQLiteVectorStore) loadMetadata(results []VectorResult) error {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(results)), ",")
+	args := make([]interface{}, len(results))
+	index := make(map[string]int, len(results))
+	for i, r := range results {
+		args[i] = r.ID
+		index[r.ID] = i
+	}
+
+	rows, err := s.db.db.Query(`SELECT knowledge_id, metadata FROM knowledge_vectors WHERE knowledge_id IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var metadataJSON sql.NullString
+		if err := rows.Scan(&id, &metadataJSON); err != nil {
+			return err
+		}
+		if metadataJSON.Valid {
+			json.Unmarshal([]byte(metadataJSON.String), &results[index[id]].Metadata)
+		}
+	}
+	return rows.Err()
+}
+
+// encodeVectorBlob float32 小端序编码
+func encodeVectorBlob(v []float32) []byte {
+	blob := make([]byte, len(v)*4)
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(blob[i*4:], math.Float32bits(x))
+	}
+	return blob
+}
+
+func decodeVectorBlob(blob []byte) []float32 {
+	v := make([]float32, len(blob)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(blob[i*4:]))
+	}
+	return v
+}
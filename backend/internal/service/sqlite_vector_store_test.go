@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestSQLiteVectorStore(t *testing.T) (*SQLiteVectorStore, *Database) {
+	db, err := NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewSQLiteVectorStore(db), db
+}
+
+func TestSQLiteVectorStore(t *testing.T) {
+	t.Run("增删查和搜索", func(t *testing.T) {
+		store, _ := newTestSQLiteVectorStore(t)
+
+		store.Add("a", []float32{1, 0}, map[string]interface{}{"title": "A"})
+		store.AddBatch([]VectorItem{
+			{ID: "b", Embedding: []float32{0, 1}, Metadata: map[string]interface{}{"title": "B"}},
+			{ID: "c", Embedding: []float32{0.9, 0.1}, Metadata: map[string]interface{}{"title": "C"}},
+		})
+
+		results, err := store.Search([]float32{1, 0}, 2, nil)
+		if err != nil {
+			t.Fatalf("搜索失败: %v", err)
+		}
+		if len(results) != 2 || results[0].ID != "a" || results[1].ID != "c" {
+			t.Fatalf("搜索结果错误: %+v", results)
+		}
+		if results[1].Metadata["title"] != "C" {
+			t.Errorf("结果应带元数据: %+v", results[1])
+		}
+
+		item, _ := store.Get("b")
+		if item == nil || item.Embedding[1] != 1 || item.Metadata["title"] != "B" {
+			t.Errorf("Get 结果错误: %+v", item)
+		}
+
+		store.Delete("b")
+		if item, _ := store.Get("b"); item != nil {
+			t.Errorf("应已删除")
+		}
+		if ids, _ := store.IDs(); len(ids) != 2 {
+			t.Errorf("应剩 2 条, 实际 %v", ids)
+		}
+	})
+
+	t.Run("删除知识级联删除向量", func(t *testing.T) {
+		store, db := newTestSQLiteVectorStore(t)
+		db.SaveKnowledge(&Knowledge{ID: "kb_1", Content: "内容", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+		store.Add("kb_1", []float32{1, 0}, nil)
+
+		// 重新保存知识（INSERT OR REPLACE）不应误删向量
+		db.SaveKnowledge(&Knowledge{ID: "kb_1", Content: "内容", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+		if item, _ := store.Get("kb_1"); item == nil {
+			t.Fatalf("重新保存知识后向量不应丢失")
+		}
+
+		if err := db.DeleteKnowledge("kb_1"); err != nil {
+			t.Fatalf("删除失败: %v", err)
+		}
+		if item, _ := store.Get("kb_1"); item != nil {
+			t.Errorf("向量应随知识级联删除")
+		}
+	})
+}
+
+func TestKnowledgeRepository_SQLiteVectorStore(t *testing.T) {
+	ctx := context.Background()
+
+	newRepo := func(t *testing.T) (*KnowledgeRepository, *SQLiteVectorStore) {
+		repo, rag, _ := newTestKnowledgeRepository(t)
+		store := NewSQLiteVectorStore(repo.db)
+		rag.vectorStore = store
+		return repo, store
+	}
+
+	t.Run("知识和向量同事务写入", func(t *testing.T) {
+		repo, store := newRepo(t)
+		k := &Knowledge{ID: "kb_1", Title: "标题", Content: "内容"}
+		if err := repo.Save(ctx, k); err != nil {
+			t.Fatalf("保存失败: %v", err)
+		}
+		item, _ := store.Get("kb_1")
+		if item == nil || item.Metadata["title"] != "标题" {
+			t.Fatalf("向量应与知识一起写入: %+v", item)
+		}
+
+		k.Title = "新标题"
+		if err := repo.Update(ctx, k, k.Version); err != nil {
+			t.Fatalf("更新失败: %v", err)
+		}
+		if item, _ := store.Get("kb_1"); item.Metadata["title"] != "新标题" {
+			t.Errorf("向量元数据未更新: %v", item.Metadata)
+		}
+
+		if err := repo.Delete(ctx, "kb_1"); err != nil {
+			t.Fatalf("删除失败: %v", err)
+		}
+		if item, _ := store.Get("kb_1"); item != nil {
+			t.Errorf("向量应已删除")
+		}
+	})
+
+	t.Run("向量写入失败时知识一起回滚", func(t *testing.T) {
+		repo, _ := newRepo(t)
+		_, err := repo.db.db.Exec(`CREATE TRIGGER fail_vector_insert BEFORE INSERT ON knowledge_vectors
+			BEGIN SELECT RAISE(ABORT, '模拟写入失败'); END`)
+		if err != nil {
+			t.Fatalf("创建触发器失败: %v", err)
+		}
+
+		if err := repo.Save(ctx, &Knowledge{ID: "kb_1", Content: "内容"}); err == nil {
+			t.Fatalf("应返回错误")
+		}
+		if got, _ := repo.Get("kb_1"); got != nil {
+			t.Errorf("事务失败时知识不应写入")
+		}
+	})
+
+	t.Run("向量化失败时只写知识，由对账补建", func(t *testing.T) {
+		repo, store := newRepo(t)
+		repo.rag.embeddingClient.baseURL = "http://127.0.0.1:1"
+
+		if err := repo.Save(ctx, &Knowledge{ID: "kb_1", Content: "内容"}); err != nil {
+			t.Fatalf("向量化失败不应影响保存: %v", err)
+		}
+		if item, _ := store.Get("kb_1"); item != nil {
+			t.Errorf("不应有向量")
+		}
+
+		repo.rag.embeddingClient.baseURL = newTestRAGBaseURL(t)
+		report, err := repo.Reconcile(ctx, true)
+		if err != nil || report.Indexed != 1 {
+			t.Fatalf("对账应补建 1 条: %+v, %v", report, err)
+		}
+		if item, _ := store.Get("kb_1"); item == nil {
+			t.Errorf("对账后应有向量")
+		}
+	})
+}
+
+// newTestRAGBaseURL 返回一个新的假 Embedding 服务地址
+func newTestRAGBaseURL(t *testing.T) string {
+	rag, _ := newTestRAGService(t)
+	return rag.embeddingClient.baseURL
+}
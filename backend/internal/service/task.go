@@ -0,0 +1,260 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 待办状态
+const (
+	TaskPending = "pending"
+	TaskDone    = "done"
+)
+
+var (
+	// ErrTaskNotFound 待办不存在
+	ErrTaskNotFound = errors.New("待办不存在")
+	// ErrTaskDone 待办已完成（不能再推迟）
+	ErrTaskDone = errors.New("待办已完成")
+)
+
+// Task 待办事项
+type Task struct {
+	ID          int64      `json:"id"`
+	Title       string     `json:"title"`
+	Status      string     `json:"status"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	AllDay      bool       `json:"all_day"` // 只有截止日期没有具体时刻
+	KnowledgeID string     `json:"knowledge_id,omitempty"`
+	SessionID   string     `json:"session_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// Overdue 待办是否已过期（只有截止日期的待办过了当天才算过期）
+func (t *Task) Overdue(now time.Time) bool {
+	if t.Status != TaskPending || t.DueAt == nil {
+		return false
+	}
+	if t.AllDay {
+		return !now.Before(t.DueAt.AddDate(0, 0, 1))
+	}
+	return t.DueAt.Before(now)
+}
+
+// TaskFilter 待办查询条件
+type TaskFilter struct {
+	Status         string     // 为空时不限状态
+	DueBefore      *time.Time // 只返回截止时间早于该时间的待办
+	IncludeUndated bool       // DueBefore 非空时是否同时返回没有截止时间的待办
+	Limit          int
+}
+
+// TaskStore 待办事项存储（与知识共用数据库，表结构由 Database 初始化）
+type TaskStore struct {
+	db *Database
+}
+
+// NewTaskStore 创建待办存储
+func NewTaskStore(db *Database) *TaskStore {
+	return &TaskStore{db: db}
+}
+
+// SyncKnowledge 按知识的 ActionItems 同步待办：新增的行动项生成待办，已删除的行动项对应的未完成待办一并删除
+func (s *TaskStore) SyncKnowledge(k *Knowledge) error {
+	return s.db.WithTx(func(tx *sql.Tx) error {
+		return s.syncKnowledge(tx, k)
+	})
+}
+
+func (s *TaskStore) syncKnowledge(q queryer, k *Knowledge) error {
+	wanted := make(map[string]bool)
+	for _, item := range k.ActionItems {
+		wanted[strings.TrimSpace(item)] = true
+	}
+
+	rows, err := q.Query(`SELECT id, title, status FROM tasks WHERE knowledge_id = ?`, k.ID)
+	if err != nil {
+		return fmt.Errorf("读取待办失败: %w", err)
+	}
+	existing := make(map[string]bool)
+	var stale []int64
+	for rows.Next() {
+		var id int64
+		var title, status string
+		if err := rows.Scan(&id, &title, &status); err != nil {
+			rows.Close()
+			return fmt.Errorf("读取待办失败: %w", err)
+		}
+		existing[title] = true
+		if !wanted[title] && status == TaskPending {
+			stale = append(stale, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("读取待办失败: %w", err)
+	}
+
+	for _, id := range stale {
+		if _, err := q.Exec(`DELETE FROM tasks WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("删除待办失败: %w", err)
+		}
+	}
+
+	// 行动项中的时间相对知识的创建时间解析（"明天交报告" 指记录那天的明天）
+	base := k.CreatedAt
+	if base.IsZero() {
+		base = time.Now()
+	}
+	now := time.Now().Unix()
+	for _, item := range k.ActionItems {
+		title := strings.TrimSpace(item)
+		if title == "" || existing[title] {
+			continue
+		}
+		existing[title] = true
+
+		var dueAt interface{}
+		allDay := false
+		if due := ParseDueDate(title, base); due != nil {
+			dueAt, allDay = due.Time.Unix(), due.AllDay
+		}
+		_, err := q.Exec(`INSERT INTO tasks (title, status, due_at, all_day, knowledge_id, session_id, created_at, updated_at)
+				  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, title, TaskPending, dueAt, allDay, k.ID, k.SessionID, now, now)
+		if err != nil {
+			return fmt.Errorf("写入待办失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// Backfill 为有行动项但还没有生成待办的知识生成待办（升级前保存的知识），返回处理的条数
+func (s *TaskStore) Backfill() (int, error) {
+	knowledges, err := s.db.queryKnowledge(`SELECT ` + knowledgeColumns + ` FROM knowledge
+		WHERE COALESCE(action_items, '[]') NOT IN ('', '[]', 'null')
+		AND id NOT IN (SELECT knowledge_id FROM tasks WHERE knowledge_id IS NOT NULL)`)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := range knowledges {
+		k := &knowledges[i]
+		if len(k.ActionItems) == 0 {
+			continue
+		}
+		if err := s.SyncKnowledge(k); err != nil {
+			return count, fmt.Errorf("生成待办失败 (ID: %s): %w", k.ID, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// taskColumns 待办查询列
+const taskColumns = `id, title, status, due_at, all_day, COALESCE(knowledge_id, ''), COALESCE(session_id, ''), created_at, updated_at, completed_at`
+
+func scanTask(row rowScanner) (*Task, error) {
+	var t Task
+	var dueAt, completedAt sql.NullInt64
+	var createdAt, updatedAt int64
+	err := row.Scan(&t.ID, &t.Title, &t.Status, &dueAt, &t.AllDay, &t.KnowledgeID, &t.SessionID,
+		&createdAt, &updatedAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+	if dueAt.Valid {
+		due := time.Unix(dueAt.Int64, 0)
+		t.DueAt = &due
+	}
+	if completedAt.Valid {
+		completed := time.Unix(completedAt.Int64, 0)
+		t.CompletedAt = &completed
+	}
+	t.CreatedAt = time.Unix(createdAt, 0)
+	t.UpdatedAt = time.Unix(updatedAt, 0)
+	return &t, nil
+}
+
+// GetTask 获取待办，不存在时返回 ErrTaskNotFound
+func (s *TaskStore) GetTask(id int64) (*Task, error) {
+	t, err := scanTask(s.db.db.QueryRow(`SELECT `+taskColumns+` FROM tasks WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrTaskNotFound
+	}
+	return t, err
+}
+
+// ListTasks 按截止时间列出待办（没有截止时间的排在最后）
+func (s *TaskStore) ListTasks(filter TaskFilter) ([]Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE 1 = 1`
+	var args []interface{}
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+	if filter.DueBefore != nil {
+		if filter.IncludeUndated {
+			query += ` AND (due_at < ? OR due_at IS NULL)`
+		} else {
+			query += ` AND due_at < ?`
+		}
+		args = append(args, filter.DueBefore.Unix())
+	}
+	query += ` ORDER BY due_at IS NULL, due_at, id`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []Task{}
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *t)
+	}
+	return tasks, rows.Err()
+}
+
+// CompleteTask 标记待办完成（已完成时不做修改）
+func (s *TaskStore) CompleteTask(id int64) (*Task, error) {
+	now := time.Now().Unix()
+	_, err := s.db.db.Exec(`UPDATE tasks SET status = ?, completed_at = ?, updated_at = ? WHERE id = ? AND status = ?`,
+		TaskDone, now, now, id, TaskPending)
+	if err != nil {
+		return nil, fmt.Errorf("更新待办失败: %w", err)
+	}
+	// 不存在时由 GetTask 返回 ErrTaskNotFound
+	return s.GetTask(id)
+}
+
+// SnoozeTask 把未完成的待办推迟到 due；已完成的待办返回 ErrTaskDone
+func (s *TaskStore) SnoozeTask(id int64, due DueDate) (*Task, error) {
+	t, err := s.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+	if t.Status != TaskPending {
+		return nil, ErrTaskDone
+	}
+
+	_, err = s.db.db.Exec(`UPDATE tasks SET due_at = ?, all_day = ?, updated_at = ? WHERE id = ?`,
+		due.Time.Unix(), due.AllDay, time.Now().Unix(), id)
+	if err != nil {
+		return nil, fmt.Errorf("更新待办失败: %w", err)
+	}
+	return s.GetTask(id)
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestTaskStore 创建使用临时数据库的待办存储和只写数据库的知识仓库
+func newTestTaskStore(t *testing.T) (*TaskStore, *KnowledgeRepository) {
+	db, err := NewDatabase(t.TempDir())
+	if err != nil {
+		t.Fatalf("创建数据库失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewTaskStore(db), NewKnowledgeRepository(db, nil)
+}
+
+func taskTitles(tasks []Task) []string {
+	titles := make([]string, len(tasks))
+	for i, task := range tasks {
+		titles[i] = task.Title
+	}
+	return titles
+}
+
+func TestTaskStore_SyncKnowledge(t *testing.T) {
+	ctx := context.Background()
+	// 2026-03-11 是周三
+	created := time.Date(2026, 3, 11, 10, 0, 0, 0, time.Local)
+
+	t.Run("保存知识时生成待办并解析截止时间", func(t *testing.T) {
+		tasks, repo := newTestTaskStore(t)
+		k := &Knowledge{ID: "kb_1", Title: "周会", SessionID: "sess_1", CreatedAt: created,
+			ActionItems: []string{"明天下午三点交周报", "整理会议纪要"}}
+		if err := repo.Save(ctx, k); err != nil {
+			t.Fatalf("保存失败: %v", err)
+		}
+
+		list, err := tasks.ListTasks(TaskFilter{})
+		if err != nil {
+			t.Fatalf("查询失败: %v", err)
+		}
+		if len(list) != 2 {
+			t.Fatalf("期望 2 条待办, 实际 %v", taskTitles(list))
+		}
+		report := list[0]
+		want := time.Date(2026, 3, 12, 15, 0, 0, 0, time.Local)
+		if report.Title != "明天下午三点交周报" || report.DueAt == nil || !report.DueAt.Equal(want) || report.AllDay {
+			t.Errorf("截止时间应相对知识创建时间解析: %+v", report)
+		}
+		if report.KnowledgeID != "kb_1" || report.SessionID != "sess_1" || report.Status != TaskPending {
+			t.Errorf("待办来源或状态错误: %+v", report)
+		}
+		if list[1].DueAt != nil {
+			t.Errorf("没有时间信息的待办不应有截止时间: %+v", list[1])
+		}
+	})
+
+	t.Run("更新行动项时删除过时的未完成待办", func(t *testing.T) {
+		tasks, repo := newTestTaskStore(t)
+		k := &Knowledge{ID: "kb_1", Title: "周会", CreatedAt: created,
+			ActionItems: []string{"交周报", "订会议室", "发邮件"}}
+		if err := repo.Save(ctx, k); err != nil {
+			t.Fatalf("保存失败: %v", err)
+		}
+		list, _ := tasks.ListTasks(TaskFilter{})
+		var done int64
+		for _, task := range list {
+			if task.Title == "发邮件" {
+				done = task.ID
+			}
+		}
+		if _, err := tasks.CompleteTask(done); err != nil {
+			t.Fatalf("完成待办失败: %v", err)
+		}
+
+		k.ActionItems = []string{"交周报", "预约面试"}
+		if err := repo.Update(ctx, k, k.Version); err != nil {
+			t.Fatalf("更新失败: %v", err)
+		}
+
+		list, _ = tasks.ListTasks(TaskFilter{})
+		got := map[string]string{}
+		for _, task := range list {
+			got[task.Title] = task.Status
+		}
+		want := map[string]string{"交周报": TaskPending, "预约面试": TaskPending, "发邮件": TaskDone}
+		if len(got) != len(want) {
+			t.Fatalf("期望 %v, 实际 %v", want, got)
+		}
+		for title, status := range want {
+			if got[title] != status {
+				t.Errorf("%s 期望状态 %s, 实际 %q", title, status, got[title])
+			}
+		}
+	})
+
+	t.Run("删除知识后保留待办", func(t *testing.T) {
+		tasks, repo := newTestTaskStore(t)
+		k := &Knowledge{ID: "kb_1", Title: "周会", ActionItems: []string{"交周报"}}
+		if err := repo.Save(ctx, k); err != nil {
+			t.Fatalf("保存失败: %v", err)
+		}
+		if err := repo.Delete(ctx, "kb_1"); err != nil {
+			t.Fatalf("删除失败: %v", err)
+		}
+
+		list, _ := tasks.ListTasks(TaskFilter{})
+		if len(list) != 1 || list[0].KnowledgeID != "" {
+			t.Errorf("待办应保留并解除与知识的关联: %+v", list)
+		}
+	})
+
+	t.Run("为旧知识补建待办", func(t *testing.T) {
+		tasks, repo := newTestTaskStore(t)
+		// 直接写数据库，模拟升级前保存的知识
+		if err := repo.db.SaveKnowledge(&Knowledge{ID: "kb_old", Title: "旧知识", ActionItems: []string{"续费域名"}}); err != nil {
+			t.Fatalf("保存失败: %v", err)
+		}
+		if err := repo.db.SaveKnowledge(&Knowledge{ID: "kb_none", Title: "没有行动项"}); err != nil {
+			t.Fatalf("保存失败: %v", err)
+		}
+
+		count, err := tasks.Backfill()
+		if err != nil || count != 1 {
+			t.Fatalf("期望补建 1 条, 实际 %d (err: %v)", count, err)
+		}
+		if count, _ := tasks.Backfill(); count != 0 {
+			t.Errorf("重复补建应跳过已生成待办的知识, 实际 %d", count)
+		}
+		list, _ := tasks.ListTasks(TaskFilter{})
+		if len(list) != 1 || list[0].Title != "续费域名" || list[0].KnowledgeID != "kb_old" {
+			t.Errorf("补建结果错误: %+v", list)
+		}
+	})
+}
+
+func TestTaskStore_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	tasks, repo := newTestTaskStore(t)
+	k := &Knowledge{ID: "kb_1", Title: "计划", ActionItems: []string{"明天交报告", "三天后复查", "整理笔记"}}
+	if err := repo.Save(ctx, k); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	list, _ := tasks.ListTasks(TaskFilter{})
+	ids := map[string]int64{}
+	for _, task := range list {
+		ids[task.Title] = task.ID
+	}
+
+	t.Run("按截止时间排序，没有截止时间的排在最后", func(t *testing.T) {
+		got := taskTitles(list)
+		if len(got) != 3 || got[0] != "明天交报告" || got[1] != "三天后复查" || got[2] != "整理笔记" {
+			t.Errorf("排序错误: %v", got)
+		}
+	})
+
+	t.Run("按截止时间过滤", func(t *testing.T) {
+		until := today.AddDate(0, 0, 2)
+		dated, _ := tasks.ListTasks(TaskFilter{Status: TaskPending, DueBefore: &until})
+		if got := taskTitles(dated); len(got) != 1 || got[0] != "明天交报告" {
+			t.Errorf("期望只有明天到期的待办, 实际 %v", got)
+		}
+		withUndated, _ := tasks.ListTasks(TaskFilter{Status: TaskPending, DueBefore: &until, IncludeUndated: true})
+		if got := taskTitles(withUndated); len(got) != 2 || got[1] != "整理笔记" {
+			t.Errorf("期望包含没有截止时间的待办, 实际 %v", got)
+		}
+	})
+
+	t.Run("推迟待办", func(t *testing.T) {
+		due := DueDate{Time: today.AddDate(0, 0, 7).Add(9 * time.Hour)}
+		task, err := tasks.SnoozeTask(ids["明天交报告"], due)
+		if err != nil {
+			t.Fatalf("推迟失败: %v", err)
+		}
+		if !task.DueAt.Equal(due.Time) || task.AllDay {
+			t.Errorf("截止时间未更新: %+v", task)
+		}
+	})
+
+	t.Run("完成待办", func(t *testing.T) {
+		task, err := tasks.CompleteTask(ids["整理笔记"])
+		if err != nil {
+			t.Fatalf("完成失败: %v", err)
+		}
+		if task.Status != TaskDone || task.CompletedAt == nil {
+			t.Errorf("状态未更新: %+v", task)
+		}
+		again, err := tasks.CompleteTask(ids["整理笔记"])
+		if err != nil || !again.CompletedAt.Equal(*task.CompletedAt) {
+			t.Errorf("重复完成应保持原完成时间: %+v (err: %v)", again, err)
+		}
+		if _, err := tasks.SnoozeTask(ids["整理笔记"], DueDate{Time: now}); !errors.Is(err, ErrTaskDone) {
+			t.Errorf("推迟已完成的待办应返回 ErrTaskDone, 实际 %v", err)
+		}
+	})
+
+	t.Run("待办不存在", func(t *testing.T) {
+		if _, err := tasks.GetTask(999); !errors.Is(err, ErrTaskNotFound) {
+			t.Errorf("期望 ErrTaskNotFound, 实际 %v", err)
+		}
+		if _, err := tasks.CompleteTask(999); !errors.Is(err, ErrTaskNotFound) {
+			t.Errorf("期望 ErrTaskNotFound, 实际 %v", err)
+		}
+	})
+}
+
+func TestTask_Overdue(t *testing.T) {
+	now := time.Date(2026, 3, 11, 10, 0, 0, 0, time.Local)
+	yesterday := time.Date(2026, 3, 10, 0, 0, 0, 0, time.Local)
+	today := time.Date(2026, 3, 11, 0, 0, 0, 0, time.Local)
+	earlier := time.Date(2026, 3, 11, 9, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name string
+		task Task
+		want bool
+	}{
+		{"没有截止时间", Task{Status: TaskPending}, false},
+		{"当天全天待办", Task{Status: TaskPending, DueAt: &today, AllDay: true}, false},
+		{"昨天的全天待办", Task{Status: TaskPending, DueAt: &yesterday, AllDay: true}, true},
+		{"已过截止时刻", Task{Status: TaskPending, DueAt: &earlier}, true},
+		{"已完成", Task{Status: TaskDone, DueAt: &earlier}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.task.Overdue(now); got != tt.want {
+				t.Errorf("期望 %v, 实际 %v", tt.want, got)
+			}
+		})
+	}
+}
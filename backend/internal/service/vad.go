@@ -0,0 +1,248 @@
+package service
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// VADConfig 语音活动检测配置
+// 输入固定为 16kHz 16bit 单声道 PCM（与 /ws 上传的格式一致）
+type VADConfig struct {
+	SampleRate      int     // 采样率
+	FrameMs         int     // 分析帧长度（毫秒）
+	EnergyThreshold float64 // 语音帧的最低 RMS 能量（归一化到 0~1）
+	ZCRThreshold    float64 // 浊音帧的最高过零率，超过则视为清音或噪声
+	HangoverMs      int     // 静音持续多久判定一句话结束
+	MinSpeechMs     int     // 连续语音达到多长才确认开口（过滤咳嗽、敲击等短噪声）
+	MaxSpeechMs     int     // 单句最长时长，超过后强制切分
+	PreRollMs       int     // 确认开口时向前保留的音频，避免吞掉首字
+}
+
+// DefaultVADConfig 默认 VAD 配置
+func DefaultVADConfig() VADConfig {
+	return VADConfig{
+		SampleRate:      16000,
+		FrameMs:         30,
+		EnergyThreshold: 0.02,
+		ZCRThreshold:    0.25,
+		HangoverMs:      600,
+		MinSpeechMs:     150,
+		MaxSpeechMs:     15000,
+		PreRollMs:       300,
+	}
+}
+
+// VADEventType VAD 事件类型
+type VADEventType int
+
+const (
+	VADSpeechStart VADEventType = iota // 确认用户开始说话
+	VADSpeechEnd                       // 一句话结束，Audio 为整句 PCM
+)
+
+// VADEvent VAD 事件
+type VADEvent struct {
+	Type  VADEventType
+	Audio []byte
+}
+
+// VAD 基于短时能量与过零率的语音活动检测（纯 Go 实现，无外部依赖）
+// 把连续的 PCM 流切分为一句一句的语音片段。非并发安全，每个连接各用一个实例。
+type VAD struct {
+	cfg        VADConfig
+	frameBytes int
+
+	pending []byte   // 不足一帧的残余数据
+	preRoll [][]byte // 静音期间保留的最近若干帧
+	speech  []byte   // 当前语音段（含 pre-roll）
+
+	inSpeech      bool // 是否已确认开口
+	speechFrames  int  // 候选/当前语音段中的语音帧数
+	silenceFrames int  // 连续静音帧数
+	totalFrames   int  // 当前语音段总帧数
+}
+
+// NewVAD 创建 VAD，未设置的配置项使用默认值
+func NewVAD(cfg VADConfig) *VAD {
+	def := DefaultVADConfig()
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = def.SampleRate
+	}
+	if cfg.FrameMs <= 0 {
+		cfg.FrameMs = def.FrameMs
+	}
+	if cfg.EnergyThreshold <= 0 {
+		cfg.EnergyThreshold = def.EnergyThreshold
+	}
+	if cfg.ZCRThreshold <= 0 {
+		cfg.ZCRThreshold = def.ZCRThreshold
+	}
+	if cfg.HangoverMs <= 0 {
+		cfg.HangoverMs = def.HangoverMs
+	}
+	if cfg.MinSpeechMs <= 0 {
+		cfg.MinSpeechMs = def.MinSpeechMs
+	}
+	if cfg.MaxSpeechMs <= 0 {
+		cfg.MaxSpeechMs = def.MaxSpeechMs
+	}
+	if cfg.PreRollMs < 0 {
+		cfg.PreRollMs = 0
+	}
+
+	return &VAD{
+		cfg:        cfg,
+		frameBytes: cfg.SampleRate * cfg.FrameMs / 1000 * 2,
+	}
+}
+
+// Speaking 当前是否处于已确认的语音段中
+func (v *VAD) Speaking() bool {
+	return v.inSpeech
+}
+
+// Write 输入一段 PCM，返回期间产生的事件（可能为空）
+func (v *VAD) Write(pcm []byte) []VADEvent {
+	v.pending = append(v.pending, pcm...)
+
+	var events []VADEvent
+	for len(v.pending) >= v.frameBytes {
+		frame := make([]byte, v.frameBytes)
+		copy(frame, v.pending[:v.frameBytes])
+		v.pending = v.pending[v.frameBytes:]
+
+		if event, ok := v.processFrame(frame); ok {
+			events = append(events, event)
+		}
+	}
+	// 避免 pending 底层数组无限增长
+	v.pending = append([]byte(nil), v.pending...)
+
+	return events
+}
+
+// Flush 输入结束（客户端停止收音），已确认的语音段作为最后一句输出
+func (v *VAD) Flush() []VADEvent {
+	defer v.Reset()
+	if !v.inSpeech {
+		return nil
+	}
+	audio := append(v.speech, v.pending...)
+	return []VADEvent{{Type: VADSpeechEnd, Audio: audio}}
+}
+
+// Reset 丢弃所有状态
+func (v *VAD) Reset() {
+	v.pending = nil
+	v.preRoll = nil
+	v.resetSegment()
+}
+
+// processFrame 处理一帧，返回可能产生的事件
+func (v *VAD) processFrame(frame []byte) (VADEvent, bool) {
+	rms, zcr := frameFeatures(frame)
+	isSpeech := v.isSpeechFrame(rms, zcr)
+
+	// 静音期：只维护 pre-roll
+	if v.speechFrames == 0 && !isSpeech {
+		v.pushPreRoll(frame)
+		return VADEvent{}, false
+	}
+
+	// 候选语音段开始：带上 pre-roll
+	if v.speechFrames == 0 {
+		for _, f := range v.preRoll {
+			v.speech = append(v.speech, f...)
+		}
+		v.preRoll = nil
+	}
+
+	v.speech = append(v.speech, frame...)
+	v.totalFrames++
+	if isSpeech {
+		v.speechFrames++
+		v.silenceFrames = 0
+	} else {
+		v.silenceFrames++
+	}
+
+	if !v.inSpeech {
+		// 候选段在达到最短时长前就静音了：视为噪声丢弃
+		if v.silenceFrames*v.cfg.FrameMs >= v.cfg.HangoverMs {
+			v.resetSegment()
+			return VADEvent{}, false
+		}
+		if v.speechFrames*v.cfg.FrameMs >= v.cfg.MinSpeechMs {
+			v.inSpeech = true
+			return VADEvent{Type: VADSpeechStart}, true
+		}
+		return VADEvent{}, false
+	}
+
+	// 已确认开口：静音超过 hangover 或超长时切分
+	if v.silenceFrames*v.cfg.FrameMs >= v.cfg.HangoverMs || v.totalFrames*v.cfg.FrameMs >= v.cfg.MaxSpeechMs {
+		audio := v.speech
+		v.resetSegment()
+		return VADEvent{Type: VADSpeechEnd, Audio: audio}, true
+	}
+
+	return VADEvent{}, false
+}
+
+// isSpeechFrame 判断一帧是否为语音
+// 浊音能量高、过零率低；清音（s、sh 等）能量较低但过零率高，只在语音段内计入，
+// 避免把持续的高频噪声（风扇、电流声）当作开口
+func (v *VAD) isSpeechFrame(rms, zcr float64) bool {
+	if rms >= v.cfg.EnergyThreshold && zcr <= v.cfg.ZCRThreshold {
+		return true
+	}
+	return v.speechFrames > 0 && rms >= v.cfg.EnergyThreshold/2 && zcr > v.cfg.ZCRThreshold
+}
+
+// pushPreRoll 保留最近 PreRollMs 的静音帧
+func (v *VAD) pushPreRoll(frame []byte) {
+	maxFrames := v.cfg.PreRollMs / v.cfg.FrameMs
+	if maxFrames == 0 {
+		return
+	}
+	v.preRoll = append(v.preRoll, frame)
+	if len(v.preRoll) > maxFrames {
+		v.preRoll = v.preRoll[len(v.preRoll)-maxFrames:]
+	}
+}
+
+// resetSegment 结束当前语音段
+func (v *VAD) resetSegment() {
+	v.speech = nil
+	v.inSpeech = false
+	v.speechFrames = 0
+	v.silenceFrames = 0
+	v.totalFrames = 0
+}
+
+// frameFeatures 计算一帧 16bit PCM 的 RMS 能量（归一化）和过零率
+func frameFeatures(frame []byte) (rms, zcr float64) {
+	n := len(frame) / 2
+	if n == 0 {
+		return 0, 0
+	}
+
+	var sum float64
+	crossings := 0
+	prev := int16(0)
+	for i := 0; i < n; i++ {
+		sample := int16(binary.LittleEndian.Uint16(frame[i*2:]))
+		s := float64(sample) / 32768.0
+		sum += s * s
+		if i > 0 && (sample >= 0) != (prev >= 0) {
+			crossings++
+		}
+		prev = sample
+	}
+
+	rms = math.Sqrt(sum / float64(n))
+	if n > 1 {
+		zcr = float64(crossings) / float64(n-1)
+	}
+	return rms, zcr
+}
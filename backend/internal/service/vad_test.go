@@ -0,0 +1,152 @@
+package service
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// genTone 生成指定时长的正弦波 PCM（模拟浊音）
+func genTone(ms int, amplitude float64) []byte {
+	n := 16000 * ms / 1000
+	pcm := make([]byte, n*2)
+	for i := 0; i < n; i++ {
+		v := amplitude * math.Sin(2*math.Pi*200*float64(i)/16000)
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(v*32767)))
+	}
+	return pcm
+}
+
+// genSilence 生成静音 PCM
+func genSilence(ms int) []byte {
+	return make([]byte, 16000*ms/1000*2)
+}
+
+// genNoise 生成白噪声 PCM（过零率高）
+func genNoise(ms int, amplitude float64) []byte {
+	r := rand.New(rand.NewSource(1))
+	n := 16000 * ms / 1000
+	pcm := make([]byte, n*2)
+	for i := 0; i < n; i++ {
+		v := amplitude * (r.Float64()*2 - 1)
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(v*32767)))
+	}
+	return pcm
+}
+
+func concatPCM(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func TestVAD_SegmentsUtterance(t *testing.T) {
+	vad := NewVAD(DefaultVADConfig())
+
+	events := vad.Write(concatPCM(genSilence(500), genTone(1000, 0.3), genSilence(1000)))
+
+	if len(events) != 2 {
+		t.Fatalf("期望 2 个事件 (开始+结束), 实际 %d", len(events))
+	}
+	if events[0].Type != VADSpeechStart || events[1].Type != VADSpeechEnd {
+		t.Fatalf("事件顺序错误: %+v", events)
+	}
+
+	// 语音段 = pre-roll 300ms + 语音 1000ms + hangover 600ms
+	durationMs := len(events[1].Audio) / 32
+	if durationMs < 1800 || durationMs > 2000 {
+		t.Errorf("语音段时长不符合预期: %dms", durationMs)
+	}
+	if vad.Speaking() {
+		t.Errorf("句子结束后不应处于说话状态")
+	}
+}
+
+func TestVAD_StreamedInSmallChunks(t *testing.T) {
+	vad := NewVAD(DefaultVADConfig())
+	pcm := concatPCM(genTone(600, 0.3), genSilence(800), genTone(600, 0.3), genSilence(800))
+
+	// 模拟客户端 ScriptProcessor 的不规则分片
+	var events []VADEvent
+	for i := 0; i < len(pcm); i += 1000 {
+		end := i + 1000
+		if end > len(pcm) {
+			end = len(pcm)
+		}
+		events = append(events, vad.Write(pcm[i:end])...)
+	}
+
+	ends := 0
+	for _, e := range events {
+		if e.Type == VADSpeechEnd {
+			ends++
+		}
+	}
+	if ends != 2 {
+		t.Errorf("期望切分出 2 句话, 实际 %d", ends)
+	}
+}
+
+func TestVAD_IgnoresShortAndNoise(t *testing.T) {
+	t.Run("短促噪声不算开口", func(t *testing.T) {
+		vad := NewVAD(DefaultVADConfig())
+		events := vad.Write(concatPCM(genTone(60, 0.5), genSilence(1000)))
+		if len(events) != 0 {
+			t.Errorf("短于 MinSpeechMs 的声音不应触发事件: %+v", events)
+		}
+	})
+
+	t.Run("高过零率噪声不算开口", func(t *testing.T) {
+		vad := NewVAD(DefaultVADConfig())
+		events := vad.Write(genNoise(1000, 0.3))
+		if len(events) != 0 {
+			t.Errorf("白噪声不应触发事件, 实际 %d 个", len(events))
+		}
+	})
+
+	t.Run("低于能量阈值", func(t *testing.T) {
+		vad := NewVAD(DefaultVADConfig())
+		events := vad.Write(concatPCM(genTone(1000, 0.005), genSilence(1000)))
+		if len(events) != 0 {
+			t.Errorf("能量过低的声音不应触发事件: %+v", events)
+		}
+	})
+}
+
+func TestVAD_MaxSpeechSplit(t *testing.T) {
+	cfg := DefaultVADConfig()
+	cfg.MaxSpeechMs = 1000
+	vad := NewVAD(cfg)
+
+	events := vad.Write(genTone(2500, 0.3))
+
+	ends := 0
+	for _, e := range events {
+		if e.Type == VADSpeechEnd {
+			ends++
+		}
+	}
+	if ends != 2 {
+		t.Errorf("超长语音应被强制切分, 期望 2 段, 实际 %d", ends)
+	}
+}
+
+func TestVAD_Flush(t *testing.T) {
+	vad := NewVAD(DefaultVADConfig())
+
+	events := vad.Write(genTone(500, 0.3))
+	if len(events) != 1 || events[0].Type != VADSpeechStart {
+		t.Fatalf("应确认开口: %+v", events)
+	}
+
+	flushed := vad.Flush()
+	if len(flushed) != 1 || flushed[0].Type != VADSpeechEnd || len(flushed[0].Audio) == 0 {
+		t.Errorf("Flush 应输出未结束的语音段: %+v", flushed)
+	}
+	if len(vad.Flush()) != 0 {
+		t.Errorf("重复 Flush 不应再输出")
+	}
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VectorFilter 向量搜索的元数据过滤条件
+// 零值字段不参与过滤，多个条件之间为 AND；按向量元数据（category、tags、importance、session_id、created_at）判断
+type VectorFilter struct {
+	Category   string     `json:"category,omitempty"`
+	Tags       []string   `json:"tags,omitempty"` // 须包含全部标签（不区分大小写）
+	Importance string     `json:"importance,omitempty"`
+	SessionID  string     `json:"session_id,omitempty"`
+	Since      *time.Time `json:"since,omitempty"` // 创建时间 >= Since
+	Until      *time.Time `json:"until,omitempty"` // 创建时间 < Until
+}
+
+// IsEmpty 没有任何过滤条件（nil 也视为空）
+func (f *VectorFilter) IsEmpty() bool {
+	return f == nil || (f.Category == "" && len(f.Tags) == 0 && f.Importance == "" &&
+		f.SessionID == "" && f.Since == nil && f.Until == nil)
+}
+
+// Match 判断元数据是否满足过滤条件
+func (f *VectorFilter) Match(metadata map[string]interface{}) bool {
+	if f.IsEmpty() {
+		return true
+	}
+
+	if f.Category != "" && metadataString(metadata, "category") != f.Category {
+		return false
+	}
+	if f.Importance != "" && metadataString(metadata, "importance") != f.Importance {
+		return false
+	}
+	if f.SessionID != "" && metadataString(metadata, "session_id") != f.SessionID {
+		return false
+	}
+
+	if len(f.Tags) > 0 {
+		tags := metadataTags(metadata)
+		for _, want := range f.Tags {
+			if !containsFold(tags, want) {
+				return false
+			}
+		}
+	}
+
+	if f.Since != nil || f.Until != nil {
+		createdAt, ok := metadataTime(metadata, "created_at")
+		if !ok {
+			return false
+		}
+		if f.Since != nil && createdAt.Before(*f.Since) {
+			return false
+		}
+		if f.Until != nil && !createdAt.Before(*f.Until) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String 便于日志输出的描述
+func (f *VectorFilter) String() string {
+	if f.IsEmpty() {
+		return "无"
+	}
+
+	var parts []string
+	if f.Category != "" {
+		parts = append(parts, "分类="+f.Category)
+	}
+	if len(f.Tags) > 0 {
+		parts = append(parts, "标签="+strings.Join(f.Tags, ","))
+	}
+	if f.Importance != "" {
+		parts = append(parts, "重要度="+f.Importance)
+	}
+	if f.SessionID != "" {
+		parts = append(parts, "会话="+f.SessionID)
+	}
+	if f.Since != nil {
+		parts = append(parts, "起始="+f.Since.Format("2006-01-02 15:04"))
+	}
+	if f.Until != nil {
+		parts = append(parts, "截止="+f.Until.Format("2006-01-02 15:04"))
+	}
+	return strings.Join(parts, " ")
+}
+
+func metadataString(metadata map[string]interface{}, key string) string {
+	s, _ := metadata[key].(string)
+	return s
+}
+
+// metadataTags 兼容内存中的 []string、JSON 解析后的 []interface{} 和逗号分隔的字符串
+func metadataTags(metadata map[string]interface{}) []string {
+	switch v := metadata["tags"].(type) {
+	case []string:
+		return v
+	case []interface{}:
+		tags := make([]string, 0, len(v))
+		for _, t := range v {
+			if s, ok := t.(string); ok {
+				tags = append(tags, s)
+			}
+		}
+		return tags
+	case string:
+		return strings.Split(v, ",")
+	}
+	return nil
+}
+
+// metadataTime 兼容 time.Time、RFC3339 字符串和 Unix 秒
+func metadataTime(metadata map[string]interface{}, key string) (time.Time, bool) {
+	switch v := metadata[key].(type) {
+	case time.Time:
+		return v, !v.IsZero()
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t, err == nil && !t.IsZero()
+	case float64:
+		return time.Unix(int64(v), 0), v > 0
+	case int64:
+		return time.Unix(v, 0), v > 0
+	}
+	return time.Time{}, false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), s) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	filterCategoryPattern   = regexp.MustCompile(`(技术|生活|工作|学习|想法)(上|方面|相关)?的?(笔记|知识|记录|内容|备忘)`)
+	filterImportancePattern = regexp.MustCompile(`重要的?(笔记|知识|记录|内容|备忘|事)`)
+	filterRecentDaysPattern = regexp.MustCompile(`(最近|近)([0-9]+|[一二两三四五六七八九十]+)(天|周|个星期|个月)`)
+)
+
+// ParseSearchFilter 从自然语言查询中提取过滤条件，如 "搜索上周的工作笔记"
+// 支持分类、重要程度和常见的相对时间（今天、昨天、本周、上周、本月、上个月、最近 N 天等），
+// 没有识别出任何条件时返回 nil
+func ParseSearchFilter(query string, now time.Time) *VectorFilter {
+	filter := &VectorFilter{}
+
+	if m := filterCategoryPattern.FindStringSubmatch(query); m != nil {
+		filter.Category = m[1]
+	}
+	if filterImportancePattern.MatchString(query) {
+		filter.Importance = "high"
+	}
+	filter.Since, filter.Until = parseTimeRange(query, now)
+
+	if filter.IsEmpty() {
+		return nil
+	}
+	return filter
+}
+
+// parseTimeRange 解析相对时间范围，返回 [since, until)
+func parseTimeRange(query string, now time.Time) (*time.Time, *time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	// 一周从周一开始
+	weekStart := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	yearStart := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+
+	span := func(since, until time.Time) (*time.Time, *time.Time) {
+		return &since, &until
+	}
+
+	if m := filterRecentDaysPattern.FindStringSubmatch(query); m != nil {
+		if n, ok := chineseNumber(m[2]); ok && n > 0 {
+			switch m[3] {
+			case "天":
+				return span(today.AddDate(0, 0, 1-n), today.AddDate(0, 0, 1))
+			case "周", "个星期":
+				return span(today.AddDate(0, 0, 1-7*n), today.AddDate(0, 0, 1))
+			case "个月":
+				return span(today.AddDate(0, -n, 1), today.AddDate(0, 0, 1))
+			}
+		}
+	}
+
+	switch {
+	case strings.Contains(query, "前天"):
+		return span(today.AddDate(0, 0, -2), today.AddDate(0, 0, -1))
+	case strings.Contains(query, "昨天"):
+		return span(today.AddDate(0, 0, -1), today)
+	case strings.Contains(query, "今天"):
+		return span(today, today.AddDate(0, 0, 1))
+	case containsAnyOf(query, "上周", "上星期", "上个星期", "上礼拜"):
+		return span(weekStart.AddDate(0, 0, -7), weekStart)
+	case containsAnyOf(query, "这周", "本周", "这个星期", "这星期", "这礼拜"):
+		return span(weekStart, weekStart.AddDate(0, 0, 7))
+	case containsAnyOf(query, "上个月", "上月"):
+		return span(monthStart.AddDate(0, -1, 0), monthStart)
+	case containsAnyOf(query, "这个月", "本月"):
+		return span(monthStart, monthStart.AddDate(0, 1, 0))
+	case strings.Contains(query, "去年"):
+		return span(yearStart.AddDate(-1, 0, 0), yearStart)
+	case containsAnyOf(query, "今年", "本年"):
+		return span(yearStart, yearStart.AddDate(1, 0, 0))
+	}
+	return nil, nil
+}
+
+func containsAnyOf(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// chineseNumber 解析阿拉伯数字或 1-99 的中文数字（如 "三"、"十五"、"二十"、"两"）
+func chineseNumber(s string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, true
+	}
+
+	digits := map[rune]int{'一': 1, '二': 2, '两': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9}
+	runes := []rune(s)
+	switch {
+	case len(runes) == 1 && runes[0] == '十':
+		return 10, true
+	case len(runes) == 1:
+		n, ok := digits[runes[0]]
+		return n, ok
+	case len(runes) == 2 && runes[0] == '十':
+		n, ok := digits[runes[1]]
+		return 10 + n, ok
+	case len(runes) == 2 && runes[1] == '十':
+		n, ok := digits[runes[0]]
+		return n * 10, ok
+	case len(runes) == 3 && runes[1] == '十':
+		tens, ok1 := digits[runes[0]]
+		ones, ok2 := digits[runes[2]]
+		return tens*10 + ones, ok1 && ok2
+	}
+	return 0, false
+}
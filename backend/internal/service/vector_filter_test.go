@@ -0,0 +1,201 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestVectorFilter_Match(t *testing.T) {
+	created := time.Date(2026, 3, 10, 9, 0, 0, 0, time.Local)
+	metadata := map[string]interface{}{
+		"category":   "工作",
+		"tags":       []string{"Go", "部署"},
+		"importance": "high",
+		"session_id": "s1",
+		"created_at": created,
+	}
+	// 经过 JSON 往返后（SQLite / HNSW 持久化）类型会变成 []interface{} 和字符串
+	var roundTrip map[string]interface{}
+	data, _ := json.Marshal(metadata)
+	json.Unmarshal(data, &roundTrip)
+
+	since := created.Add(-time.Hour)
+	until := created.Add(time.Hour)
+	tests := []struct {
+		name   string
+		filter *VectorFilter
+		want   bool
+	}{
+		{"nil 不过滤", nil, true},
+		{"分类匹配", &VectorFilter{Category: "工作"}, true},
+		{"分类不匹配", &VectorFilter{Category: "生活"}, false},
+		{"标签不区分大小写", &VectorFilter{Tags: []string{"go"}}, true},
+		{"须包含全部标签", &VectorFilter{Tags: []string{"go", "测试"}}, false},
+		{"重要程度", &VectorFilter{Importance: "low"}, false},
+		{"会话", &VectorFilter{SessionID: "s1"}, true},
+		{"时间范围内", &VectorFilter{Since: &since, Until: &until}, true},
+		{"截止时间不包含", &VectorFilter{Until: &created}, false},
+		{"组合条件", &VectorFilter{Category: "工作", Tags: []string{"部署"}, Since: &since}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(metadata); got != tt.want {
+				t.Errorf("内存元数据: 期望 %v, 实际 %v", tt.want, got)
+			}
+			if got := tt.filter.Match(roundTrip); got != tt.want {
+				t.Errorf("JSON 元数据: 期望 %v, 实际 %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("缺少创建时间时不满足时间条件", func(t *testing.T) {
+		if (&VectorFilter{Since: &since}).Match(map[string]interface{}{"category": "工作"}) {
+			t.Errorf("应不匹配")
+		}
+	})
+}
+
+func TestParseSearchFilter(t *testing.T) {
+	// 2026-03-11 是周三
+	now := time.Date(2026, 3, 11, 15, 30, 0, 0, time.Local)
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.Local) }
+
+	tests := []struct {
+		query    string
+		category string
+		since    time.Time
+		until    time.Time
+	}{
+		{"搜索上周的工作笔记", "工作", day(2), day(9)},
+		{"这周记了哪些学习相关的内容", "学习", day(9), day(16)},
+		{"昨天的想法记录", "想法", day(10), day(11)},
+		{"最近三天的技术笔记", "技术", day(9), day(12)},
+		{"最近7天", "", day(5), day(12)},
+		{"上个月的生活记录", "生活", time.Date(2026, 2, 1, 0, 0, 0, 0, time.Local), day(1)},
+		{"工作笔记", "工作", time.Time{}, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			f := ParseSearchFilter(tt.query, now)
+			if f == nil {
+				t.Fatalf("应识别出过滤条件")
+			}
+			if f.Category != tt.category {
+				t.Errorf("分类: 期望 %q, 实际 %q", tt.category, f.Category)
+			}
+			if tt.since.IsZero() {
+				if f.Since != nil || f.Until != nil {
+					t.Errorf("不应有时间条件: %s", f)
+				}
+				return
+			}
+			if f.Since == nil || !f.Since.Equal(tt.since) || f.Until == nil || !f.Until.Equal(tt.until) {
+				t.Errorf("时间范围: 期望 [%v, %v), 实际 %s", tt.since, tt.until, f)
+			}
+		})
+	}
+
+	t.Run("重要的知识", func(t *testing.T) {
+		if f := ParseSearchFilter("找一下重要的笔记", now); f == nil || f.Importance != "high" {
+			t.Errorf("应识别重要程度: %v", f)
+		}
+	})
+
+	t.Run("没有条件返回 nil", func(t *testing.T) {
+		if f := ParseSearchFilter("gin 框架怎么配置中间件", now); f != nil {
+			t.Errorf("不应识别出条件: %s", f)
+		}
+	})
+}
+
+// TestVectorStore_SearchFilter 所有向量存储实现的过滤语义一致
+func TestVectorStore_SearchFilter(t *testing.T) {
+	stores := map[string]func(t *testing.T) VectorStore{
+		"simple": func(t *testing.T) VectorStore {
+			store, err := NewSimpleVectorStore(t.TempDir())
+			if err != nil {
+				t.Fatalf("创建失败: %v", err)
+			}
+			return store
+		},
+		"hnsw": func(t *testing.T) VectorStore {
+			store, err := NewHNSWVectorStore(t.TempDir(), HNSWConfig{M: 8, EfConstruction: 50, EfSearch: 16})
+			if err != nil {
+				t.Fatalf("创建失败: %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+		"sqlite": func(t *testing.T) VectorStore {
+			store, _ := newTestSQLiteVectorStore(t)
+			return store
+		},
+	}
+
+	// 500 条向量，每 50 条有 1 条是 "工作"，只有 1 条带 "urgent" 标签
+	rng := rand.New(rand.NewSource(7))
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)
+	items := make([]VectorItem, 500)
+	for i := range items {
+		category := "生活"
+		if i%50 == 0 {
+			category = "工作"
+		}
+		tags := []string{"日常"}
+		if i == 250 {
+			tags = append(tags, "urgent")
+		}
+		items[i] = VectorItem{
+			ID:        fmt.Sprintf("kb_%d", i),
+			Embedding: []float32{rng.Float32(), rng.Float32(), rng.Float32(), rng.Float32()},
+			Metadata: map[string]interface{}{
+				"category":   category,
+				"tags":       tags,
+				"session_id": fmt.Sprintf("s%d", i%3),
+				"created_at": base.Add(time.Duration(i) * time.Hour),
+			},
+		}
+	}
+	query := []float32{0.5, 0.5, 0.5, 0.5}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			if err := store.AddBatch(items); err != nil {
+				t.Fatalf("添加失败: %v", err)
+			}
+
+			results, err := store.Search(query, 5, &VectorFilter{Category: "工作"})
+			if err != nil {
+				t.Fatalf("搜索失败: %v", err)
+			}
+			if len(results) != 5 {
+				t.Fatalf("期望 5 条, 实际 %d", len(results))
+			}
+			for _, r := range results {
+				if r.Metadata["category"] != "工作" {
+					t.Errorf("结果 %s 不满足分类条件", r.ID)
+				}
+			}
+
+			results, _ = store.Search(query, 5, &VectorFilter{Tags: []string{"urgent"}})
+			if len(results) != 1 || results[0].ID != "kb_250" {
+				t.Errorf("标签过滤结果错误: %+v", results)
+			}
+
+			since, until := base.Add(100*time.Hour), base.Add(110*time.Hour)
+			results, _ = store.Search(query, 20, &VectorFilter{Since: &since, Until: &until, SessionID: "s1"})
+			if len(results) != 4 {
+				t.Errorf("时间+会话过滤期望 4 条 (100-109 中 i%%3==1), 实际 %d", len(results))
+			}
+
+			results, _ = store.Search(query, 5, &VectorFilter{Category: "不存在"})
+			if len(results) != 0 {
+				t.Errorf("无匹配时应返回空: %+v", results)
+			}
+		})
+	}
+}
@@ -57,6 +57,18 @@ func (s *SimpleVectorStore) Add(id string, embedding []float32, metadata map[str
 	return s.save()
 }
 
+// AddBatch 批量添加向量，只写一次文件
+func (s *SimpleVectorStore) AddBatch(items []VectorItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, item := range items {
+		s.items[item.ID] = item
+	}
+
+	return s.save()
+}
+
 // Delete 删除向量
 func (s *SimpleVectorStore) Delete(id string) error {
 	s.mu.Lock()
@@ -66,8 +78,32 @@ func (s *SimpleVectorStore) Delete(id string) error {
 	return s.save()
 }
 
+// Get 获取向量条目
+func (s *SimpleVectorStore) Get(id string) (*VectorItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.items[id]
+	if !ok {
+		return nil, nil
+	}
+	return &item, nil
+}
+
+// IDs 列出所有向量 ID
+func (s *SimpleVectorStore) IDs() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.items))
+	for id := range s.items {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 // Search 搜索相似向量
-func (s *SimpleVectorStore) Search(queryVector []float32, limit int) ([]VectorResult, error) {
+func (s *SimpleVectorStore) Search(queryVector []float32, limit int, filter *VectorFilter) ([]VectorResult, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -79,6 +115,9 @@ func (s *SimpleVectorStore) Search(queryVector []float32, limit int) ([]VectorRe
 	var candidates []scoredItem
 
 	for _, item := range s.items {
+		if !filter.Match(item.Metadata) {
+			continue
+		}
 		score := cosineSimilarity(queryVector, item.Embedding)
 		candidates = append(candidates, scoredItem{item: item, score: score})
 	}
@@ -123,6 +162,33 @@ func (s *SimpleVectorStore) load() error {
 	return json.Unmarshal(data, &s.items)
 }
 
+// MigrateVectors 把 src 中的全部向量复制到 dst（切换向量存储实现时使用），返回复制的条数
+func MigrateVectors(dst, src VectorStore) (int, error) {
+	ids, err := src.IDs()
+	if err != nil {
+		return 0, err
+	}
+
+	items := make([]VectorItem, 0, len(ids))
+	for _, id := range ids {
+		item, err := src.Get(id)
+		if err != nil {
+			return 0, err
+		}
+		if item != nil {
+			items = append(items, *item)
+		}
+	}
+	if len(items) == 0 {
+		return 0, nil
+	}
+
+	if err := dst.AddBatch(items); err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
+
 // cosineSimilarity 计算余弦相似度
 func cosineSimilarity(a, b []float32) float32 {
 	if len(a) != len(b) || len(a) == 0 {
@@ -2,7 +2,7 @@
 // 负责音频采集、WebSocket 通信和状态管理
 
 class VoiceClient {
-    constructor(url, onStateChange, onTranscript, onAudio, onSpeechStart, onAIResponse) {
+    constructor(url, onStateChange, onTranscript, onAudio, onSpeechStart, onAIResponse, onAIDelta) {
         this.url = url;
         this.socket = null;
         this.audioContext = null;
@@ -22,14 +22,23 @@ class VoiceClient {
         this.onAudio = onAudio || (() => {});
         this.onSpeechStart = onSpeechStart || (() => {});
         this.onAIResponse = onAIResponse || (() => {});
+        this.onAIDelta = onAIDelta || (() => {});
+        // 定时提醒到点时调用，可在外部覆盖；默认有通知权限时弹系统通知
+        this.onReminder = (reminder) => {
+            if (window.Notification && Notification.permission === 'granted') {
+                new Notification('⏰ 提醒', { body: reminder.text });
+            }
+        };
     }
 
     // 连接 WebSocket
     connect() {
         if (this.socket && this.socket.readyState === WebSocket.OPEN) return;
 
-        console.log('正在连接 WebSocket:', this.url);
-        this.socket = new WebSocket(this.url);
+        // 声明能解析带序号的音频帧，服务端开启句子级流式 TTS 时按句推送音频
+        const url = this.url + (this.url.includes('?') ? '&' : '?') + 'audio_frames=seq';
+        console.log('正在连接 WebSocket:', url);
+        this.socket = new WebSocket(url);
         this.socket.binaryType = 'arraybuffer';
 
         this.socket.onopen = () => {
@@ -56,9 +65,11 @@ class VoiceClient {
     // 处理收到的消息
     handleMessage(event) {
         if (event.data instanceof ArrayBuffer) {
-            // 收到音频数据 (TTS)
-            console.log('<- [WS] 收到音频数据, 大小:', event.data.byteLength);
-            this.onAudio(event.data);
+            // 收到音频数据 (TTS)：前 4 字节为大端序号，其后为音频
+            const seq = new DataView(event.data).getUint32(0);
+            const audio = event.data.slice(4);
+            console.log('<- [WS] 收到音频分片 #' + seq + ', 大小:', audio.byteLength);
+            this.onAudio(audio, seq);
             return;
         }
 
@@ -69,13 +80,19 @@ class VoiceClient {
                 case 'state':
                     this.onStateChange(msg.status);
                     break;
-                case 'stt_intermediate':
+                case 'stt_partial':
                 case 'stt_final':
                     this.onTranscript(msg.text, msg.type === 'stt_final');
                     break;
+                case 'llm_delta':
+                    this.onAIDelta(msg.text);
+                    break;
                 case 'llm_reply':
                     this.onAIResponse(msg.text);
                     break;
+                case 'reminder':
+                    this.onReminder(msg.data);
+                    break;
                 case 'error':
                     console.error('服务端错误:', msg.error);
                     this.onStateChange('error');
@@ -154,6 +171,11 @@ class VoiceClient {
             this.processor.connect(this.audioContext.destination); // 必须连接到 destination 才能工作
 
             this.isRecording = true;
+            // 通知服务端开始流式识别，之后的二进制帧都是 PCM 分片
+            // 浏览器可能忽略请求的 16kHz，声明实际采样率由服务端重采样
+            if (this.socket && this.socket.readyState === WebSocket.OPEN) {
+                this.socket.send(JSON.stringify({ type: 'audio_start', data: { sample_rate: this.audioContext.sampleRate } }));
+            }
             this.onStateChange('recording');
             
         } catch (error) {
@@ -184,8 +206,8 @@ class VoiceClient {
             this.audioContext = null;
         }
 
-        // 发送停止信号(可选，目前服务端是基于包的，这可能用于告诉服务端这波说完了)
-        // this.socket.send(JSON.stringify({type: "stop"}));
+        // 通知服务端这段语音结束，剩余的识别结果会以 stt_final 返回
+        this.sendControl('audio_end');
         this.onStateChange('idle'); // Change state to idle after stopping
     }
 
@@ -205,6 +227,25 @@ class VoiceClient {
         }
     }
 
+    // 发送控制指令 (audio_start / audio_end)
+    sendControl(type) {
+        if (this.socket && this.socket.readyState === WebSocket.OPEN) {
+            this.socket.send(JSON.stringify({ type: type }));
+        }
+    }
+
+    // 开启/关闭服务端 VAD（连续收音：服务端切分语音并在助手说话时检测插话）
+    setServerVAD(enabled) {
+        if (this.socket && this.socket.readyState === WebSocket.OPEN) {
+            this.socket.send(JSON.stringify({ type: 'config', data: { vad: enabled } }));
+        }
+    }
+
+    // 回复音频播放完毕时调用：服务端据此判断之后开口是新的一轮而不是插话
+    playbackEnded() {
+        this.sendControl('playback_end');
+    }
+
     // 发送打断信号
     interrupt() {
         if (this.socket && this.socket.readyState === WebSocket.OPEN) {